@@ -9,6 +9,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	extr "github.com/nci/gsky/crawl/extractor"
 	"github.com/nci/gsky/utils"
@@ -34,6 +35,8 @@ func main() {
 	approx := true
 	sentinel2Yaml := false
 	landsatYaml := false
+	eo3Yaml := false
+	stacItem := false
 	var configFile string
 	ncMetadata := false
 	var outputFormat string
@@ -42,6 +45,9 @@ func main() {
 
 	followSymlink := false
 
+	var manifestFile string
+	hashFiles := false
+
 	if len(os.Args) > 2 {
 		flagSet := flag.NewFlagSet("Usage", flag.ExitOnError)
 		flagSet.IntVar(&concLimit, "conc", 0, "Concurrent limit on processing subdatasets")
@@ -51,10 +57,14 @@ func main() {
 		flagSet.StringVar(&configFile, "conf", "", "Crawl config file")
 		flagSet.BoolVar(&ncMetadata, "nc_md", false, "Look for netCDF metadata")
 		flagSet.BoolVar(&landsatYaml, "landsat_yaml", false, "Extract landsat metadata from its yaml files")
+		flagSet.BoolVar(&eo3Yaml, "eo3_yaml", false, "Extract metadata from Open Data Cube eo3 dataset documents")
+		flagSet.BoolVar(&stacItem, "stac_item", false, "Extract metadata from STAC Item JSON files")
 		flagSet.StringVar(&outputFormat, "fmt", "raw", "Output format. Valid values include raw and tsv")
 		flagSet.BoolVar(&posix, "posix", false, "Extract POSIX metadata from input directory")
 		flagSet.StringVar(&filePattern, "pattern", "", "pattern expression for POSIX crawl")
 		flagSet.BoolVar(&followSymlink, "followSymlink", false, "Extract POSIX metadata from input directory")
+		flagSet.StringVar(&manifestFile, "manifest", "", "Manifest file of incremental crawls. Only the new or changed files under the input directories are extracted and tombstones are output for deleted files")
+		flagSet.BoolVar(&hashFiles, "hash", false, "Record the content hash of files in the manifest and only extract files whose content changed")
 		flagSet.Parse(os.Args[2:])
 
 		approx = !exact
@@ -102,54 +112,102 @@ func main() {
 		return
 	}
 
+	walkConcLimit := concLimit
+	if walkConcLimit < 1 {
+		walkConcLimit = DefaultPosixCrawlConcLimit
+	}
+
 	if concLimit < 1 {
 		concLimit = DefaultContentCrawlConcLimit
 	}
 
-	var cfg []byte
-	for _, path = range pathList {
-		var geoFile *extr.GeoFile
+	config := &extr.Config{}
+	if len(configFile) > 0 {
+		cfg, err := ioutil.ReadFile(configFile)
+		ensure(err)
+		err = utils.Unmarshal([]byte(cfg), config)
+		ensure(err)
+	} else if ncMetadata {
+		ruleSet := extr.RuleSet{
+			NcMetadata:    ncMetadata,
+			NameSpace:     extr.NSDataset,
+			SRSText:       extr.SRSDetect,
+			Proj4Text:     extr.Proj4Detect,
+			Pattern:       `.+`,
+			MatchFullPath: true,
+			TimeAxis:      &extr.DatasetAxis{},
+		}
+		config.RuleSets = append(config.RuleSets, ruleSet)
+	}
+
+	extract := func(path string) (*extr.GeoFile, error) {
 		if sentinel2Yaml {
-			geoFile, err = extr.ExtractYaml(path, "sentinel2")
+			return extr.ExtractYaml(path, "sentinel2")
 		} else if landsatYaml {
-			geoFile, err = extr.ExtractYaml(path, "landsat")
-		} else {
-			config := &extr.Config{}
-			if len(configFile) > 0 {
-				if len(cfg) == 0 {
-					cfg, err = ioutil.ReadFile(configFile)
-					ensure(err)
-					err = utils.Unmarshal([]byte(cfg), config)
-					ensure(err)
+			return extr.ExtractYaml(path, "landsat")
+		} else if eo3Yaml {
+			return extr.ExtractYaml(path, "eo3")
+		} else if stacItem {
+			return extr.ExtractYaml(path, "stac")
+		}
+		return extr.ExtractGDALInfo(path, concLimit, approx, config)
+	}
+
+	if len(manifestFile) > 0 {
+		manifest, err := extr.LoadManifest(manifestFile)
+		ensure(err)
+
+		for _, path = range pathList {
+			changes, err := manifest.ScanChanges(path, walkConcLimit, filePattern, followSymlink, hashFiles)
+			if err != nil {
+				os.Stderr.Write([]byte(err.Error() + "\n"))
+				if changes == nil {
+					continue
 				}
-			} else {
-				if ncMetadata {
-					ruleSet := extr.RuleSet{
-						NcMetadata:    ncMetadata,
-						NameSpace:     extr.NSDataset,
-						SRSText:       extr.SRSDetect,
-						Proj4Text:     extr.Proj4Detect,
-						Pattern:       `.+`,
-						MatchFullPath: true,
-						TimeAxis:      &extr.DatasetAxis{},
-					}
-					config.RuleSets = append(config.RuleSets, ruleSet)
+			}
+
+			nExtracted := 0
+			for _, entry := range changes.Changed {
+				geoFile, err := extract(entry.Path)
+				if err != nil {
+					os.Stderr.Write([]byte(err.Error() + "\n"))
+					continue
 				}
+				printRecord(entry.Path, "gdal", geoFile, outputFormat)
+				manifest.Entries[entry.Path] = entry
+				nExtracted++
 			}
-			geoFile, err = extr.ExtractGDALInfo(path, concLimit, approx, config)
-		}
-		if err == nil {
-			out, err := json.Marshal(&geoFile)
-			ensure(err)
 
-			rec := string(out)
-			if outputFormat == "tsv" {
-				rec = fmt.Sprintf("%s\tgdal\t%s\n", path, string(out))
+			for _, deleted := range changes.Deleted {
+				tombstone := &extr.Tombstone{FilePath: deleted, Tombstone: true, Deleted: time.Now().UTC()}
+				printRecord(deleted, extr.TombstoneType, tombstone, outputFormat)
+				delete(manifest.Entries, deleted)
 			}
 
-			fmt.Print(rec)
+			log.Printf("%s: %d extracted, %d failed, %d unchanged, %d deleted", path, nExtracted, len(changes.Changed)-nExtracted, changes.Unchanged, len(changes.Deleted))
+		}
+
+		ensure(manifest.Save(manifestFile))
+		return
+	}
+
+	for _, path = range pathList {
+		geoFile, err := extract(path)
+		if err == nil {
+			printRecord(path, "gdal", geoFile, outputFormat)
 		} else {
 			os.Stderr.Write([]byte(err.Error()))
 		}
 	}
 }
+
+func printRecord(path string, recType string, rec interface{}, outputFormat string) {
+	out, err := json.Marshal(rec)
+	ensure(err)
+
+	if outputFormat == "tsv" {
+		fmt.Printf("%s\t%s\t%s\n", path, recType, string(out))
+	} else {
+		fmt.Printf("%s\n", string(out))
+	}
+}
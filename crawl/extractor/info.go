@@ -69,8 +69,6 @@ func init() {
 	LogErr = log.New(os.Stderr, "Crawler: ", log.Ldate|log.Ltime|log.Lshortfile)
 }
 
-var dateFormats []string = []string{"2006-01-02 15:04:05.0", "2006-1-2 15:4:5"}
-var durationUnits map[string]time.Duration = map[string]time.Duration{"seconds": time.Second, "hours": time.Hour, "days": time.Hour * 24}
 var CsubDS *C.char = C.CString("SUBDATASETS")
 var CncVarname *C.char = C.CString("NETCDF_VARNAME")
 
@@ -197,7 +195,12 @@ func getDataSetInfo(filename string, dsName *C.char, driverName string, approx b
 	var ncTimes []string
 	var err error
 	var times []time.Time
-	if ruleSet.NcMetadata || driverName == "netCDF" || driverName == "JP2OpenJPEG" {
+	if len(ruleSet.TimeAttribute) > 0 {
+		timeStamp, err = getAttributeTime(hSubdataset, ruleSet)
+		if err != nil {
+			return &GeoMetaData{}, fmt.Errorf("Error parsing dates: %v", err)
+		}
+	} else if ruleSet.NcMetadata || driverName == "netCDF" || driverName == "JP2OpenJPEG" {
 		ncTimes, err = getNCTime(datasetName, hSubdataset, ruleSet)
 		if err != nil && timeStamp.IsZero() && len(ruleSet.TimesText) == 0 {
 			return &GeoMetaData{}, fmt.Errorf("Error parsing dates: %v", err)
@@ -226,6 +229,11 @@ func getDataSetInfo(filename string, dsName *C.char, driverName string, approx b
 		times = append(times, timeStamp)
 	}
 
+	times, err = ruleSet.adjustTimes(times)
+	if err != nil {
+		return &GeoMetaData{}, err
+	}
+
 	var ncAxes []*DatasetAxis
 	if ruleSet.NcMetadata || driverName == "netCDF" || driverName == "JP2OpenJPEG" {
 		if ruleSet.TimeAxis != nil {
@@ -336,6 +344,7 @@ func getDataSetInfo(filename string, dsName *C.char, driverName string, approx b
 	polyWkt := getGeometryWKT(geot, int(C.GDALGetRasterXSize(hSubdataset)), int(C.GDALGetRasterYSize(hSubdataset)), ruleSet)
 
 	noData := C.GDALGetRasterNoDataValue(hBand, nil)
+	scaleFactor, addOffset, units, validRange := getBandPacking(hBand)
 
 	var mins, maxs, means, stddevs []float64
 	var sampleCounts []int
@@ -406,11 +415,94 @@ func getDataSetInfo(filename string, dsName *C.char, driverName string, approx b
 		StdDevs:      stddevs,
 		SampleCounts: sampleCounts,
 		NoData:       float64(noData),
+		ScaleFactor:  scaleFactor,
+		AddOffset:    addOffset,
+		Units:        units,
+		ValidRange:   validRange,
 		Axes:         ncAxes,
 		GeoLocation:  geoLocation,
 	}, nil
 }
 
+// getBandPacking returns the scale factor, offset, units and valid range
+// of the values of a band. The scale factor and offset are 0 if the
+// values are not packed.
+func getBandPacking(hBand C.GDALRasterBandH) (float64, float64, string, []float64) {
+	getItem := func(name string) string {
+		nameC := C.CString(name)
+		defer C.free(unsafe.Pointer(nameC))
+		valC := C.GDALGetMetadataItem(C.GDALMajorObjectH(hBand), nameC, nil)
+		if valC == nil {
+			return ""
+		}
+		return strings.TrimSpace(C.GoString(valC))
+	}
+
+	getFloat := func(name string) (float64, bool) {
+		val, err := strconv.ParseFloat(getItem(name), 64)
+		return val, err == nil
+	}
+
+	var hasScale, hasOffset C.int
+	scaleFactor := float64(C.GDALGetRasterScale(hBand, &hasScale))
+	addOffset := float64(C.GDALGetRasterOffset(hBand, &hasOffset))
+	if hasScale == 0 {
+		scaleFactor, _ = getFloat("scale_factor")
+	}
+	if hasOffset == 0 {
+		addOffset, _ = getFloat("add_offset")
+	}
+	if (scaleFactor == 0 || scaleFactor == 1) && addOffset == 0 {
+		scaleFactor = 0
+	}
+
+	units := strings.TrimSpace(C.GoString(C.GDALGetRasterUnitType(hBand)))
+	if len(units) == 0 {
+		units = getItem("units")
+	}
+
+	validRange := parseValidRange(getItem("valid_range"))
+	if len(validRange) == 0 {
+		validMin, hasMin := getFloat("valid_min")
+		validMax, hasMax := getFloat("valid_max")
+		if hasMin || hasMax {
+			validRange = []float64{-math.MaxFloat64, math.MaxFloat64}
+			if hasMin {
+				validRange[0] = validMin
+			}
+			if hasMax {
+				validRange[1] = validMax
+			}
+		}
+	}
+
+	return scaleFactor, addOffset, units, validRange
+}
+
+// parseValidRange parses valid_range attributes such as {0,10000} as
+// reported by the netCDF driver
+func parseValidRange(attr string) []float64 {
+	fields := strings.FieldsFunc(attr, func(r rune) bool {
+		return r == '{' || r == '}' || r == '[' || r == ']' || r == ',' || r == ' '
+	})
+	if len(fields) != 2 {
+		return nil
+	}
+
+	validRange := make([]float64, 2)
+	for i, field := range fields {
+		val, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil
+		}
+		validRange[i] = val
+	}
+	if validRange[0] > validRange[1] {
+		return nil
+	}
+	return validRange
+}
+
 func getGeometryWKT(geot []float64, xSize, ySize int, ruleSet *RuleSet) string {
 	var ulX, ulY, lrX, lrY C.double
 
@@ -471,7 +563,7 @@ func parseName(path string, config *Config) (*RuleSet, map[string]string, time.T
 			}
 			newRuleSet := RuleSet{}
 			copyRuleSet(&newRuleSet, &ruleSet)
-			return &newRuleSet, result, parseTime(result)
+			return &newRuleSet, result, parseTime(result, &newRuleSet)
 		}
 	}
 	return nil, nil, time.Time{}
@@ -550,7 +642,16 @@ func getGeoLocation(geoLoc *GeoLocRule, path string) (*GeoLocInfo, error) {
 	return locInfo, nil
 }
 
-func parseTime(nameFields map[string]string) time.Time {
+func parseTime(nameFields map[string]string, ruleSet *RuleSet) time.Time {
+	if value, ok := nameFields["time"]; ok && len(value) > 0 {
+		t, err := parseTimeValue(value, ruleSet)
+		if err != nil {
+			log.Println(err)
+			return time.Time{}
+		}
+		return t
+	}
+
 	if _, ok := nameFields["year"]; ok {
 		year, _ := strconv.Atoi(nameFields["year"])
 		t := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -587,15 +688,6 @@ func parseTime(nameFields map[string]string) time.Time {
 	return time.Time{}
 }
 
-func getDate(inDate string) (time.Time, error) {
-	for _, dateFormat := range dateFormats {
-		if t, err := time.Parse(dateFormat, inDate); err == nil {
-			return t, err
-		}
-	}
-	return time.Time{}, fmt.Errorf("Could not parse time string: %s", inDate)
-}
-
 func getNCTime(sdsName string, hSubdataset C.GDALDatasetH, ruleSet *RuleSet) ([]string, error) {
 	times := []string{}
 	mObj := C.GDALMajorObjectH(hSubdataset)
@@ -618,18 +710,15 @@ func getNCTime(sdsName string, hSubdataset C.GDALDatasetH, ruleSet *RuleSet) ([]
 
 		timeUnits = C.GoString(C.CSLFetchNameValue(metadata, CtimeUnits))
 	}
-	timeUnitsWords := strings.Split(timeUnits, " ")
-	if timeUnitsWords[1] != "since" {
-		return nil, fmt.Errorf("Cannot parse Units string")
-	}
-	if len(timeUnitsWords) == 3 {
-		timeUnitsWords = append(timeUnitsWords, "00:00:00.0")
-	}
-	stepUnitStr := strings.Trim(timeUnitsWords[0], " ")
-	stepUnit := durationUnits[stepUnitStr]
-	startDate, err := getDate(strings.Join(timeUnitsWords[2:], " "))
-	if err != nil {
-		return times, err
+
+	calendar := ruleSet.TimeCalendar
+	if len(calendar) == 0 {
+		Ccalendar := C.CString(fmt.Sprintf("%s#calendar", timeDim))
+		defer C.free(unsafe.Pointer(Ccalendar))
+
+		if value := C.CSLFetchNameValue(metadata, Ccalendar); value != nil {
+			calendar = C.GoString(value)
+		}
 	}
 
 	CncDimTimeValues := C.CString(fmt.Sprintf("NETCDF_DIM_%s_VALUES", timeDim))
@@ -637,24 +726,21 @@ func getNCTime(sdsName string, hSubdataset C.GDALDatasetH, ruleSet *RuleSet) ([]
 
 	value := C.CSLFetchNameValue(metadata, CncDimTimeValues)
 	if value != nil {
-
+		var offsets []float64
 		timeStr := C.GoString(value)
 		for _, tStr := range strings.Split(strings.Trim(timeStr, "{}"), ",") {
 			tF, err := strconv.ParseFloat(tStr, 64)
 			if err != nil {
 				return times, fmt.Errorf("Problem parsing dates with dataset %s", sdsName)
 			}
-			d, f := math.Modf(tF)
-			var t time.Time
-			if stepUnitStr == "days" {
-				t = startDate.AddDate(0, 0, int(d)).Add(time.Duration(f * float64(durationUnits["days"])))
-			} else if stepUnitStr == "months" {
-				t = startDate.AddDate(0, int(d), 0)
-			} else if stepUnitStr == "years" {
-				t = startDate.AddDate(int(d), 0, 0)
-			} else {
-				t = startDate.Add(time.Duration(d) * stepUnit)
-			}
+			offsets = append(offsets, tF)
+		}
+
+		ts, err := cfTimes(offsets, timeUnits, calendar)
+		if err != nil {
+			return times, err
+		}
+		for _, t := range ts {
 			times = append(times, t.Format("2006-01-02T15:04:05Z"))
 		}
 
@@ -663,6 +749,23 @@ func getNCTime(sdsName string, hSubdataset C.GDALDatasetH, ruleSet *RuleSet) ([]
 	return times, fmt.Errorf("Dataset %s doesn't contain times", sdsName)
 }
 
+// getAttributeTime parses the time in the time_attribute metadata item
+// of the dataset or of its first band
+func getAttributeTime(hSubdataset C.GDALDatasetH, ruleSet *RuleSet) (time.Time, error) {
+	key := C.CString(ruleSet.TimeAttribute)
+	defer C.free(unsafe.Pointer(key))
+
+	value := C.GDALGetMetadataItem(C.GDALMajorObjectH(hSubdataset), key, nil)
+	if value == nil && C.GDALGetRasterCount(hSubdataset) > 0 {
+		hBand := C.GDALGetRasterBand(hSubdataset, 1)
+		value = C.GDALGetMetadataItem(C.GDALMajorObjectH(hBand), key, nil)
+	}
+	if value == nil {
+		return time.Time{}, fmt.Errorf("metadata item %s not found", ruleSet.TimeAttribute)
+	}
+	return parseTimeValue(C.GoString(value), ruleSet)
+}
+
 func getNCAxes(sdsName string, hSubdataset C.GDALDatasetH, ruleSet *RuleSet) ([]*DatasetAxis, error) {
 	var axes []*DatasetAxis
 	mObj := C.GDALMajorObjectH(hSubdataset)
@@ -85,6 +85,9 @@ type PosixCrawler struct {
 	pattern       *goeval.EvaluableExpression
 	followSymlink bool
 	outputFormat  string
+
+	// outputFunc consumes the outputs instead of printing them
+	outputFunc func(*PosixInfo)
 }
 
 type DirEntInfo struct {
@@ -332,6 +335,10 @@ func (pc *PosixCrawler) evaluatePatternExpression(filePath string, fileMode uint
 
 func (pc *PosixCrawler) outputResult() {
 	for info := range pc.Outputs {
+		if pc.outputFunc != nil {
+			pc.outputFunc(info)
+			continue
+		}
 		out, _ := json.Marshal(info)
 		rec := string(out)
 		if pc.outputFormat == "tsv" {
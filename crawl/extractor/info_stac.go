@@ -0,0 +1,617 @@
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// docGeometry is a GeoJSON Polygon or MultiPolygon of a metadata
+// document
+type docGeometry struct {
+	Type        string      `json:"type" yaml:"type"`
+	Coordinates interface{} `json:"coordinates" yaml:"coordinates"`
+}
+
+// docGrid is the raster grid of a band. Transform holds the first six
+// coefficients of the affine transform from pixel to CRS coordinates:
+// x = a*col + b*row + c, y = d*col + e*row + f
+type docGrid struct {
+	Shape     []int     `json:"shape" yaml:"shape"`
+	Transform []float64 `json:"transform" yaml:"transform"`
+}
+
+// docBand is a band of a metadata document which is indexed as a
+// namespace
+type docBand struct {
+	NameSpace   string
+	Path        string
+	Grid        *docGrid
+	DataType    string
+	NoData      *float64
+	RasterCount int
+	ScaleFactor float64
+	AddOffset   float64
+	Units       string
+}
+
+// metadataDoc is what GSKY needs from a STAC Item or an eo3 dataset
+// document to index it without opening its rasters
+type metadataDoc struct {
+	SRS        string
+	Geometry   *docGeometry
+	TimeStamps []time.Time
+	Bands      []*docBand
+
+	// geometryIsNative is false if Geometry is in EPSG:4326 rather
+	// than SRS
+	geometryIsNative bool
+}
+
+// docDataTypes maps the data types of the STAC raster extension and of
+// numpy to GDAL data types
+var docDataTypes = map[string]string{
+	"uint8":   "Byte",
+	"int16":   "Int16",
+	"uint16":  "UInt16",
+	"int32":   "Int32",
+	"uint32":  "UInt32",
+	"float32": "Float32",
+	"float64": "Float64",
+}
+
+func getDocDataType(dataType string, bandName string) string {
+	if len(dataType) == 0 {
+		return getBandDataType(bandName)
+	}
+	if gdalType, found := docDataTypes[strings.ToLower(strings.TrimSpace(dataType))]; found {
+		return gdalType
+	}
+	log.Printf("unsupported data type %s for band: %v", dataType, bandName)
+	return getBandDataType(bandName)
+}
+
+// resolveHref turns the href of an asset into a path readable by GDAL.
+// Relative hrefs are relative to the directory of the document.
+func resolveHref(docDir string, href string) string {
+	switch {
+	case strings.HasPrefix(href, "file://"):
+		return strings.TrimPrefix(href, "file://")
+	case strings.HasPrefix(href, "http://"), strings.HasPrefix(href, "https://"):
+		return "/vsicurl/" + href
+	case strings.HasPrefix(href, "s3://"):
+		return "/vsis3/" + strings.TrimPrefix(href, "s3://")
+	case strings.HasPrefix(href, "gs://"):
+		return "/vsigs/" + strings.TrimPrefix(href, "gs://")
+	case filepath.IsAbs(href):
+		return href
+	default:
+		return filepath.Join(docDir, href)
+	}
+}
+
+// bandPath returns a path which GDAL reads as a single band raster
+// holding band n of path. Bands after the first are extracted by a
+// vrt:// connection string.
+func bandPath(path string, n int) string {
+	if n <= 1 {
+		return path
+	}
+	return fmt.Sprintf("vrt://%s?bands=%d", path, n)
+}
+
+// getDriverName guesses the GDAL driver of a file from its extension
+func getDriverName(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tif", ".tiff":
+		return "GTiff"
+	case ".nc", ".nc4":
+		return "netCDF"
+	case ".jp2":
+		return "JP2OpenJPEG"
+	case ".h5", ".hdf5", ".he5":
+		return "HDF5"
+	case ".vrt":
+		return "VRT"
+	default:
+		return ""
+	}
+}
+
+// parseDocTime parses the datetimes of STAC and eo3 documents, which
+// are RFC 3339 but often written with a space or without a time zone
+func parseDocTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC(), nil
+	case string:
+		layouts := []string{
+			time.RFC3339Nano,
+			"2006-01-02 15:04:05.999999999Z07:00",
+			"2006-01-02T15:04:05.999999999",
+			"2006-01-02 15:04:05.999999999",
+			"2006-01-02",
+		}
+		s := strings.TrimSpace(v)
+		for _, layout := range layouts {
+			if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid datetime: %s", s)
+	default:
+		return time.Time{}, fmt.Errorf("invalid datetime: %v", value)
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// ringsWKT formats the rings of a polygon as WKT
+func ringsWKT(rings interface{}) (string, error) {
+	ringList, ok := rings.([]interface{})
+	if !ok || len(ringList) == 0 {
+		return "", fmt.Errorf("invalid polygon coordinates")
+	}
+
+	var ringTexts []string
+	for _, ring := range ringList {
+		points, ok := ring.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("invalid polygon ring")
+		}
+		var pointTexts []string
+		for _, point := range points {
+			coords, ok := point.([]interface{})
+			if !ok || len(coords) < 2 {
+				return "", fmt.Errorf("invalid polygon point")
+			}
+			x, okX := toFloat(coords[0])
+			y, okY := toFloat(coords[1])
+			if !okX || !okY {
+				return "", fmt.Errorf("invalid polygon point")
+			}
+			pointTexts = append(pointTexts, strconv.FormatFloat(x, 'f', -1, 64)+" "+strconv.FormatFloat(y, 'f', -1, 64))
+		}
+		ringTexts = append(ringTexts, "("+strings.Join(pointTexts, ",")+")")
+	}
+	return "(" + strings.Join(ringTexts, ",") + ")", nil
+}
+
+// geometryWKT formats a GeoJSON Polygon or MultiPolygon as WKT
+func geometryWKT(geom *docGeometry) (string, error) {
+	switch geom.Type {
+	case "Polygon":
+		rings, err := ringsWKT(geom.Coordinates)
+		if err != nil {
+			return "", err
+		}
+		return "POLYGON " + rings, nil
+	case "MultiPolygon":
+		polygons, ok := geom.Coordinates.([]interface{})
+		if !ok || len(polygons) == 0 {
+			return "", fmt.Errorf("invalid multipolygon coordinates")
+		}
+		var polygonTexts []string
+		for _, polygon := range polygons {
+			rings, err := ringsWKT(polygon)
+			if err != nil {
+				return "", err
+			}
+			polygonTexts = append(polygonTexts, rings)
+		}
+		return "MULTIPOLYGON (" + strings.Join(polygonTexts, ",") + ")", nil
+	default:
+		return "", fmt.Errorf("unsupported geometry type: %s", geom.Type)
+	}
+}
+
+// gridFootprint returns the polygon covered by a grid in its CRS
+func gridFootprint(grid *docGrid) string {
+	rows := float64(grid.Shape[0])
+	cols := float64(grid.Shape[1])
+	t := grid.Transform
+
+	var points []string
+	for _, pixel := range [][2]float64{{0, 0}, {cols, 0}, {cols, rows}, {0, rows}, {0, 0}} {
+		x := t[0]*pixel[0] + t[1]*pixel[1] + t[2]
+		y := t[3]*pixel[0] + t[4]*pixel[1] + t[5]
+		points = append(points, strconv.FormatFloat(x, 'f', -1, 64)+" "+strconv.FormatFloat(y, 'f', -1, 64))
+	}
+	return "POLYGON ((" + strings.Join(points, ",") + "))"
+}
+
+func (g *docGrid) isValid() bool {
+	return g != nil && len(g.Shape) >= 2 && len(g.Transform) >= 6
+}
+
+// toGeoFile converts a metadata document into the crawler output of
+// filename
+func (doc *metadataDoc) toGeoFile(filename string) (*GeoFile, error) {
+	if len(doc.Bands) == 0 {
+		return nil, fmt.Errorf("%s: no bands found", filename)
+	}
+
+	projWkt, proj4 := "", ""
+	if len(doc.SRS) > 0 {
+		projWkt, proj4 = getSRSTexts(doc.SRS)
+		if len(projWkt) == 0 {
+			return nil, fmt.Errorf("%s: invalid CRS: %s", filename, doc.SRS)
+		}
+	}
+
+	var geomWKT string
+	if doc.Geometry != nil {
+		var err error
+		geomWKT, err = geometryWKT(doc.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+
+	geoFile := &GeoFile{FileName: filename}
+	for _, band := range doc.Bands {
+		ds := &GeoMetaData{
+			DataSetName:  band.Path,
+			NameSpace:    band.NameSpace,
+			Type:         band.DataType,
+			RasterCount:  int32(band.RasterCount),
+			TimeStamps:   doc.TimeStamps,
+			GeoTransform: []float64{0, 0, 0, 0, 0, 0},
+			ProjWKT:      projWkt,
+			Proj4:        proj4,
+		}
+		if ds.RasterCount < 1 {
+			ds.RasterCount = 1
+		}
+		if band.NoData != nil {
+			ds.NoData = *band.NoData
+		}
+		if band.ScaleFactor != 0 || band.AddOffset != 0 {
+			ds.ScaleFactor = band.ScaleFactor
+			if ds.ScaleFactor == 0 {
+				ds.ScaleFactor = 1
+			}
+			ds.AddOffset = band.AddOffset
+		}
+		ds.Units = band.Units
+
+		if band.Grid.isValid() {
+			ds.YSize = int32(band.Grid.Shape[0])
+			ds.XSize = int32(band.Grid.Shape[1])
+			t := band.Grid.Transform
+			ds.GeoTransform = []float64{t[2], t[0], t[1], t[5], t[3], t[4]}
+		}
+
+		// The polygon must be in the CRS of the raster if it is known
+		switch {
+		case len(geomWKT) > 0 && (doc.geometryIsNative || len(projWkt) == 0):
+			ds.Polygon = geomWKT
+		case len(projWkt) > 0 && band.Grid.isValid():
+			ds.Polygon = gridFootprint(band.Grid)
+		case len(geomWKT) > 0:
+			ds.Polygon = geomWKT
+			ds.ProjWKT, ds.Proj4 = getSRSTexts("EPSG:4326")
+		default:
+			return nil, fmt.Errorf("%s: no geometry for band %s", filename, band.NameSpace)
+		}
+		if len(ds.ProjWKT) == 0 {
+			// GeoJSON geometries are in EPSG:4326
+			ds.ProjWKT, ds.Proj4 = getSRSTexts("EPSG:4326")
+		}
+
+		if len(geoFile.Driver) == 0 {
+			geoFile.Driver = getDriverName(band.Path)
+		}
+		geoFile.DataSets = append(geoFile.DataSets, ds)
+	}
+	return geoFile, nil
+}
+
+type stacBand struct {
+	Name     string          `json:"name"`
+	DataType string          `json:"data_type"`
+	NoData   json.RawMessage `json:"nodata"`
+	Scale    *float64        `json:"scale"`
+	Offset   *float64        `json:"offset"`
+	Unit     string          `json:"unit"`
+}
+
+type stacProj struct {
+	EPSG      *int         `json:"proj:epsg"`
+	WKT2      string       `json:"proj:wkt2"`
+	Geometry  *docGeometry `json:"proj:geometry"`
+	Shape     []int        `json:"proj:shape"`
+	Transform []float64    `json:"proj:transform"`
+}
+
+func (p *stacProj) srs() string {
+	if p.EPSG != nil {
+		return fmt.Sprintf("EPSG:%d", *p.EPSG)
+	}
+	return p.WKT2
+}
+
+type stacAsset struct {
+	stacProj
+	Href        string     `json:"href"`
+	Type        string     `json:"type"`
+	Roles       []string   `json:"roles"`
+	EOBands     []stacBand `json:"eo:bands"`
+	RasterBands []stacBand `json:"raster:bands"`
+}
+
+type stacProperties struct {
+	stacProj
+	Datetime      *string `json:"datetime"`
+	StartDatetime *string `json:"start_datetime"`
+}
+
+type stacItem struct {
+	Type       string                `json:"type"`
+	Geometry   *docGeometry          `json:"geometry"`
+	Properties stacProperties        `json:"properties"`
+	Assets     map[string]*stacAsset `json:"assets"`
+}
+
+// isDataAsset reports whether an asset holds raster data rather than
+// thumbnails or metadata
+func (a *stacAsset) isDataAsset() bool {
+	for _, role := range a.Roles {
+		switch role {
+		case "data":
+			return true
+		case "thumbnail", "overview", "metadata", "visual":
+			return false
+		}
+	}
+	mediaType := strings.ToLower(a.Type)
+	for _, rasterType := range []string{"tiff", "netcdf", "jp2", "hdf"} {
+		if strings.Contains(mediaType, rasterType) {
+			return true
+		}
+	}
+	return len(a.Type) == 0 && len(getDriverName(a.Href)) > 0
+}
+
+// setProperties sets the nodata, scaling and units of a band from its
+// STAC description
+func (band *docBand) setProperties(sb *stacBand) {
+	band.NoData = parseNoData(sb.NoData)
+	if sb.Scale != nil && *sb.Scale != 1 {
+		band.ScaleFactor = *sb.Scale
+	}
+	if sb.Offset != nil {
+		band.AddOffset = *sb.Offset
+	}
+	band.Units = sb.Unit
+}
+
+func parseNoData(raw json.RawMessage) *float64 {
+	var noData float64
+	if len(raw) == 0 || json.Unmarshal(raw, &noData) != nil {
+		return nil
+	}
+	return &noData
+}
+
+// ExtractSTACItem indexes the data assets of a STAC Item. Each asset is
+// a namespace named after its key, or each of its bands is if it has
+// several of them. CRS and grids come from the proj extension and data
+// types from the raster or eo extension.
+func ExtractSTACItem(filename string) (*GeoFile, error) {
+	rawData, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &stacItem{}
+	if err = json.Unmarshal(rawData, item); err != nil {
+		return nil, err
+	}
+	if item.Type != "Feature" {
+		return nil, fmt.Errorf("%s: not a STAC Item", filename)
+	}
+
+	fn, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	docDir := filepath.Dir(fn)
+
+	doc := &metadataDoc{Geometry: item.Geometry}
+	props := &item.Properties
+	datetime := props.Datetime
+	if datetime == nil {
+		datetime = props.StartDatetime
+	}
+	if datetime == nil {
+		return nil, fmt.Errorf("%s: no datetime", filename)
+	}
+	t, err := parseDocTime(*datetime)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	doc.TimeStamps = []time.Time{t}
+
+	var keys []string
+	for key := range item.Assets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Datasets of a file share a CRS, which is the CRS of its first
+	// data asset
+	var firstKey string
+	for _, key := range keys {
+		asset := item.Assets[key]
+		if !asset.isDataAsset() {
+			continue
+		}
+
+		srs := asset.srs()
+		if len(srs) == 0 {
+			srs = props.srs()
+		}
+		if len(firstKey) == 0 {
+			firstKey = key
+			doc.SRS = srs
+		} else if srs != doc.SRS {
+			log.Printf("%s: asset %s is in CRS %q rather than %q of asset %s, skipped", filename, key, srs, doc.SRS, firstKey)
+			continue
+		}
+
+		grid := &docGrid{Shape: asset.Shape, Transform: asset.Transform}
+		if !grid.isValid() {
+			grid = &docGrid{Shape: props.Shape, Transform: props.Transform}
+		}
+
+		bands := asset.RasterBands
+		if len(bands) == 0 {
+			bands = asset.EOBands
+		}
+		path := resolveHref(docDir, asset.Href)
+		if len(bands) <= 1 {
+			band := &docBand{NameSpace: key, Path: path, Grid: grid, RasterCount: 1}
+			var dataType string
+			if len(bands) > 0 {
+				dataType = bands[0].DataType
+				band.setProperties(&bands[0])
+			}
+			band.DataType = getDocDataType(dataType, key)
+			doc.Bands = append(doc.Bands, band)
+			continue
+		}
+
+		// Bands of multi-band assets are namespaces of their own
+		// named after the asset key and the band name or number
+		names := make(map[string]bool)
+		for iBand := range bands {
+			nameSpace := key + "." + bands[iBand].Name
+			if len(bands[iBand].Name) == 0 || names[nameSpace] {
+				nameSpace = fmt.Sprintf("%s.%d", key, iBand+1)
+			}
+			names[nameSpace] = true
+
+			band := &docBand{NameSpace: nameSpace, Path: bandPath(path, iBand+1), Grid: grid, RasterCount: 1}
+			band.setProperties(&bands[iBand])
+			band.DataType = getDocDataType(bands[iBand].DataType, nameSpace)
+			doc.Bands = append(doc.Bands, band)
+		}
+	}
+
+	if props.Geometry != nil {
+		doc.Geometry = props.Geometry
+		doc.geometryIsNative = true
+	}
+	return doc.toGeoFile(fn)
+}
+
+type eo3Measurement struct {
+	Path   string   `yaml:"path"`
+	Grid   string   `yaml:"grid"`
+	Band   int      `yaml:"band"`
+	Layer  string   `yaml:"layer"`
+	DType  string   `yaml:"dtype"`
+	NoData *float64 `yaml:"nodata"`
+}
+
+type eo3Dataset struct {
+	Schema       string                     `yaml:"$schema"`
+	CRS          string                     `yaml:"crs"`
+	Geometry     *docGeometry               `yaml:"geometry"`
+	Grids        map[string]*docGrid        `yaml:"grids"`
+	Properties   map[string]interface{}     `yaml:"properties"`
+	Measurements map[string]*eo3Measurement `yaml:"measurements"`
+}
+
+// ExtractEO3Yaml indexes the measurements of an Open Data Cube eo3
+// dataset document. Each measurement is a namespace. Data types are
+// taken from the optional dtype of the measurements.
+func ExtractEO3Yaml(filename string) (*GeoFile, error) {
+	rawData, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := &eo3Dataset{}
+	if err = yaml.Unmarshal(rawData, ds); err != nil {
+		return nil, err
+	}
+	if !strings.Contains(ds.Schema, "opendatacube.org/dataset") {
+		return nil, fmt.Errorf("%s: not an eo3 dataset document", filename)
+	}
+
+	fn, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	docDir := filepath.Dir(fn)
+
+	srs := ds.CRS
+	if strings.HasPrefix(strings.ToLower(srs), "epsg:") {
+		srs = strings.ToUpper(srs)
+	}
+	doc := &metadataDoc{SRS: srs, Geometry: ds.Geometry, geometryIsNative: true}
+
+	datetime, found := ds.Properties["datetime"]
+	if !found {
+		datetime, found = ds.Properties["dtr:start_datetime"]
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: no datetime", filename)
+	}
+	t, err := parseDocTime(datetime)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	doc.TimeStamps = []time.Time{t}
+
+	var names []string
+	for name := range ds.Measurements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		m := ds.Measurements[name]
+		gridName := m.Grid
+		if len(gridName) == 0 {
+			gridName = "default"
+		}
+
+		path := resolveHref(docDir, m.Path)
+		if len(m.Layer) > 0 {
+			path = fmt.Sprintf(`NETCDF:"%s":%s`, path, m.Layer)
+		}
+		path = bandPath(path, m.Band)
+
+		doc.Bands = append(doc.Bands, &docBand{
+			NameSpace:   name,
+			Path:        path,
+			Grid:        ds.Grids[gridName],
+			DataType:    getDocDataType(m.DType, name),
+			NoData:      m.NoData,
+			RasterCount: 1,
+		})
+	}
+	return doc.toGeoFile(fn)
+}
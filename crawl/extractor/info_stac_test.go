@@ -0,0 +1,96 @@
+package extractor
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractSTACItem(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	geoFile, err := ExtractSTACItem("testdata/stac_item.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		nameSpace string
+		path      string
+		dataType  string
+		noData    float64
+		xSize     int32
+	}{
+		{"B04", filepath.Join(dir, "B04.tif"), "UInt16", 0, 200},
+		{"multi.red", "/vsis3/bucket/multi.tif", "Int16", -999, 100},
+		{"multi.nir", "vrt:///vsis3/bucket/multi.tif?bands=2", "Float32", 0, 100},
+		{"multi.3", "vrt:///vsis3/bucket/multi.tif?bands=3", "Byte", 0, 100},
+	}
+	if len(geoFile.DataSets) != len(expected) {
+		t.Fatalf("expected %d datasets, got %d", len(expected), len(geoFile.DataSets))
+	}
+	for i, exp := range expected {
+		ds := geoFile.DataSets[i]
+		if ds.NameSpace != exp.nameSpace || ds.DataSetName != exp.path || ds.Type != exp.dataType || ds.NoData != exp.noData || ds.XSize != exp.xSize {
+			t.Errorf("expected %+v, got %s %s %s %v %d", exp, ds.NameSpace, ds.DataSetName, ds.Type, ds.NoData, ds.XSize)
+		}
+		if ds.RasterCount != 1 {
+			t.Errorf("%s: expected 1 raster, got %d", ds.NameSpace, ds.RasterCount)
+		}
+		if len(ds.TimeStamps) != 1 || !ds.TimeStamps[0].Equal(time.Date(2020, 1, 1, 0, 10, 20, 0, time.UTC)) {
+			t.Errorf("%s: unexpected timestamps %v", ds.NameSpace, ds.TimeStamps)
+		}
+	}
+
+	b04 := geoFile.DataSets[0]
+	if b04.ScaleFactor != 0.0001 || b04.AddOffset != -0.1 || b04.Units != "reflectance" {
+		t.Errorf("unexpected scaling of B04: %v %v %s", b04.ScaleFactor, b04.AddOffset, b04.Units)
+	}
+	if b04.Polygon != "POLYGON ((600000 6100000,602000 6100000,602000 6099000,600000 6099000,600000 6100000))" {
+		t.Errorf("unexpected polygon of B04: %s", b04.Polygon)
+	}
+
+	if !strings.Contains(logs.String(), "asset other is in CRS \"EPSG:4326\"") {
+		t.Errorf("expected the skipped asset to be logged, got %q", logs.String())
+	}
+}
+
+func TestExtractEO3Yaml(t *testing.T) {
+	geoFile, err := ExtractEO3Yaml("testdata/eo3_dataset.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "bands.tif")
+	expected := map[string]string{
+		"nir": "vrt://" + path + "?bands=2",
+		"red": path,
+	}
+	if len(geoFile.DataSets) != len(expected) {
+		t.Fatalf("expected %d datasets, got %d", len(expected), len(geoFile.DataSets))
+	}
+	for _, ds := range geoFile.DataSets {
+		if ds.DataSetName != expected[ds.NameSpace] {
+			t.Errorf("%s: expected %s, got %s", ds.NameSpace, expected[ds.NameSpace], ds.DataSetName)
+		}
+		if ds.Type != "Int16" || ds.NoData != -999 || ds.XSize != 200 || ds.YSize != 100 {
+			t.Errorf("%s: unexpected dataset %+v", ds.NameSpace, ds)
+		}
+	}
+}
@@ -58,6 +58,10 @@ func ExtractYaml(filename string, family string) (*GeoFile, error) {
 		geoFile, err = ExtractSentinel2Yaml(filename)
 	} else if family == "landsat" {
 		geoFile, err = ExtractLandsatYaml(filename)
+	} else if family == "eo3" {
+		geoFile, err = ExtractEO3Yaml(filename)
+	} else if family == "stac" {
+		geoFile, err = ExtractSTACItem(filename)
 	} else {
 		return nil, fmt.Errorf("unsupported yaml family: %s", family)
 	}
@@ -131,19 +135,7 @@ func ExtractSentinel2Yaml(filename string) (*GeoFile, error) {
 		log.Printf("invalid timestamp: %v", err)
 	}
 
-	srs := ard.Grid_spatial.Projection.Spatial_reference
-
-	cSrs := C.CString(srs)
-
-	cProjWkt := C.getWktText(cSrs, 0)
-	projWkt := C.GoString(cProjWkt)
-	C.free(unsafe.Pointer(cProjWkt))
-
-	cProj4 := C.getWktText(cSrs, 1)
-	proj4 := C.GoString(cProj4)
-	C.free(unsafe.Pointer(cProj4))
-
-	C.free(unsafe.Pointer(cSrs))
+	projWkt, proj4 := getSRSTexts(ard.Grid_spatial.Projection.Spatial_reference)
 
 	var points []string
 	for _, coord := range ard.Grid_spatial.Projection.Valid_data.Coordinates[0] {
@@ -189,19 +181,7 @@ func ExtractLandsatYaml(filename string) (*GeoFile, error) {
 	geoMd := &GeoMetaData{}
 
 	if crsRaw, ok := md["crs"]; ok {
-		srs := crsRaw.(string)
-
-		cSrs := C.CString(srs)
-
-		cProjWkt := C.getWktText(cSrs, 0)
-		geoMd.ProjWKT = C.GoString(cProjWkt)
-		C.free(unsafe.Pointer(cProjWkt))
-
-		cProj4 := C.getWktText(cSrs, 1)
-		geoMd.Proj4 = C.GoString(cProj4)
-		C.free(unsafe.Pointer(cProj4))
-
-		C.free(unsafe.Pointer(cSrs))
+		geoMd.ProjWKT, geoMd.Proj4 = getSRSTexts(crsRaw.(string))
 	}
 
 	if geometryRaw, ok := md["geometry"]; ok {
@@ -264,6 +244,27 @@ func ExtractLandsatYaml(filename string) (*GeoFile, error) {
 	return geoFile, nil
 }
 
+// getSRSTexts returns the WKT and proj4 texts of srs, which is any
+// input accepted by OSRSetFromUserInput. Invalid SRS give empty texts.
+func getSRSTexts(srs string) (string, string) {
+	cSrs := C.CString(srs)
+	defer C.free(unsafe.Pointer(cSrs))
+
+	var projWkt, proj4 string
+	cProjWkt := C.getWktText(cSrs, 0)
+	if cProjWkt != nil {
+		projWkt = C.GoString(cProjWkt)
+		C.free(unsafe.Pointer(cProjWkt))
+	}
+
+	cProj4 := C.getWktText(cSrs, 1)
+	if cProj4 != nil {
+		proj4 = C.GoString(cProj4)
+		C.free(unsafe.Pointer(cProj4))
+	}
+	return projWkt, proj4
+}
+
 func getBandDataType(bandName string) string {
 	switch bandName {
 	case "nbart_contiguity":
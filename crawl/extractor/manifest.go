@@ -0,0 +1,239 @@
+package extractor
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TombstoneType is the metadata type of the records of deleted files
+const TombstoneType = "tombstone"
+
+// ManifestEntry is the state of a file when it was last extracted
+type ManifestEntry struct {
+	Path  string    `json:"path"`
+	Size  int64     `json:"size"`
+	MTime time.Time `json:"mtime"`
+	INode uint64    `json:"inode"`
+	Hash  string    `json:"hash,omitempty"`
+}
+
+// Tombstone is the record of a file deleted since the previous crawl.
+// MAS drops the records of its path.
+type Tombstone struct {
+	FilePath  string    `json:"file_path"`
+	Tombstone bool      `json:"tombstone"`
+	Deleted   time.Time `json:"deleted"`
+}
+
+// Manifest records the files extracted by the previous crawls so that
+// incremental crawls only extract new or changed files
+type Manifest struct {
+	Entries map[string]*ManifestEntry
+}
+
+// LoadManifest reads a manifest of JSON lines. A missing manifest is
+// empty.
+func LoadManifest(manifestFile string) (*Manifest, error) {
+	manifest := &Manifest{Entries: make(map[string]*ManifestEntry)}
+
+	f, err := os.Open(manifestFile)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	nLines := 0
+	for scanner.Scan() {
+		nLines++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+
+		entry := &ManifestEntry{}
+		if err := json.Unmarshal([]byte(line), entry); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", manifestFile, nLines, err)
+		}
+		manifest.Entries[entry.Path] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Save writes the manifest sorted by path. The previous manifest is
+// replaced only once the new one is completely written.
+func (m *Manifest) Save(manifestFile string) error {
+	var paths []string
+	for path := range m.Entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	tmpFile := manifestFile + ".tmp"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, path := range paths {
+		out, err := json.Marshal(m.Entries[path])
+		if err == nil {
+			_, err = fmt.Fprintf(w, "%s\n", out)
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(tmpFile)
+			return err
+		}
+	}
+
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return os.Rename(tmpFile, manifestFile)
+}
+
+func newManifestEntry(info *PosixInfo) *ManifestEntry {
+	return &ManifestEntry{
+		Path:  info.FilePath,
+		Size:  info.Size,
+		MTime: info.MTime,
+		INode: info.INode,
+	}
+}
+
+func (e *ManifestEntry) sameStat(other *ManifestEntry) bool {
+	return e.Size == other.Size && e.MTime.Equal(other.MTime) && e.INode == other.INode
+}
+
+// FileHash returns the SHA-256 of the content of a file
+func FileHash(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// ManifestChanges are the differences between a directory tree and a
+// manifest
+type ManifestChanges struct {
+	// Changed are the entries of the new or changed files, which
+	// replace their manifest entries once extracted
+	Changed []*ManifestEntry
+
+	// Deleted are the paths of the manifest missing from the tree
+	Deleted []string
+
+	// Unchanged is the number of files whose extracted metadata is
+	// up to date
+	Unchanged int
+}
+
+// ScanChanges walks rootDir with the PosixCrawler and compares the
+// files found with the manifest. Files are changed if their size, mtime
+// or inode changed. If hash is true, files whose content hash did not
+// change are not changed either, and only their manifest entries are
+// updated.
+//
+// If the walk fails, the changes found are returned with the error but
+// without deleted files, lest files under unreadable directories be
+// dropped.
+func (m *Manifest) ScanChanges(rootDir string, conc int, pattern string, followSymlink bool, hash bool) (*ManifestChanges, error) {
+	absRootDir, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := parsePatternExpression(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []*PosixInfo
+	crawler := NewPosixCrawler(conc, expr, followSymlink, "")
+	crawler.outputFunc = func(info *PosixInfo) {
+		infos = append(infos, info)
+	}
+	walkErr := crawler.Crawl(absRootDir)
+
+	changes := &ManifestChanges{}
+	found := make(map[string]struct{}, len(infos))
+	for _, info := range infos {
+		found[info.FilePath] = struct{}{}
+
+		entry := newManifestEntry(info)
+		old, exists := m.Entries[info.FilePath]
+		if exists && old.sameStat(entry) {
+			if hash && len(old.Hash) == 0 {
+				old.Hash, err = FileHash(info.FilePath)
+				if err != nil {
+					log.Printf("%v", err)
+				}
+			}
+			changes.Unchanged++
+			continue
+		}
+
+		if hash {
+			entry.Hash, err = FileHash(info.FilePath)
+			if err != nil {
+				log.Printf("%v", err)
+				continue
+			}
+			if exists && entry.Hash == old.Hash {
+				m.Entries[info.FilePath] = entry
+				changes.Unchanged++
+				continue
+			}
+		}
+		changes.Changed = append(changes.Changed, entry)
+	}
+
+	sort.Slice(changes.Changed, func(i, j int) bool { return changes.Changed[i].Path < changes.Changed[j].Path })
+	if walkErr != nil {
+		return changes, walkErr
+	}
+
+	prefix := strings.TrimSuffix(absRootDir, "/") + "/"
+	for path := range m.Entries {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if _, exists := found[path]; !exists {
+			changes.Deleted = append(changes.Deleted, path)
+		}
+	}
+	sort.Strings(changes.Deleted)
+	return changes, nil
+}
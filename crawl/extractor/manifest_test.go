@@ -0,0 +1,247 @@
+package extractor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, filePath string, content string) {
+	if err := ioutil.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func changedPaths(changes *ManifestChanges) []string {
+	paths := []string{}
+	for _, entry := range changes.Changed {
+		paths = append(paths, entry.Path)
+	}
+	return paths
+}
+
+func TestManifestLoadSave(t *testing.T) {
+	dir := t.TempDir()
+	manifestFile := filepath.Join(dir, "manifest.jsonl")
+
+	manifest, err := LoadManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(manifest.Entries) != 0 {
+		t.Errorf("expected empty manifest, got %v", manifest.Entries)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	manifest.Entries["/data/b.nc"] = &ManifestEntry{Path: "/data/b.nc", Size: 20, MTime: mtime, INode: 2}
+	manifest.Entries["/data/a.nc"] = &ManifestEntry{Path: "/data/a.nc", Size: 10, MTime: mtime, INode: 1, Hash: "abc"}
+	if err = manifest.Save(manifestFile); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if _, err = os.Stat(manifestFile + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected temporary manifest to be renamed, got %v", err)
+	}
+
+	out, err := ioutil.ReadFile(manifestFile)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := `{"path":"/data/a.nc","size":10,"mtime":"2020-01-02T03:04:05.000000006Z","inode":1,"hash":"abc"}
+{"path":"/data/b.nc","size":20,"mtime":"2020-01-02T03:04:05.000000006Z","inode":2}
+`
+	if string(out) != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+
+	loaded, err := LoadManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(loaded.Entries, manifest.Entries) {
+		t.Errorf("expected %v, got %v", manifest.Entries, loaded.Entries)
+	}
+
+	// A manifest that cannot be completely written keeps the previous one
+	if err = os.Mkdir(manifestFile+".tmp", 0755); err != nil {
+		t.Fatalf("%v", err)
+	}
+	delete(manifest.Entries, "/data/a.nc")
+	if err = manifest.Save(manifestFile); err == nil {
+		t.Errorf("expected error saving over a directory")
+	}
+	out, err = ioutil.ReadFile(manifestFile)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(out) != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+
+	writeTestFile(t, manifestFile, "{\"path\":\"/data/a.nc\"}\nnot json\n")
+	if _, err = LoadManifest(manifestFile); err == nil {
+		t.Errorf("expected error loading invalid manifest")
+	}
+}
+
+func TestManifestScanChanges(t *testing.T) {
+	dir := t.TempDir()
+	rootDir := filepath.Join(dir, "data")
+	if err := os.Mkdir(rootDir, 0755); err != nil {
+		t.Fatalf("%v", err)
+	}
+	manifestFile := filepath.Join(dir, "manifest.jsonl")
+
+	path := func(name string) string {
+		return filepath.Join(rootDir, name)
+	}
+	for _, name := range []string{"a.nc", "b.nc", "c.nc", "d.nc", "f.nc"} {
+		writeTestFile(t, path(name), "content of "+name)
+	}
+
+	// The first crawl extracts every file
+	manifest, err := LoadManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	changes, err := manifest.ScanChanges(rootDir, 4, "", false, true)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := []string{path("a.nc"), path("b.nc"), path("c.nc"), path("d.nc"), path("f.nc")}
+	if !reflect.DeepEqual(changedPaths(changes), expected) {
+		t.Errorf("expected %v, got %v", expected, changedPaths(changes))
+	}
+	if changes.Unchanged != 0 || len(changes.Deleted) != 0 {
+		t.Errorf("expected no unchanged or deleted files, got %d, %v", changes.Unchanged, changes.Deleted)
+	}
+	for _, entry := range changes.Changed {
+		hash, err := FileHash(entry.Path)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if entry.Hash != hash {
+			t.Errorf("expected %v, got %v", hash, entry.Hash)
+		}
+		manifest.Entries[entry.Path] = entry
+	}
+
+	// Files outside the crawled directory are not deleted
+	manifest.Entries["/elsewhere/x.nc"] = &ManifestEntry{Path: "/elsewhere/x.nc", Size: 1}
+	if err = manifest.Save(manifestFile); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Nothing changed since the first crawl
+	manifest, err = LoadManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	changes, err = manifest.ScanChanges(rootDir, 4, "", false, false)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(changes.Changed) != 0 || len(changes.Deleted) != 0 || changes.Unchanged != 5 {
+		t.Errorf("expected 5 unchanged files, got %v, %v, %d", changedPaths(changes), changes.Deleted, changes.Unchanged)
+	}
+
+	// a.nc is touched, b.nc modified, c.nc deleted, d.nc replaced by
+	// a copy with the same size and mtime but another inode and e.nc
+	// created
+	touched := time.Now().Add(time.Hour)
+	if err = os.Chtimes(path("a.nc"), touched, touched); err != nil {
+		t.Fatalf("%v", err)
+	}
+	writeTestFile(t, path("b.nc"), "new content of b.nc")
+	if err = os.Remove(path("c.nc")); err != nil {
+		t.Fatalf("%v", err)
+	}
+	dInfo, err := os.Stat(path("d.nc"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	writeTestFile(t, path("d.nc.copy"), "content of d.nc")
+	if err = os.Chtimes(path("d.nc.copy"), dInfo.ModTime(), dInfo.ModTime()); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err = os.Rename(path("d.nc.copy"), path("d.nc")); err != nil {
+		t.Fatalf("%v", err)
+	}
+	writeTestFile(t, path("e.nc"), "content of e.nc")
+
+	testCases := []struct {
+		hash      bool
+		changed   []string
+		unchanged int
+	}{
+		{false, []string{path("a.nc"), path("b.nc"), path("d.nc"), path("e.nc")}, 1},
+		// a.nc and d.nc kept their content
+		{true, []string{path("b.nc"), path("e.nc")}, 3},
+	}
+
+	for _, tc := range testCases {
+		manifest, err = LoadManifest(manifestFile)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		changes, err = manifest.ScanChanges(rootDir, 4, "", false, tc.hash)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !reflect.DeepEqual(changedPaths(changes), tc.changed) {
+			t.Errorf("hash %v: expected %v, got %v", tc.hash, tc.changed, changedPaths(changes))
+		}
+		if changes.Unchanged != tc.unchanged {
+			t.Errorf("hash %v: expected %d unchanged, got %d", tc.hash, tc.unchanged, changes.Unchanged)
+		}
+		if !reflect.DeepEqual(changes.Deleted, []string{path("c.nc")}) {
+			t.Errorf("hash %v: expected %v, got %v", tc.hash, []string{path("c.nc")}, changes.Deleted)
+		}
+	}
+
+	// Files whose content did not change only have their manifest
+	// entries updated
+	if !manifest.Entries[path("a.nc")].MTime.Equal(touched) {
+		t.Errorf("expected %v, got %v", touched, manifest.Entries[path("a.nc")].MTime)
+	}
+
+	// The deleted files are recorded as tombstones and dropped from the
+	// manifest, so that the next crawl finds no changes
+	for _, entry := range changes.Changed {
+		manifest.Entries[entry.Path] = entry
+	}
+	for _, deleted := range changes.Deleted {
+		tombstone := &Tombstone{FilePath: deleted, Tombstone: true, Deleted: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)}
+		out, err := json.Marshal(tombstone)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		expected := `{"file_path":"` + deleted + `","` + TombstoneType + `":true,"deleted":"2020-01-02T00:00:00Z"}`
+		if string(out) != expected {
+			t.Errorf("expected %s, got %s", expected, out)
+		}
+		delete(manifest.Entries, deleted)
+	}
+	if err = manifest.Save(manifestFile); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	manifest, err = LoadManifest(manifestFile)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, found := manifest.Entries["/elsewhere/x.nc"]; !found {
+		t.Errorf("expected /elsewhere/x.nc to be kept")
+	}
+	changes, err = manifest.ScanChanges(rootDir, 4, "", false, true)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(changes.Changed) != 0 || len(changes.Deleted) != 0 || changes.Unchanged != 5 {
+		t.Errorf("expected 5 unchanged files, got %v, %v, %d", changedPaths(changes), changes.Deleted, changes.Unchanged)
+	}
+}
@@ -28,6 +28,11 @@ type RuleSet struct {
 	ComputeStats  bool           `json:"compute_stats"`
 	TimeAxis      *DatasetAxis   `json:"time_axis"`
 	TimeUnits     string         `json:"time_units"`
+	TimeFormat    string         `json:"time_format"`
+	TimeCalendar  string         `json:"time_calendar"`
+	TimeAttribute string         `json:"time_attribute"`
+	TimeOffset    string         `json:"time_offset"`
+	TimeBounds    *TimeBounds    `json:"time_bounds,omitempty"`
 	TimesText     []string       `json:"times_text"`
 	BBox          []float64      `json:"bbox"`
 	GeoLoc        *GeoLocRule    `json:"geo_loc"`
@@ -53,6 +58,38 @@ type RuleSet struct {
 
 *****/
 
+/***** Time rules
+
+The timestamps of a file come from, in order of precedence:
+
+1. time_attribute: a GDAL metadata key of the dataset or of its first
+   band, such as "NC_GLOBAL#time_coverage_start" or "TIFFTAG_DATETIME".
+2. The time variable of netCDF files, whose CF time units and calendar
+   attributes can be overridden with time_units and time_calendar.
+3. times_text.
+4. The named groups of the pattern. A group named "time" is parsed with
+   time_format, or as an offset in time_units if it is a number.
+   Otherwise the year, month, day, julian_day, hour, minute and second
+   groups make up the timestamp.
+
+time_format is a strptime format such as "%Y%m%dT%H%M" or a Go time
+layout such as "20060102T1504". time_calendar is one of standard,
+gregorian, proleptic_gregorian, noleap, 365_day, all_leap, 366_day or
+360_day. Model dates are kept in years whose dates all exist in the
+Gregorian calendar. Otherwise, as in 360_day calendars, they are placed
+at the same fraction of the Gregorian year, so that 29 and 30 February
+remain distinct.
+
+time_offset, a Go duration such as "-12h" or a number of days such as
+"0.5d", is then added to every timestamp, and time_bounds snaps them to
+a position in their period, for instance:
+
+      "pattern": "_(?P<time>\\d{6})\\.nc$",
+      "time_format": "%Y%m",
+      "time_bounds": { "period": "month", "position": "middle" }
+
+*****/
+
 type Config struct {
 	RuleSets []RuleSet `json:"rule_sets"`
 }
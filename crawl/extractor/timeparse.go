@@ -0,0 +1,426 @@
+package extractor
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeBounds snaps timestamps to a position within the period of time
+// containing them, such as the start of the month of monthly means
+// stamped in the middle of their month
+type TimeBounds struct {
+	// Period is one of hour, day, month or year
+	Period string `json:"period"`
+
+	// Position is one of start, middle or end. The default is start.
+	Position string `json:"position"`
+}
+
+// timeLayouts are tried in turn on time values without a time format
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006:01:02 15:04:05",
+	"20060102T150405Z",
+	"20060102T150405",
+	"2006-01-02",
+	"20060102",
+}
+
+// strptimeDirectives maps strptime directives to Go layout elements
+var strptimeDirectives = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'j': "002",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'p': "PM",
+	'b': "Jan",
+	'h': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'z': "-0700",
+	'Z': "MST",
+	'%': "%",
+}
+
+// strptimeLayout converts a strptime format such as %Y%m%dT%H%M into a
+// Go time layout
+func strptimeLayout(format string) (string, error) {
+	var layout strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			layout.WriteByte(format[i])
+			continue
+		}
+		if i+1 >= len(format) {
+			return "", fmt.Errorf("time format ends with %%: %s", format)
+		}
+		i++
+		elem, found := strptimeDirectives[format[i]]
+		if !found {
+			return "", fmt.Errorf("unsupported directive %%%c in time format: %s", format[i], format)
+		}
+		layout.WriteString(elem)
+	}
+	return layout.String(), nil
+}
+
+// parseTimeFormat parses value with a strptime format if it contains
+// '%', or else with a Go time layout. Times without a time zone are in
+// UTC.
+func parseTimeFormat(value string, format string) (time.Time, error) {
+	layout := format
+	if strings.Contains(format, "%") {
+		var err error
+		layout, err = strptimeLayout(format)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	t, err := time.ParseInLocation(layout, value, time.UTC)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// parseTimeValue parses a time value taken from a file name or a
+// metadata attribute according to the time rules of ruleSet. Numbers
+// are offsets in the time units of ruleSet.
+func parseTimeValue(value string, ruleSet *RuleSet) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if len(ruleSet.TimeFormat) > 0 {
+		return parseTimeFormat(value, ruleSet.TimeFormat)
+	}
+
+	if len(ruleSet.TimeUnits) > 0 {
+		if offset, err := strconv.ParseFloat(value, 64); err == nil {
+			times, err := cfTimes([]float64{offset}, ruleSet.TimeUnits, ruleSet.TimeCalendar)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return times[0], nil
+		}
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Could not parse time string: %s", value)
+}
+
+// cfUnitSeconds are the lengths of the CF time units of fixed length
+var cfUnitSeconds = map[string]float64{
+	"microseconds": 1e-6, "microsecond": 1e-6, "us": 1e-6,
+	"milliseconds": 1e-3, "millisecond": 1e-3, "msec": 1e-3, "ms": 1e-3,
+	"seconds": 1, "second": 1, "secs": 1, "sec": 1, "s": 1,
+	"minutes": 60, "minute": 60, "mins": 60, "min": 60,
+	"hours": 3600, "hour": 3600, "hrs": 3600, "hr": 3600, "h": 3600,
+	"days": 86400, "day": 86400, "d": 86400,
+	"weeks": 7 * 86400, "week": 7 * 86400,
+}
+
+// calDate is a date of a model calendar, which may not exist in the
+// Gregorian calendar
+type calDate struct {
+	year, month, day int
+	seconds          float64
+}
+
+var calDateRe = regexp.MustCompile(`^(-?\d+)-(\d{1,2})-(\d{1,2})(?:[ T](\d{1,2}):(\d{1,2})(?::(\d{1,2}(?:\.\d*)?))?)?\s*(Z|UTC|[+-]\d{1,2}(?::?\d{2})?)?$`)
+
+// parseCalDate parses the reference date of CF time units, such as
+// 1850-1-1 00:00:00 or 2000-01-01T00:00:00Z. Time zones are folded into
+// the seconds of the date.
+func parseCalDate(s string) (*calDate, error) {
+	match := calDateRe.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return nil, fmt.Errorf("Could not parse time string: %s", s)
+	}
+
+	d := &calDate{}
+	d.year, _ = strconv.Atoi(match[1])
+	d.month, _ = strconv.Atoi(match[2])
+	d.day, _ = strconv.Atoi(match[3])
+	if d.month < 1 || d.month > 12 || d.day < 1 || d.day > 31 {
+		return nil, fmt.Errorf("Could not parse time string: %s", s)
+	}
+
+	if len(match[4]) > 0 {
+		hour, _ := strconv.Atoi(match[4])
+		minute, _ := strconv.Atoi(match[5])
+		d.seconds = float64(hour*3600 + minute*60)
+		if len(match[6]) > 0 {
+			second, _ := strconv.ParseFloat(match[6], 64)
+			d.seconds += second
+		}
+	}
+
+	zone := match[7]
+	if len(zone) > 0 && zone != "Z" && zone != "UTC" {
+		sign := 1.0
+		if zone[0] == '-' {
+			sign = -1.0
+		}
+		zone = strings.Replace(zone[1:], ":", "", 1)
+		var hours, minutes int
+		if len(zone) > 2 {
+			hours, _ = strconv.Atoi(zone[:len(zone)-2])
+			minutes, _ = strconv.Atoi(zone[len(zone)-2:])
+		} else {
+			hours, _ = strconv.Atoi(zone)
+		}
+		d.seconds -= sign * float64(hours*3600+minutes*60)
+	}
+	return d, nil
+}
+
+// modelCalendar is a CF calendar whose years all have the same months
+type modelCalendar struct {
+	monthDays [12]int
+	yearDays  int
+}
+
+func newModelCalendar(monthDays [12]int) *modelCalendar {
+	cal := &modelCalendar{monthDays: monthDays}
+	for _, days := range monthDays {
+		cal.yearDays += days
+	}
+	return cal
+}
+
+var (
+	noLeapCalendar  = newModelCalendar([12]int{31, 28, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31})
+	allLeapCalendar = newModelCalendar([12]int{31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31})
+	day360Calendar  = newModelCalendar([12]int{30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30})
+)
+
+// getCalendar returns the model calendar of a CF calendar name, or nil
+// for the Gregorian calendar
+func getCalendar(name string) (*modelCalendar, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "standard", "gregorian", "proleptic_gregorian":
+		return nil, nil
+	case "noleap", "365_day":
+		return noLeapCalendar, nil
+	case "all_leap", "366_day":
+		return allLeapCalendar, nil
+	case "360_day":
+		return day360Calendar, nil
+	default:
+		return nil, fmt.Errorf("unsupported calendar: %s", name)
+	}
+}
+
+func floorDiv(a int, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+func (cal *modelCalendar) dayNumber(year, month, day int) int {
+	n := year * cal.yearDays
+	for m := 1; m < month; m++ {
+		n += cal.monthDays[m-1]
+	}
+	return n + day - 1
+}
+
+func (cal *modelCalendar) date(n int) (int, int, int) {
+	year := floorDiv(n, cal.yearDays)
+	rem := n - year*cal.yearDays
+	month := 1
+	for rem >= cal.monthDays[month-1] {
+		rem -= cal.monthDays[month-1]
+		month++
+	}
+	return year, month, rem + 1
+}
+
+// gregorianTime returns the time of a date in a model calendar. Dates
+// are kept if every date of the model year exists in the Gregorian
+// year, as in noleap calendars. Otherwise, as in 360_day calendars,
+// the time is at the same fraction of the Gregorian year as the date
+// is of the model year, which keeps distinct dates distinct and in
+// order.
+func (cal *modelCalendar) gregorianTime(year, month, day int, seconds float64) time.Time {
+	fits := true
+	for m := 1; m <= 12; m++ {
+		if cal.monthDays[m-1] > time.Date(year, time.Month(m)+1, 0, 0, 0, 0, 0, time.UTC).Day() {
+			fits = false
+			break
+		}
+	}
+	if fits {
+		t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		return t.Add(time.Duration(math.Round(seconds*1e3)) * time.Millisecond)
+	}
+
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	yearSeconds := time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC).Sub(start).Seconds()
+	fraction := (float64(cal.dayNumber(0, month, day))*86400 + seconds) / float64(cal.yearDays*86400)
+	return start.Add(time.Duration(math.Round(fraction*yearSeconds*1e3)) * time.Millisecond)
+}
+
+// cfTimes converts offsets in CF time units such as "days since
+// 1850-01-01" into times. Offsets in months or years add whole calendar
+// months or years.
+func cfTimes(values []float64, units string, calendar string) ([]time.Time, error) {
+	words := strings.Fields(units)
+	if len(words) < 3 || strings.ToLower(words[1]) != "since" {
+		return nil, fmt.Errorf("Cannot parse Units string: %s", units)
+	}
+
+	unit := strings.ToLower(words[0])
+	unitSeconds, fixedUnit := cfUnitSeconds[unit]
+	calendarUnit := unit == "months" || unit == "month" || unit == "years" || unit == "year"
+	if !fixedUnit && !calendarUnit {
+		return nil, fmt.Errorf("unsupported time unit: %s", words[0])
+	}
+
+	ref, err := parseCalDate(strings.Join(words[2:], " "))
+	if err != nil {
+		return nil, err
+	}
+
+	cal, err := getCalendar(calendar)
+	if err != nil {
+		return nil, err
+	}
+
+	times := make([]time.Time, 0, len(values))
+	for _, value := range values {
+		year, month, day := ref.year, ref.month, ref.day
+		seconds := ref.seconds
+
+		if calendarUnit {
+			months := int(value)
+			if strings.HasPrefix(unit, "year") {
+				months *= 12
+			}
+			months += year*12 + month - 1
+			year = floorDiv(months, 12)
+			month = months - year*12 + 1
+		} else {
+			seconds += value * unitSeconds
+		}
+
+		days := math.Floor(seconds / 86400)
+		seconds -= days * 86400
+
+		if cal == nil {
+			t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days))
+			times = append(times, t.Add(time.Duration(math.Round(seconds*1e3))*time.Millisecond))
+			continue
+		}
+
+		if day > cal.monthDays[month-1] {
+			day = cal.monthDays[month-1]
+		}
+		year, month, day = cal.date(cal.dayNumber(year, month, day) + int(days))
+		times = append(times, cal.gregorianTime(year, month, day, seconds))
+	}
+	return times, nil
+}
+
+// parseTimeOffset parses a Go duration such as -1h30m or a number of
+// days such as 0.5d
+func parseTimeOffset(offset string) (time.Duration, error) {
+	offset = strings.TrimSpace(offset)
+	if strings.HasSuffix(offset, "d") {
+		days, err := strconv.ParseFloat(strings.TrimSuffix(offset, "d"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time offset: %s", offset)
+		}
+		return time.Duration(days * float64(24*time.Hour)), nil
+	}
+
+	d, err := time.ParseDuration(offset)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time offset: %s", offset)
+	}
+	return d, nil
+}
+
+func (b *TimeBounds) apply(t time.Time) (time.Time, error) {
+	var start, end time.Time
+	switch strings.ToLower(b.Period) {
+	case "hour":
+		start = t.Truncate(time.Hour)
+		end = start.Add(time.Hour)
+	case "day":
+		start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 0, 1)
+	case "month":
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 1, 0)
+	case "year":
+		start = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(1, 0, 0)
+	default:
+		return t, fmt.Errorf("unsupported time bounds period: %s", b.Period)
+	}
+
+	switch strings.ToLower(b.Position) {
+	case "", "start":
+		return start, nil
+	case "middle":
+		return start.Add(end.Sub(start) / 2), nil
+	case "end":
+		return end, nil
+	default:
+		return t, fmt.Errorf("unsupported time bounds position: %s", b.Position)
+	}
+}
+
+// adjustTimes applies the time offset and then the time bounds of
+// ruleSet to times. Unknown times remain zero.
+func (ruleSet *RuleSet) adjustTimes(times []time.Time) ([]time.Time, error) {
+	if len(ruleSet.TimeOffset) == 0 && ruleSet.TimeBounds == nil {
+		return times, nil
+	}
+
+	var offset time.Duration
+	if len(ruleSet.TimeOffset) > 0 {
+		var err error
+		offset, err = parseTimeOffset(ruleSet.TimeOffset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	adjusted := make([]time.Time, len(times))
+	for i, t := range times {
+		if t.IsZero() {
+			continue
+		}
+		t = t.UTC().Add(offset)
+		if ruleSet.TimeBounds != nil {
+			var err error
+			t, err = ruleSet.TimeBounds.apply(t)
+			if err != nil {
+				return nil, err
+			}
+		}
+		adjusted[i] = t
+	}
+	return adjusted, nil
+}
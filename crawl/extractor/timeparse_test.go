@@ -0,0 +1,70 @@
+package extractor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCFTimesCalendars(t *testing.T) {
+	date := func(year int, month time.Month, day, hour, min, sec, msec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, msec*1e6, time.UTC)
+	}
+
+	testCases := []struct {
+		calendar string
+		units    string
+		values   []float64
+		times    []time.Time
+	}{
+		{"standard", "days since 2000-01-01", []float64{59, 60, 366.5}, []time.Time{date(2000, 2, 29, 0, 0, 0, 0), date(2000, 3, 1, 0, 0, 0, 0), date(2001, 1, 1, 12, 0, 0, 0)}},
+		{"gregorian", "hours since 1999-12-31 12:00", []float64{12, 36}, []time.Time{date(2000, 1, 1, 0, 0, 0, 0), date(2000, 1, 2, 0, 0, 0, 0)}},
+		{"proleptic_gregorian", "days since 1582-10-04", []float64{1}, []time.Time{date(1582, 10, 5, 0, 0, 0, 0)}},
+		{"noleap", "days since 2000-01-01", []float64{58, 59, 365}, []time.Time{date(2000, 2, 28, 0, 0, 0, 0), date(2000, 3, 1, 0, 0, 0, 0), date(2001, 1, 1, 0, 0, 0, 0)}},
+		{"365_day", "months since 2000-02-01", []float64{0, 1, 12}, []time.Time{date(2000, 2, 1, 0, 0, 0, 0), date(2000, 3, 1, 0, 0, 0, 0), date(2001, 2, 1, 0, 0, 0, 0)}},
+		// Leap years keep their dates
+		{"all_leap", "days since 2000-01-01", []float64{59, 60, 366}, []time.Time{date(2000, 2, 29, 0, 0, 0, 0), date(2000, 3, 1, 0, 0, 0, 0), date(2001, 1, 1, 0, 0, 0, 0)}},
+		// 29 February 2001 is 59/366 into the Gregorian year
+		{"366_day", "days since 2001-01-01", []float64{0, 59, 366}, []time.Time{date(2001, 1, 1, 0, 0, 0, 0), date(2001, 2, 28, 20, 7, 52, 131), date(2002, 1, 1, 0, 0, 0, 0)}},
+		// 29 and 30 February are 58/360 and 59/360 into the Gregorian year
+		{"360_day", "days since 2000-01-01", []float64{0, 58, 59, 60, 360}, []time.Time{date(2000, 1, 1, 0, 0, 0, 0), date(2000, 2, 28, 23, 12, 0, 0), date(2000, 2, 29, 23, 36, 0, 0), date(2000, 3, 2, 0, 0, 0, 0), date(2001, 1, 1, 0, 0, 0, 0)}},
+		{"360_day", "months since 2000-01-16", []float64{0, 1, 2}, []time.Time{date(2000, 1, 16, 6, 0, 0, 0), date(2000, 2, 15, 18, 0, 0, 0), date(2000, 3, 17, 6, 0, 0, 0)}},
+	}
+
+	for _, tc := range testCases {
+		times, err := cfTimes(tc.values, tc.units, tc.calendar)
+		if err != nil {
+			t.Errorf("%s, %s: %v", tc.calendar, tc.units, err)
+			continue
+		}
+		if len(times) != len(tc.times) {
+			t.Errorf("%s, %s: expected %v, got %v", tc.calendar, tc.units, tc.times, times)
+			continue
+		}
+		for i := range times {
+			if !times[i].Equal(tc.times[i]) {
+				t.Errorf("%s, %s: %v: expected %v, got %v", tc.calendar, tc.units, tc.values[i], tc.times[i], times[i])
+			}
+		}
+	}
+
+	if _, err := cfTimes([]float64{0}, "days since 2000-01-01", "julian"); err == nil {
+		t.Errorf("expected an error for an unsupported calendar")
+	}
+}
+
+func TestCFTimes360DayUnique(t *testing.T) {
+	var values []float64
+	for day := 0; day < 3*360; day++ {
+		values = append(values, float64(day))
+	}
+
+	times, err := cfTimes(values, "days since 1999-01-01", "360_day")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(times); i++ {
+		if !times[i].After(times[i-1]) {
+			t.Fatalf("day %d at %v is not after day %d at %v", i, times[i], i-1, times[i-1])
+		}
+	}
+}
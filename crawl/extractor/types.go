@@ -26,6 +26,10 @@ type GeoMetaData struct {
 	StdDevs      []float64      `json:"stddevs,omitempty"`
 	SampleCounts []int          `json:"sample_counts,omitempty"`
 	NoData       float64        `json:"nodata,omitempty"`
+	ScaleFactor  float64        `json:"scale_factor,omitempty"`
+	AddOffset    float64        `json:"add_offset,omitempty"`
+	Units        string         `json:"units,omitempty"`
+	ValidRange   []float64      `json:"valid_range,omitempty"`
 	Axes         []*DatasetAxis `json:"axes,omitempty"`
 	GeoLocation  *GeoLocInfo    `json:"geo_loc,omitempty"`
 }
@@ -3,8 +3,14 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nci/gsky/metrics"
 	"github.com/nci/gsky/utils"
@@ -32,6 +38,10 @@ func serveDap(ctx context.Context, conf *utils.Config, r *http.Request, w http.R
 		return
 	}
 
+	if checksum, found := query["dap4.checksum"]; found && len(checksum) > 0 {
+		wcsParams.Dap4Checksum = strings.ToLower(checksum[0]) == "true"
+	}
+
 	serveWCS(ctx, *wcsParams, conf, r, w, query, metricsCollector)
 }
 
@@ -39,7 +49,8 @@ func dapToWcs(ce *utils.DapConstraints, conf *utils.Config) (*utils.WCSParams, e
 	defaultBbox := []float64{-180, -90, 180, 90}
 	defaultGeoSize := []int{-1, -1}
 
-	wcsParams := &utils.WCSParams{BBox: defaultBbox, Coverages: []string{ce.Dataset}, NoReprojection: true, AxisMapping: 1}
+	// The bbox is a copy as the constraints narrow it down in place
+	wcsParams := &utils.WCSParams{BBox: append([]float64{}, defaultBbox...), Coverages: []string{ce.Dataset}, NoReprojection: true, AxisMapping: 1}
 	idx, err := utils.GetCoverageIndex(*wcsParams, conf)
 	if err != nil {
 		return wcsParams, fmt.Errorf("dataset not found: %v", ce.Dataset)
@@ -51,7 +62,8 @@ func dapToWcs(ce *utils.DapConstraints, conf *utils.Config) (*utils.WCSParams, e
 	}
 
 	if len(layer.DefaultGeoBbox) == 4 {
-		wcsParams.BBox = layer.DefaultGeoBbox
+		wcsParams.BBox = make([]float64, len(layer.DefaultGeoBbox))
+		copy(wcsParams.BBox, layer.DefaultGeoBbox)
 	}
 
 	if len(layer.DefaultGeoSize) == 2 {
@@ -119,6 +131,17 @@ func dapToWcs(ce *utils.DapConstraints, conf *utils.Config) (*utils.WCSParams, e
 				continue
 			}
 
+			if vp.Name == "time" && len(layer.Dates) > 0 && (len(vp.IdxSelectors) > 0 || vp.ValEnd != nil) {
+				axisParam, err := dapTimeAxis(vp, layer.Dates)
+				if err != nil {
+					return wcsParams, err
+				}
+				startTime := time.Unix(int64(axisParam.InValues[0]), 0).UTC()
+				wcsParams.Time = &startTime
+				wcsParams.Axes = append(wcsParams.Axes, axisParam)
+				continue
+			}
+
 			var axisParam *utils.AxisParam
 			if len(vp.IdxSelectors) > 0 {
 				axisParam = &utils.AxisParam{Name: vp.Name}
@@ -165,6 +188,346 @@ func dapToWcs(ce *utils.DapConstraints, conf *utils.Config) (*utils.WCSParams, e
 	return wcsParams, nil
 }
 
+// dapTimeAxis selects the layer dates of the index selectors or the
+// value range of a time constraint. Time indices are indices of the
+// layer dates rather than of the timestamps of each file.
+func dapTimeAxis(vp *utils.DapVarParam, dates []string) (*utils.AxisParam, error) {
+	timestamps := make([]float64, len(dates))
+	for i, date := range dates {
+		t, err := time.Parse(utils.ISOFormat, date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid layer date %v: %v", date, err)
+		}
+		timestamps[i] = float64(t.Unix())
+	}
+
+	axisParam := &utils.AxisParam{Name: vp.Name}
+	selected := make(map[int]struct{})
+	selectIdx := func(idx int) {
+		if _, found := selected[idx]; !found {
+			selected[idx] = struct{}{}
+			axisParam.InValues = append(axisParam.InValues, timestamps[idx])
+		}
+	}
+
+	if len(vp.IdxSelectors) == 0 {
+		for i, ts := range timestamps {
+			if ts >= *vp.ValStart && ts <= *vp.ValEnd {
+				selectIdx(i)
+			}
+		}
+	}
+
+	for _, sel := range vp.IdxSelectors {
+		start := 0
+		end := len(timestamps) - 1
+		step := 1
+		if !sel.IsAll {
+			if sel.Start != nil {
+				start = *sel.Start
+			}
+			if !sel.IsRange {
+				end = start
+			} else if sel.End != nil {
+				end = *sel.End
+			}
+			if sel.Step != nil {
+				step = *sel.Step
+			}
+		}
+
+		if step < 1 {
+			return nil, fmt.Errorf("indexing step must be greater or equal to 1")
+		}
+		if start > end {
+			return nil, fmt.Errorf("starting index must be lower or equal to ending index")
+		}
+		if end > len(timestamps)-1 {
+			return nil, fmt.Errorf("time index %d out of range, the dataset has %d dates", end, len(timestamps))
+		}
+
+		for i := start; i <= end; i += step {
+			selectIdx(i)
+		}
+	}
+
+	if len(axisParam.InValues) == 0 {
+		return nil, fmt.Errorf("no dates found for the time constraint")
+	}
+	return axisParam, nil
+}
+
 func logDapError(err error) {
 	log.Printf("DAP: error: %s", err)
 }
+
+// dapFileWriter captures a response into a file
+type dapFileWriter struct {
+	header http.Header
+	file   *os.File
+	status int
+}
+
+func (fw *dapFileWriter) Header() http.Header {
+	return fw.header
+}
+
+func (fw *dapFileWriter) Write(b []byte) (int, error) {
+	if fw.status == 0 {
+		fw.status = 200
+	}
+	return fw.file.Write(b)
+}
+
+func (fw *dapFileWriter) WriteHeader(status int) {
+	if fw.status == 0 {
+		fw.status = status
+	}
+}
+
+// serveDapDataset serves the DAP4 dataset metadata response and the
+// DAP2 DDS, DAS and data responses of a layer
+func serveDapDataset(ctx context.Context, conf *utils.Config, layerName string, ext string, r *http.Request, w http.ResponseWriter, metricsCollector *metrics.MetricsCollector) {
+	dapErr := func(status int, err error) {
+		logDapError(err)
+		metricsCollector.Info.HTTPStatus = status
+		http.Error(w, err.Error(), status)
+	}
+
+	idx, err := utils.GetCoverageIndex(utils.WCSParams{Coverages: []string{layerName}}, conf)
+	if err != nil {
+		dapErr(404, fmt.Errorf("dataset not found: %v", layerName))
+		return
+	}
+
+	service := "dap2"
+	if ext == ".dmr" {
+		service = "dap4"
+	}
+	if utils.CheckDisableServices(&conf.Layers[idx], service) {
+		dapErr(400, fmt.Errorf("%s is disabled for this dataset: %v", service, layerName))
+		return
+	}
+
+	newConf := conf.Copy(r)
+	newConf.GetLayerDates(idx, *verbose)
+	ds, err := utils.NewDapDataset(&newConf.Layers[idx])
+	if err != nil {
+		dapErr(400, err)
+		return
+	}
+
+	var projs []*utils.DapProjection
+	if ext == ".dds" || ext == ".dods" {
+		projs, err = utils.ParseDap2ConstraintExpr(r.URL.RawQuery)
+		if err == nil {
+			projs, err = ds.Project(projs)
+		}
+		if err != nil {
+			dapErr(400, fmt.Errorf("Failed to parse constraint expression: %v", err))
+			return
+		}
+	}
+
+	switch ext {
+	case ".dmr":
+		w.Header().Set("Content-Type", "application/vnd.opendap.dap4.dataset-metadata+xml")
+		err = ds.WriteDMR(w)
+	case ".dds":
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Description", "dods-dds")
+		err = ds.WriteDDS(w, projs)
+	case ".das":
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Description", "dods-das")
+		err = ds.WriteDAS(w)
+	case ".dods":
+		err = serveDods(ctx, conf, idx, ds, projs, r, w, metricsCollector)
+	}
+	if err != nil {
+		logDapError(err)
+	}
+}
+
+// serveDods writes the DDS of the projected variables followed by their
+// values in XDR. Every time step and axis value of a data variable is
+// rendered by a WCS GetCoverage of the lat/lon window of the request.
+func serveDods(ctx context.Context, conf *utils.Config, idx int, ds *utils.DapDataset, projs []*utils.DapProjection, r *http.Request, w http.ResponseWriter, metricsCollector *metrics.MetricsCollector) error {
+	layer := &conf.Layers[idx]
+	badRequest := func(err error) error {
+		metricsCollector.Info.HTTPStatus = 400
+		http.Error(w, err.Error(), 400)
+		return err
+	}
+
+	// Errors cannot be reported once the data is streaming, so the
+	// whole request is validated first
+	for _, axis := range ds.Axes {
+		for _, val := range axis.Values {
+			if _, err := strconv.ParseFloat(val, 64); err != nil {
+				return badRequest(fmt.Errorf("invalid value of axis %s: %s", axis.Name, val))
+			}
+		}
+	}
+
+	bandExprs := make([]*utils.BandExpressions, len(projs))
+	var nValues int64
+	for iProj, p := range projs {
+		v := ds.Variable(p.Name)
+		if !v.IsData {
+			continue
+		}
+		if len(p.Slices) < 2 {
+			return badRequest(fmt.Errorf("variable %s has no lat and lon dimensions", p.Name))
+		}
+		latSlice := p.Slices[len(p.Slices)-2]
+		lonSlice := p.Slices[len(p.Slices)-1]
+		if latSlice.Count() > layer.WcsMaxHeight || lonSlice.Count() > layer.WcsMaxWidth {
+			return badRequest(fmt.Errorf("Requested width/height is too large, max width:%d, height:%d", layer.WcsMaxWidth, layer.WcsMaxHeight))
+		}
+		nValues += int64(p.Count())
+
+		bandExpr, err := utils.ParseBandExpressions([]string{v.Expr})
+		if err != nil {
+			return badRequest(fmt.Errorf("invalid band expression of %s: %v", p.Name, err))
+		}
+		bandExprs[iProj] = bandExpr
+	}
+
+	// All time steps and axis values together are bounded like a
+	// single coverage
+	maxValues := int64(layer.WcsMaxWidth) * int64(layer.WcsMaxHeight)
+	if nValues > maxValues {
+		return badRequest(fmt.Errorf("Requested data is too large: %d values, max: %d", nValues, maxValues))
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Description", "dods-data")
+	if err := ds.WriteDDS(w, projs); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "Data:\n"); err != nil {
+		return err
+	}
+
+	for iProj, p := range projs {
+		v := ds.Variable(p.Name)
+		if !v.IsData {
+			if err := ds.WriteCoordinateXDR(w, p); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := utils.WriteXDRLength(w, p.Count()); err != nil {
+			return err
+		}
+
+		bandExpr := bandExprs[iProj]
+		nOuter := len(p.Slices) - 2
+		outerIndices := make([][]int, nOuter)
+		for i := 0; i < nOuter; i++ {
+			outerIndices[i] = p.Slices[i].Indices()
+		}
+		bbox, width, height := ds.DapGridWindow(p.Slices[nOuter], p.Slices[nOuter+1])
+
+		// iterate the outer dimensions in row-major order
+		pos := make([]int, nOuter)
+		for {
+			var timeIdx int
+			axisIdx := make([]int, len(ds.Axes))
+			for i := range pos {
+				if len(ds.Times) > 0 && i == 0 {
+					timeIdx = outerIndices[i][pos[i]]
+				} else if len(ds.Times) > 0 {
+					axisIdx[i-1] = outerIndices[i][pos[i]]
+				} else {
+					axisIdx[i] = outerIndices[i][pos[i]]
+				}
+			}
+
+			data, err := renderDapWindow(ctx, conf, idx, ds, bandExpr, timeIdx, axisIdx, bbox, width, height, r, metricsCollector)
+			if err != nil {
+				return err
+			}
+			if err := utils.WriteXDRFloat32(w, data); err != nil {
+				return err
+			}
+
+			i := nOuter - 1
+			for ; i >= 0; i-- {
+				pos[i]++
+				if pos[i] < len(outerIndices[i]) {
+					break
+				}
+				pos[i] = 0
+			}
+			if i < 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// renderDapWindow renders a band expression over a bbox at a time step
+// and axis values through WCS GetCoverage
+func renderDapWindow(ctx context.Context, conf *utils.Config, idx int, ds *utils.DapDataset, bandExpr *utils.BandExpressions, timeIdx int, axisIdx []int, bbox []float64, width int, height int, r *http.Request, metricsCollector *metrics.MetricsCollector) ([]float32, error) {
+	params := utils.WCSParams{
+		Service:   new(string),
+		Version:   new(string),
+		Request:   new(string),
+		CRS:       new(string),
+		Format:    new(string),
+		Width:     &width,
+		Height:    &height,
+		BBox:      bbox,
+		Coverages: []string{conf.Layers[idx].Name},
+		BandExpr:  bandExpr,
+	}
+	*params.Service = "WCS"
+	*params.Version = "1.0.0"
+	*params.Request = "GetCoverage"
+	*params.CRS = "EPSG:4326"
+	*params.Format = "geotiff"
+
+	if len(ds.Times) > 0 {
+		t := ds.Times[timeIdx]
+		params.Time = &t
+	}
+	for i, axis := range ds.Axes {
+		val, err := strconv.ParseFloat(axis.Values[axisIdx[i]], 64)
+		if err != nil {
+			return nil, err
+		}
+		params.Axes = append(params.Axes, &utils.AxisParam{Name: axis.Name, Start: &val, Order: 1})
+	}
+	params.Axes = append(params.Axes, &utils.AxisParam{Name: "time", Aggregate: 1})
+
+	tempFile, err := ioutil.TempFile(conf.ServiceConfig.TempDir, "dap_*.tif")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	fw := &dapFileWriter{header: make(http.Header), file: tempFile}
+	serveWCS(ctx, params, conf, r, fw, map[string][]string{}, metricsCollector)
+	if fw.status != 200 {
+		tempFile.Seek(0, io.SeekStart)
+		msg, _ := ioutil.ReadAll(tempFile)
+		return nil, fmt.Errorf("rendering failed: %s", strings.TrimSpace(string(msg)))
+	}
+	if err := tempFile.Sync(); err != nil {
+		return nil, err
+	}
+
+	data, dataWidth, dataHeight, err := utils.ReadDapBand(tempFile.Name(), 1)
+	if err != nil {
+		return nil, err
+	}
+	if dataWidth != width || dataHeight != height {
+		return nil, fmt.Errorf("rendered %dx%d pixels instead of %dx%d", dataWidth, dataHeight, width, height)
+	}
+	return data, nil
+}
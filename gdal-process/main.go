@@ -10,6 +10,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/proto"
@@ -19,20 +20,14 @@ import (
 )
 
 func sendOutput(out *pb.Result, conn net.Conn) error {
-	outb, err := proto.Marshal(out)
-	if err != nil {
-		return err
-	}
-
-	_, err = conn.Write(outb)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return gp.WriteResult(conn, out)
 }
 
-func dataHandler(conn net.Conn, timeout int) {
+// Tasks are run one at a time while cancel requests are served
+// concurrently with the running task
+var taskMutex sync.Mutex
+
+func connHandler(conn net.Conn, timeout int) {
 	defer conn.Close()
 	out := &pb.Result{}
 
@@ -41,6 +36,7 @@ func dataHandler(conn net.Conn, timeout int) {
 	if err != nil {
 		out.Error = fmt.Sprintf("Error reading data %d from socket: %v", n, err)
 		sendOutput(out, conn)
+		return
 	}
 
 	in := new(pb.GeoRPCGranule)
@@ -48,12 +44,30 @@ func dataHandler(conn net.Conn, timeout int) {
 	if err != nil {
 		out.Error = fmt.Sprintf("Error unmarshaling protobuf request: %v", err)
 		sendOutput(out, conn)
+		return
+	}
+
+	if in.Operation == "cancel" {
+		out.Error = "OK"
+		if !gp.CancelTask(in.TaskId) {
+			out.Error = fmt.Sprintf("Task %d is not running", in.TaskId)
+		}
+		sendOutput(out, conn)
+		return
 	}
 
 	if len(in.Path) == 0 {
 		return
 	}
 
+	taskMutex.Lock()
+	defer taskMutex.Unlock()
+	dataHandler(conn, in, timeout)
+}
+
+func dataHandler(conn net.Conn, in *pb.GeoRPCGranule, timeout int) {
+	var out *pb.Result
+
 	done := make(chan bool, 1)
 	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 
@@ -67,6 +81,7 @@ func dataHandler(conn net.Conn, timeout int) {
 		}
 	}()
 
+	gp.BeginTask(in.TaskId)
 	switch in.Operation {
 	case "warp":
 		out = gp.WarpRaster(in)
@@ -77,11 +92,12 @@ func dataHandler(conn net.Conn, timeout int) {
 	case "info":
 		out = gp.ExtractGDALInfo(in)
 	default:
-		out.Error = fmt.Sprintf("Unknown operation: %s", in.Operation)
+		out = &pb.Result{Error: fmt.Sprintf("Unknown operation: %s", in.Operation)}
 	}
+	gp.EndTask(in.TaskId)
 	done <- true
 
-	err = sendOutput(out, conn)
+	err := sendOutput(out, conn)
 	if err != nil {
 		log.Println(err)
 	}
@@ -119,6 +135,6 @@ func main() {
 			return
 		}
 
-		dataHandler(conn, *timeout)
+		go connHandler(conn, *timeout)
 	}
 }
@@ -8,10 +8,13 @@ require (
 	github.com/edisonguo/jet v2.1.2+incompatible
 	github.com/golang/protobuf v1.5.2
 	github.com/kavu/go_reuseport v1.5.0
+	github.com/klauspost/compress v1.15.9
 	github.com/lib/pq v1.10.1
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/nci/geometry v0.0.0-20170727004624-e73695b914d9
 	github.com/nci/gomemcache v0.0.0-20170208213004-1952afaa557d
 	golang.org/x/crypto v0.0.0-20210505212654-3497b51f5e64
+	golang.org/x/image v0.0.0-20210220032944-ac19c3e999fb
 	golang.org/x/net v0.0.0-20210505214959-0714010a04ed
 	google.golang.org/grpc v1.37.0
 	gopkg.in/yaml.v2 v2.4.0
@@ -7,7 +7,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/nci/gsky/utils"
 	pp "github.com/nci/gsky/worker/gdalprocess"
 	pb "github.com/nci/gsky/worker/gdalservice"
 
@@ -18,24 +21,53 @@ import (
 	reuseport "github.com/kavu/go_reuseport"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type server struct {
-	PoolSize int
-	Pool     *pp.ProcessPool
+	PoolSize    int
+	Pool        *pp.ProcessPool
+	ChunkSize   int
+	TaskTimeout time.Duration
+	activeTasks int32
+}
+
+// taskContext applies the task timeout of the server to the deadline of
+// a request. The deadline cancels the task in gsky-gdal-process.
+func (s *server) taskContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.TaskTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.TaskTimeout)
 }
 
 func (s *server) Process(ctx context.Context, in *pb.GeoRPCGranule) (*pb.Result, error) {
 	if in.Operation == "worker_info" {
-		return &pb.Result{WorkerInfo: &pb.WorkerInfo{PoolSize: int32(s.PoolSize)}}, nil
+		workerInfo := &pb.WorkerInfo{
+			PoolSize:    int32(s.PoolSize),
+			ActiveTasks: atomic.LoadInt32(&s.activeTasks),
+			QueueLength: int32(s.Pool.QueueLength()),
+		}
+		for _, l := range s.Pool.QueueLengths() {
+			workerInfo.QueueLengths = append(workerInfo.QueueLengths, int32(l))
+		}
+		return &pb.Result{WorkerInfo: workerInfo}, nil
 	}
 
+	atomic.AddInt32(&s.activeTasks, 1)
+	defer atomic.AddInt32(&s.activeTasks, -1)
+
+	ctx, cancel := s.taskContext(ctx)
+	defer cancel()
+
+	// The channels are left open for the process pool to deliver the
+	// outcome of tasks whose requests have been cancelled
 	rChan := make(chan *pb.Result, 1)
-	defer close(rChan)
 	errChan := make(chan error, 1)
-	defer close(errChan)
 
-	s.Pool.AddQueue(&pp.Task{Payload: in, Resp: rChan, Error: errChan})
+	s.Pool.AddQueue(&pp.Task{Context: ctx, Payload: in, Resp: rChan, Error: errChan})
 
 	select {
 	case out, ok := <-rChan:
@@ -48,6 +80,58 @@ func (s *server) Process(ctx context.Context, in *pb.GeoRPCGranule) (*pb.Result,
 		return out, nil
 	case err := <-errChan:
 		return &pb.Result{}, fmt.Errorf("Error in ops: %v", err)
+	case <-ctx.Done():
+		return &pb.Result{}, ctx.Err()
+	}
+}
+
+// ProcessStream forwards the raster of a task in chunks as it is read
+// from gsky-gdal-process, so the worker never holds the whole raster.
+func (s *server) ProcessStream(in *pb.GeoRPCGranule, stream pb.GDAL_ProcessStreamServer) error {
+	ctx := stream.Context()
+	if in.Operation == "worker_info" {
+		out, err := s.Process(ctx, in)
+		if err != nil {
+			return err
+		}
+		return utils.SendRasterStream(stream, out, s.ChunkSize)
+	}
+
+	atomic.AddInt32(&s.activeTasks, 1)
+	defer atomic.AddInt32(&s.activeTasks, -1)
+
+	ctx, cancel := s.taskContext(ctx)
+	defer cancel()
+
+	// As in Process, the channels are left open for the process pool
+	chunks := make(chan *pb.RasterChunk, 1)
+	errChan := make(chan error, 1)
+
+	s.Pool.AddQueue(&pp.Task{Context: ctx, Payload: in, Chunks: chunks, ChunkSize: s.ChunkSize, Error: errChan})
+
+	header := true
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return nil
+			}
+			if header {
+				if chunk.Header.Error != "OK" {
+					return fmt.Errorf("%s", chunk.Header.Error)
+				}
+				header = false
+			}
+
+			err := stream.Send(chunk)
+			if err != nil {
+				return err
+			}
+		case err := <-errChan:
+			return fmt.Errorf("Error in ops: %v", err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 }
 
@@ -57,21 +141,71 @@ func main() {
 	executable := flag.String("exec", filepath.Dir(os.Args[0])+"/gsky-gdal-process", "Executable filepath")
 	maxTaskProcessed := flag.Int("max_tasks", 20000, "Maximum number of tasks processed before starting gsky-gdal-process.")
 	oomThreshold := flag.Int("oom_threshold", int(1.5*1024*1024), "MemAvailable lower than the threshold (KB) triggers an OOM of the worker process")
+	oomPressure := flag.Float64("oom_pressure", 0, "Memory pressure (PSI full avg10 percentage) which triggers an OOM of the worker process. 0 disables the check.")
+	memBudget := flag.Int("mem_budget", 0, "Memory (MB) available to concurrent tasks, which are held back beyond it. 0 derives it from the host or cgroup memory less the OOM threshold. -1 disables it.")
 	verbose := flag.Bool("verbose", false, "verbose logging")
+	tlsCert := flag.String("tls_cert", "", "TLS certificate file. TLS is enabled if set.")
+	tlsKey := flag.String("tls_key", "", "TLS private key file.")
+	tlsClientCA := flag.String("tls_client_ca", "", "CA certificate file used to verify client certificates. Client certificates are required if set.")
+	authTokenFile := flag.String("auth_token_file", "", "File containing the bearer token required from clients.")
+	chunkSize := flag.Int("chunk_size", utils.DefaultGrpcChunkSize, "Size in bytes of the raster chunks sent by streaming requests.")
+	taskTimeout := flag.Int("task_timeout", 0, "Maximum seconds a task may run before it is cancelled. The deadline of the request applies if it is earlier. 0 disables it.")
 	flag.Parse()
 
-	procPool, err := pp.CreateProcessPool(*poolSize, *executable, *port, *maxTaskProcessed, *verbose)
+	var serverOpts []grpc.ServerOption
+	if len(*tlsCert) > 0 {
+		tlsConfig, err := utils.LoadServerTLSConfig(*tlsCert, *tlsKey, *tlsClientCA)
+		if err != nil {
+			log.Printf("Failed to load TLS config: %v", err)
+			os.Exit(2)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else if len(*tlsClientCA) > 0 {
+		log.Printf("-tls_client_ca requires -tls_cert and -tls_key")
+		os.Exit(2)
+	}
+
+	if len(*authTokenFile) > 0 {
+		token, err := utils.ReadAuthToken(*authTokenFile)
+		if err != nil {
+			log.Printf("%v", err)
+			os.Exit(2)
+		}
+		if len(*tlsCert) == 0 {
+			log.Printf("Warning: auth token is accepted over plaintext connections")
+		}
+		serverOpts = append(serverOpts,
+			grpc.UnaryInterceptor(utils.TokenAuthUnaryInterceptor(token)),
+			grpc.StreamInterceptor(utils.TokenAuthStreamInterceptor(token)))
+	}
+
+	taskMemBudget := int64(*memBudget) * 1024 * 1024
+	if *memBudget == 0 {
+		var err error
+		taskMemBudget, err = pp.GetMemoryBudgetLimit(*oomThreshold)
+		if err != nil {
+			log.Printf("Failed to determine memory budget, tasks are not held back: %v", err)
+		}
+	}
+	if *verbose {
+		log.Printf("task memory budget: %d MB", taskMemBudget/1024/1024)
+	}
+
+	procPool, err := pp.CreateProcessPool(*poolSize, *executable, *port, *maxTaskProcessed, taskMemBudget, *verbose)
 	if err != nil {
 		log.Printf("Failed to create process pool: %v", err)
 		os.Exit(2)
 	}
 
+	healthServer := health.NewServer()
+
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
 	go func() {
 		for {
 			select {
 			case <-signals:
+				healthServer.Shutdown()
 				for _, proc := range procPool.Pool {
 					proc.RemoveTempFiles()
 				}
@@ -92,11 +226,13 @@ func main() {
 			execMatch = fileName[:maxLen]
 		}
 		mon := pp.NewOOMMonitor(execMatch, *oomThreshold, *verbose)
+		mon.PressureThreshold = *oomPressure
 		mon.StartMonitorLoop()
 	}()
 
-	s := grpc.NewServer()
-	pb.RegisterGDALServer(s, &server{Pool: procPool, PoolSize: *poolSize})
+	s := grpc.NewServer(serverOpts...)
+	pb.RegisterGDALServer(s, &server{Pool: procPool, PoolSize: *poolSize, ChunkSize: *chunkSize, TaskTimeout: time.Duration(*taskTimeout) * time.Second})
+	healthpb.RegisterHealthServer(s, healthServer)
 
 	lis, err := reuseport.Listen("tcp", fmt.Sprintf(":%d", *port))
 	if err != nil {
@@ -5,7 +5,6 @@ package main
 
 import (
 	"crypto/md5"
-	"database/sql"
 	"encoding/hex"
 	"errors"
 	"flag"
@@ -13,20 +12,37 @@ import (
 	"log"
 	"net/http"
 
-	_ "github.com/lib/pq"
 	"github.com/nci/gomemcache/memcache"
 )
 
+// MASDataDir is the installation directory of the MAS SQL scripts
+var MASDataDir = "."
+
 var (
-	db       *sql.DB
-	mc       *memcache.Client
-	dbHost   = flag.String("dbhost", "/var/run/postgresql", "dbhost")
-	dbName   = flag.String("database", "mas", "database name")
-	dbUser   = flag.String("user", "api", "database user name")
-	dbPool   = flag.Int("pool", 8, "database pool size")
-	dbLimit  = flag.Int("limit", 64, "database concurrent requests")
-	httpPort = flag.Int("port", 8080, "http port")
-	mcURI    = flag.String("memcache", "", "memcache uri host:port")
+	backend       Backend
+	ingestHandler *IngestHandler
+	changeFeed    = NewChangeFeed(DefaultChangeHistory)
+	cache         *ResponseCache
+	mc            *memcache.Client
+	dbBackend     = flag.String("backend", "postgres", "index backend: postgres or sqlite")
+	dbHost        = flag.String("dbhost", "/var/run/postgresql", "dbhost")
+	dbName        = flag.String("database", "mas", "database name, or database file for the sqlite backend")
+	ingestFiles   = flag.String("ingest", "", "comma separated gsky-crawl TSV files to ingest at startup, '-' for stdin (sqlite backend only)")
+	ingestRoot    = flag.String("ingest_root", "", "gpath root of the ingested files, defaults to their deepest common directory")
+	dbUser        = flag.String("user", "api", "database user name")
+	dbPool        = flag.Int("pool", 8, "database pool size")
+	dbLimit       = flag.Int("limit", 64, "database concurrent requests")
+	httpPort      = flag.Int("port", 8080, "http port")
+	mcURI         = flag.String("memcache", "", "memcache uri host:port")
+	cacheSize     = flag.Int("cache_size", DefaultCacheSize, "size in bytes of the in-process response cache, 0 to disable")
+	cacheTTL      = flag.Duration("cache_ttl", 0, "lifetime of cached responses, 0 to keep them until the index changes")
+
+	ingestTokenFile = flag.String("ingest_token_file", "", "file containing the bearer token of the ingestion API, which is disabled if empty")
+	ingestUser      = flag.String("ingest_user", "mas", "database user owning the shards, used by the ingestion API")
+	ingestBatch     = flag.Int("ingest_batch", DefaultIngestBatchSize, "number of crawl records ingested per batch")
+	sqlDir          = flag.String("sql_dir", MASDataDir, "directory of the MAS SQL scripts")
+	refreshInterval = flag.Duration("refresh_interval", 0, "interval for refreshing shards changed by the ingestion API, 0 to refresh on request only")
+	listenChanges   = flag.Bool("listen_changes", true, "announce the shards refreshed by shard_refresh.sh in the change feed (postgres backend only)")
 )
 
 // Spit out a simple JSON-formatted error message for Content-Type: application/json
@@ -36,129 +52,68 @@ func httpJSONError(response http.ResponseWriter, err error, status int) {
 
 func handler(response http.ResponseWriter, request *http.Request) {
 
+	if isIngestRequest(request) {
+		ingestHandler.ServeHTTP(response, request)
+		return
+	}
+
+	// Long polling responses must never be cached
+	if _, ok := request.URL.Query()["changes"]; ok {
+		changeFeed.ServeHTTP(response, request)
+		return
+	}
+
 	response.Header().Set("Content-Type", "application/json")
 
 	var hash string
+	cacheable := isCacheable(request)
 
-	if mc != nil {
+	if cacheable && (cache != nil || mc != nil) {
 
-		buff := md5.Sum([]byte(request.URL.RequestURI()))
+		// Drill queries post their wkt in the body
+		request.ParseForm()
+		buff := md5.Sum([]byte(request.URL.RequestURI() + "\n" + request.PostForm.Encode()))
 		hash = hex.EncodeToString(buff[:])
 
-		if cached, ok := mc.Get(hash); ok == nil {
-			response.Write(cached.Value)
-			return
+		if cache != nil {
+			if cached, ok := cache.Get(hash); ok {
+				response.Write(cached)
+				return
+			}
+		}
+
+		if mc != nil {
+			if cached, ok := mc.Get(hash); ok == nil {
+				if cache != nil {
+					cache.Put(hash, cached.Value)
+				}
+				response.Write(cached.Value)
+				return
+			}
 		}
 	}
 
 	query := request.URL.Query()
+	q := newQuery(request)
 	var payload string
 	var err error
 
 	if _, ok := query["intersects"]; ok {
-
-		// Use Postgres prepared statements and placeholders for input checks.
-		// The nullif() noise is to coerce Go's empty string zero values for
-		// missing parameters into proper null arguments.
-		// The string_to_array() call will return null in the case of a null
-		// argument, rather than array[] or array[null].
-
-		err = db.QueryRow(
-			`select mas_intersects(
-				nullif($1,'')::text,
-				nullif($2,'')::text,
-				nullif($3,'')::text,
-				nullif($4,'')::integer,
-				nullif($5,'')::timestamptz,
-				nullif($6,'')::timestamptz,
-				string_to_array(nullif($7,''), ','),
-				nullif($8,'')::text,
-				nullif($9,'')::float8,
-				nullif($10,'')::float,
-				nullif($11,'')::int
-			) as json`,
-			request.URL.Path,
-			request.FormValue("srs"),
-			request.FormValue("wkt"),
-			request.FormValue("nseg"),
-			request.FormValue("time"),
-			request.FormValue("until"),
-			request.FormValue("namespace"),
-			request.FormValue("metadata"),
-			request.FormValue("identitytol"),
-			request.FormValue("dptol"),
-			request.FormValue("limit"),
-		).Scan(&payload)
-
+		payload, err = backend.Intersects(q)
 	} else if _, ok := query["timestamps"]; ok {
-		err = db.QueryRow(
-			`select mas_timestamps(
-				nullif($1,'')::text,
-				nullif($2,'')::timestamptz,
-				nullif($3,'')::timestamptz,
-				string_to_array(nullif($4,''), ','),
-				nullif($5,'')::text
-			) as json`,
-			request.URL.Path,
-			request.FormValue("time"),
-			request.FormValue("until"),
-			request.FormValue("namespace"),
-			request.FormValue("token"),
-		).Scan(&payload)
-
+		payload, err = backend.Timestamps(q)
 	} else if _, ok := query["extents"]; ok {
-		err = db.QueryRow(
-			`select mas_spatial_temporal_extents(
-				nullif($1,'')::text,
-				string_to_array(nullif($2,''), ',')
-			) as json`,
-			request.URL.Path,
-			request.FormValue("namespace"),
-		).Scan(&payload)
-
+		payload, err = backend.Extents(q)
 	} else if _, ok := query["list_root_gpath"]; ok {
-		err = db.QueryRow(
-			`select mas_list_root_gpath() as json`,
-		).Scan(&payload)
-
+		payload, err = backend.ListRootGPath()
 	} else if _, ok := query["list_sub_gpath"]; ok {
-		err = db.QueryRow(
-			`select mas_list_sub_gpath(
-				nullif($1,'')::text
-			) as json`,
-			request.URL.Path,
-		).Scan(&payload)
-
+		payload, err = backend.ListSubGPath(q)
 	} else if _, ok := query["generate_layers"]; ok {
-		err = db.QueryRow(
-			`select mas_generate_layers(
-				nullif($1,'')::text
-			) as json`,
-			request.URL.Path,
-		).Scan(&payload)
-
+		payload, err = backend.GenerateLayers(q)
 	} else if _, ok := query["put_ows_cache"]; ok {
-		err = db.QueryRow(
-			`select mas_put_ows_cache(
-				nullif($1,'')::text,
-        nullif($2,'')::text,
-        nullif($3,'')::jsonb
-			) as json`,
-			request.URL.Path,
-			request.FormValue("query"),
-			request.FormValue("value"),
-		).Scan(&payload)
-
+		payload, err = backend.PutOWSCache(q)
 	} else if _, ok := query["get_ows_cache"]; ok {
-		err = db.QueryRow(
-			`select mas_get_ows_cache(
-				nullif($1,'')::text,
-        nullif($2,'')::text
-			) as json`,
-			request.URL.Path,
-			request.FormValue("query"),
-		).Scan(&payload)
-
+		payload, err = backend.GetOWSCache(q)
 	} else {
 		httpJSONError(response, errors.New("unknown operation; currently supported: ?intersects, ?timestamps, ?extents"), 400)
 		return
@@ -171,6 +126,14 @@ func handler(response http.ResponseWriter, request *http.Request) {
 
 	response.Write([]byte(payload))
 
+	if !cacheable {
+		return
+	}
+
+	if cache != nil {
+		cache.Put(hash, []byte(payload))
+	}
+
 	if mc != nil {
 		// don't care about errors; memcache may not necessarily retain this anyway
 		mc.Set(&memcache.Item{Key: hash, Value: []byte(payload)})
@@ -178,35 +141,93 @@ func handler(response http.ResponseWriter, request *http.Request) {
 
 }
 
+func isCacheable(request *http.Request) bool {
+	query := request.URL.Query()
+	for _, op := range cacheableOperations {
+		if _, found := query[op]; found {
+			return true
+		}
+	}
+	return false
+}
+
 func main() {
 
 	flag.Parse()
 
-	log.Printf("dbHost %s dbUser %s dbName %s dbPool %d httpPort %d", *dbHost, *dbUser, *dbName, *dbPool, *httpPort)
+	if len(*ingestFiles) > 0 && *dbBackend != "sqlite" {
+		log.Fatalf("-ingest is only supported by the sqlite backend")
+	}
 
-	dbinfo := fmt.Sprintf("user=%s host=%s dbname=%s sslmode=disable", *dbUser, *dbHost, *dbName)
+	switch *dbBackend {
+	case "postgres":
+		log.Printf("dbHost %s dbUser %s dbName %s dbPool %d httpPort %d", *dbHost, *dbUser, *dbName, *dbPool, *httpPort)
+		pgBackend, err := NewPostgresBackend(*dbHost, *dbName, *dbUser, *dbPool, *dbLimit)
+		if err != nil {
+			panic(err)
+		}
+		backend = pgBackend
 
-	var err error
-	db, err = sql.Open("postgres", dbinfo)
-	if err != nil {
-		panic(err)
+		if *listenChanges {
+			err = pgBackend.ListenChanges(*dbHost, *dbName, *dbUser, changeFeed)
+			if err != nil {
+				log.Fatalf("listen %s: %v", ChangesChannel, err)
+			}
+		}
+
+	case "sqlite":
+		log.Printf("dbName %s httpPort %d", *dbName, *httpPort)
+		sqliteBackend, err := openSQLiteBackend(*dbName, *dbLimit, *ingestFiles)
+		if err != nil {
+			log.Fatalf("sqlite backend: %v", err)
+		}
+		backend = sqliteBackend
+
+	default:
+		log.Fatalf("unknown backend: %s", *dbBackend)
 	}
-	defer db.Close()
+	defer backend.Close()
 
-	// sql.Open() does lazy evaluation. Here we do some simple
-	// test to assert if connection is okay.
-	var payload string
-	err = db.QueryRow("select true").Scan(&payload)
-	if err != nil {
-		panic(err)
+	if len(*ingestTokenFile) > 0 {
+		token, err := ReadIngestToken(*ingestTokenFile)
+		if err != nil {
+			log.Fatalf("ingestion API: %v", err)
+		}
+
+		if pgBackend, ok := backend.(*PostgresBackend); ok {
+			err = pgBackend.OpenIngestDB(*dbHost, *dbName, *ingestUser, *sqlDir)
+			if err != nil {
+				log.Fatalf("ingestion API: %v", err)
+			}
+		}
+
+		ingester := backend.(Ingester)
+		jobs := NewJobManager(ingester)
+		jobs.Changes = changeFeed
+		if *refreshInterval > 0 {
+			jobs.AutoRefresh(*refreshInterval)
+		}
+		ingestHandler = &IngestHandler{Ingester: ingester, Jobs: jobs, Token: token, BatchSize: *ingestBatch}
 	}
 
-	db.SetMaxIdleConns(*dbPool)
-	db.SetMaxOpenConns(*dbLimit)
+	if *cacheSize > 0 {
+		cache = NewResponseCache(*cacheSize, *cacheTTL)
+		changeFeed.OnPublish(func(changes []*Change) {
+			cache.Purge()
+		})
+	}
 
 	if *mcURI != "" {
 		// lazy connection; errors returned in .Get
 		mc = memcache.New(*mcURI)
+
+		// Cached responses are keyed by request URI, so any change
+		// flushes all of them
+		changeFeed.OnPublish(func(changes []*Change) {
+			if err := mc.FlushAll(); err != nil {
+				log.Printf("memcache flush: %v", err)
+			}
+		})
 	}
 
 	http.HandleFunc("/", handler)
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+)
+
+// Query holds the parameters of a MAS API request as they appear in the
+// request. Missing parameters are empty strings.
+type Query struct {
+	GPath       string
+	SRS         string
+	WKT         string
+	NSeg        string
+	Time        string
+	Until       string
+	Namespace   string
+	Metadata    string
+	IdentityTol string
+	DpTol       string
+	Limit       string
+	Offset      string
+	Token       string
+	Key         string
+	Value       string
+}
+
+func newQuery(request *http.Request) *Query {
+	return &Query{
+		GPath:       request.URL.Path,
+		SRS:         request.FormValue("srs"),
+		WKT:         request.FormValue("wkt"),
+		NSeg:        request.FormValue("nseg"),
+		Time:        request.FormValue("time"),
+		Until:       request.FormValue("until"),
+		Namespace:   request.FormValue("namespace"),
+		Metadata:    request.FormValue("metadata"),
+		IdentityTol: request.FormValue("identitytol"),
+		DpTol:       request.FormValue("dptol"),
+		Limit:       request.FormValue("limit"),
+		Offset:      request.FormValue("offset"),
+		Token:       request.FormValue("token"),
+		Key:         request.FormValue("query"),
+		Value:       request.FormValue("value"),
+	}
+}
+
+// Backend is an index store serving the MAS API operations. Every
+// operation returns its response as a JSON document.
+type Backend interface {
+	// Intersects finds the datasets under a gpath intersecting a polygon
+	// and a time range
+	Intersects(q *Query) (string, error)
+
+	// Timestamps lists the distinct timestamps of the datasets under a
+	// gpath within a time range
+	Timestamps(q *Query) (string, error)
+
+	// Extents computes the EPSG:3857 bounding box and time range of the
+	// datasets under a gpath
+	Extents(q *Query) (string, error)
+
+	ListRootGPath() (string, error)
+	ListSubGPath(q *Query) (string, error)
+	GenerateLayers(q *Query) (string, error)
+
+	PutOWSCache(q *Query) (string, error)
+	GetOWSCache(q *Query) (string, error)
+
+	Close() error
+}
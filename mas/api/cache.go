@@ -0,0 +1,49 @@
+package main
+
+import (
+	"time"
+
+	"github.com/nci/gsky/utils/lru"
+)
+
+// Default size of the in-process response cache in bytes
+const DefaultCacheSize = 64 * 1024 * 1024
+
+// cacheableOperations are the query operations whose responses depend
+// only on the index. The OWS cache operations read and write state of
+// their own and are never cached.
+var cacheableOperations = []string{"intersects", "timestamps", "extents", "list_root_gpath", "list_sub_gpath", "generate_layers"}
+
+// ResponseCache is a LRU cache of responses bounded by the total size
+// of the responses. Entries expire after ttl if it is positive.
+type ResponseCache struct {
+	cache *lru.Cache
+}
+
+func NewResponseCache(maxBytes int, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{cache: lru.New(maxBytes, ttl)}
+}
+
+func (c *ResponseCache) Get(key string) ([]byte, bool) {
+	value, found := c.cache.Get(key)
+	if !found {
+		return nil, false
+	}
+	return value.([]byte), true
+}
+
+// Put caches value under key. Values larger than the whole cache are
+// not cached.
+func (c *ResponseCache) Put(key string, value []byte) {
+	c.cache.Put(key, value, len(value))
+}
+
+// Purge drops every cached response
+func (c *ResponseCache) Purge() {
+	c.cache.Purge()
+}
+
+// Len returns the number of cached responses
+func (c *ResponseCache) Len() int {
+	return c.cache.Len()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	c := NewResponseCache(10, 0)
+
+	c.Put("a", []byte("1234"))
+	c.Put("b", []byte("1234"))
+	if _, found := c.Get("a"); !found {
+		t.Errorf("expected a to be cached")
+	}
+
+	// b is the least recently used once a was read
+	c.Put("c", []byte("1234"))
+	if _, found := c.Get("b"); found {
+		t.Errorf("expected b to be evicted")
+	}
+	if value, found := c.Get("a"); !found || string(value) != "1234" {
+		t.Errorf("expected a to be cached, got %q", value)
+	}
+
+	c.Put("d", []byte("01234567890"))
+	if _, found := c.Get("d"); found {
+		t.Errorf("expected a response larger than the cache not to be cached")
+	}
+
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("expected an empty cache after purge, got %d entries", c.Len())
+	}
+
+	c = NewResponseCache(10, time.Millisecond)
+	c.Put("a", []byte("1234"))
+	time.Sleep(5 * time.Millisecond)
+	if _, found := c.Get("a"); found {
+		t.Errorf("expected a to expire")
+	}
+}
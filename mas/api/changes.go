@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Number of changes kept for subscribers catching up
+const DefaultChangeHistory = 1000
+
+// Longest a changes request waits for a change
+const MaxChangesTimeout = 5 * time.Minute
+
+// Postgres channel announcing the gpaths of refreshed shards
+const ChangesChannel = "mas_changes"
+
+// Change announces that files were added or removed under GPath
+type Change struct {
+	Seq   int64     `json:"seq"`
+	GPath string    `json:"gpath"`
+	Time  time.Time `json:"time"`
+}
+
+// ChangeFeed keeps the recent changes of the index for long polling
+// subscribers
+type ChangeFeed struct {
+	mutex   sync.Mutex
+	seq     int64
+	changes []*Change
+	history int
+
+	// Closed and replaced on every publish to wake up waiting requests
+	notify chan struct{}
+
+	hooks []func([]*Change)
+}
+
+func NewChangeFeed(history int) *ChangeFeed {
+	if history <= 0 {
+		history = DefaultChangeHistory
+	}
+	return &ChangeFeed{history: history, notify: make(chan struct{})}
+}
+
+// OnPublish registers fn to be called with every published batch of
+// changes
+func (f *ChangeFeed) OnPublish(fn func([]*Change)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.hooks = append(f.hooks, fn)
+}
+
+// Publish announces changes under gpaths. Duplicated gpaths are
+// announced once.
+func (f *ChangeFeed) Publish(gpaths ...string) {
+	f.mutex.Lock()
+
+	now := time.Now().UTC()
+	seen := make(map[string]bool)
+	var published []*Change
+	for _, gpath := range gpaths {
+		gpath = cleanGPath(gpath)
+		if seen[gpath] {
+			continue
+		}
+		seen[gpath] = true
+
+		f.seq++
+		change := &Change{Seq: f.seq, GPath: gpath, Time: now}
+		f.changes = append(f.changes, change)
+		published = append(published, change)
+	}
+	if len(published) == 0 {
+		f.mutex.Unlock()
+		return
+	}
+
+	if len(f.changes) > f.history {
+		f.changes = append([]*Change(nil), f.changes[len(f.changes)-f.history:]...)
+	}
+	close(f.notify)
+	f.notify = make(chan struct{})
+	hooks := f.hooks
+	f.mutex.Unlock()
+
+	for _, fn := range hooks {
+		fn(published)
+	}
+}
+
+// Since returns the changes after seq and the latest sequence number.
+// reset is true if changes after seq were dropped from the history, in
+// which case subscribers must assume everything changed.
+func (f *ChangeFeed) Since(seq int64) (changes []*Change, latest int64, reset bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.since(seq)
+}
+
+func (f *ChangeFeed) since(seq int64) ([]*Change, int64, bool) {
+	// The sequence was issued by an earlier run of MAS
+	if seq > f.seq {
+		return nil, f.seq, true
+	}
+
+	oldest := f.seq - int64(len(f.changes))
+	if seq < oldest {
+		return nil, f.seq, true
+	}
+
+	changes := make([]*Change, f.seq-seq)
+	copy(changes, f.changes[len(f.changes)-len(changes):])
+	return changes, f.seq, false
+}
+
+// Wait waits up to timeout for changes after seq
+func (f *ChangeFeed) Wait(ctx context.Context, seq int64, timeout time.Duration) ([]*Change, int64, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		f.mutex.Lock()
+		changes, latest, reset := f.since(seq)
+		notify := f.notify
+		f.mutex.Unlock()
+
+		if len(changes) > 0 || reset {
+			return changes, latest, reset
+		}
+
+		select {
+		case <-notify:
+		case <-timer.C:
+			return changes, latest, reset
+		case <-ctx.Done():
+			return changes, latest, reset
+		}
+	}
+}
+
+// ServeHTTP serves ?changes&since=<seq>&timeout=<duration>. Without
+// since, it returns the latest sequence number immediately so that a new
+// subscriber can start from it.
+func (f *ChangeFeed) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", "application/json")
+	response.Header().Set("Cache-Control", "no-cache")
+
+	query := request.URL.Query()
+	if len(query.Get("since")) == 0 {
+		_, latest, _ := f.Since(0)
+		writeJSON(response, map[string]interface{}{"seq": latest, "changes": []*Change{}, "reset": false})
+		return
+	}
+
+	seq, err := strconv.ParseInt(query.Get("since"), 10, 64)
+	if err != nil || seq < 0 {
+		httpJSONError(response, fmt.Errorf("invalid since: %q", query.Get("since")), http.StatusBadRequest)
+		return
+	}
+
+	timeout := 30 * time.Second
+	if len(query.Get("timeout")) > 0 {
+		timeout, err = time.ParseDuration(query.Get("timeout"))
+		if err != nil || timeout < 0 {
+			httpJSONError(response, fmt.Errorf("invalid timeout: %q", query.Get("timeout")), http.StatusBadRequest)
+			return
+		}
+		if timeout > MaxChangesTimeout {
+			timeout = MaxChangesTimeout
+		}
+	}
+
+	changes, latest, reset := f.Wait(request.Context(), seq, timeout)
+	if changes == nil {
+		changes = []*Change{}
+	}
+	writeJSON(response, map[string]interface{}{"seq": latest, "changes": changes, "reset": reset})
+}
+
+// ListenChanges publishes the gpaths notified on ChangesChannel, which
+// shard_refresh.sh notifies after swapping in a refreshed shard
+func (b *PostgresBackend) ListenChanges(host, name, user string, feed *ChangeFeed) error {
+	dbinfo := fmt.Sprintf("user=%s host=%s dbname=%s sslmode=disable", user, host, name)
+	listener := pq.NewListener(dbinfo, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("%s listener: %v", ChangesChannel, err)
+		}
+	})
+	if err := listener.Listen(ChangesChannel); err != nil {
+		listener.Close()
+		return err
+	}
+	b.listener = listener
+
+	go func() {
+		for notification := range listener.Notify {
+			// A nil notification follows a reconnection, after which
+			// notifications may have been missed
+			if notification == nil {
+				feed.Publish("/")
+				continue
+			}
+			feed.Publish(notification.Extra)
+		}
+	}()
+	return nil
+}
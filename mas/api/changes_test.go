@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChangeFeed(t *testing.T) {
+	f := NewChangeFeed(3)
+
+	f.Publish("/g/data/ab1/", "/g/data/ab1", "/g/data/ab2")
+	changes, latest, reset := f.Since(0)
+	if len(changes) != 2 || latest != 2 || reset {
+		t.Fatalf("expected 2 changes, got %d, latest %d, reset %v", len(changes), latest, reset)
+	}
+	if changes[0].GPath != "/g/data/ab1" {
+		t.Errorf("expected a clean gpath, got %s", changes[0].GPath)
+	}
+
+	f.Publish("/g/data/ab3", "/g/data/ab4")
+	if _, _, reset = f.Since(0); !reset {
+		t.Errorf("expected a reset once changes are dropped from the history")
+	}
+	if changes, _, _ = f.Since(2); len(changes) != 2 || changes[0].Seq != 3 {
+		t.Errorf("expected changes 3 and 4, got %+v", changes)
+	}
+	if _, _, reset = f.Since(100); !reset {
+		t.Errorf("expected a reset for a sequence of an earlier run")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		f.Publish("/g/data/ab5")
+	}()
+	changes, latest, _ = f.Wait(context.Background(), 4, time.Minute)
+	if len(changes) != 1 || latest != 5 {
+		t.Errorf("expected waiting to return change 5, got %+v", changes)
+	}
+
+	recorder := httptest.NewRecorder()
+	f.ServeHTTP(recorder, httptest.NewRequest("GET", "/?changes&since=5&timeout=10ms", nil))
+	var result struct {
+		Seq     int64     `json:"seq"`
+		Changes []*Change `json:"changes"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Seq != 5 || len(result.Changes) != 0 {
+		t.Errorf("expected no changes after a timeout, got %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type point struct {
+	X, Y float64
+}
+
+type bbox struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+func emptyBBox() bbox {
+	return bbox{MinX: math.Inf(1), MinY: math.Inf(1), MaxX: math.Inf(-1), MaxY: math.Inf(-1)}
+}
+
+func (b *bbox) extend(x, y float64) {
+	b.MinX = math.Min(b.MinX, x)
+	b.MinY = math.Min(b.MinY, y)
+	b.MaxX = math.Max(b.MaxX, x)
+	b.MaxY = math.Max(b.MaxY, y)
+}
+
+func (b bbox) isEmpty() bool {
+	return b.MinX > b.MaxX || b.MinY > b.MaxY
+}
+
+var wktRingRe = regexp.MustCompile(`\(([^()]*)\)`)
+
+// parseWKTRings returns the coordinate sequences of a WKT geometry, e.g.
+// the rings of a POLYGON or MULTIPOLYGON
+func parseWKTRings(wkt string) ([][]point, error) {
+	var rings [][]point
+	for _, match := range wktRingRe.FindAllStringSubmatch(wkt, -1) {
+		var ring []point
+		for _, coord := range strings.Split(match[1], ",") {
+			fields := strings.Fields(coord)
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("invalid WKT coordinate: %q", coord)
+			}
+
+			x, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid WKT coordinate: %q", coord)
+			}
+			y, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid WKT coordinate: %q", coord)
+			}
+			ring = append(ring, point{X: x, Y: y})
+		}
+		rings = append(rings, ring)
+	}
+
+	if len(rings) == 0 {
+		return nil, fmt.Errorf("invalid WKT: %q", wkt)
+	}
+	return rings, nil
+}
+
+// segmentize adds vertices to the rings so that no edge is longer than
+// maxLength, so that the rings keep their shape after reprojection
+func segmentize(rings [][]point, maxLength float64) [][]point {
+	if !(maxLength > 0) {
+		return rings
+	}
+
+	out := make([][]point, len(rings))
+	for ir, ring := range rings {
+		for i, p := range ring {
+			if i > 0 {
+				prev := ring[i-1]
+				n := math.Ceil(math.Hypot(p.X-prev.X, p.Y-prev.Y) / maxLength)
+				for s := 1.0; s < n; s++ {
+					out[ir] = append(out[ir], point{X: prev.X + (p.X-prev.X)*s/n, Y: prev.Y + (p.Y-prev.Y)*s/n})
+				}
+			}
+			out[ir] = append(out[ir], p)
+		}
+	}
+	return out
+}
+
+// segmentizeN splits every edge of the rings into n segments
+func segmentizeN(rings [][]point, n int) [][]point {
+	maxLength := 0.0
+	for _, ring := range rings {
+		for i := 1; i < len(ring); i++ {
+			maxLength = math.Max(maxLength, math.Hypot(ring[i].X-ring[i-1].X, ring[i].Y-ring[i-1].Y))
+		}
+	}
+	return segmentize(rings, maxLength/float64(n))
+}
+
+func ringsBBox(rings [][]point) bbox {
+	box := emptyBBox()
+	for _, ring := range rings {
+		for _, p := range ring {
+			box.extend(p.X, p.Y)
+		}
+	}
+	return box
+}
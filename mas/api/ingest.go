@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const DefaultIngestBatchSize = 10000
+
+// TombstoneType is the metadata type of the records of files deleted
+// since an incremental crawl. Ingesting a tombstone deletes the records
+// of its path.
+const TombstoneType = "tombstone"
+
+// CrawlRecord is a gsky-crawl output record
+type CrawlRecord struct {
+	Path     string          `json:"path"`
+	Type     string          `json:"type"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// Shard is a collection of datasets under the same root gpath
+type Shard struct {
+	Code  string `json:"shard"`
+	GPath string `json:"gpath"`
+}
+
+// Ingester is implemented by backends which accept crawl records over
+// the API
+type Ingester interface {
+	CreateShard(code string, gpath string) (*Shard, error)
+
+	// ShardOf returns the shard containing gpath or nil if there is none
+	ShardOf(gpath string) (*Shard, error)
+
+	BeginIngest(shard *Shard) (IngestTx, error)
+
+	// Refresh makes the ingested records of a shard visible to queries
+	// and invalidates its cached responses
+	Refresh(shard *Shard) error
+}
+
+// IngestTx is a set of changes to a shard applied atomically on Commit
+type IngestTx interface {
+	// Delete deletes the records of gpath and of the paths below it
+	Delete(gpath string) (int64, error)
+	Ingest(records []*CrawlRecord) error
+	Commit() error
+	Rollback() error
+}
+
+var shardCodeRe = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// parseTSVRecord parses a <path>\t<metadata_type>\t<json> line
+func parseTSVRecord(line string) (*CrawlRecord, error) {
+	fields := strings.SplitN(strings.TrimRight(line, "\r\n"), "\t", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("expecting 3 tab separated columns")
+	}
+
+	return &CrawlRecord{
+		Path:     strings.TrimSpace(fields[0]),
+		Type:     strings.TrimSpace(fields[1]),
+		Metadata: json.RawMessage(fields[2]),
+	}, nil
+}
+
+// parseJSONRecord parses a CrawlRecord or a record of gsky-crawl raw
+// output, whose path and type are inferred from its fields
+func parseJSONRecord(line string) (*CrawlRecord, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return nil, err
+	}
+
+	rec := &CrawlRecord{}
+	if _, found := fields["metadata"]; found {
+		if err := json.Unmarshal([]byte(line), rec); err != nil {
+			return nil, err
+		}
+		return rec, nil
+	}
+
+	var pathField string
+	if _, found := fields["geo_metadata"]; found {
+		rec.Type = "gdal"
+		pathField = "filename"
+	} else if _, found := fields[TombstoneType]; found {
+		rec.Type = TombstoneType
+		pathField = "file_path"
+	} else if _, found := fields["inode"]; found {
+		rec.Type = "posix"
+		pathField = "file_path"
+	} else {
+		return nil, fmt.Errorf("unknown record format")
+	}
+
+	if err := json.Unmarshal(fields[pathField], &rec.Path); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", pathField, err)
+	}
+	rec.Metadata = json.RawMessage(line)
+	return rec, nil
+}
+
+// cleanGPath removes redundant slashes around a gpath
+func cleanGPath(gpath string) string {
+	return "/" + strings.Trim(gpath, "/")
+}
+
+// isUnderGPath reports whether path is root or below it, so that the
+// root /g/data/ab does not claim /g/data/abc
+func isUnderGPath(path string, root string) bool {
+	root = strings.TrimSuffix(root, "/")
+	return path == root || strings.HasPrefix(path, root+"/")
+}
+
+// validateRecord checks that a record can be ingested under gpath
+func validateRecord(rec *CrawlRecord, gpath string) error {
+	if !strings.HasPrefix(rec.Path, "/") {
+		return fmt.Errorf("path must be absolute: %q", rec.Path)
+	}
+	if len(rec.Type) == 0 || strings.ContainsAny(rec.Type, "\t\n") {
+		return fmt.Errorf("invalid metadata type: %q", rec.Type)
+	}
+	if !strings.HasPrefix(rec.Path, strings.TrimSuffix(gpath, "/")+"/") {
+		return fmt.Errorf("path is not under %s: %s", gpath, rec.Path)
+	}
+	if !utf8.Valid(rec.Metadata) {
+		return fmt.Errorf("metadata is not valid UTF-8")
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Metadata, &obj); err != nil {
+		return fmt.Errorf("metadata is not a JSON object: %v", err)
+	}
+	return nil
+}
+
+// readCrawlRecords reads TSV or JSON lines records and passes them to fn
+// in batches
+func readCrawlRecords(r io.Reader, jsonLines bool, batchSize int, fn func([]*CrawlRecord) error) (int, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultIngestBatchSize
+	}
+
+	parse := parseTSVRecord
+	if jsonLines {
+		parse = parseJSONRecord
+	}
+
+	var batch []*CrawlRecord
+	nLines := 0
+	nRecords := 0
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(strings.TrimSpace(line)) > 0 {
+			nLines++
+			rec, parseErr := parse(line)
+			if parseErr != nil {
+				return nRecords, fmt.Errorf("line %d: %v", nLines, parseErr)
+			}
+			batch = append(batch, rec)
+		}
+
+		if len(batch) >= batchSize || (err == io.EOF && len(batch) > 0) {
+			if fnErr := fn(batch); fnErr != nil {
+				return nRecords, fnErr
+			}
+			nRecords += len(batch)
+			batch = nil
+		}
+
+		if err == io.EOF {
+			return nRecords, nil
+		}
+		if err != nil {
+			return nRecords, err
+		}
+	}
+}
+
+// ReadIngestToken reads the bearer token of the ingestion API
+func ReadIngestToken(tokenFile string) (string, error) {
+	data, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(data))
+	if len(token) == 0 {
+		return "", fmt.Errorf("%s is empty", tokenFile)
+	}
+	return token, nil
+}
+
+// IngestHandler serves the ingestion API. Every request must carry the
+// ingestion token as a bearer token.
+type IngestHandler struct {
+	Ingester  Ingester
+	Jobs      *JobManager
+	Token     string
+	BatchSize int
+}
+
+// ingestOperations are the query operations served by IngestHandler
+var ingestOperations = []string{"create_shard", "ingest", "delete_records", "refresh", "jobs", "job"}
+
+func isIngestRequest(request *http.Request) bool {
+	query := request.URL.Query()
+	for _, op := range ingestOperations {
+		if _, found := query[op]; found {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *IngestHandler) authorized(request *http.Request) bool {
+	if len(h.Token) == 0 {
+		return false
+	}
+	expected := []byte("Bearer " + h.Token)
+	return subtle.ConstantTimeCompare([]byte(request.Header.Get("Authorization")), expected) == 1
+}
+
+func (h *IngestHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", "application/json")
+
+	if h == nil || h.Ingester == nil || len(h.Token) == 0 {
+		httpJSONError(response, errors.New("ingestion API is disabled"), http.StatusForbidden)
+		return
+	}
+
+	if !h.authorized(request) {
+		httpJSONError(response, errors.New("invalid ingestion token"), http.StatusUnauthorized)
+		return
+	}
+
+	query := request.URL.Query()
+	if _, ok := query["jobs"]; ok {
+		writeJSON(response, h.Jobs.List())
+		return
+	}
+
+	if _, ok := query["job"]; ok {
+		id, err := strconv.ParseInt(query.Get("id"), 10, 64)
+		if err != nil {
+			httpJSONError(response, fmt.Errorf("invalid job id"), http.StatusBadRequest)
+			return
+		}
+		job := h.Jobs.Get(id)
+		if job == nil {
+			httpJSONError(response, fmt.Errorf("job %d not found", id), http.StatusNotFound)
+			return
+		}
+		writeJSON(response, job)
+		return
+	}
+
+	if request.Method != http.MethodPost {
+		httpJSONError(response, errors.New("operation requires POST"), http.StatusMethodNotAllowed)
+		return
+	}
+
+	gpath := cleanGPath(request.URL.Path)
+
+	if _, ok := query["create_shard"]; ok {
+		code := query.Get("shard")
+		if !shardCodeRe.MatchString(code) {
+			httpJSONError(response, fmt.Errorf("invalid shard code: %q", code), http.StatusBadRequest)
+			return
+		}
+		if gpath == "/" {
+			httpJSONError(response, fmt.Errorf("invalid shard gpath"), http.StatusBadRequest)
+			return
+		}
+
+		shard, err := h.Ingester.CreateShard(code, gpath)
+		if err != nil {
+			httpJSONError(response, err, http.StatusConflict)
+			return
+		}
+		writeJSON(response, shard)
+		return
+	}
+
+	shard, err := h.Ingester.ShardOf(gpath)
+	if err != nil {
+		httpJSONError(response, err, http.StatusInternalServerError)
+		return
+	}
+	if shard == nil {
+		httpJSONError(response, fmt.Errorf("no shard contains %s", gpath), http.StatusNotFound)
+		return
+	}
+
+	if _, ok := query["ingest"]; ok {
+		h.ingest(response, request, shard, gpath)
+		return
+	}
+
+	if _, ok := query["refresh"]; ok {
+		var delay time.Duration
+		if len(query.Get("delay")) > 0 {
+			delay, err = time.ParseDuration(query.Get("delay"))
+			if err != nil {
+				httpJSONError(response, fmt.Errorf("invalid delay: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		writeJSON(response, h.Jobs.ScheduleRefresh(shard, delay))
+		return
+	}
+
+	if _, ok := query["delete_records"]; ok {
+		var deleted int64
+		err := h.apply(shard, func(tx IngestTx) error {
+			var err error
+			deleted, err = tx.Delete(gpath)
+			return err
+		})
+		if err != nil {
+			httpJSONError(response, err, http.StatusBadRequest)
+			return
+		}
+		if deleted > 0 {
+			h.Jobs.MarkChanged(shard, gpath)
+		}
+		writeJSON(response, map[string]interface{}{"shard": shard.Code, "deleted": deleted})
+		return
+	}
+}
+
+// ingest ingests the records in the request body under gpath. With
+// the replace parameter, the existing records under gpath are deleted
+// first.
+func (h *IngestHandler) ingest(response http.ResponseWriter, request *http.Request, shard *Shard, gpath string) {
+	// The body is read as records, never as a form
+	query := request.URL.Query()
+	jsonLines := strings.Contains(request.Header.Get("Content-Type"), "json")
+	switch query.Get("format") {
+	case "tsv":
+		jsonLines = false
+	case "jsonl":
+		jsonLines = true
+	case "":
+	default:
+		httpJSONError(response, fmt.Errorf("unknown format: %s", query.Get("format")), http.StatusBadRequest)
+		return
+	}
+
+	_, replace := query["replace"]
+
+	var deleted int64
+	var nRecords int
+	err := h.apply(shard, func(tx IngestTx) error {
+		var err error
+		if replace {
+			deleted, err = tx.Delete(gpath)
+			if err != nil {
+				return err
+			}
+		}
+
+		nRecords, err = readCrawlRecords(request.Body, jsonLines, h.BatchSize, func(records []*CrawlRecord) error {
+			for _, rec := range records {
+				if err := validateRecord(rec, gpath); err != nil {
+					return err
+				}
+			}
+			return tx.Ingest(records)
+		})
+		return err
+	})
+	if err != nil {
+		httpJSONError(response, err, http.StatusBadRequest)
+		return
+	}
+
+	result := map[string]interface{}{"shard": shard.Code, "records": nRecords, "deleted": deleted}
+	if nRecords > 0 || deleted > 0 {
+		h.Jobs.MarkChanged(shard, gpath)
+		if _, ok := query["refresh"]; ok {
+			result["job"] = h.Jobs.ScheduleRefresh(shard, 0)
+		}
+	}
+	writeJSON(response, result)
+}
+
+// apply runs fn in an IngestTx of the shard and commits it if fn
+// succeeds
+func (h *IngestHandler) apply(shard *Shard, fn func(IngestTx) error) error {
+	tx, err := h.Ingester.BeginIngest(shard)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func writeJSON(response http.ResponseWriter, v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		httpJSONError(response, err, http.StatusInternalServerError)
+		return
+	}
+	response.Write(payload)
+}
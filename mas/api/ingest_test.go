@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCrawlRecords(t *testing.T) {
+	testCases := []struct {
+		line      string
+		jsonLines bool
+		path      string
+		mdType    string
+	}{
+		{"/g/data/a.nc\tgdal\t{\"filename\":\"/g/data/a.nc\"}", false, "/g/data/a.nc", "gdal"},
+		{`{"path":"/g/data/b.nc","type":"gdal","metadata":{"geo_metadata":[]}}`, true, "/g/data/b.nc", "gdal"},
+		{`{"filename":"/g/data/c.nc","file_type":"netCDF","geo_metadata":[]}`, true, "/g/data/c.nc", "gdal"},
+		{`{"file_path":"/g/data/d","inode":12,"size":0}`, true, "/g/data/d", "posix"},
+		{`{"file_path":"/g/data/e.nc","tombstone":true,"deleted":"2020-01-01T00:00:00Z"}`, true, "/g/data/e.nc", "tombstone"},
+		{"/g/data/f.nc\ttombstone\t{\"file_path\":\"/g/data/f.nc\",\"tombstone\":true}", false, "/g/data/f.nc", "tombstone"},
+	}
+
+	for _, tc := range testCases {
+		var records []*CrawlRecord
+		n, err := readCrawlRecords(strings.NewReader(tc.line+"\n\n"), tc.jsonLines, 0, func(batch []*CrawlRecord) error {
+			records = append(records, batch...)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: %v", tc.line, err)
+			continue
+		}
+		if n != 1 || records[0].Path != tc.path || records[0].Type != tc.mdType {
+			t.Errorf("%s: unexpected records %+v", tc.line, records)
+			continue
+		}
+		if err = validateRecord(records[0], "/g/data"); err != nil {
+			t.Errorf("%s: %v", tc.line, err)
+		}
+	}
+
+	if err := validateRecord(&CrawlRecord{Path: "/g/other/a.nc", Type: "gdal", Metadata: json.RawMessage(`{}`)}, "/g/data"); err == nil {
+		t.Errorf("expected an error for a record outside the gpath")
+	}
+	if err := validateRecord(&CrawlRecord{Path: "/g/data/a.nc", Type: "gdal", Metadata: json.RawMessage(`[]`)}, "/g/data"); err == nil {
+		t.Errorf("expected an error for metadata which is not an object")
+	}
+}
+
+func TestIsUnderGPath(t *testing.T) {
+	testCases := []struct {
+		path  string
+		root  string
+		under bool
+	}{
+		{"/g/data/ab", "/g/data/ab", true},
+		{"/g/data/ab/c.nc", "/g/data/ab/", true},
+		{"/g/data/abc/c.nc", "/g/data/ab", false},
+		{"/g/data/ab", "/", true},
+	}
+
+	for _, tc := range testCases {
+		if isUnderGPath(tc.path, tc.root) != tc.under {
+			t.Errorf("%s under %s: expected %v", tc.path, tc.root, tc.under)
+		}
+	}
+}
+
+func TestJobManagerMergesQueuedRefreshes(t *testing.T) {
+	m := NewJobManager(nil)
+	shard := &Shard{Code: "ab1", GPath: "/g/data/ab1"}
+
+	first := m.ScheduleRefresh(shard, time.Hour)
+	second := m.ScheduleRefresh(shard, time.Hour)
+	if first.ID != second.ID {
+		t.Errorf("expected queued refreshes to be merged, got jobs %d and %d", first.ID, second.ID)
+	}
+	if len(m.List()) != 1 {
+		t.Errorf("expected 1 job, got %d", len(m.List()))
+	}
+}
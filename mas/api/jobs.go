@@ -0,0 +1,240 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Number of finished jobs kept for status queries
+const DefaultJobHistory = 100
+
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Job is a refresh of a shard. Jobs are copied when reported so that
+// their status can be read without locking.
+type Job struct {
+	ID        int64      `json:"id"`
+	Shard     *Shard     `json:"shard"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	Scheduled time.Time  `json:"scheduled"`
+	Started   *time.Time `json:"started,omitempty"`
+	Finished  *time.Time `json:"finished,omitempty"`
+}
+
+// JobManager runs the refresh jobs of the ingestion API. Refreshes of
+// the same shard run one at a time and a refresh requested while another
+// is queued is merged into the queued one.
+type JobManager struct {
+	Ingester Ingester
+
+	// Changes announces the changed gpaths of every refreshed shard
+	Changes *ChangeFeed
+
+	mutex   sync.Mutex
+	nextID  int64
+	jobs    []*Job
+	queued  map[string]*Job
+	locks   map[string]*sync.Mutex
+	changed map[string]*Shard
+	pending map[string][]string
+}
+
+func NewJobManager(ingester Ingester) *JobManager {
+	return &JobManager{
+		Ingester: ingester,
+		queued:   make(map[string]*Job),
+		locks:    make(map[string]*sync.Mutex),
+		changed:  make(map[string]*Shard),
+		pending:  make(map[string][]string),
+	}
+}
+
+// ScheduleRefresh schedules a refresh of the shard after delay
+func (m *JobManager) ScheduleRefresh(shard *Shard, delay time.Duration) *Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	scheduled := time.Now().Add(delay)
+	if job, found := m.queued[shard.GPath]; found {
+		if scheduled.Before(job.Scheduled) {
+			job.Scheduled = scheduled
+			m.startAt(job)
+		}
+		copied := *job
+		return &copied
+	}
+
+	m.nextID++
+	job := &Job{ID: m.nextID, Shard: shard, Status: JobQueued, Scheduled: scheduled}
+	m.jobs = append(m.jobs, job)
+	m.queued[shard.GPath] = job
+	m.trimHistory()
+	m.startAt(job)
+
+	copied := *job
+	return &copied
+}
+
+func (m *JobManager) startAt(job *Job) {
+	scheduled := job.Scheduled
+	time.AfterFunc(time.Until(scheduled), func() {
+		m.mutex.Lock()
+		// The job was rescheduled or has already started
+		if job.Status != JobQueued || !job.Scheduled.Equal(scheduled) {
+			m.mutex.Unlock()
+			return
+		}
+		lock, found := m.locks[job.Shard.GPath]
+		if !found {
+			lock = &sync.Mutex{}
+			m.locks[job.Shard.GPath] = lock
+		}
+		m.mutex.Unlock()
+
+		lock.Lock()
+		defer lock.Unlock()
+		m.run(job)
+	})
+}
+
+func (m *JobManager) run(job *Job) {
+	m.mutex.Lock()
+	if job.Status != JobQueued {
+		m.mutex.Unlock()
+		return
+	}
+	started := time.Now()
+	job.Status = JobRunning
+	job.Started = &started
+	delete(m.queued, job.Shard.GPath)
+	delete(m.changed, job.Shard.GPath)
+	gpaths := m.pending[job.Shard.GPath]
+	delete(m.pending, job.Shard.GPath)
+	m.mutex.Unlock()
+
+	err := m.Ingester.Refresh(job.Shard)
+
+	m.mutex.Lock()
+	finished := time.Now()
+	job.Finished = &finished
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+		log.Printf("refresh %s failed: %v", job.Shard.GPath, err)
+
+		// The changes are announced by the next refresh
+		if len(gpaths) > 0 {
+			m.changed[job.Shard.GPath] = job.Shard
+			m.pending[job.Shard.GPath] = append(gpaths, m.pending[job.Shard.GPath]...)
+		}
+		m.mutex.Unlock()
+		return
+	}
+	job.Status = JobDone
+	log.Printf("refresh %s done in %v", job.Shard.GPath, finished.Sub(started))
+	m.mutex.Unlock()
+
+	if m.Changes != nil {
+		// A refresh requested without changes through the API may
+		// follow changes made by the ingestion scripts
+		if len(gpaths) == 0 {
+			gpaths = []string{job.Shard.GPath}
+		}
+		m.Changes.Publish(gpaths...)
+	}
+}
+
+// trimHistory drops the oldest finished jobs beyond DefaultJobHistory
+func (m *JobManager) trimHistory() {
+	for len(m.jobs) > DefaultJobHistory {
+		idx := -1
+		for i, job := range m.jobs {
+			if job.Status == JobDone || job.Status == JobFailed {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return
+		}
+		m.jobs = append(m.jobs[:idx], m.jobs[idx+1:]...)
+	}
+}
+
+// MarkChanged records that a shard has records under gpaths not yet
+// refreshed
+func (m *JobManager) MarkChanged(shard *Shard, gpaths ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.changed[shard.GPath] = shard
+
+	pending := m.pending[shard.GPath]
+	for _, gpath := range gpaths {
+		found := false
+		for _, p := range pending {
+			if p == gpath {
+				found = true
+				break
+			}
+		}
+		if !found {
+			pending = append(pending, gpath)
+		}
+	}
+	m.pending[shard.GPath] = pending
+}
+
+// RefreshChanged schedules a refresh of every changed shard
+func (m *JobManager) RefreshChanged() {
+	m.mutex.Lock()
+	var shards []*Shard
+	for _, shard := range m.changed {
+		shards = append(shards, shard)
+	}
+	m.mutex.Unlock()
+
+	for _, shard := range shards {
+		m.ScheduleRefresh(shard, 0)
+	}
+}
+
+// AutoRefresh refreshes the changed shards every interval
+func (m *JobManager) AutoRefresh(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			m.RefreshChanged()
+		}
+	}()
+}
+
+func (m *JobManager) Get(id int64) *Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, job := range m.jobs {
+		if job.ID == id {
+			copied := *job
+			return &copied
+		}
+	}
+	return nil
+}
+
+func (m *JobManager) List() []*Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	jobs := make([]*Job, len(m.jobs))
+	for i, job := range m.jobs {
+		copied := *job
+		jobs[i] = &copied
+	}
+	return jobs
+}
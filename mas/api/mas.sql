@@ -205,6 +205,8 @@ $$;
 -- filtered by time, namespace (netcdf variable), etc.
 -- Include raw metadata from crawlers for each matched file, if requested.
 
+drop function if exists mas_intersects(text, text, text, integer, timestamptz, timestamptz, text[], text, float8, float, integer);
+
 create or replace function mas_intersects(
   gpath      text,
   srs        text, -- EPSG:nnnn
@@ -216,7 +218,8 @@ create or replace function mas_intersects(
   raw_metadata text, -- gdal, pdal
   identity_tol float8, -- distance tolerance considered as same point
   dp_tol       float, -- distance tolerance for Douglas-Peucker algorithm
-  limit_val    integer -- limit on number of query rows
+  limit_val    integer, -- limit on number of files
+  offset_val   integer -- number of files skipped, in the order of their hashes
 )
   returns jsonb language plpgsql as $$
   declare
@@ -304,11 +307,15 @@ create or replace function mas_intersects(
       limit_val := null;
     end if;
 
+    if offset_val < 0 then
+      raise exception 'invalid offset';
+    end if;
+
     if raw_metadata = 'gdal' then
       if segmask is not null then
-        return shard_intersect_polygons(gpath, segmask, namespace, time_a, time_b, limit_val);
+        return shard_intersect_polygons(gpath, segmask, namespace, time_a, time_b, limit_val, offset_val);
       else
-        return shard_intersect_times(gpath, namespace, time_a, time_b, limit_val);
+        return shard_intersect_times(gpath, namespace, time_a, time_b, limit_val, offset_val);
       end if;
     end if;
 
@@ -349,7 +356,9 @@ create or replace function codegen_shard_intersect_times()
           or po_name = any(namespaces)
         )
         and path_hash(gpath) = any(pa_parents)
+        order by po_hash
         limit limit_val
+        offset offset_val
 
       $f$;
 
@@ -362,7 +371,8 @@ create or replace function codegen_shard_intersect_times()
           namespaces text[],
           time_a timestamptz,
           time_b timestamptz,
-          limit_val integer
+          limit_val integer,
+          offset_val integer
       )
         returns jsonb language plpgsql as $ff$
         begin
@@ -388,7 +398,7 @@ create or replace function codegen_shard_intersect_polygons()
 
       parts := array_append(parts, format($f$
         (
-        select
+        select distinct
           po_hash
         from
           polygons
@@ -422,7 +432,8 @@ create or replace function codegen_shard_intersect_polygons()
             or namespaces is null
           )
           and path_hash(gpath) = any(pa_parents)
-          limit limit_val )
+          order by po_hash
+          limit limit_val + coalesce(offset_val, 0) )
 
         $f$, rec.srid
 
@@ -449,7 +460,7 @@ create or replace function codegen_shard_intersect_polygons()
           polygon_srids
       )
       select po_hash
-      from (select po_hash as po_hash from (%1$s) u limit limit_val) hashes
+      from (select po_hash as po_hash from (%1$s) u order by po_hash limit limit_val offset offset_val) hashes
 
       $f$, qstr
     );
@@ -464,7 +475,8 @@ create or replace function codegen_shard_intersect_polygons()
           namespaces text[],
           time_a timestamptz,
           time_b timestamptz,
-          limit_val integer
+          limit_val integer,
+          offset_val integer
       )
         returns jsonb language plpgsql as $ff$
         begin
@@ -523,6 +535,14 @@ create or replace function codegen_gdal_json()
               geo->'sample_counts',
               'nodata',
               geo->'nodata',
+              'scale_factor',
+              geo->'scale_factor',
+              'add_offset',
+              geo->'add_offset',
+              'units',
+              geo->'units',
+              'valid_range',
+              geo->'valid_range',
               'axes',
               geo->'axes',
               'geo_loc',
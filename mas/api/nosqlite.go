@@ -0,0 +1,12 @@
+//go:build !sqlite
+// +build !sqlite
+
+package main
+
+import "fmt"
+
+// The SQLite backend reprojects footprints with GDAL, so it is only built
+// with the sqlite tag to keep the Postgres-only server free of cgo GDAL
+func openSQLiteBackend(dbFile string, limit int, ingestFiles string) (Backend, error) {
+	return nil, fmt.Errorf("masapi was built without the sqlite tag")
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// PostgresBackend serves the MAS API from the PostGIS stored procedures
+// in mas.sql
+type PostgresBackend struct {
+	db *sql.DB
+
+	// Connection of the ingestion API
+	ingestDB *sql.DB
+	shardSQL string
+
+	listener *pq.Listener
+}
+
+func NewPostgresBackend(host, name, user string, pool, limit int) (*PostgresBackend, error) {
+	dbinfo := fmt.Sprintf("user=%s host=%s dbname=%s sslmode=disable", user, host, name)
+
+	db, err := sql.Open("postgres", dbinfo)
+	if err != nil {
+		return nil, err
+	}
+
+	// sql.Open() does lazy evaluation. Here we do some simple
+	// test to assert if connection is okay.
+	var payload string
+	err = db.QueryRow("select true").Scan(&payload)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	db.SetMaxIdleConns(pool)
+	db.SetMaxOpenConns(limit)
+
+	return &PostgresBackend{db: db}, nil
+}
+
+func (b *PostgresBackend) query(stmt string, args ...interface{}) (string, error) {
+	var payload string
+	err := b.db.QueryRow(stmt, args...).Scan(&payload)
+	return payload, err
+}
+
+// Use Postgres prepared statements and placeholders for input checks.
+// The nullif() noise is to coerce Go's empty string zero values for
+// missing parameters into proper null arguments.
+// The string_to_array() call will return null in the case of a null
+// argument, rather than array[] or array[null].
+
+func (b *PostgresBackend) Intersects(q *Query) (string, error) {
+	return b.query(
+		`select mas_intersects(
+			nullif($1,'')::text,
+			nullif($2,'')::text,
+			nullif($3,'')::text,
+			nullif($4,'')::integer,
+			nullif($5,'')::timestamptz,
+			nullif($6,'')::timestamptz,
+			string_to_array(nullif($7,''), ','),
+			nullif($8,'')::text,
+			nullif($9,'')::float8,
+			nullif($10,'')::float,
+			nullif($11,'')::int,
+			nullif($12,'')::int
+		) as json`,
+		q.GPath,
+		q.SRS,
+		q.WKT,
+		q.NSeg,
+		q.Time,
+		q.Until,
+		q.Namespace,
+		q.Metadata,
+		q.IdentityTol,
+		q.DpTol,
+		q.Limit,
+		q.Offset,
+	)
+}
+
+func (b *PostgresBackend) Timestamps(q *Query) (string, error) {
+	return b.query(
+		`select mas_timestamps(
+			nullif($1,'')::text,
+			nullif($2,'')::timestamptz,
+			nullif($3,'')::timestamptz,
+			string_to_array(nullif($4,''), ','),
+			nullif($5,'')::text
+		) as json`,
+		q.GPath,
+		q.Time,
+		q.Until,
+		q.Namespace,
+		q.Token,
+	)
+}
+
+func (b *PostgresBackend) Extents(q *Query) (string, error) {
+	return b.query(
+		`select mas_spatial_temporal_extents(
+			nullif($1,'')::text,
+			string_to_array(nullif($2,''), ',')
+		) as json`,
+		q.GPath,
+		q.Namespace,
+	)
+}
+
+func (b *PostgresBackend) ListRootGPath() (string, error) {
+	return b.query(`select mas_list_root_gpath() as json`)
+}
+
+func (b *PostgresBackend) ListSubGPath(q *Query) (string, error) {
+	return b.query(
+		`select mas_list_sub_gpath(
+			nullif($1,'')::text
+		) as json`,
+		q.GPath,
+	)
+}
+
+func (b *PostgresBackend) GenerateLayers(q *Query) (string, error) {
+	return b.query(
+		`select mas_generate_layers(
+			nullif($1,'')::text
+		) as json`,
+		q.GPath,
+	)
+}
+
+func (b *PostgresBackend) PutOWSCache(q *Query) (string, error) {
+	return b.query(
+		`select mas_put_ows_cache(
+			nullif($1,'')::text,
+			nullif($2,'')::text,
+			nullif($3,'')::jsonb
+		) as json`,
+		q.GPath,
+		q.Key,
+		q.Value,
+	)
+}
+
+func (b *PostgresBackend) GetOWSCache(q *Query) (string, error) {
+	return b.query(
+		`select mas_get_ows_cache(
+			nullif($1,'')::text,
+			nullif($2,'')::text
+		) as json`,
+		q.GPath,
+		q.Key,
+	)
+}
+
+func (b *PostgresBackend) Close() error {
+	if b.ingestDB != nil {
+		b.ingestDB.Close()
+	}
+	if b.listener != nil {
+		b.listener.Close()
+	}
+	return b.db.Close()
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/lib/pq"
+)
+
+// OpenIngestDB connects the user who owns the shard schemas, which is
+// needed by the ingestion API. sqlDir is the directory of shard.sql.
+func (b *PostgresBackend) OpenIngestDB(host, name, user string, sqlDir string) error {
+	shardSQL, err := ioutil.ReadFile(filepath.Join(sqlDir, "shard.sql"))
+	if err != nil {
+		return err
+	}
+
+	dbinfo := fmt.Sprintf("user=%s host=%s dbname=%s sslmode=disable", user, host, name)
+	db, err := sql.Open("postgres", dbinfo)
+	if err != nil {
+		return err
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return err
+	}
+
+	b.ingestDB = db
+	b.shardSQL = string(shardSQL)
+	return nil
+}
+
+func (b *PostgresBackend) CreateShard(code string, gpath string) (*Shard, error) {
+	shard, err := b.ShardOf(gpath)
+	if err != nil {
+		return nil, err
+	}
+	if shard != nil {
+		if shard.Code == code && shard.GPath == gpath {
+			return nil, fmt.Errorf("shard '%s' existed", code)
+		}
+		return nil, fmt.Errorf("%s belongs to shard '%s' at %s", gpath, shard.Code, shard.GPath)
+	}
+
+	tx, err := b.ingestDB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	schema := pq.QuoteIdentifier(code)
+	stmts := []string{
+		fmt.Sprintf(`create schema %s`, schema),
+		fmt.Sprintf(`set local search_path to %s`, schema),
+		fmt.Sprintf(`grant usage on schema %s to public`, schema),
+		fmt.Sprintf(`alter default privileges for role mas in schema %s grant select on tables to public`, schema),
+		b.shardSQL,
+		fmt.Sprintf(`grant select,insert,update on %s.ows_cache to api`, schema),
+	}
+	for _, stmt := range stmts {
+		if _, err = tx.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("create shard '%s': %v", code, err)
+		}
+	}
+
+	_, err = tx.Exec(`insert into public.shards (sh_code, sh_path) values ($1, $2)`, code, gpath)
+	if err != nil {
+		return nil, fmt.Errorf("create shard '%s': %v", code, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &Shard{Code: code, GPath: gpath}, nil
+}
+
+func (b *PostgresBackend) ShardOf(gpath string) (*Shard, error) {
+	shard := &Shard{}
+	err := b.db.QueryRow(`select sh_code, sh_path from public.shards
+		where $1 = sh_path or $1 like concat(rtrim(sh_path, '/'), '/%') order by length(sh_path) desc limit 1`, gpath).Scan(&shard.Code, &shard.GPath)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return shard, nil
+}
+
+type postgresIngestTx struct {
+	tx *sql.Tx
+}
+
+// BeginIngest writes to the shard directly. As with ingest.sh, the
+// records are only visible to queries after a refresh.
+func (b *PostgresBackend) BeginIngest(shard *Shard) (IngestTx, error) {
+	tx, err := b.ingestDB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`set local search_path to %s, public`, pq.QuoteIdentifier(shard.Code)))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return &postgresIngestTx{tx: tx}, nil
+}
+
+func (t *postgresIngestTx) Delete(gpath string) (int64, error) {
+	var deleted int64
+	err := t.tx.QueryRow(`
+		with deleted as (
+			delete from paths
+			where pa_path = $1 or public.path_hash($1) = any(pa_parents)
+			returning pa_hash
+		), deleted_metadata as (
+			delete from metadata
+			where md_hash in (select pa_hash from deleted)
+		)
+		select count(*) from deleted`, gpath).Scan(&deleted)
+	return deleted, err
+}
+
+// Ingest copies the records into the ingest table of the shard. Its
+// triggers parse the records in bulk at the end of each copy.
+func (t *postgresIngestTx) Ingest(records []*CrawlRecord) error {
+	stmt, err := t.tx.Prepare(pq.CopyIn("ingest", "in_path", "in_type", "in_json"))
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range records {
+		_, err = stmt.Exec(rec.Path, rec.Type, string(rec.Metadata))
+		if err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+
+	if _, err = stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	return stmt.Close()
+}
+
+func (t *postgresIngestTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *postgresIngestTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// Refresh rebuilds the materialized views of the shard as
+// shard_refresh.sh does, albeit in place
+func (b *PostgresBackend) Refresh(shard *Shard) error {
+	ctx := context.Background()
+	conn, err := b.ingestDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		fmt.Sprintf(`set local search_path to %s, public`, pq.QuoteIdentifier(shard.Code)),
+		`select refresh_views()`,
+		`select refresh_polygons()`,
+		`select refresh_caches()`,
+		`select refresh_codegens()`,
+	}
+	for _, stmt := range stmts {
+		if _, err = tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	// refresh_polygons() tunes the session for parallel queries
+	_, err = conn.ExecContext(ctx, `discard all`)
+	return err
+}
@@ -0,0 +1,811 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// The SQLite index keeps the bounding box of every dataset in EPSG:4326
+// in an R*Tree. Intersection is evaluated between the bounding boxes of
+// the datasets and of the query polygon.
+const sqliteSchema = `
+create table if not exists roots (
+  ro_path text primary key,
+  ro_code text unique
+);
+
+create table if not exists metadata (
+  md_path text not null,
+  md_parent text not null,
+  md_type text not null,
+  md_json text not null,
+  primary key (md_path, md_type)
+);
+
+create table if not exists polygons (
+  po_id integer primary key,
+  po_path text not null,
+  po_parent text not null,
+  po_name text,
+  po_min_stamp integer,
+  po_max_stamp integer,
+  po_axes text
+);
+
+create index if not exists poi_path on polygons (po_path);
+create index if not exists poi_parent on polygons (po_parent);
+create index if not exists poi_name on polygons (po_name);
+
+create virtual table if not exists polygons_rtree using rtree (
+  po_id, xmin, xmax, ymin, ymax
+);
+
+create table if not exists stamps (
+  st_id integer not null,
+  st_stamp integer not null
+);
+
+create index if not exists sti_id on stamps (st_id, st_stamp);
+create index if not exists sti_stamp on stamps (st_stamp);
+
+create table if not exists ows_cache (
+  query_id text primary key,
+  value text not null
+);
+`
+
+const epsg4326 = "EPSG:4326"
+const epsg3857 = "EPSG:3857"
+
+// Latitude bounds of EPSG:3857
+const maxMercatorLat = 85.0511287798
+
+// SQLiteBackend serves the MAS API from a single SQLite file populated
+// from gsky-crawl outputs. It needs no database server.
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+func NewSQLiteBackend(path string, limit int) (*SQLiteBackend, error) {
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=10000", path)
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %v", err)
+	}
+
+	db.SetMaxOpenConns(limit)
+
+	return &SQLiteBackend{db: db}, nil
+}
+
+func (b *SQLiteBackend) Close() error {
+	return b.db.Close()
+}
+
+// gpathRange returns the bounds of the paths below gpath in lexical order
+func gpathRange(gpath string) (string, string) {
+	prefix := strings.TrimSuffix(gpath, "/") + "/"
+	return prefix, prefix[:len(prefix)-1] + "0"
+}
+
+// parentDir returns the directory of a path
+func parentDir(path string) string {
+	idx := strings.LastIndex(path, "/")
+	if idx <= 0 {
+		return "/"
+	}
+	return path[:idx]
+}
+
+// rootOf returns the root the gpath belongs to or an empty string if
+// nothing has been ingested under the gpath
+func (b *SQLiteBackend) rootOf(gpath string) (string, error) {
+	shard, err := b.ShardOf(gpath)
+	if err != nil || shard == nil {
+		return "", err
+	}
+	return shard.GPath, nil
+}
+
+// parseStamp parses a timestamp parameter into microseconds since the
+// epoch
+func parseStamp(value string) (int64, error) {
+	layouts := []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t.UnixNano() / 1000, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid timestamp: %s", value)
+}
+
+func stampTime(stamp int64) time.Time {
+	return time.Unix(stamp/1000000, (stamp%1000000)*1000).UTC()
+}
+
+func splitNamespaces(namespace string) []string {
+	if len(namespace) == 0 {
+		return nil
+	}
+	return strings.Split(namespace, ",")
+}
+
+// namespaceClause returns an SQL condition restricting po_name to the
+// namespaces along with its arguments
+func namespaceClause(namespaces []string) (string, []interface{}) {
+	if len(namespaces) == 0 {
+		return "", nil
+	}
+
+	var args []interface{}
+	for _, ns := range namespaces {
+		args = append(args, ns)
+	}
+	return fmt.Sprintf(" and po_name in (%s)", strings.TrimSuffix(strings.Repeat("?,", len(namespaces)), ",")), args
+}
+
+// md5UUID hashes a string the same way as md5(str)::uuid in Postgres
+func md5UUID(str string) string {
+	sum := md5.Sum([]byte(str))
+	h := hex.EncodeToString(sum[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func marshalPayload(v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// queryBBox computes the EPSG:4326 bounding box of the polygon of an
+// intersects query
+func queryBBox(q *Query) (bbox, error) {
+	rings, err := parseWKTRings(q.WKT)
+	if err != nil {
+		return bbox{}, fmt.Errorf("invalid wkt from user inputs")
+	}
+
+	nSeg := 2
+	if len(q.NSeg) > 0 {
+		nSeg, err = strconv.Atoi(q.NSeg)
+		if err != nil {
+			return bbox{}, fmt.Errorf("invalid nseg: %s", q.NSeg)
+		}
+	}
+
+	// Intersection occurs in EPSG:4326. Make sure the bounding box
+	// covers roughly the same area after transformation.
+	box := ringsBBox(rings)
+	if nSeg > 0 {
+		rings = segmentize(rings, math.Ceil((box.MaxX-box.MinX)/float64(nSeg)))
+	}
+
+	trans, err := newCoordTransform(q.SRS, epsg4326)
+	if err != nil {
+		return bbox{}, fmt.Errorf("unknown SRS")
+	}
+	defer trans.Close()
+
+	return trans.transformBBox(rings), nil
+}
+
+type gdalFile struct {
+	FileName *string                      `json:"filename"`
+	DataSets []map[string]json.RawMessage `json:"geo_metadata"`
+}
+
+type gdalDataset struct {
+	FilePath     *string         `json:"file_path"`
+	DataSetName  json.RawMessage `json:"ds_name"`
+	NameSpace    *string         `json:"namespace"`
+	ArrayType    json.RawMessage `json:"array_type"`
+	SRS          json.RawMessage `json:"srs"`
+	GeoTransform json.RawMessage `json:"geo_transform"`
+	TimeStamps   json.RawMessage `json:"timestamps"`
+	Polygon      json.RawMessage `json:"polygon"`
+	Overviews    json.RawMessage `json:"overviews"`
+	Means        json.RawMessage `json:"means"`
+	SampleCounts json.RawMessage `json:"sample_counts"`
+	NoData       json.RawMessage `json:"nodata"`
+	ScaleFactor  json.RawMessage `json:"scale_factor"`
+	AddOffset    json.RawMessage `json:"add_offset"`
+	Units        json.RawMessage `json:"units"`
+	ValidRange   json.RawMessage `json:"valid_range"`
+	Axes         json.RawMessage `json:"axes"`
+	GeoLocation  json.RawMessage `json:"geo_loc"`
+}
+
+func (b *SQLiteBackend) Intersects(q *Query) (string, error) {
+	if len(q.GPath) == 0 {
+		return "", fmt.Errorf("invalid search path")
+	}
+
+	type gdalResult struct {
+		Datasets []*gdalDataset `json:"gdal"`
+	}
+	result := &gdalResult{Datasets: []*gdalDataset{}}
+
+	root, err := b.rootOf(q.GPath)
+	if err != nil {
+		return "", err
+	}
+	if len(root) == 0 {
+		return marshalPayload(result)
+	}
+
+	if q.Metadata != "gdal" {
+		return "null", nil
+	}
+
+	lo, hi := gpathRange(q.GPath)
+	stmt := `select distinct po_path from polygons po`
+	args := []interface{}{}
+
+	if len(q.SRS) > 0 && len(q.WKT) > 0 {
+		box, err := queryBBox(q)
+		if err != nil {
+			return "", err
+		}
+		if box.isEmpty() {
+			return marshalPayload(result)
+		}
+
+		stmt += ` inner join polygons_rtree rt on rt.po_id = po.po_id
+			and rt.xmax >= ? and rt.xmin <= ? and rt.ymax >= ? and rt.ymin <= ?`
+		args = append(args, box.MinX, box.MaxX, box.MinY, box.MaxY)
+	}
+
+	stmt += ` where po_path > ? and po_path < ?`
+	args = append(args, lo, hi)
+
+	if len(q.Time) > 0 {
+		timeA, err := parseStamp(q.Time)
+		if err != nil {
+			return "", err
+		}
+
+		if len(q.Until) > 0 {
+			timeB, err := parseStamp(q.Until)
+			if err != nil {
+				return "", err
+			}
+			stmt += ` and po_min_stamp <= ? and po_max_stamp >= ?`
+			args = append(args, timeB+1000000, timeA-1000000)
+		} else {
+			stmt += ` and exists (select 1 from stamps where st_id = po.po_id and st_stamp = ?)`
+			args = append(args, timeA)
+		}
+	}
+
+	namespaces := splitNamespaces(q.Namespace)
+	nsClause, nsArgs := namespaceClause(namespaces)
+	stmt += nsClause
+	args = append(args, nsArgs...)
+
+	limit := -1
+	if len(q.Limit) > 0 {
+		limit, err = strconv.Atoi(q.Limit)
+		if err != nil {
+			return "", fmt.Errorf("invalid limit: %s", q.Limit)
+		}
+		if limit <= 0 {
+			limit = -1
+		}
+	}
+	offset := 0
+	if len(q.Offset) > 0 {
+		offset, err = strconv.Atoi(q.Offset)
+		if err != nil || offset < 0 {
+			return "", fmt.Errorf("invalid offset: %s", q.Offset)
+		}
+	}
+	stmt += ` order by po_path limit ? offset ?`
+	args = append(args, limit, offset)
+
+	rows, err := b.db.Query(stmt, args...)
+	if err != nil {
+		return "", err
+	}
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return "", err
+		}
+		paths = append(paths, path)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	nsFilter := make(map[string]bool)
+	for _, ns := range namespaces {
+		nsFilter[ns] = true
+	}
+
+	for _, path := range paths {
+		var mdJSON string
+		err := b.db.QueryRow(`select md_json from metadata where md_path = ? and md_type = 'gdal'`, path).Scan(&mdJSON)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		file := &gdalFile{}
+		if err := json.Unmarshal([]byte(mdJSON), file); err != nil {
+			return "", fmt.Errorf("%s: %v", path, err)
+		}
+
+		for _, geo := range file.DataSets {
+			ds := &gdalDataset{
+				FilePath:     file.FileName,
+				DataSetName:  geo["ds_name"],
+				NameSpace:    datasetNamespace(geo),
+				ArrayType:    geo["array_type"],
+				SRS:          geo["proj_wkt"],
+				GeoTransform: geo["geotransform"],
+				TimeStamps:   geo["timestamps"],
+				Polygon:      geo["polygon"],
+				Overviews:    geo["overviews"],
+				Means:        geo["means"],
+				SampleCounts: geo["sample_counts"],
+				NoData:       geo["nodata"],
+				ScaleFactor:  geo["scale_factor"],
+				AddOffset:    geo["add_offset"],
+				Units:        geo["units"],
+				ValidRange:   geo["valid_range"],
+				Axes:         geo["axes"],
+				GeoLocation:  geo["geo_loc"],
+			}
+
+			if len(nsFilter) > 0 && (ds.NameSpace == nil || !nsFilter[*ds.NameSpace]) {
+				continue
+			}
+			result.Datasets = append(result.Datasets, ds)
+		}
+	}
+
+	return marshalPayload(result)
+}
+
+func (b *SQLiteBackend) Timestamps(q *Query) (string, error) {
+	if len(q.GPath) == 0 {
+		return "", fmt.Errorf("invalid search path")
+	}
+
+	type timestampsResult struct {
+		Timestamps []string `json:"timestamps"`
+		Token      string   `json:"token"`
+	}
+
+	root, err := b.rootOf(q.GPath)
+	if err != nil {
+		return "", err
+	}
+	if len(root) == 0 {
+		return marshalPayload(&timestampsResult{Timestamps: []string{}})
+	}
+
+	nullable := func(s string) string {
+		if len(s) == 0 {
+			return "null"
+		}
+		return s
+	}
+	queryHash := md5UUID(q.GPath + nullable(q.Time) + nullable(q.Until) + nullable(q.Namespace))
+
+	var cached string
+	err = b.db.QueryRow(`select value from ows_cache where query_id = ?`, queryHash).Scan(&cached)
+	if err == nil {
+		// The client already holds the cached timestamps
+		if q.Token == queryHash {
+			return marshalPayload(&timestampsResult{Timestamps: []string{}, Token: queryHash})
+		}
+		return cached, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	lo, hi := gpathRange(q.GPath)
+	stmt := `select distinct st_stamp from stamps
+		inner join polygons on po_id = st_id
+		where po_path > ? and po_path < ?`
+	args := []interface{}{lo, hi}
+
+	nsClause, nsArgs := namespaceClause(splitNamespaces(q.Namespace))
+	stmt += nsClause
+	args = append(args, nsArgs...)
+
+	if len(q.Time) > 0 {
+		timeA, err := parseStamp(q.Time)
+		if err != nil {
+			return "", err
+		}
+		stmt += ` and st_stamp >= ?`
+		args = append(args, timeA)
+	}
+
+	// By default, we filter out all the future dates
+	timeB := time.Now().UnixNano() / 1000
+	if len(q.Until) > 0 {
+		timeB, err = parseStamp(q.Until)
+		if err != nil {
+			return "", err
+		}
+	}
+	stmt += ` and st_stamp <= ? order by st_stamp`
+	args = append(args, timeB)
+
+	rows, err := b.db.Query(stmt, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	result := &timestampsResult{Timestamps: []string{}, Token: queryHash}
+	for rows.Next() {
+		var stamp int64
+		if err := rows.Scan(&stamp); err != nil {
+			return "", err
+		}
+		result.Timestamps = append(result.Timestamps, stampTime(stamp).Format("2006-01-02T15:04:05.000Z"))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	payload, err := marshalPayload(result)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = b.db.Exec(`insert or ignore into ows_cache (query_id, value) values (?, ?)`, queryHash, payload)
+	if err != nil {
+		return "", err
+	}
+
+	return payload, nil
+}
+
+func (b *SQLiteBackend) Extents(q *Query) (string, error) {
+	if len(q.GPath) == 0 {
+		return "", fmt.Errorf("invalid search path")
+	}
+
+	root, err := b.rootOf(q.GPath)
+	if err != nil {
+		return "", err
+	}
+	if len(root) == 0 {
+		return "{}", nil
+	}
+
+	lo, hi := gpathRange(q.GPath)
+
+	namespaces := splitNamespaces(q.Namespace)
+	if len(namespaces) == 0 {
+		namespaces, err = b.queryStrings(`select distinct po_name from polygons
+			where po_path > ? and po_path < ? and po_name is not null
+			order by po_name`, lo, hi)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	type extentsResult struct {
+		XMin      *float64 `json:"xmin"`
+		YMax      *float64 `json:"ymax"`
+		XMax      *float64 `json:"xmax"`
+		YMin      *float64 `json:"ymin"`
+		MinStamp  *string  `json:"min_stamp"`
+		MaxStamp  *string  `json:"max_stamp"`
+		Variables []string `json:"variables"`
+	}
+	result := &extentsResult{Variables: namespaces}
+	if len(namespaces) == 0 {
+		return marshalPayload(result)
+	}
+
+	nsClause, nsArgs := namespaceClause(namespaces)
+	var minStamp, maxStamp sql.NullInt64
+	err = b.db.QueryRow(`select min(po_min_stamp), max(po_max_stamp) from polygons
+		where po_path > ? and po_path < ?`+nsClause, append([]interface{}{lo, hi}, nsArgs...)...).Scan(&minStamp, &maxStamp)
+	if err != nil {
+		return "", err
+	}
+
+	formatStamp := func(stamp sql.NullInt64) *string {
+		if !stamp.Valid {
+			return nil
+		}
+		str := stampTime(stamp.Int64).Format("2006-01-02T15:04:05.999999")
+		return &str
+	}
+	result.MinStamp = formatStamp(minStamp)
+	result.MaxStamp = formatStamp(maxStamp)
+
+	var xMin, yMin, xMax, yMax sql.NullFloat64
+	err = b.db.QueryRow(`select min(xmin), min(ymin), max(xmax), max(ymax) from polygons po
+		inner join polygons_rtree rt on rt.po_id = po.po_id
+		where po_path > ? and po_path < ?`+nsClause, append([]interface{}{lo, hi}, nsArgs...)...).Scan(&xMin, &yMin, &xMax, &yMax)
+	if err != nil {
+		return "", err
+	}
+
+	if xMin.Valid {
+		trans, err := newCoordTransform(epsg4326, epsg3857)
+		if err != nil {
+			return "", err
+		}
+		defer trans.Close()
+
+		clampLat := func(lat float64) float64 {
+			return math.Max(-maxMercatorLat, math.Min(maxMercatorLat, lat))
+		}
+		box := trans.transformBBox([][]point{{
+			{X: xMin.Float64, Y: clampLat(yMin.Float64)},
+			{X: xMax.Float64, Y: clampLat(yMax.Float64)},
+		}})
+
+		if !box.isEmpty() {
+			result.XMin, result.YMin, result.XMax, result.YMax = &box.MinX, &box.MinY, &box.MaxX, &box.MaxY
+		}
+	}
+
+	return marshalPayload(result)
+}
+
+func (b *SQLiteBackend) ListRootGPath() (string, error) {
+	roots, err := b.queryStrings(`select ro_path from roots order by ro_path`)
+	if err != nil {
+		return "", err
+	}
+	if roots == nil {
+		roots = []string{}
+	}
+
+	return marshalPayload(map[string][]string{"sub_paths": roots})
+}
+
+func (b *SQLiteBackend) ListSubGPath(q *Query) (string, error) {
+	root, err := b.rootOf(q.GPath)
+	if err != nil {
+		return "", err
+	}
+	if len(root) == 0 {
+		return "{}", nil
+	}
+
+	gpath := cleanGPath(q.GPath)
+	lo, hi := gpathRange(gpath)
+
+	parents, err := b.queryStrings(`select distinct md_parent from metadata
+		where md_parent > ? and md_parent < ?`, lo, hi)
+	if err != nil {
+		return "", err
+	}
+
+	subPathMap := make(map[string]bool)
+	for _, parent := range parents {
+		subPath := parent[len(lo):]
+		if idx := strings.Index(subPath, "/"); idx >= 0 {
+			subPath = subPath[:idx]
+		}
+		subPathMap["/"+subPath] = true
+	}
+
+	subPaths := []string{}
+	for subPath := range subPathMap {
+		subPaths = append(subPaths, subPath)
+	}
+	sort.Strings(subPaths)
+
+	var hasNamespaces int
+	err = b.db.QueryRow(`select count(*) from (select 1 from polygons where po_parent = ? limit 1)`, gpath).Scan(&hasNamespaces)
+	if err != nil {
+		return "", err
+	}
+
+	return marshalPayload(&struct {
+		SubPaths      []string `json:"sub_paths"`
+		HasNamespaces bool     `json:"has_namespaces"`
+		GPathRoot     string   `json:"gpath_root"`
+	}{subPaths, hasNamespaces > 0, root})
+}
+
+type layerAxis struct {
+	Name   json.RawMessage `json:"name"`
+	Values []string        `json:"values"`
+}
+
+func (b *SQLiteBackend) GenerateLayers(q *Query) (string, error) {
+	if len(q.GPath) == 0 {
+		return "", fmt.Errorf("invalid search path")
+	}
+
+	root, err := b.rootOf(q.GPath)
+	if err != nil {
+		return "", err
+	}
+	if len(root) == 0 {
+		return "{}", nil
+	}
+
+	gpath := cleanGPath(q.GPath)
+	namespaces, err := b.queryStrings(`select distinct po_name from polygons
+		where po_parent = ? and po_name is not null
+		order by po_name`, gpath)
+	if err != nil {
+		return "", err
+	}
+
+	type masLayer struct {
+		Title         string       `json:"title"`
+		Name          string       `json:"name"`
+		TimeGenerator string       `json:"time_generator"`
+		DataSource    string       `json:"data_source"`
+		RGBProducts   []string     `json:"rgb_products"`
+		Axes          []*layerAxis `json:"axes,omitempty"`
+	}
+
+	layers := []*masLayer{}
+	for _, ns := range namespaces {
+		axes, err := b.namespaceAxes(gpath, ns)
+		if err != nil {
+			return "", err
+		}
+
+		layers = append(layers, &masLayer{
+			Title:         ns,
+			Name:          ns,
+			TimeGenerator: "mas",
+			DataSource:    gpath,
+			RGBProducts:   []string{ns},
+			Axes:          axes,
+		})
+	}
+
+	return marshalPayload(map[string]interface{}{"layers": layers})
+}
+
+// namespaceAxes lists the non-spatial axes of a namespace under gpath.
+// The values of an axis are taken from the dataset with the most values.
+func (b *SQLiteBackend) namespaceAxes(gpath string, ns string) ([]*layerAxis, error) {
+	lo, hi := gpathRange(gpath)
+	axesList, err := b.queryStrings(`select distinct po_axes from polygons
+		where po_path > ? and po_path < ? and po_name = ? and po_axes is not null`, lo, hi, ns)
+	if err != nil {
+		return nil, err
+	}
+
+	type datasetAxis struct {
+		Name   json.RawMessage `json:"name"`
+		Params []json.Number   `json:"params"`
+	}
+
+	axisMap := make(map[string]*layerAxis)
+	for _, axesJSON := range axesList {
+		var axes []*datasetAxis
+		if err := json.Unmarshal([]byte(axesJSON), &axes); err != nil {
+			return nil, err
+		}
+
+		for _, axis := range axes {
+			if axis == nil || len(axis.Params) == 0 {
+				continue
+			}
+
+			name := string(axis.Name)
+			if la, found := axisMap[name]; found && len(la.Values) >= len(axis.Params) {
+				continue
+			}
+
+			la := &layerAxis{Name: axis.Name}
+			for _, param := range axis.Params {
+				la.Values = append(la.Values, param.String())
+			}
+			axisMap[name] = la
+		}
+	}
+
+	var names []string
+	for name := range axisMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var axes []*layerAxis
+	for _, name := range names {
+		axes = append(axes, axisMap[name])
+	}
+	return axes, nil
+}
+
+func (b *SQLiteBackend) PutOWSCache(q *Query) (string, error) {
+	root, err := b.rootOf(q.GPath)
+	if err != nil {
+		return "", err
+	}
+	if len(q.GPath) == 0 || len(root) == 0 {
+		return "", fmt.Errorf("invalid search path")
+	}
+
+	if !json.Valid([]byte(q.Value)) {
+		return "", fmt.Errorf("invalid input syntax for type json")
+	}
+
+	_, err = b.db.Exec(`insert or replace into ows_cache (query_id, value) values (?, ?)`, md5UUID(q.Key), q.Value)
+	if err != nil {
+		return "", err
+	}
+
+	return `{"error": ""}`, nil
+}
+
+func (b *SQLiteBackend) GetOWSCache(q *Query) (string, error) {
+	root, err := b.rootOf(q.GPath)
+	if err != nil {
+		return "", err
+	}
+	if len(q.GPath) == 0 || len(root) == 0 {
+		return "", fmt.Errorf("invalid search path")
+	}
+
+	var value sql.NullString
+	err = b.db.QueryRow(`select value from ows_cache where query_id = ?`, md5UUID(q.Key)).Scan(&value)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	result := map[string]json.RawMessage{"value": nil}
+	if value.Valid {
+		result["value"] = json.RawMessage(value.String)
+	}
+	return marshalPayload(result)
+}
+
+func (b *SQLiteBackend) queryStrings(stmt string, args ...interface{}) ([]string, error) {
+	rows, err := b.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
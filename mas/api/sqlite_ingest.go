@@ -0,0 +1,383 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Number of segments each edge of a dataset polygon is split into before
+// the polygon is transformed into EPSG:4326
+const datasetPolygonSegments = 16
+
+var invalidNamespaceRe = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// datasetNamespace returns the sanitised namespace of a dataset in
+// gsky-crawl outputs or nil if the dataset has no namespace
+func datasetNamespace(geo map[string]json.RawMessage) *string {
+	var ns string
+	if err := json.Unmarshal(geo["namespace"], &ns); err != nil {
+		return nil
+	}
+	ns = invalidNamespaceRe.ReplaceAllString(strings.TrimSpace(ns), "_")
+	return &ns
+}
+
+// commonDir returns the deepest directory containing both paths
+func commonDir(a string, b string) string {
+	aParts := strings.Split(a, "/")
+	bParts := strings.Split(b, "/")
+
+	n := 0
+	for n < len(aParts) && n < len(bParts) && aParts[n] == bParts[n] {
+		n++
+	}
+	return cleanGPath(strings.Join(aParts[:n], "/"))
+}
+
+type sqliteIngestTx struct {
+	tx         *sql.Tx
+	transforms map[string]*coordTransform
+}
+
+// Ingest loads gsky-crawl TSV output into the index. Ingested files are
+// found under root, which defaults to the deepest directory containing
+// all of them.
+func (b *SQLiteBackend) Ingest(r io.Reader, root string) error {
+	t, err := b.beginIngest()
+	if err != nil {
+		return err
+	}
+	defer t.Rollback()
+
+	var commonRoot string
+	n, err := readCrawlRecords(r, false, 0, func(records []*CrawlRecord) error {
+		for _, rec := range records {
+			if err := validateRecord(rec, "/"); err != nil {
+				return err
+			}
+
+			if len(commonRoot) == 0 {
+				commonRoot = parentDir(rec.Path)
+			} else {
+				commonRoot = commonDir(commonRoot, parentDir(rec.Path))
+			}
+		}
+		return t.Ingest(records)
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	if len(root) > 0 {
+		commonRoot = cleanGPath(root)
+	}
+	_, err = t.tx.Exec(`insert or ignore into roots (ro_path) values (?)`, commonRoot)
+	if err != nil {
+		return err
+	}
+
+	return t.Commit()
+}
+
+func (b *SQLiteBackend) CreateShard(code string, gpath string) (*Shard, error) {
+	shard, err := b.ShardOf(gpath)
+	if err != nil {
+		return nil, err
+	}
+	if shard != nil {
+		if shard.Code == code && shard.GPath == gpath {
+			return nil, fmt.Errorf("shard '%s' existed", code)
+		}
+		return nil, fmt.Errorf("%s belongs to shard '%s' at %s", gpath, shard.Code, shard.GPath)
+	}
+
+	_, err = b.db.Exec(`insert into roots (ro_path, ro_code) values (?, ?)`, gpath, code)
+	if err != nil {
+		return nil, fmt.Errorf("create shard '%s': %v", code, err)
+	}
+	return &Shard{Code: code, GPath: gpath}, nil
+}
+
+func (b *SQLiteBackend) ShardOf(gpath string) (*Shard, error) {
+	rows, err := b.db.Query(`select ro_path, coalesce(ro_code, '') from roots order by length(ro_path) desc`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		shard := &Shard{}
+		if err := rows.Scan(&shard.GPath, &shard.Code); err != nil {
+			return nil, err
+		}
+		if isUnderGPath(gpath, shard.GPath) {
+			return shard, nil
+		}
+	}
+	return nil, rows.Err()
+}
+
+// BeginIngest starts a transaction on the index. Changes are visible to
+// queries as soon as they are committed.
+func (b *SQLiteBackend) BeginIngest(shard *Shard) (IngestTx, error) {
+	return b.beginIngest()
+}
+
+func (b *SQLiteBackend) beginIngest() (*sqliteIngestTx, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteIngestTx{tx: tx, transforms: make(map[string]*coordTransform)}, nil
+}
+
+// Refresh clears the cached responses. The index itself needs no
+// refresh.
+func (b *SQLiteBackend) Refresh(shard *Shard) error {
+	_, err := b.db.Exec(`delete from ows_cache`)
+	return err
+}
+
+func (t *sqliteIngestTx) Delete(gpath string) (int64, error) {
+	lo, hi := gpathRange(gpath)
+	where := `(md_path = ? or (md_path > ? and md_path < ?))`
+	args := []interface{}{gpath, lo, hi}
+
+	var deleted int64
+	err := t.tx.QueryRow(`select count(distinct md_path) from metadata where `+where, args...).Scan(&deleted)
+	if err != nil {
+		return 0, err
+	}
+
+	poWhere := strings.Replace(where, "md_path", "po_path", -1)
+	for _, stmt := range []string{
+		`delete from stamps where st_id in (select po_id from polygons where ` + poWhere + `)`,
+		`delete from polygons_rtree where po_id in (select po_id from polygons where ` + poWhere + `)`,
+		`delete from polygons where ` + poWhere,
+		`delete from metadata where ` + where,
+	} {
+		if _, err := t.tx.Exec(stmt, args...); err != nil {
+			return 0, err
+		}
+	}
+	return deleted, nil
+}
+
+// Ingest adds the records to the index. Records replace the existing
+// records of the same path and type.
+func (t *sqliteIngestTx) Ingest(records []*CrawlRecord) error {
+	for _, rec := range records {
+		if err := t.ingestRecord(rec); err != nil {
+			return fmt.Errorf("%s: %v", rec.Path, err)
+		}
+	}
+	return nil
+}
+
+func (t *sqliteIngestTx) ingestRecord(rec *CrawlRecord) error {
+	if rec.Type == TombstoneType {
+		_, err := t.Delete(rec.Path)
+		return err
+	}
+
+	parent := parentDir(rec.Path)
+	_, err := t.tx.Exec(`insert or replace into metadata (md_path, md_parent, md_type, md_json) values (?, ?, ?, ?)`,
+		rec.Path, parent, rec.Type, string(rec.Metadata))
+	if err != nil {
+		return err
+	}
+
+	if rec.Type != "gdal" {
+		return nil
+	}
+
+	for _, stmt := range []string{
+		`delete from stamps where st_id in (select po_id from polygons where po_path = ?)`,
+		`delete from polygons_rtree where po_id in (select po_id from polygons where po_path = ?)`,
+		`delete from polygons where po_path = ?`,
+	} {
+		if _, err := t.tx.Exec(stmt, rec.Path); err != nil {
+			return err
+		}
+	}
+
+	file := &gdalFile{}
+	if err := json.Unmarshal(rec.Metadata, file); err != nil {
+		return err
+	}
+
+	for _, geo := range file.DataSets {
+		if err := t.ingestDataset(rec.Path, parent, geo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit commits the changes. Cached responses are stale afterwards.
+func (t *sqliteIngestTx) Commit() error {
+	defer t.closeTransforms()
+
+	_, err := t.tx.Exec(`delete from ows_cache`)
+	if err != nil {
+		t.tx.Rollback()
+		return err
+	}
+	return t.tx.Commit()
+}
+
+func (t *sqliteIngestTx) Rollback() error {
+	defer t.closeTransforms()
+	return t.tx.Rollback()
+}
+
+func (t *sqliteIngestTx) closeTransforms() {
+	for srs, trans := range t.transforms {
+		if trans != nil {
+			trans.Close()
+		}
+		delete(t.transforms, srs)
+	}
+}
+
+func (t *sqliteIngestTx) ingestDataset(path string, parent string, geo map[string]json.RawMessage) error {
+	var timestamps []time.Time
+	if len(geo["timestamps"]) > 0 {
+		if err := json.Unmarshal(geo["timestamps"], &timestamps); err != nil {
+			return fmt.Errorf("timestamps: %v", err)
+		}
+	}
+
+	var minStamp, maxStamp sql.NullInt64
+	stamps := make([]int64, len(timestamps))
+	for i, ts := range timestamps {
+		stamps[i] = ts.UnixNano() / 1000
+		if !minStamp.Valid || stamps[i] < minStamp.Int64 {
+			minStamp = sql.NullInt64{Int64: stamps[i], Valid: true}
+		}
+		if !maxStamp.Valid || stamps[i] > maxStamp.Int64 {
+			maxStamp = sql.NullInt64{Int64: stamps[i], Valid: true}
+		}
+	}
+
+	var axes sql.NullString
+	if len(geo["axes"]) > 0 && string(geo["axes"]) != "null" {
+		axes = sql.NullString{String: string(geo["axes"]), Valid: true}
+	}
+
+	res, err := t.tx.Exec(`insert into polygons (po_path, po_parent, po_name, po_min_stamp, po_max_stamp, po_axes) values (?, ?, ?, ?, ?, ?)`,
+		path, parent, datasetNamespace(geo), minStamp, maxStamp, axes)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for _, stamp := range stamps {
+		_, err = t.tx.Exec(`insert into stamps (st_id, st_stamp) values (?, ?)`, id, stamp)
+		if err != nil {
+			return err
+		}
+	}
+
+	box, ok := t.datasetBBox(geo)
+	if !ok {
+		return nil
+	}
+
+	_, err = t.tx.Exec(`insert into polygons_rtree (po_id, xmin, xmax, ymin, ymax) values (?, ?, ?, ?, ?)`,
+		id, box.MinX, box.MaxX, box.MinY, box.MaxY)
+	return err
+}
+
+// datasetBBox computes the EPSG:4326 bounding box of a dataset. Datasets
+// without a valid polygon or SRS are only found by non-spatial queries.
+func (t *sqliteIngestTx) datasetBBox(geo map[string]json.RawMessage) (bbox, bool) {
+	var polygon, srs, proj4 string
+	json.Unmarshal(geo["polygon"], &polygon)
+	json.Unmarshal(geo["proj_wkt"], &srs)
+	json.Unmarshal(geo["proj4"], &proj4)
+
+	srs = strings.TrimSpace(srs)
+	if len(srs) == 0 {
+		srs = strings.TrimSpace(proj4)
+	}
+	if len(strings.TrimSpace(polygon)) == 0 || len(srs) == 0 {
+		return bbox{}, false
+	}
+
+	rings, err := parseWKTRings(polygon)
+	if err != nil {
+		return bbox{}, false
+	}
+
+	trans, found := t.transforms[srs]
+	if !found {
+		trans, err = newCoordTransform(srs, epsg4326)
+		if err != nil {
+			log.Printf("ingest: %v", err)
+		}
+		t.transforms[srs] = trans
+	}
+	if trans == nil {
+		return bbox{}, false
+	}
+
+	box := trans.transformBBox(segmentizeN(rings, datasetPolygonSegments))
+	return box, !box.isEmpty()
+}
+
+// openSQLiteBackend opens the SQLite index and ingests the comma
+// separated crawl files into it
+func openSQLiteBackend(dbFile string, limit int, ingestFiles string) (Backend, error) {
+	b, err := NewSQLiteBackend(dbFile, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ingestFiles) > 0 {
+		for _, file := range strings.Split(ingestFiles, ",") {
+			err = ingestFile(b, strings.TrimSpace(file))
+			if err != nil {
+				b.Close()
+				return nil, fmt.Errorf("ingest %s: %v", file, err)
+			}
+		}
+	}
+	return b, nil
+}
+
+func ingestFile(b *SQLiteBackend, file string) error {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	start := time.Now()
+	err := b.Ingest(r, *ingestRoot)
+	if err == nil {
+		log.Printf("ingested %s in %v", file, time.Since(start))
+	}
+	return err
+}
@@ -0,0 +1,277 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWGS84 = `GEOGCS[\"WGS 84\",DATUM[\"WGS_1984\",SPHEROID[\"WGS 84\",6378137,298.257223563]],PRIMEM[\"Greenwich\",0],UNIT[\"degree\",0.0174532925199433],AUTHORITY[\"EPSG\",\"4326\"]]`
+
+func testCrawlRecord(path string, ns string, polygon string, stamps ...string) string {
+	return fmt.Sprintf(`%s	gdal	{"filename":"%s","geo_metadata":[{"ds_name":"NETCDF:\"%s\":%s","namespace":"%s","array_type":"Float32","timestamps":["%s"],"polygon":"%s","proj_wkt":"%s","axes":[{"name":"level","params":[1,2.5]}]}]}`,
+		path, path, path, ns, ns, strings.Join(stamps, `","`), polygon, testWGS84)
+}
+
+func TestSQLiteBackend(t *testing.T) {
+	b, err := NewSQLiteBackend(filepath.Join(t.TempDir(), "mas.db"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	tsv := strings.Join([]string{
+		testCrawlRecord("/g/data/ab1/prod/2019/a.nc", "temp-max", "POLYGON ((110 -10,120 -10,120 -20,110 -20,110 -10))", "2019-01-01T00:00:00Z", "2019-01-02T00:00:00Z"),
+		testCrawlRecord("/g/data/ab1/prod/2020/b.nc", "temp-max", "POLYGON ((140 -30,150 -30,150 -40,140 -40,140 -30))", "2020-01-01T00:00:00Z"),
+		testCrawlRecord("/g/data/ab1/prod/2020/c.nc", "rain", "POLYGON ((140 -30,150 -30,150 -40,140 -40,140 -30))", "2020-01-01T00:00:00Z"),
+	}, "\n")
+	if err = b.Ingest(strings.NewReader(tsv), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	intersects := func(q *Query) []string {
+		q.Metadata = "gdal"
+		payload, err := b.Intersects(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result struct {
+			Datasets []struct {
+				FilePath  string `json:"file_path"`
+				NameSpace string `json:"namespace"`
+			} `json:"gdal"`
+		}
+		if err := json.Unmarshal([]byte(payload), &result); err != nil {
+			t.Fatal(err)
+		}
+
+		files := []string{}
+		for _, ds := range result.Datasets {
+			if ds.NameSpace != "temp_max" && ds.NameSpace != "rain" {
+				t.Errorf("unexpected namespace %q", ds.NameSpace)
+			}
+			files = append(files, filepath.Base(ds.FilePath))
+		}
+		return files
+	}
+
+	bboxWKT := "POLYGON ((115 -15,145 -15,145 -35,115 -35,115 -15))"
+	testCases := []struct {
+		query *Query
+		files []string
+	}{
+		{&Query{GPath: "/g/data/ab1/prod"}, []string{"a.nc", "b.nc", "c.nc"}},
+		{&Query{GPath: "/g/data/ab1/prod/2020"}, []string{"b.nc", "c.nc"}},
+		{&Query{GPath: "/g/data/ab1/prod", Namespace: "temp_max"}, []string{"a.nc", "b.nc"}},
+		{&Query{GPath: "/g/data/ab1/prod", Time: "2019-01-02T00:00:00.000Z"}, []string{"a.nc"}},
+		{&Query{GPath: "/g/data/ab1/prod", Time: "2019-01-03T00:00:00.000Z"}, []string{}},
+		{&Query{GPath: "/g/data/ab1/prod", Time: "2019-06-01T00:00:00.000Z", Until: "2020-06-01T00:00:00.000Z"}, []string{"b.nc", "c.nc"}},
+		{&Query{GPath: "/g/data/ab1/prod", SRS: "EPSG:4326", WKT: bboxWKT}, []string{"a.nc", "b.nc", "c.nc"}},
+		{&Query{GPath: "/g/data/ab1/prod", SRS: "EPSG:4326", WKT: "POLYGON ((100 0,105 0,105 -5,100 -5,100 0))"}, []string{}},
+		{&Query{GPath: "/g/data/ab1/prod", SRS: "EPSG:4326", WKT: bboxWKT, Limit: "1"}, []string{"a.nc"}},
+		{&Query{GPath: "/g/data/ab1/prod", SRS: "EPSG:4326", WKT: bboxWKT, Limit: "1", Offset: "1"}, []string{"b.nc"}},
+		{&Query{GPath: "/g/data/ab1/prod", Offset: "2"}, []string{"c.nc"}},
+		{&Query{GPath: "/g/data/xy9"}, []string{}},
+	}
+
+	for _, tc := range testCases {
+		files := intersects(tc.query)
+		if !reflect.DeepEqual(files, tc.files) {
+			t.Errorf("%+v: expected %v, got %v", tc.query, tc.files, files)
+		}
+	}
+
+	payload, err := b.Timestamps(&Query{GPath: "/g/data/ab1/prod", Namespace: "temp_max"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stamps struct {
+		Timestamps []string `json:"timestamps"`
+		Token      string   `json:"token"`
+	}
+	if err = json.Unmarshal([]byte(payload), &stamps); err != nil {
+		t.Fatal(err)
+	}
+	expectedStamps := []string{"2019-01-01T00:00:00.000Z", "2019-01-02T00:00:00.000Z", "2020-01-01T00:00:00.000Z"}
+	if !reflect.DeepEqual(stamps.Timestamps, expectedStamps) {
+		t.Errorf("expected timestamps %v, got %v", expectedStamps, stamps.Timestamps)
+	}
+
+	// The client holds the timestamps of its token
+	payload, err = b.Timestamps(&Query{GPath: "/g/data/ab1/prod", Namespace: "temp_max", Token: stamps.Token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(payload, `"timestamps":[]`) {
+		t.Errorf("expected no timestamps for a valid token, got %s", payload)
+	}
+
+	payload, err = b.GenerateLayers(&Query{GPath: "/g/data/ab1/prod/2020/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedLayers := `{"layers":[` +
+		`{"title":"rain","name":"rain","time_generator":"mas","data_source":"/g/data/ab1/prod/2020","rgb_products":["rain"],"axes":[{"name":"level","values":["1","2.5"]}]},` +
+		`{"title":"temp_max","name":"temp_max","time_generator":"mas","data_source":"/g/data/ab1/prod/2020","rgb_products":["temp_max"],"axes":[{"name":"level","values":["1","2.5"]}]}]}`
+	if payload != expectedLayers {
+		t.Errorf("expected layers %s, got %s", expectedLayers, payload)
+	}
+
+	payload, err = b.ListSubGPath(&Query{GPath: "/g/data/ab1/prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedSubPaths := `{"sub_paths":["/2019","/2020"],"has_namespaces":false,"gpath_root":"/g/data/ab1/prod"}`
+	if payload != expectedSubPaths {
+		t.Errorf("expected sub paths %s, got %s", expectedSubPaths, payload)
+	}
+
+	if _, err = b.PutOWSCache(&Query{GPath: "/g/data/ab1/prod", Key: "k", Value: `{"a":1}`}); err != nil {
+		t.Fatal(err)
+	}
+	payload, err = b.GetOWSCache(&Query{GPath: "/g/data/ab1/prod", Key: "k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload != `{"value":{"a":1}}` {
+		t.Errorf("unexpected cached value %s", payload)
+	}
+
+	// Re-ingesting a file replaces its datasets
+	tsv = testCrawlRecord("/g/data/ab1/prod/2019/a.nc", "temp-max", "POLYGON ((100 0,105 0,105 -5,100 -5,100 0))", "2019-01-01T00:00:00Z")
+	if err = b.Ingest(strings.NewReader(tsv), "/g/data/ab1/prod"); err != nil {
+		t.Fatal(err)
+	}
+	files := intersects(&Query{GPath: "/g/data/ab1/prod", SRS: "EPSG:4326", WKT: "POLYGON ((100 0,105 0,105 -5,100 -5,100 0))"})
+	if !reflect.DeepEqual(files, []string{"a.nc"}) {
+		t.Errorf("expected re-ingested a.nc, got %v", files)
+	}
+
+	// Tombstones delete the records of their files
+	tsv = "/g/data/ab1/prod/2020/b.nc\ttombstone\t{\"file_path\":\"/g/data/ab1/prod/2020/b.nc\",\"tombstone\":true}"
+	if err = b.Ingest(strings.NewReader(tsv), "/g/data/ab1/prod"); err != nil {
+		t.Fatal(err)
+	}
+	files = intersects(&Query{GPath: "/g/data/ab1/prod/2020"})
+	if !reflect.DeepEqual(files, []string{"c.nc"}) {
+		t.Errorf("expected c.nc after the tombstone of b.nc, got %v", files)
+	}
+}
+
+func TestIngestHandler(t *testing.T) {
+	b, err := NewSQLiteBackend(filepath.Join(t.TempDir(), "mas.db"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	h := &IngestHandler{Ingester: b, Jobs: NewJobManager(b), Token: "secret"}
+	h.Jobs.Changes = NewChangeFeed(0)
+
+	do := func(method string, url string, body string, status int) map[string]interface{} {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer secret")
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, request)
+
+		if recorder.Code != status {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", method, url, status, recorder.Code, recorder.Body.String())
+		}
+
+		result := make(map[string]interface{})
+		json.Unmarshal(recorder.Body.Bytes(), &result)
+		return result
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/g/data/ab1?create_shard&shard=ab1", nil)
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected an unauthorized request to be rejected, got %d", recorder.Code)
+	}
+
+	do(http.MethodPost, "/g/data/ab1?create_shard&shard=Ab-1", "", http.StatusBadRequest)
+	do(http.MethodPost, "/g/data/ab1?create_shard&shard=ab1", "", http.StatusOK)
+	do(http.MethodPost, "/g/data/ab1/prod?create_shard&shard=ab2", "", http.StatusConflict)
+	do(http.MethodPost, "/g/data/xy9?ingest", "", http.StatusNotFound)
+
+	tsv := strings.Join([]string{
+		testCrawlRecord("/g/data/ab1/prod/2019/a.nc", "temp", "POLYGON ((110 -10,120 -10,120 -20,110 -20,110 -10))", "2019-01-01T00:00:00Z"),
+		testCrawlRecord("/g/data/ab1/prod/2020/b.nc", "temp", "POLYGON ((140 -30,150 -30,150 -40,140 -40,140 -30))", "2020-01-01T00:00:00Z"),
+	}, "\n")
+	result := do(http.MethodPost, "/g/data/ab1/prod?ingest", tsv, http.StatusOK)
+	if result["records"] != 2.0 {
+		t.Errorf("expected 2 records ingested, got %v", result)
+	}
+
+	// A record outside the gpath fails the whole request
+	do(http.MethodPost, "/g/data/ab1/prod/2020?ingest", tsv, http.StatusBadRequest)
+
+	countFiles := func() int {
+		payload, err := b.Intersects(&Query{GPath: "/g/data/ab1", Metadata: "gdal"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files struct {
+			Datasets []interface{} `json:"gdal"`
+		}
+		json.Unmarshal([]byte(payload), &files)
+		return len(files.Datasets)
+	}
+	if n := countFiles(); n != 2 {
+		t.Errorf("expected 2 files, got %d", n)
+	}
+
+	jsonl := `{"path":"/g/data/ab1/prod/2020/c.nc","type":"gdal","metadata":{"filename":"/g/data/ab1/prod/2020/c.nc","geo_metadata":[{"namespace":"temp"}]}}`
+	result = do(http.MethodPost, "/g/data/ab1/prod/2020?ingest&replace&format=jsonl", jsonl, http.StatusOK)
+	if result["records"] != 1.0 || result["deleted"] != 1.0 {
+		t.Errorf("expected 1 record replacing 1 record, got %v", result)
+	}
+	if n := countFiles(); n != 2 {
+		t.Errorf("expected 2 files after replace, got %d", n)
+	}
+
+	result = do(http.MethodPost, "/g/data/ab1/prod/2019?delete_records", "", http.StatusOK)
+	if result["deleted"] != 1.0 {
+		t.Errorf("expected 1 record deleted, got %v", result)
+	}
+	if n := countFiles(); n != 1 {
+		t.Errorf("expected 1 file after delete, got %d", n)
+	}
+
+	job := do(http.MethodPost, "/g/data/ab1?refresh", "", http.StatusOK)
+	id := int64(job["id"].(float64))
+	for i := 0; i < 100; i++ {
+		if h.Jobs.Get(id).Status == JobDone {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status := h.Jobs.Get(id).Status; status != JobDone {
+		t.Errorf("expected refresh job to be done, got %s", status)
+	}
+
+	changes, _, _ := h.Jobs.Changes.Since(0)
+	announced := make(map[string]bool)
+	for _, change := range changes {
+		announced[change.GPath] = true
+	}
+	for _, gpath := range []string{"/g/data/ab1/prod", "/g/data/ab1/prod/2020", "/g/data/ab1/prod/2019"} {
+		if !announced[gpath] {
+			t.Errorf("expected %s to be announced after refresh, got %+v", gpath, changes)
+		}
+	}
+
+	do(http.MethodGet, "/?jobs", "", http.StatusOK)
+	do(http.MethodGet, "/g/data/ab1?ingest", "", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,90 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+// #include "ogr_srs_api.h"
+// #include "cpl_conv.h"
+// #cgo pkg-config: gdal
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// coordTransform reprojects coordinates between two spatial reference
+// systems given in any form accepted by OSRSetFromUserInput. A
+// coordTransform must not be used by several goroutines at once.
+type coordTransform struct {
+	src   C.OGRSpatialReferenceH
+	dst   C.OGRSpatialReferenceH
+	trans C.OGRCoordinateTransformationH
+}
+
+func newCoordTransform(srcSRS string, dstSRS string) (*coordTransform, error) {
+	t := &coordTransform{}
+
+	t.src = C.OSRNewSpatialReference(nil)
+	t.dst = C.OSRNewSpatialReference(nil)
+
+	srcSRSC := C.CString(srcSRS)
+	defer C.free(unsafe.Pointer(srcSRSC))
+	if C.OSRSetFromUserInput(t.src, srcSRSC) != C.OGRERR_NONE {
+		t.Close()
+		return nil, fmt.Errorf("unknown SRS: %s", srcSRS)
+	}
+
+	dstSRSC := C.CString(dstSRS)
+	defer C.free(unsafe.Pointer(dstSRSC))
+	if C.OSRSetFromUserInput(t.dst, dstSRSC) != C.OGRERR_NONE {
+		t.Close()
+		return nil, fmt.Errorf("unknown SRS: %s", dstSRS)
+	}
+
+	C.OSRSetAxisMappingStrategy(t.src, C.OAMS_TRADITIONAL_GIS_ORDER)
+	C.OSRSetAxisMappingStrategy(t.dst, C.OAMS_TRADITIONAL_GIS_ORDER)
+
+	t.trans = C.OCTNewCoordinateTransformation(t.src, t.dst)
+	if t.trans == nil {
+		t.Close()
+		return nil, fmt.Errorf("failed to transform from %s to %s", srcSRS, dstSRS)
+	}
+
+	return t, nil
+}
+
+// transformBBox reprojects the vertices of the rings and returns their
+// bounding box. Vertices which cannot be reprojected are dropped.
+func (t *coordTransform) transformBBox(rings [][]point) bbox {
+	box := emptyBBox()
+	for _, ring := range rings {
+		if len(ring) == 0 {
+			continue
+		}
+
+		dx := make([]C.double, len(ring))
+		dy := make([]C.double, len(ring))
+		success := make([]C.int, len(ring))
+		for i, p := range ring {
+			dx[i] = C.double(p.X)
+			dy[i] = C.double(p.Y)
+		}
+
+		C.OCTTransformEx(t.trans, C.int(len(ring)), &dx[0], &dy[0], nil, &success[0])
+		for i := range ring {
+			if success[i] != 0 {
+				box.extend(float64(dx[i]), float64(dy[i]))
+			}
+		}
+	}
+	return box
+}
+
+func (t *coordTransform) Close() {
+	if t.trans != nil {
+		C.OCTDestroyCoordinateTransformation(t.trans)
+	}
+	C.OSRDestroySpatialReference(t.dst)
+	C.OSRDestroySpatialReference(t.src)
+}
@@ -55,8 +55,20 @@ create or replace function ingest_line()
       md_json jsonb not null
     );
 
+    -- Tombstones of deleted files emitted by incremental crawls
+    create temporary table if not exists mytombstones (
+      tb_hash uuid not null primary key
+    );
+
     set client_min_messages to warning;
 
+    if trim(new.in_type) = 'tombstone' then
+      insert into mytombstones (tb_hash)
+        values (md5(trim(new.in_path))::uuid)
+        on conflict (tb_hash) do nothing;
+      return null;
+    end if;
+
     insert into mymetadata (
       md_hash,
       md_type,
@@ -146,6 +158,14 @@ create or replace function ingested_lines()
 
     drop table mymetadata;
 
+    delete from metadata
+      where md_hash in (select tb_hash from mytombstones);
+
+    delete from paths
+      where pa_hash in (select tb_hash from mytombstones);
+
+    drop table mytombstones;
+
     return null;
   end
 $$;
@@ -25,5 +25,8 @@ select refresh_codegens();
 set search_path to public;
 alter schema ${shard} rename to ${shard}_old;
 alter schema ${shard}_tmp rename to ${shard};
+
+-- Announce the refreshed shard in the change feed of MAS
+select pg_notify('mas_changes', sh_path) from public.shards where sh_code = '${shard}';
 EOD
 )
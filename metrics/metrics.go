@@ -34,6 +34,8 @@ type IndexerInfo struct {
 	GeometryArea float64       `json:"geometry_area"`
 	NumFiles     int           `json:"num_files"`
 	NumGranules  int           `json:"num_granules"`
+	CacheHits    int64         `json:"cache_hits"`
+	CacheMisses  int64         `json:"cache_misses"`
 }
 
 type RPCInfo struct {
@@ -175,7 +177,7 @@ func (i *MetricsInfo) normaliseGeometry() error {
 		}
 
 		srcSRS := C.OSRNewSpatialReference(nil)
-		srsC := C.CString(i.Indexer.SRS)
+		srsC := C.CString(utils.ResolveCRS(i.Indexer.SRS))
 		C.OSRSetFromUserInput(srcSRS, srsC)
 		C.free(unsafe.Pointer(srsC))
 
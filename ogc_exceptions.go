@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/nci/gsky/metrics"
+	"github.com/nci/gsky/utils"
+)
+
+// Images of INIMAGE and BLANK exceptions are at most this size in
+// either dimension
+const maxExceptionImageSize = 4096
+
+// writeOGCException writes an exception report rendered by a template.
+// The message is written as plain text if the template can't be
+// rendered.
+func writeOGCException(w http.ResponseWriter, tplFile string, contentType string, report *utils.OGCExceptionReport, metricsCollector *metrics.MetricsCollector) {
+	metricsCollector.Info.HTTPStatus = report.HTTPStatus
+
+	buf := new(bytes.Buffer)
+	tpl, err := fileResolver.Lookup(tplFile)
+	if err == nil {
+		err = utils.ExecuteWriteTemplateFile(buf, report, tpl)
+	}
+	if err != nil {
+		Error.Printf("Error rendering %s: %v\n", tplFile, err)
+		http.Error(w, report.Message, report.HTTPStatus)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(report.HTTPStatus)
+	w.Write(buf.Bytes())
+}
+
+// writeWMSException writes a WMS exception in the format of the
+// EXCEPTIONS parameter of the request. The exceptions of GetMap requests
+// are drawn into an image for INIMAGE and are a blank image for BLANK,
+// both of which are encoded in the FORMAT of the request and returned
+// with status 200 so that clients display them.
+func writeWMSException(w http.ResponseWriter, params *utils.WMSParams, exc *utils.OGCException, metricsCollector *metrics.MetricsCollector) {
+	version := "1.3.0"
+	if params.Version != nil && *params.Version == "1.1.1" {
+		version = "1.1.1"
+	}
+
+	format := utils.ExceptionsXML
+	if params.Exceptions != nil {
+		format = *params.Exceptions
+	}
+
+	isGetMap := params.Request != nil && *params.Request == "GetMap"
+	if isGetMap && format != utils.ExceptionsXML {
+		width, height := 256, 256
+		if params.Width != nil && *params.Width > 0 && *params.Width <= maxExceptionImageSize {
+			width = *params.Width
+		}
+		if params.Height != nil && *params.Height > 0 && *params.Height <= maxExceptionImageSize {
+			height = *params.Height
+		}
+
+		imageFormat := utils.ExceptionImageFormat("")
+		if params.Format != nil {
+			imageFormat = utils.ExceptionImageFormat(*params.Format)
+		}
+
+		msg := ""
+		if format == utils.ExceptionsInImage {
+			msg = exc.Message
+		}
+		out, err := utils.GetExceptionImage(msg, width, height, imageFormat)
+		if err == nil {
+			metricsCollector.Info.HTTPStatus = 200
+			w.Header().Set("Content-Type", imageFormat)
+			w.Write(out)
+			return
+		}
+		Error.Printf("Error rendering %s exception: %v\n", format, err)
+	}
+
+	contentType := "text/xml"
+	report := &utils.OGCExceptionReport{Version: version, OGCException: exc}
+	if version == "1.1.1" {
+		contentType = "application/vnd.ogc.se_xml"
+		if exc.Code == utils.InvalidCRS {
+			e := *exc
+			e.Code = "InvalidSRS"
+			report.OGCException = &e
+		}
+	}
+	writeOGCException(w, "templates/WMS_ServiceException.tpl", contentType, report, metricsCollector)
+}
+
+// writeWCSException writes a WCS 1.0.0 exception report
+func writeWCSException(w http.ResponseWriter, exc *utils.OGCException, metricsCollector *metrics.MetricsCollector) {
+	report := &utils.OGCExceptionReport{Version: "1.0.0", OGCException: exc}
+	writeOGCException(w, "templates/WCS_ServiceException.tpl", "application/vnd.ogc.se_xml", report, metricsCollector)
+}
+
+// writeWPSException writes a WPS 1.0.0 exception report
+func writeWPSException(w http.ResponseWriter, exc *utils.OGCException, metricsCollector *metrics.MetricsCollector) {
+	report := &utils.OGCExceptionReport{Version: "1.0.0", OGCException: exc}
+	writeOGCException(w, "templates/WPS_ExceptionReport.tpl", "text/xml", report, metricsCollector)
+}
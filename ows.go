@@ -37,6 +37,8 @@ import (
 	"github.com/nci/gsky/metrics"
 	proc "github.com/nci/gsky/processor"
 	"github.com/nci/gsky/utils"
+	gp "github.com/nci/gsky/worker/gdalprocess"
+	pb "github.com/nci/gsky/worker/gdalservice"
 
 	geo "github.com/nci/geometry"
 )
@@ -48,14 +50,20 @@ var mutex *sync.Mutex
 var fileResolver *utils.RuntimeFileResolver
 var builtinPalettes *utils.BuiltinPalettes
 var (
-	port            = flag.Int("p", 8080, "Server listening port.")
-	serverDataDir   = flag.String("data_dir", utils.DataDir, "Server data directory.")
-	serverConfigDir = flag.String("conf_dir", utils.EtcDir, "Server config directory.")
-	serverLogDir    = flag.String("log_dir", "", "Server log directory.")
-	validateConfig  = flag.Bool("check_conf", false, "Validate server config files.")
-	dumpConfig      = flag.Bool("dump_conf", false, "Dump server config files.")
-	verbose         = flag.Bool("v", false, "Verbose mode for more server outputs.")
-	version         = flag.Bool("version", false, "Get GSKY version")
+	port             = flag.Int("p", 8080, "Server listening port.")
+	serverDataDir    = flag.String("data_dir", utils.DataDir, "Server data directory.")
+	serverConfigDir  = flag.String("conf_dir", utils.EtcDir, "Server config directory.")
+	serverLogDir     = flag.String("log_dir", "", "Server log directory.")
+	validateConfig   = flag.Bool("check_conf", false, "Validate server config files.")
+	dumpConfig       = flag.Bool("dump_conf", false, "Dump server config files.")
+	verbose          = flag.Bool("v", false, "Verbose mode for more server outputs.")
+	version          = flag.Bool("version", false, "Get GSKY version")
+	masChanges       = flag.Bool("mas_changes", false, "Subscribe to the MAS change feeds to refresh layer dates and cached capabilities as data is ingested.")
+	indexerCacheSize = flag.Int("indexer_cache_size", proc.DefaultIndexerCacheSize, "Size in bytes of the cache of MAS intersects responses, 0 to disable.")
+	indexerCacheTTL  = flag.Duration("indexer_cache_ttl", proc.DefaultIndexerCacheTTL, "Lifetime of cached MAS intersects responses, 0 to disable the cache.")
+	climCacheSize    = flag.Int("climatology_cache_size", proc.DefaultClimatologyCacheSize, "Size in bytes of the cache of climatology tiles of anomaly layers, 0 to disable.")
+	climCacheTTL     = flag.Duration("climatology_cache_ttl", proc.DefaultClimatologyCacheTTL, "Lifetime of cached climatology tiles, 0 to disable the cache.")
+	localWorkers     = flag.Int("local_workers", runtime.NumCPU(), "Maximum number of tasks run concurrently in-process when worker_nodes is empty or \"local\".")
 )
 
 var reWMSMap map[string]*regexp.Regexp
@@ -95,6 +103,9 @@ func init() {
 		"templates/WMS_GetCapabilities.tpl",
 		"templates/WMS_DescribeLayer.tpl",
 		"templates/WMS_ServiceException.tpl",
+		"templates/WMS_FeatureInfo.tpl",
+		"templates/WCS_ServiceException.tpl",
+		"templates/WPS_ExceptionReport.tpl",
 		"templates/WPS_DescribeProcess.tpl",
 		"templates/WPS_Execute.tpl",
 		"templates/WPS_GetCapabilities.tpl",
@@ -110,7 +121,11 @@ func init() {
 		}
 	}
 
+	utils.GetWorkerManager().SetLocalExecutor(gp.NewLocalExecutor(*localWorkers))
+
 	http.DefaultTransport.(*http.Transport).MaxConnsPerHost = proc.DefaultMASMaxConnsPerHost
+	proc.SetIndexerCache(*indexerCacheSize, *indexerCacheTTL)
+	proc.SetClimatologyCache(*climCacheSize, *climCacheTTL)
 	confMap, err := utils.LoadAllConfigFiles(utils.EtcDir, *verbose)
 	if err != nil {
 		Error.Printf("Error in loading config files: %v\n", err)
@@ -138,6 +153,10 @@ func init() {
 
 	mutex = &sync.Mutex{}
 
+	if *masChanges {
+		watchMASChanges(confMap)
+	}
+
 	builtinPalettes = utils.NewBuiltinPalettes()
 
 	reWMSMap = utils.CompileWMSRegexMap()
@@ -178,8 +197,7 @@ func init() {
 func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r *http.Request, w http.ResponseWriter, metricsCollector *metrics.MetricsCollector) {
 
 	if params.Request == nil {
-		metricsCollector.Info.HTTPStatus = 400
-		http.Error(w, "Malformed WMS, a Request field needs to be specified", 400)
+		writeWMSException(w, &params, utils.NewOGCException(400, utils.MissingParameterValue, "request", "Malformed WMS, a Request field needs to be specified"), metricsCollector)
 		return
 	}
 
@@ -188,56 +206,15 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 	switch *params.Request {
 	case "GetCapabilities":
 		if params.Version != nil && !utils.CheckWMSVersion(*params.Version) {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("This server can only accept WMS requests compliant with version 1.1.1 and 1.3.0: %s", reqURL), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.InvalidParameterValue, "version", fmt.Sprintf("This server can only accept WMS requests compliant with version 1.1.1 and 1.3.0: %s", reqURL)), metricsCollector)
 			return
 		}
 
-		urlPath := strings.Trim(r.URL.Path, "/")
-		query := fmt.Sprintf("wms_getcaps_%s", urlPath)
-		gpath := utils.FindConfigGPath(conf)
-		owsCache := utils.NewOWSCache(conf.ServiceConfig.MASAddress, gpath, *verbose)
-		newConf, err := owsCache.GetConfig(query)
-		cacheMiss := false
-		if err != nil {
-			if *verbose {
-				log.Printf("WMS GetCapabilities get cache error: %v", err)
-			}
-			cacheMiss = true
-		} else if newConf == nil {
-			cacheMiss = true
-		} else if len(newConf.Layers) == 0 {
-			cacheMiss = true
-		}
-
-		if cacheMiss {
-			newConf = conf.Copy(r)
-			err = utils.LoadConfigTimestamps(newConf, *verbose)
-			if err != nil {
-				log.Printf("WMS GetCapabilities LoadConfigTimestamps error: %v", err)
-			}
-		}
-
+		newConf := getCapabilitiesConfig(conf, r, "wms")
 		tpl, _ := fileResolver.Lookup("templates/WMS_GetCapabilities.tpl")
-		err = utils.ExecuteWriteTemplateFile(w, newConf, tpl)
+		err := utils.ExecuteWriteTemplateFile(w, newConf, tpl)
 		if err != nil {
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, err.Error(), 500)
-		}
-
-		if cacheMiss {
-			jsonBytes, mErr := json.Marshal(newConf)
-			if mErr == nil {
-				err = owsCache.Put(query, string(jsonBytes))
-				if err != nil {
-					if *verbose {
-						log.Printf("WMS GetCapabilities put cache error: %v", err)
-					}
-				}
-			} else {
-				log.Printf("json.Marshal failed for WMS GetCapabilities")
-			}
-
+			writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 		}
 
 		for iLayer := range conf.Layers {
@@ -250,26 +227,32 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 		}
 
 	case "GetFeatureInfo":
+		infoFormat := utils.FeatureInfoJSON
+		if params.InfoFormat != nil {
+			infoFormat = utils.ParseFeatureInfoFormat(*params.InfoFormat)
+			if len(infoFormat) == 0 {
+				writeWMSException(w, &params, utils.NewOGCException(400, utils.InvalidFormat, "info_format", fmt.Sprintf("Unsupported info_format: %s", *params.InfoFormat)), metricsCollector)
+				return
+			}
+		}
+
 		x, y, err := utils.GetCoordinates(params)
 		if err != nil {
 			Error.Printf("%s\n", err)
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Malformed WMS GetFeatureInfo request: %v", err), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.MissingParameterValue, "", fmt.Sprintf("Malformed WMS GetFeatureInfo request: %v", err)), metricsCollector)
 			return
 		}
 
 		if params.Time == nil {
 			idx, err := utils.GetLayerIndex(params, conf)
 			if err != nil {
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, fmt.Sprintf("Malformed getFeatureInfo request: %s", reqURL), 400)
+				writeWMSException(w, &params, utils.NewOGCException(400, utils.LayerNotDefined, "layers", fmt.Sprintf("Malformed getFeatureInfo request: %s", reqURL)), metricsCollector)
 				return
 			}
 
 			currentTime, err := utils.GetCurrentTimeStamp(conf.Layers[idx].Dates)
 			if err != nil {
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, fmt.Sprintf("%v: %s", err, reqURL), 400)
+				writeWMSException(w, &params, utils.NewOGCException(400, utils.InvalidDimensionValue, "time", fmt.Sprintf("%v: %s", err, reqURL)), metricsCollector)
 				return
 			}
 			params.Time = currentTime
@@ -279,91 +262,103 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 		for _, axis := range params.Axes {
 			if axis.Name == utils.WeightedTimeAxis {
 				for _, val := range axis.InValues {
-					t := time.Unix(int64(val), 0).UTC().Format(utils.ISOFormat)
-					times = append(times, fmt.Sprintf(`"%s"`, t))
+					times = append(times, time.Unix(int64(val), 0).UTC().Format(utils.ISOFormat))
 				}
 			}
 		}
 
-		var timeStr string
+		featInfo, err := proc.GetFeatureInfo(ctx, params, conf, getConfigMap(), *verbose, metricsCollector)
+		if err != nil {
+			featInfo = &utils.FeatureInfo{Error: err.Error()}
+			Error.Printf("%v\n", err)
+		}
+
+		featInfo.X = x
+		featInfo.Y = y
 		if len(times) > 0 {
-			timeStr = fmt.Sprintf(`"times": [%s]`, strings.Join(times, ","))
+			featInfo.Times = times
 		} else {
-			timeStr = fmt.Sprintf(`"time": "%s"`, (*params.Time).Format(utils.ISOFormat))
+			featInfo.Time = (*params.Time).Format(utils.ISOFormat)
 		}
 
-		feat_info, err := proc.GetFeatureInfo(ctx, params, conf, getConfigMap(), *verbose, metricsCollector)
-		if err != nil {
-			feat_info = fmt.Sprintf(`"error": "%v"`, err)
-			Error.Printf("%v\n", err)
+		w.Header().Set("Content-Type", infoFormat)
+		if infoFormat == utils.FeatureInfoHTML {
+			tpl, _ := fileResolver.Lookup("templates/WMS_FeatureInfo.tpl")
+			err = utils.ExecuteWriteTemplateFile(w, featInfo, tpl)
+			if err != nil {
+				Error.Printf("%v\n", err)
+			}
+			return
 		}
 
-		resp := fmt.Sprintf(`{"type":"FeatureCollection","features":[{"type":"Feature","properties":{"x":%f, "y":%f, %s, %s}}]}`, x, y, timeStr, feat_info)
-		w.Write([]byte(resp))
+		out, err := utils.EncodeFeatureInfo(featInfo, infoFormat)
+		if err != nil {
+			writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
+			return
+		}
+		w.Write(out)
 
 	case "DescribeLayer":
 		conf = conf.Copy(r)
 		idx, err := utils.GetLayerIndex(params, conf)
 		if err != nil {
 			Error.Printf("%s\n", err)
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Malformed WMS DescribeLayer request: %v", err), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.LayerNotDefined, "layers", fmt.Sprintf("Malformed WMS DescribeLayer request: %v", err)), metricsCollector)
 			return
 		}
 
 		tpl, _ := fileResolver.Lookup("templates/WMS_DescribeLayer.tpl")
 		err = utils.ExecuteWriteTemplateFile(w, conf.Layers[idx], tpl)
 		if err != nil {
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, err.Error(), 500)
+			writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 		}
 
 	case "GetMap":
 		if params.Version == nil || !utils.CheckWMSVersion(*params.Version) {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("This server can only accept WMS requests compliant with version 1.1.1 and 1.3.0: %s", reqURL), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.InvalidParameterValue, "version", fmt.Sprintf("This server can only accept WMS requests compliant with version 1.1.1 and 1.3.0: %s", reqURL)), metricsCollector)
 			return
 		}
 
 		idx, err := utils.GetLayerIndex(params, conf)
 		if err != nil {
 			Error.Printf("%s\n", err)
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Malformed WMS GetMap request: %v", err), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.LayerNotDefined, "layers", fmt.Sprintf("Malformed WMS GetMap request: %v", err)), metricsCollector)
 			return
 		}
 		if params.Time == nil {
 			currentTime, err := utils.GetCurrentTimeStamp(conf.Layers[idx].Dates)
 			if err != nil {
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, fmt.Sprintf("%v: %s", err, reqURL), 400)
+				writeWMSException(w, &params, utils.NewOGCException(400, utils.InvalidDimensionValue, "time", fmt.Sprintf("%v: %s", err, reqURL)), metricsCollector)
 				return
 			}
 			params.Time = currentTime
 		}
 		if params.CRS == nil {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Request %s should contain a valid ISO 'crs/srs' parameter.", reqURL), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.MissingParameterValue, "crs", fmt.Sprintf("Request %s should contain a valid ISO 'crs/srs' parameter.", reqURL)), metricsCollector)
 			return
 		}
 		if len(params.BBox) != 4 {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Request %s should contain a valid 'bbox' parameter.", reqURL), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.MissingParameterValue, "bbox", fmt.Sprintf("Request %s should contain a valid 'bbox' parameter.", reqURL)), metricsCollector)
 			return
 		}
 		if params.Height == nil || params.Width == nil {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Request %s should contain valid 'width' and 'height' parameters.", reqURL), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.MissingParameterValue, "width", fmt.Sprintf("Request %s should contain valid 'width' and 'height' parameters.", reqURL)), metricsCollector)
 			return
 		}
 
-		if strings.ToUpper(*params.CRS) == "EPSG:4326" && *params.Version == "1.3.0" {
+		if !utils.CheckLayerCRS(&conf.Layers[idx], *params.CRS) {
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.InvalidCRS, "crs", fmt.Sprintf("Layer %s doesn't support CRS %s", conf.Layers[idx].Name, *params.CRS)), metricsCollector)
+			return
+		}
+
+		if utils.CRSHasLatLonOrder(*params.CRS) && *params.Version == "1.3.0" {
 			params.BBox = []float64{params.BBox[1], params.BBox[0], params.BBox[3], params.BBox[2]}
 		}
 
 		if strings.ToUpper(*params.CRS) == "CRS:84" && *params.Version == "1.3.0" {
 			*params.CRS = "EPSG:4326"
 		}
+		params.BBox = utils.UnwrapAntimeridian(*params.CRS, params.BBox)
 
 		var endTime *time.Time
 		if conf.Layers[idx].Accum == true {
@@ -373,15 +368,14 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 		}
 
 		if *params.Height > conf.Layers[idx].WmsMaxHeight || *params.Width > conf.Layers[idx].WmsMaxWidth {
-			http.Error(w, fmt.Sprintf("Requested width/height is too large, max width:%d, height:%d", conf.Layers[idx].WmsMaxWidth, conf.Layers[idx].WmsMaxHeight), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.InvalidParameterValue, "width", fmt.Sprintf("Requested width/height is too large, max width:%d, height:%d", conf.Layers[idx].WmsMaxWidth, conf.Layers[idx].WmsMaxHeight)), metricsCollector)
 			return
 		}
 
 		styleIdx, err := utils.GetLayerStyleIndex(params, conf, idx)
 		if err != nil {
 			Error.Printf("%s\n", err)
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Malformed WMS GetMap request: %v", err), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.StyleNotDefined, "styles", fmt.Sprintf("Malformed WMS GetMap request: %v", err)), metricsCollector)
 			return
 		}
 
@@ -392,8 +386,7 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 
 		if utils.CheckDisableServices(styleLayer, "wms") {
 			Error.Printf("WMS GetMap is disabled for this layer")
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, "WMS GetMap is disabled for this layer", 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.OperationNotSupported, "request", "WMS GetMap is disabled for this layer"), metricsCollector)
 			return
 		}
 
@@ -423,8 +416,7 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 			if !foundPalette {
 				msg := fmt.Sprintf("Requested palette not found: %s", *params.Palette)
 				Error.Printf(msg)
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, msg, 400)
+				writeWMSException(w, &params, utils.NewOGCException(400, utils.InvalidParameterValue, "palette", msg), metricsCollector)
 				return
 			}
 		}
@@ -462,6 +454,7 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 			IndexTileYSize:      conf.Layers[idx].IndexTileYSize,
 			SpatialExtent:       conf.Layers[idx].SpatialExtent,
 			IndexResLimit:       conf.Layers[idx].IndexResLimit,
+			Unpack:              conf.Layers[idx].Unpack,
 			MasQueryHint:        conf.Layers[idx].MasQueryHint,
 			ReqRes:              reqRes,
 			SRSCf:               conf.Layers[idx].SRSCf,
@@ -488,16 +481,14 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 			if len(params.BandExpr.Expressions) > 0 && len(params.BandExpr.Expressions) != 1 && len(params.BandExpr.Expressions) != 3 {
 				err = fmt.Errorf("Number of band expressions must be either 1 or 3 for WMS")
 				Error.Printf("%s\n", err)
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, fmt.Sprintf("Malformed WMS GetMap request: %v", err), 400)
+				writeWMSException(w, &params, utils.NewOGCException(400, utils.InvalidParameterValue, "", fmt.Sprintf("Malformed WMS GetMap request: %v", err)), metricsCollector)
 				return
 			}
 
 			err := utils.CheckBandExpressionsComplexity(params.BandExpr, conf.Layers[idx].WmsBandExpressionCriteria)
 			if err != nil {
 				Error.Printf("%s\n", err)
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, fmt.Sprintf("Malformed WMS GetMap request: %v", err), 400)
+				writeWMSException(w, &params, utils.NewOGCException(400, utils.InvalidParameterValue, "", fmt.Sprintf("Malformed WMS GetMap request: %v", err)), metricsCollector)
 				return
 			}
 
@@ -532,8 +523,7 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 				out, err := utils.GetEmptyTile(zoomFile, *params.Height, *params.Width)
 				if err != nil {
 					Info.Printf("Error in the utils.GetEmptyTile(zoom.png): %v\n", err)
-					metricsCollector.Info.HTTPStatus = 500
-					http.Error(w, err.Error(), 500)
+					writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 					return
 				}
 				w.Write(out)
@@ -541,8 +531,7 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 				out, err := utils.GetEmptyTile("", *params.Height, *params.Width)
 				if err != nil {
 					Info.Printf("Error in the utils.GetEmptyTile(): %v\n", err)
-					metricsCollector.Info.HTTPStatus = 500
-					http.Error(w, err.Error(), 500)
+					writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 				} else {
 					w.Write(out)
 				}
@@ -562,8 +551,7 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 			norm, err := utils.Scale(res, scaleParams)
 			if err != nil {
 				Info.Printf("Error in the utils.Scale: %v\n", err)
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, err.Error(), 500)
+				writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 				return
 			}
 
@@ -571,8 +559,7 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 				out, err := utils.GetEmptyTile(conf.Layers[idx].NoDataLegendPath, *params.Height, *params.Width)
 				if err != nil {
 					Info.Printf("Error in the utils.GetEmptyTile(): %v\n", err)
-					metricsCollector.Info.HTTPStatus = 500
-					http.Error(w, err.Error(), 500)
+					writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 				} else {
 					w.Write(out)
 				}
@@ -582,23 +569,19 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 			out, err := utils.EncodePNG(norm, palette)
 			if err != nil {
 				Info.Printf("Error in the utils.EncodePNG: %v\n", err)
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, err.Error(), 500)
+				writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 				return
 			}
 			w.Write(out)
 		case err := <-errChan:
 			Info.Printf("Error in the pipeline: %v\n", err)
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, err.Error(), 500)
+			writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 		case <-ctx.Done():
 			Error.Printf("Context cancelled with message: %v\n", ctx.Err())
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, ctx.Err().Error(), 500)
+			writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", ctx.Err().Error()), metricsCollector)
 		case <-timeoutCtx.Done():
 			Error.Printf("WMS pipeline timed out, threshold:%v seconds", conf.Layers[idx].WmsTimeout)
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, "WMS request timed out", 500)
+			writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", "WMS request timed out"), metricsCollector)
 		}
 		return
 
@@ -607,19 +590,16 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 		if err != nil {
 			Error.Printf("%s\n", err)
 			if len(params.Layers) > 0 {
-				tpl, _ := fileResolver.Lookup("templates/WMS_ServiceException.tpl")
-				utils.ExecuteWriteTemplateFile(w, params.Layers[0], tpl)
+				writeWMSException(w, &params, utils.NewOGCException(400, utils.LayerNotDefined, "layer", fmt.Sprintf("%s no such layer on this server.", params.Layers[0])), metricsCollector)
 			} else {
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, err.Error(), 400)
+				writeWMSException(w, &params, utils.NewOGCException(400, utils.MissingParameterValue, "layer", err.Error()), metricsCollector)
 			}
 			return
 		}
 		styleIdx, err := utils.GetLayerStyleIndex(params, conf, idx)
 		if err != nil {
 			Error.Printf("%s\n", err)
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Malformed WMS GetMap request: %v", err), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.StyleNotDefined, "styles", fmt.Sprintf("Malformed WMS GetMap request: %v", err)), metricsCollector)
 			return
 		}
 
@@ -631,23 +611,33 @@ func serveWMS(ctx context.Context, params utils.WMSParams, conf *utils.Config, r
 		b, err := ioutil.ReadFile(styleLayer.LegendPath)
 		if err != nil {
 			Error.Printf("Error reading legend image: %v, %v\n", styleLayer.LegendPath, err)
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, "Legend graphics not found", 500)
+			writeWMSException(w, &params, utils.NewOGCException(500, utils.NoApplicableCode, "", "Legend graphics not found"), metricsCollector)
 			return
 		}
 		w.Write(b)
 
 	default:
-		metricsCollector.Info.HTTPStatus = 400
-		http.Error(w, fmt.Sprintf("%s not recognised.", *params.Request), 400)
+		writeWMSException(w, &params, utils.NewOGCException(400, utils.OperationNotSupported, "request", fmt.Sprintf("%s not recognised.", *params.Request)), metricsCollector)
 	}
 
 }
 
+// coverageDescription is the layer, the output bands and the CRSs of a
+// WCS DescribeCoverage response
+type coverageDescription struct {
+	utils.Layer
+	Bands []coverageBand
+	CRS   []string
+}
+
+type coverageBand struct {
+	Name  string
+	Units string
+}
+
 func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r *http.Request, w http.ResponseWriter, query map[string][]string, metricsCollector *metrics.MetricsCollector) {
 	if params.Request == nil {
-		metricsCollector.Info.HTTPStatus = 400
-		http.Error(w, "Malformed WCS, a Request field needs to be specified", 400)
+		writeWCSException(w, utils.NewOGCException(400, utils.MissingParameterValue, "request", "Malformed WCS, a Request field needs to be specified"), metricsCollector)
 	}
 
 	reqURL := r.URL.String()
@@ -655,90 +645,104 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 	switch *params.Request {
 	case "GetCapabilities":
 		if params.Version != nil && !utils.CheckWCSVersion(*params.Version) {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("This server can only accept WCS requests compliant with version 1.0.0: %s", reqURL), 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "version", fmt.Sprintf("This server can only accept WCS requests compliant with version 1.0.0: %s", reqURL)), metricsCollector)
 			return
 		}
 
-		newConf := conf.Copy(r)
+		newConf := getCapabilitiesConfig(conf, r, "wcs")
 		for iLayer := range conf.Layers {
-			if len(conf.Layers[iLayer].EffectiveStartDate) == 0 {
-				newConf.GetLayerDates(iLayer, *verbose)
-				if len(newConf.Layers[iLayer].EffectiveStartDate) > 0 {
-					mutex.Lock()
-					conf.Layers[iLayer].EffectiveStartDate = newConf.Layers[iLayer].EffectiveStartDate
-					conf.Layers[iLayer].EffectiveEndDate = newConf.Layers[iLayer].EffectiveEndDate
-					mutex.Unlock()
-				}
+			if len(conf.Layers[iLayer].EffectiveStartDate) == 0 && len(newConf.Layers[iLayer].EffectiveStartDate) > 0 {
+				mutex.Lock()
+				conf.Layers[iLayer].EffectiveStartDate = newConf.Layers[iLayer].EffectiveStartDate
+				conf.Layers[iLayer].EffectiveEndDate = newConf.Layers[iLayer].EffectiveEndDate
+				mutex.Unlock()
 			}
 		}
 
 		tpl, _ := fileResolver.Lookup("templates/WCS_GetCapabilities.tpl")
-		err := utils.ExecuteWriteTemplateFile(w, &newConf, tpl)
+		err := utils.ExecuteWriteTemplateFile(w, newConf, tpl)
 		if err != nil {
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, err.Error(), 500)
+			writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 		}
 
 	case "DescribeCoverage":
 		idx, err := utils.GetCoverageIndex(params, conf)
 		if err != nil {
 			Info.Printf("Error in the pipeline: %v\n", err)
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Malformed WMS DescribeCoverage request: %v", err), 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.CoverageNotDefined, "coverage", fmt.Sprintf("Malformed WCS DescribeCoverage request: %v", err)), metricsCollector)
 			return
 		}
 
 		newConf := conf.Copy(r)
 		newConf.GetLayerDates(idx, *verbose)
 
+		coverage := &coverageDescription{Layer: newConf.Layers[idx], CRS: []string{"EPSG:4326"}}
+		if len(newConf.Layers[idx].SupportedCRS) > 0 {
+			// WCS outputs can only be encoded in EPSG CRSs
+			coverage.CRS = nil
+			for _, crs := range newConf.Layers[idx].SupportedCRS {
+				if _, err := utils.ExtractEPSGCode(crs); err == nil {
+					coverage.CRS = append(coverage.CRS, crs)
+				}
+			}
+		}
+		units := proc.GetLayerUnits(ctx, conf, idx, *verbose)
+		for _, name := range newConf.Layers[idx].RGBExpressions.ExprNames {
+			coverage.Bands = append(coverage.Bands, coverageBand{Name: name, Units: units[name]})
+		}
+
 		tpl, _ := fileResolver.Lookup("templates/WCS_DescribeCoverage.tpl")
-		err = utils.ExecuteWriteTemplateFile(w, newConf.Layers[idx], tpl)
+		err = utils.ExecuteWriteTemplateFile(w, coverage, tpl)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 		}
 
 	case "GetCoverage":
 		if params.Version == nil || !utils.CheckWCSVersion(*params.Version) {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("This server can only accept WCS requests compliant with version 1.0.0: %s", reqURL), 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "version", fmt.Sprintf("This server can only accept WCS requests compliant with version 1.0.0: %s", reqURL)), metricsCollector)
 			return
 		}
 
 		idx, err := utils.GetCoverageIndex(params, conf)
 		if err != nil {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("%v: %s", err, reqURL), 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.CoverageNotDefined, "coverage", fmt.Sprintf("%v: %s", err, reqURL)), metricsCollector)
 			return
 		}
 
 		if params.Time == nil {
 			currentTime, err := utils.GetCurrentTimeStamp(conf.Layers[idx].Dates)
 			if err != nil {
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, fmt.Sprintf("%v: %s", err, reqURL), 400)
+				writeWCSException(w, utils.NewOGCException(400, utils.InvalidDimensionValue, "time", fmt.Sprintf("%v: %s", err, reqURL)), metricsCollector)
 				return
 			}
 			params.Time = currentTime
 		}
 		if params.CRS == nil {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Request %s should contain a valid ISO 'crs/srs' parameter.", reqURL), 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.MissingParameterValue, "crs", fmt.Sprintf("Request %s should contain a valid ISO 'crs/srs' parameter.", reqURL)), metricsCollector)
 			return
 		}
 		if len(params.BBox) != 4 {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Request %s should contain a valid 'bbox' parameter.", reqURL), 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.MissingParameterValue, "bbox", fmt.Sprintf("Request %s should contain a valid 'bbox' parameter.", reqURL)), metricsCollector)
 			return
 		}
+		if !utils.CheckLayerCRS(&conf.Layers[idx], *params.CRS) {
+			writeWCSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "crs", fmt.Sprintf("Coverage %s doesn't support CRS %s", conf.Layers[idx].Name, *params.CRS)), metricsCollector)
+			return
+		}
+		// Coverages are encoded in EPSG CRSs only, which CRS aliases
+		// such as GSKY:1 aren't
+		epsg, err := utils.ExtractEPSGCode(*params.CRS)
+		if err != nil {
+			writeWCSException(w, utils.NewOGCException(400, utils.InvalidCRS, "crs", fmt.Sprintf("Coverage %s can't be encoded in CRS %s, which isn't an EPSG CRS", conf.Layers[idx].Name, *params.CRS)), metricsCollector)
+			return
+		}
+		params.BBox = utils.UnwrapAntimeridian(*params.CRS, params.BBox)
 		if params.Height == nil || params.Width == nil {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Request %s should contain valid 'width' and 'height' parameters.", reqURL), 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.MissingParameterValue, "width", fmt.Sprintf("Request %s should contain valid 'width' and 'height' parameters.", reqURL)), metricsCollector)
 			return
 		}
 		if params.Format == nil {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Unsupported encoding format"), 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.InvalidFormat, "format", fmt.Sprintf("Unsupported encoding format")), metricsCollector)
 			return
 		}
 
@@ -752,15 +756,13 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 		styleIdx, err := utils.GetCoverageStyleIndex(params, conf, idx)
 		if err != nil {
 			Error.Printf("%s\n", err)
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Malformed WCS GetCoverage request: %v", err), 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "styles", fmt.Sprintf("Malformed WCS GetCoverage request: %v", err)), metricsCollector)
 			return
 		} else if styleIdx < 0 {
 			styleCount := len(conf.Layers[idx].Styles)
 			if styleCount > 1 && params.BandExpr == nil {
 				Error.Printf("WCS style not specified")
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, "WCS style not specified", 400)
+				writeWCSException(w, utils.NewOGCException(400, utils.MissingParameterValue, "styles", "WCS style not specified"), metricsCollector)
 				return
 			} else if styleCount == 1 {
 				styleIdx = 0
@@ -774,8 +776,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 
 		if utils.CheckDisableServices(styleLayer, "wcs") {
 			Error.Printf("WCS GetCoverage is disabled for this layer")
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, "WCS GetCoverage is disabled for this layer", 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.OperationNotSupported, "request", "WCS GetCoverage is disabled for this layer"), metricsCollector)
 			return
 		}
 
@@ -783,8 +784,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 			err := utils.CheckBandExpressionsComplexity(params.BandExpr, conf.Layers[idx].WcsBandExpressionCriteria)
 			if err != nil {
 				Error.Printf("%s\n", err)
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, fmt.Sprintf("Malformed WCS GetCoverage request: %v", err), 400)
+				writeWCSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "", fmt.Sprintf("Malformed WCS GetCoverage request: %v", err)), metricsCollector)
 				return
 			}
 		}
@@ -822,9 +822,11 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 				IndexTileYSize:      conf.Layers[idx].IndexTileYSize,
 				SpatialExtent:       conf.Layers[idx].SpatialExtent,
 				IndexResLimit:       conf.Layers[idx].IndexResLimit,
+				Unpack:              conf.Layers[idx].Unpack,
 				MasQueryHint:        conf.Layers[idx].MasQueryHint,
 				SRSCf:               conf.Layers[idx].SRSCf,
 				FusionUnscale:       1,
+				Priority:            pb.Priority_BULK,
 				MetricsCollector:    metricsCollector,
 			},
 				Collection: styleLayer.DataSource,
@@ -862,19 +864,11 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 		defer ctxCancel()
 		errChan := make(chan error, 100)
 
-		epsg, err := utils.ExtractEPSGCode(*params.CRS)
-		if err != nil {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Invalid CRS code %s", *params.CRS), 400)
-			return
-		}
-
 		if *params.Width <= 0 || *params.Height <= 0 {
 			if isWorker {
 				msg := "WCS: worker width or height negative"
 				Info.Printf(msg)
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, msg, 500)
+				writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", msg), metricsCollector)
 				return
 			}
 
@@ -897,16 +891,14 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 			} else {
 				errMsg := "WCS: failed to compute output extent"
 				Info.Printf(errMsg, err)
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, errMsg, 500)
+				writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", errMsg), metricsCollector)
 				return
 			}
 
 		}
 
 		if *params.Height > conf.Layers[idx].WcsMaxHeight || *params.Width > conf.Layers[idx].WcsMaxWidth {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Requested width/height is too large, max width:%d, height:%d", conf.Layers[idx].WcsMaxWidth, conf.Layers[idx].WcsMaxHeight), 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "width", fmt.Sprintf("Requested width/height is too large, max width:%d, height:%d", conf.Layers[idx].WcsMaxWidth, conf.Layers[idx].WcsMaxHeight)), metricsCollector)
 			return
 		}
 
@@ -977,8 +969,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 		} else {
 			for _, qParams := range []string{"wwidth", "wheight", "woffx", "woffy"} {
 				if len(query[qParams]) != len(query["wbbox"]) {
-					metricsCollector.Info.HTTPStatus = 400
-					http.Error(w, fmt.Sprintf("worker parameter %v has different length from wbbox: %v", qParams, reqURL), 400)
+					writeWCSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "", fmt.Sprintf("worker parameter %v has different length from wbbox: %v", qParams, reqURL)), metricsCollector)
 					return
 				}
 			}
@@ -1006,8 +997,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 
 				workerParams, err := utils.WMSParamsChecker(wParams, reWMSMap)
 				if err != nil {
-					metricsCollector.Info.HTTPStatus = 400
-					http.Error(w, fmt.Sprintf("worker parameter error: %v", err), 400)
+					writeWCSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "", fmt.Sprintf("worker parameter error: %v", err)), metricsCollector)
 					return
 				}
 
@@ -1046,8 +1036,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 				if err != nil {
 					errMsg := fmt.Sprintf("WCS: worker NewRequest error: %v", err)
 					Info.Printf(errMsg)
-					metricsCollector.Info.HTTPStatus = 500
-					http.Error(w, errMsg, 500)
+					writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", errMsg), metricsCollector)
 					return
 				}
 				defer trans.CancelRequest(req)
@@ -1056,8 +1045,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 				if err != nil {
 					errMsg := fmt.Sprintf("WCS: failed to create raster temp file for WCS worker: %v", err)
 					Info.Printf(errMsg)
-					metricsCollector.Info.HTTPStatus = 500
-					http.Error(w, errMsg, 500)
+					writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", errMsg), metricsCollector)
 					return
 				}
 				tempFileHandle.Close()
@@ -1107,6 +1095,8 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 		var bandNames []string
 
 		tp := proc.InitTilePipeline(ctx, styleLayer.MASAddress, conf.ServiceConfig.WorkerNodes, conf.Layers[idx].MaxGrpcRecvMsgSize, conf.Layers[idx].WcsPolygonShardConcLimit, conf.ServiceConfig.MaxGrpcBufferSize, errChan)
+		tp.CurrentLayer = styleLayer
+		tp.DataSources = getConfigMap()
 		for ir, geoReq := range workerTileRequests[0] {
 			if *verbose {
 				Info.Printf("WCS: processing tile (%d of %d): xOff:%v, yOff:%v, width:%v, height:%v", ir+1, len(workerTileRequests[0]), geoReq.OffX, geoReq.OffY, geoReq.Width, geoReq.Height)
@@ -1141,8 +1131,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 						utils.RemoveGdalTempFile(masterTempFile)
 						errMsg := fmt.Sprintf("EncodeGdalOpen() failed: %v", err)
 						Info.Printf(errMsg)
-						metricsCollector.Info.HTTPStatus = 500
-						http.Error(w, errMsg, 500)
+						writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", errMsg), metricsCollector)
 						return
 					}
 					defer utils.EncodeGdalClose(&hDstDS)
@@ -1154,31 +1143,26 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 				bn, err := utils.EncodeGdal(hDstDS, res, geoReq.OffX, geoReq.OffY)
 				if err != nil {
 					Info.Printf("Error in the utils.EncodeGdal: %v\n", err)
-					metricsCollector.Info.HTTPStatus = 500
-					http.Error(w, err.Error(), 500)
+					writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 					return
 				}
 				bandNames = bn
 
 			case err := <-errChan:
 				Info.Printf("WCS: error in the pipeline: %v\n", err)
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, err.Error(), 500)
+				writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 				return
 			case err := <-workerErrChan:
 				Info.Printf("WCS worker error: %v\n", err)
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, err.Error(), 500)
+				writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 				return
 			case <-ctx.Done():
 				Error.Printf("Context cancelled with message: %v\n", ctx.Err())
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, ctx.Err().Error(), 500)
+				writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", ctx.Err().Error()), metricsCollector)
 				return
 			case <-timeoutCtx.Done():
 				Error.Printf("WCS pipeline timed out, threshold:%v seconds", conf.Layers[idx].WcsTimeout)
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, "WCS pipeline timed out", 500)
+				writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", "WCS pipeline timed out"), metricsCollector)
 				return
 			}
 
@@ -1213,8 +1197,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 					err := utils.EncodeGdalMerge(ctx, hDstDS, "geotiff", workerTempFileName, width, height, offX, offY)
 					if err != nil {
 						Info.Printf("%v\n", err)
-						metricsCollector.Info.HTTPStatus = 500
-						http.Error(w, err.Error(), 500)
+						writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 						return
 					}
 					os.Remove(workerTempFileName)
@@ -1230,13 +1213,11 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 					}
 				case err := <-workerErrChan:
 					Info.Printf("%v\n", err)
-					metricsCollector.Info.HTTPStatus = 500
-					http.Error(w, err.Error(), 500)
+					writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 					return
 				case <-ctx.Done():
 					Error.Printf("Context cancelled with message: %v\n", ctx.Err())
-					metricsCollector.Info.HTTPStatus = 500
-					http.Error(w, ctx.Err().Error(), 500)
+					writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", ctx.Err().Error()), metricsCollector)
 					return
 				}
 
@@ -1250,12 +1231,11 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 		hDstDS = nil
 
 		if *params.Format == "dap4" {
-			err := utils.EncodeDap4(w, masterTempFile, bandNames, *verbose)
+			err := utils.EncodeDap4(w, masterTempFile, bandNames, params.Dap4Checksum, *verbose)
 			if err != nil {
 				errMsg := fmt.Sprintf("DAP: error: %v", err)
 				Info.Printf(errMsg)
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, errMsg, 500)
+				writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", errMsg), metricsCollector)
 			}
 			return
 		}
@@ -1283,8 +1263,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 		if err != nil {
 			errMsg := fmt.Sprintf("Error opening raster file: %v", err)
 			Info.Printf(errMsg)
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, errMsg, 500)
+			writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", errMsg), metricsCollector)
 		}
 		defer fileHandle.Close()
 
@@ -1292,8 +1271,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 		if err != nil {
 			errMsg := fmt.Sprintf("file stat() failed: %v", err)
 			Info.Printf(errMsg)
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, errMsg, 500)
+			writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", errMsg), metricsCollector)
 		}
 		w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
 
@@ -1301,8 +1279,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 		if err != nil {
 			errMsg := fmt.Sprintf("SendFile failed: %v", err)
 			Info.Printf(errMsg)
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, errMsg, 500)
+			writeWCSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", errMsg), metricsCollector)
 		}
 
 		if *verbose {
@@ -1312,15 +1289,13 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 		return
 
 	default:
-		metricsCollector.Info.HTTPStatus = 400
-		http.Error(w, fmt.Sprintf("%s not recognised.", *params.Request), 400)
+		writeWCSException(w, utils.NewOGCException(400, utils.OperationNotSupported, "request", fmt.Sprintf("%s not recognised.", *params.Request)), metricsCollector)
 	}
 }
 
 func serveWPS(ctx context.Context, params utils.WPSParams, conf *utils.Config, r *http.Request, w http.ResponseWriter, metricsCollector *metrics.MetricsCollector) {
 	if params.Request == nil {
-		metricsCollector.Info.HTTPStatus = 400
-		http.Error(w, "Malformed WPS, a Request field needs to be specified", 400)
+		writeWPSException(w, utils.NewOGCException(400, utils.MissingParameterValue, "request", "Malformed WPS, a Request field needs to be specified"), metricsCollector)
 		return
 	}
 
@@ -1332,44 +1307,38 @@ func serveWPS(ctx context.Context, params utils.WPSParams, conf *utils.Config, r
 		tpl, _ := fileResolver.Lookup("templates/WPS_GetCapabilities.tpl")
 		err := utils.ExecuteWriteTemplateFile(w, newConf, tpl)
 		if err != nil {
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, err.Error(), 500)
+			writeWPSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 		}
 	case "DescribeProcess":
 		idx, err := utils.GetProcessIndex(params, conf)
 		if err != nil {
 			Error.Printf("Requested process not found: %v, %v\n", err, reqURL)
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("%v: %s", err, reqURL), 400)
+			writeWPSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "identifier", fmt.Sprintf("%v: %s", err, reqURL)), metricsCollector)
 			return
 		}
 		process := conf.Processes[idx]
 		tpl, _ := fileResolver.Lookup("templates/WPS_DescribeProcess.tpl")
 		err = utils.ExecuteWriteTemplateFile(w, process, tpl)
 		if err != nil {
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, err.Error(), 500)
+			writeWPSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 		}
 	case "Execute":
 		idx, err := utils.GetProcessIndex(params, conf)
 		if err != nil {
 			Error.Printf("Requested process not found: %v, %v\n", err, reqURL)
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("%v: %s", err, reqURL), 400)
+			writeWPSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "identifier", fmt.Sprintf("%v: %s", err, reqURL)), metricsCollector)
 			return
 		}
 		process := conf.Processes[idx]
 		if len(process.DataSources) == 0 {
 			Error.Printf("No data source specified")
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, "No data source specified", 500)
+			writeWPSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", "No data source specified"), metricsCollector)
 			return
 		}
 
 		if len(params.FeatCol.Features) == 0 {
 			Info.Printf("The request does not contain the 'feature' property.\n")
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, "The request does not contain the 'feature' property", 400)
+			writeWPSException(w, utils.NewOGCException(400, utils.MissingParameterValue, "DataInputs", "The request does not contain the 'feature' property"), metricsCollector)
 			return
 		}
 
@@ -1388,15 +1357,13 @@ func serveWPS(ctx context.Context, params utils.WPSParams, conf *utils.Config, r
 			}
 			if area == 0.0 || area > process.MaxArea {
 				Info.Printf("The requested area %.02f, is too large.\n", area)
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, "The requested area is too large. Please try with a smaller one.", 400)
+				writeWPSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "geometry", "The requested area is too large. Please try with a smaller one."), metricsCollector)
 				return
 			}
 			feat, _ = json.Marshal(&geo.Feature{Type: "Feature", Geometry: geom})
 
 		default:
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, "Geometry not supported. Only Features containing Polygon or MultiPolygon are available..", 400)
+			writeWPSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "geometry", "Geometry not supported. Only Features containing Polygon or MultiPolygon are available.."), metricsCollector)
 			return
 		}
 
@@ -1482,8 +1449,7 @@ func serveWPS(ctx context.Context, params utils.WPSParams, conf *utils.Config, r
 			}
 
 			if clipLower > clipUpper {
-				metricsCollector.Info.HTTPStatus = 400
-				http.Error(w, "clipLower greater than clipUpper", 400)
+				writeWPSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "clip", "clipLower greater than clipUpper"), metricsCollector)
 				return
 			}
 
@@ -1503,6 +1469,8 @@ func serveWPS(ctx context.Context, params utils.WPSParams, conf *utils.Config, r
 				GrpcConcLimit:    dataSource.GrpcWpsConcPerNode,
 				IndexTileXSize:   dataSource.IndexTileXSize,
 				IndexTileYSize:   dataSource.IndexTileYSize,
+				Anomaly:          dataSource.Anomaly,
+				Unpack:           dataSource.Unpack,
 				MetricsCollector: metricsCollector,
 			}
 
@@ -1518,18 +1486,15 @@ func serveWPS(ctx context.Context, params utils.WPSParams, conf *utils.Config, r
 				result.WriteString(res)
 			case err := <-errChan:
 				Info.Printf("Error in the pipeline: %v\n", err)
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, err.Error(), 500)
+				writeWPSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 				return
 			case <-ctx.Done():
 				Error.Printf("Context cancelled with message: %v\n", ctx.Err())
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, ctx.Err().Error(), 500)
+				writeWPSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", ctx.Err().Error()), metricsCollector)
 				return
 			case <-timeoutCtx.Done():
 				Error.Printf("WPS pipeline timed out, threshold:%v seconds", process.WpsTimeout)
-				metricsCollector.Info.HTTPStatus = 500
-				http.Error(w, "WPS request timed out", 500)
+				writeWPSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", "WPS request timed out"), metricsCollector)
 				return
 			}
 		}
@@ -1537,13 +1502,11 @@ func serveWPS(ctx context.Context, params utils.WPSParams, conf *utils.Config, r
 		tpl, _ := fileResolver.Lookup("templates/WPS_Execute.tpl")
 		err = utils.ExecuteWriteTemplateFile(w, result.String(), tpl)
 		if err != nil {
-			metricsCollector.Info.HTTPStatus = 500
-			http.Error(w, err.Error(), 500)
+			writeWPSException(w, utils.NewOGCException(500, utils.NoApplicableCode, "", err.Error()), metricsCollector)
 		}
 
 	default:
-		metricsCollector.Info.HTTPStatus = 400
-		http.Error(w, fmt.Sprintf("%s not recognised.", *params.Request), 400)
+		writeWPSException(w, utils.NewOGCException(400, utils.OperationNotSupported, "request", fmt.Sprintf("%s not recognised.", *params.Request)), metricsCollector)
 	}
 }
 
@@ -1578,6 +1541,133 @@ func getMASAddress() (string, error) {
 	return masAddress, nil
 }
 
+// Services whose GetCapabilities responses list the layer dates, which
+// are cached in the OWS cache of MAS. WPS capabilities only describe the
+// processes and are not cached.
+var cachedCapsServices = []string{"wms", "wcs"}
+
+// capsCacheKey returns the OWSCache query of the GetCapabilities response
+// of service at urlPath
+func capsCacheKey(service string, urlPath string) string {
+	return fmt.Sprintf("%s_getcaps_%s", service, strings.Trim(urlPath, "/"))
+}
+
+// getCapabilitiesConfig returns a copy of conf with the layer dates of
+// the GetCapabilities response of service. The dates are read from the
+// OWS cache of MAS, or loaded from MAS and cached on a cache miss.
+func getCapabilitiesConfig(conf *utils.Config, r *http.Request, service string) *utils.Config {
+	query := capsCacheKey(service, r.URL.Path)
+	owsCache := utils.NewOWSCache(conf.ServiceConfig.MASAddress, utils.FindConfigGPath(conf), *verbose)
+	newConf, err := owsCache.GetConfig(query)
+	if err != nil && *verbose {
+		log.Printf("%s GetCapabilities get cache error: %v", strings.ToUpper(service), err)
+	}
+	if err == nil && newConf != nil && len(newConf.Layers) == len(conf.Layers) && len(newConf.Layers) > 0 {
+		return newConf
+	}
+
+	newConf = conf.Copy(r)
+	err = utils.LoadConfigTimestamps(newConf, *verbose)
+	if err != nil {
+		log.Printf("%s GetCapabilities LoadConfigTimestamps error: %v", strings.ToUpper(service), err)
+	}
+
+	jsonBytes, err := json.Marshal(newConf)
+	if err != nil {
+		log.Printf("json.Marshal failed for %s GetCapabilities", strings.ToUpper(service))
+		return newConf
+	}
+	err = owsCache.Put(query, string(jsonBytes))
+	if err != nil && *verbose {
+		log.Printf("%s GetCapabilities put cache error: %v", strings.ToUpper(service), err)
+	}
+	return newConf
+}
+
+// watchMASChanges subscribes to the change feed of every MAS server
+// indexing the layers in confMap
+func watchMASChanges(confMap map[string]*utils.Config) {
+	addresses := make(map[string]bool)
+	for _, conf := range confMap {
+		if conf == nil {
+			continue
+		}
+		addresses[strings.TrimSpace(conf.ServiceConfig.MASAddress)] = true
+		for _, layer := range conf.Layers {
+			addresses[strings.TrimSpace(layer.MASAddress)] = true
+		}
+	}
+	delete(addresses, "")
+
+	for masAddress := range addresses {
+		masAddress := masAddress
+		subscriber := utils.NewMASChangeSubscriber(masAddress, *verbose)
+		subscriber.Run(func(gpaths []string, reset bool) {
+			handleMASChanges(masAddress, gpaths, reset)
+		})
+		Info.Printf("Subscribed to MAS changes: %s", masAddress)
+	}
+}
+
+// handleMASChanges evicts the cached MAS responses of gpaths, reloads the
+// layer dates of the configs affected by changes under gpaths and
+// invalidates their cached capabilities and tiles
+func handleMASChanges(masAddress string, gpaths []string, reset bool) {
+	if cache := proc.GetIndexerCache(); cache != nil {
+		cache.Evict(gpaths, reset)
+	}
+
+	confMap := getConfigMap()
+	namespaces := utils.ChangedNamespaces(confMap, masAddress, gpaths, reset)
+	if len(namespaces) == 0 {
+		return
+	}
+	if *verbose {
+		Info.Printf("MAS %s changed %v, reloading namespaces %v", masAddress, gpaths, namespaces)
+	}
+
+	reloaded := make(map[string]*utils.Config)
+	for _, ns := range namespaces {
+		conf := confMap[ns]
+		newConf, err := utils.ReloadConfigTimestamps(conf, *verbose)
+		if err != nil {
+			Error.Printf("Reloading timestamps of namespace %s: %v", ns, err)
+			continue
+		}
+		reloaded[ns] = newConf
+
+		urlPath := "/ows"
+		if ns != "." {
+			urlPath += "/" + ns
+		}
+		owsCache := utils.NewOWSCache(conf.ServiceConfig.MASAddress, utils.FindConfigGPath(conf), *verbose)
+		for _, service := range cachedCapsServices {
+			if err = owsCache.Invalidate(capsCacheKey(service, urlPath)); err != nil {
+				Error.Printf("Invalidating %s GetCapabilities cache of namespace %s: %v", strings.ToUpper(service), ns, err)
+			}
+		}
+
+		// Cached tiles were computed from the granules before the change
+		if cache := proc.GetClimatologyCache(); cache != nil {
+			cache.EvictNamespace(ns)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	// Configs reloaded in the meantime already have fresh dates
+	current := getConfigMap()
+	newMap := make(map[string]*utils.Config, len(current))
+	for ns, conf := range current {
+		newMap[ns] = conf
+		if newConf, found := reloaded[ns]; found && conf == confMap[ns] {
+			newMap[ns] = newConf
+		}
+	}
+	configMap.Store("config", newMap)
+}
+
 // owsHandler handles every request received on /ows
 func generalHandler(conf *utils.Config, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -1605,14 +1695,20 @@ func generalHandler(conf *utils.Config, w http.ResponseWriter, r *http.Request)
 	metricsCollector.Info.RemoteAddr = remoteAddr
 	metricsCollector.Info.HTTPStatus = 200
 
+	if strings.HasPrefix(r.URL.Path, "/ows/") {
+		if _, layerName, ext := utils.ParseDapPath(r.URL.Path[len("/ows/"):]); len(ext) > 0 {
+			serveDapDataset(ctx, conf, layerName, ext, r, w, metricsCollector)
+			return
+		}
+	}
+
 	var query map[string][]string
 	var err error
 	switch r.Method {
 	case "POST":
 		query, err = utils.ParsePost(r.Body)
 		if err != nil {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Error parsing WPS POST payload: %s", err), 400)
+			writeWPSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "", fmt.Sprintf("Error parsing WPS POST payload: %s", err)), metricsCollector)
 			return
 		}
 
@@ -1665,16 +1761,14 @@ func generalHandler(conf *utils.Config, w http.ResponseWriter, r *http.Request)
 	case "WMS":
 		params, err := utils.WMSParamsChecker(query, reWMSMap)
 		if err != nil {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Wrong WMS parameters on URL: %s", err), 400)
+			writeWMSException(w, &params, utils.NewOGCException(400, utils.InvalidParameterValue, "", fmt.Sprintf("Wrong WMS parameters on URL: %s", err)), metricsCollector)
 			return
 		}
 		serveWMS(ctx, params, conf, r, w, metricsCollector)
 	case "WCS":
 		params, err := utils.WCSParamsChecker(query, reWCSMap)
 		if err != nil {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Wrong WCS parameters on URL: %s", err), 400)
+			writeWCSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "", fmt.Sprintf("Wrong WCS parameters on URL: %s", err)), metricsCollector)
 			return
 		}
 		serveWCS(ctx, params, conf, r, w, query, metricsCollector)
@@ -1693,8 +1787,7 @@ func generalHandler(conf *utils.Config, w http.ResponseWriter, r *http.Request)
 			}
 		}
 		if err != nil {
-			metricsCollector.Info.HTTPStatus = 400
-			http.Error(w, fmt.Sprintf("Wrong WPS parameters on URL: %s", err), 400)
+			writeWPSException(w, utils.NewOGCException(400, utils.InvalidParameterValue, "", fmt.Sprintf("Wrong WPS parameters on URL: %s", err)), metricsCollector)
 			return
 		}
 		serveWPS(ctx, params, conf, r, w, metricsCollector)
@@ -1713,6 +1806,9 @@ func owsHandler(w http.ResponseWriter, r *http.Request) {
 		if len(namespace) >= len(dapExt) && namespace[len(namespace)-len(dapExt):] == dapExt {
 			namespace = namespace[:len(namespace)-len(dapExt)]
 		}
+		if dapNamespace, _, ext := utils.ParseDapPath(namespace); len(ext) > 0 {
+			namespace = dapNamespace
+		}
 	}
 	confMap := getConfigMap()
 	config, ok := confMap[namespace]
@@ -1891,6 +1987,11 @@ func main() {
 	http.HandleFunc("/ows/", owsHandler)
 	http.HandleFunc(fmt.Sprintf("/%s", utils.CatalogueDirName), cataloguesHandler)
 	http.HandleFunc(fmt.Sprintf("/%s/", utils.CatalogueDirName), cataloguesHandler)
+	http.HandleFunc("/stac", stacHandler)
+	http.HandleFunc("/conformance", conformanceHandler)
+	http.HandleFunc("/collections", collectionsHandler)
+	http.HandleFunc("/collections/", collectionsHandler)
+	http.HandleFunc("/search", searchHandler)
 
 	listeningHost := fmt.Sprintf("0.0.0.0:%d", *port)
 	Info.Printf("GSKY is listening on %s", listeningHost)
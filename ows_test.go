@@ -1,8 +1,64 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	proc "github.com/nci/gsky/processor"
+	"github.com/nci/gsky/utils"
+)
 
 // From little things, big things grow.
 func TestFirst(t *testing.T) {
 	// pass
 }
+
+func TestWCSDescribeCoverageUnits(t *testing.T) {
+	mas := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metadata := &proc.MetadataResponse{GDALDatasets: []*proc.GDALDataset{{
+			RawPath:    "/data/temp/1.nc",
+			DSName:     "/data/temp/1.nc",
+			NameSpace:  "temp",
+			ArrayType:  "Float32",
+			TimeStamps: []time.Time{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+			Polygon:    "POLYGON ((110 -10,150 -10,150 -40,110 -40,110 -10))",
+			Units:      "K",
+		}}}
+		json.NewEncoder(w).Encode(metadata)
+	}))
+	defer mas.Close()
+
+	if fileResolver == nil {
+		fileResolver = utils.NewRuntimeFileResolver("")
+	}
+
+	bandExpr, err := utils.ParseBandExpressions([]string{"temp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &utils.Config{Layers: []utils.Layer{{
+		Name:           "temperature",
+		DataSource:     "/data/temp",
+		MASAddress:     strings.TrimPrefix(mas.URL, "http://"),
+		RGBExpressions: bandExpr,
+		Dates:          []string{"2020-01-01T00:00:00.000Z"},
+	}}}
+
+	service, version, request := "WCS", "1.0.0", "DescribeCoverage"
+	params := utils.WCSParams{Service: &service, Version: &version, Request: &request, Coverages: []string{"temperature"}}
+	req := httptest.NewRequest("GET", "http://gsky/ows?service=WCS&version=1.0.0&request=DescribeCoverage&coverage=temperature", nil)
+	rec := httptest.NewRecorder()
+	serveWCS(context.Background(), params, conf, req, rec, req.URL.Query(), nil)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `<AxisDescription refSysLabel="K">`) {
+		t.Errorf("expected the units of temp in %s", rec.Body.String())
+	}
+}
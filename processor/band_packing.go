@@ -0,0 +1,268 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"time"
+	"unsafe"
+
+	"github.com/nci/gsky/utils"
+	pb "github.com/nci/gsky/worker/gdalservice"
+)
+
+// BandPacking describes how the stored values of a band are unpacked to
+// physical values, i.e. value * ScaleFactor + AddOffset. Stored values
+// outside of the valid range are nodata.
+type BandPacking struct {
+	ScaleFactor float64
+	AddOffset   float64
+	ValidRange  []float64
+}
+
+// newBandPacking returns the packing of the values of a dataset or nil if
+// they are not packed
+func newBandPacking(ds *GDALDataset) *BandPacking {
+	hasScale := ds.ScaleFactor != 0 && ds.ScaleFactor != 1
+	hasValidRange := len(ds.ValidRange) == 2
+	if !hasScale && ds.AddOffset == 0 && !hasValidRange {
+		return nil
+	}
+
+	p := &BandPacking{ScaleFactor: ds.ScaleFactor, AddOffset: ds.AddOffset}
+	if p.ScaleFactor == 0 {
+		p.ScaleFactor = 1
+	}
+	if hasValidRange {
+		p.ValidRange = ds.ValidRange
+	}
+	return p
+}
+
+// newUnpacking returns the packing of the values of a dataset for a
+// layer that unpacks its bands. Values that are not packed are unpacked
+// as they are so that every granule of a band is converted to Float32
+// and all of them merge into a canvas of the same type.
+func newUnpacking(ds *GDALDataset) *BandPacking {
+	if p := newBandPacking(ds); p != nil {
+		return p
+	}
+	return &BandPacking{ScaleFactor: 1}
+}
+
+func (p *BandPacking) isValid(val float64) bool {
+	return len(p.ValidRange) != 2 || (val >= p.ValidRange[0] && val <= p.ValidRange[1])
+}
+
+func (p *BandPacking) unpack(val float64) float64 {
+	return val*p.ScaleFactor + p.AddOffset
+}
+
+// packedClips converts clip bounds of physical values to clip bounds of
+// stored values within the valid range
+func (p *BandPacking) packedClips(lower float32, upper float32) (float32, float32) {
+	lo := (float64(lower) - p.AddOffset) / p.ScaleFactor
+	hi := (float64(upper) - p.AddOffset) / p.ScaleFactor
+	if p.ScaleFactor < 0 {
+		lo, hi = hi, lo
+	}
+	if len(p.ValidRange) == 2 {
+		lo = math.Max(lo, p.ValidRange[0])
+		hi = math.Min(hi, p.ValidRange[1])
+	}
+	return float32(lo), float32(hi)
+}
+
+// unpackRaster converts the stored values of a raster to Float32
+// physical values. Stored nodata values and values outside of the valid
+// range are set to the nodata value of the raster.
+func unpackRaster(r *FlexRaster, p *BandPacking) error {
+	headr := *(*reflect.SliceHeader)(unsafe.Pointer(&r.Data))
+	var out []float32
+	noData := float32(r.NoData)
+
+	unpack := func(i int, val float64, isNoData bool) {
+		if isNoData || !p.isValid(val) {
+			out[i] = noData
+		} else {
+			out[i] = float32(p.unpack(val))
+		}
+	}
+
+	switch r.Type {
+	case "SignedByte":
+		data := *(*[]int8)(unsafe.Pointer(&headr))
+		out = make([]float32, len(data))
+		for i, val := range data {
+			unpack(i, float64(val), val == int8(r.NoData))
+		}
+	case "Byte":
+		out = make([]float32, len(r.Data))
+		for i, val := range r.Data {
+			unpack(i, float64(val), val == uint8(r.NoData))
+		}
+	case "Int16":
+		headr.Len /= SizeofInt16
+		headr.Cap /= SizeofInt16
+		data := *(*[]int16)(unsafe.Pointer(&headr))
+		out = make([]float32, len(data))
+		for i, val := range data {
+			unpack(i, float64(val), val == int16(r.NoData))
+		}
+	case "UInt16":
+		headr.Len /= SizeofUint16
+		headr.Cap /= SizeofUint16
+		data := *(*[]uint16)(unsafe.Pointer(&headr))
+		out = make([]float32, len(data))
+		for i, val := range data {
+			unpack(i, float64(val), val == uint16(r.NoData))
+		}
+	case "Int32":
+		headr.Len /= SizeofInt32
+		headr.Cap /= SizeofInt32
+		data := *(*[]int32)(unsafe.Pointer(&headr))
+		out = make([]float32, len(data))
+		for i, val := range data {
+			unpack(i, float64(val), val == int32(r.NoData))
+		}
+	case "UInt32":
+		headr.Len /= SizeofUint32
+		headr.Cap /= SizeofUint32
+		data := *(*[]uint32)(unsafe.Pointer(&headr))
+		out = make([]float32, len(data))
+		for i, val := range data {
+			unpack(i, float64(val), val == uint32(r.NoData))
+		}
+	case "Float32":
+		headr.Len /= SizeofFloat32
+		headr.Cap /= SizeofFloat32
+		data := *(*[]float32)(unsafe.Pointer(&headr))
+		out = make([]float32, len(data))
+		for i, val := range data {
+			unpack(i, float64(val), val == noData)
+		}
+	case "Float64":
+		headr.Len /= SizeofFloat64
+		headr.Cap /= SizeofFloat64
+		data := *(*[]float64)(unsafe.Pointer(&headr))
+		out = make([]float32, len(data))
+		for i, val := range data {
+			unpack(i, val, val == r.NoData)
+		}
+	default:
+		return fmt.Errorf("unpacking hasn't been implemented for raster type %s", r.Type)
+	}
+
+	outHeadr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
+	outHeadr.Len *= SizeofFloat32
+	outHeadr.Cap *= SizeofFloat32
+	r.Data = *(*[]uint8)(unsafe.Pointer(&outHeadr))
+	r.Type = "Float32"
+	r.NoData = float64(noData)
+	return nil
+}
+
+// unpackTimeSeries converts the stored values of the non-empty entries
+// of a drill time series to physical values
+func unpackTimeSeries(ts []*pb.TimeSeries, p *BandPacking) {
+	for _, t := range ts {
+		if t != nil && t.Count > 0 {
+			t.Value = p.unpack(t.Value)
+		}
+	}
+}
+
+// bandUnits returns the units of the variables of granules
+func bandUnits(granules []*GeoTileGranule) map[string]string {
+	units := make(map[string]string)
+	for _, g := range granules {
+		if len(g.Units) > 0 {
+			units[g.VarNameSpace] = g.Units
+		}
+	}
+	return units
+}
+
+// exprUnits returns the units of the outputs of band expressions that
+// are plain references to variables of known units
+func exprUnits(bandExpr *utils.BandExpressions, varUnits map[string]string) map[string]string {
+	units := make(map[string]string)
+	if bandExpr == nil {
+		return units
+	}
+
+	for i, expr := range bandExpr.Expressions {
+		if i >= len(bandExpr.ExprNames) || i >= len(bandExpr.ExprVarRef) {
+			break
+		}
+		if len(expr.Tokens()) != 1 || len(bandExpr.ExprVarRef[i]) != 1 {
+			continue
+		}
+		if u, found := varUnits[bandExpr.ExprVarRef[i][0]]; found {
+			units[bandExpr.ExprNames[i]] = u
+		}
+	}
+	return units
+}
+
+// GetLayerUnits returns the units of the output bands of a layer from the
+// metadata of a file of each of its variables at its latest date
+func GetLayerUnits(ctx context.Context, conf *utils.Config, idx int, verbose bool) map[string]string {
+	layer := &conf.Layers[idx]
+	if layer.Anomaly != nil || len(layer.InputLayers) > 0 || layer.RGBExpressions == nil || len(layer.Dates) == 0 {
+		return nil
+	}
+
+	startTime, err := time.Parse(ISOFormat, layer.Dates[len(layer.Dates)-1])
+	if err != nil {
+		return nil
+	}
+	var endTime *time.Time
+	if layer.Accum {
+		step := time.Minute * time.Duration(60*24*layer.StepDays+60*layer.StepHours+layer.StepMinutes)
+		eT := startTime.Add(step)
+		endTime = &eT
+	}
+
+	bbox := []float64{-180, -90, 180, 90}
+	if len(layer.DefaultGeoBbox) == 4 {
+		bbox = layer.DefaultGeoBbox
+	}
+
+	errChan := make(chan error, 100)
+	varUnits := make(map[string]string)
+	for _, ns := range layer.RGBExpressions.VarList {
+		geoReq := &GeoTileRequest{ConfigPayLoad: ConfigPayLoad{NameSpaces: []string{ns},
+			PolygonSegments:     layer.WcsPolygonSegments,
+			QueryLimit:          1,
+			UserSrcSRS:          layer.UserSrcSRS,
+			UserSrcGeoTransform: layer.UserSrcGeoTransform,
+			MasQueryHint:        layer.MasQueryHint,
+			SRSCf:               layer.SRSCf,
+		},
+			Collection: layer.DataSource,
+			CRS:        "EPSG:4326",
+			BBox:       bbox,
+			Height:     256,
+			Width:      256,
+			StartTime:  &startTime,
+			EndTime:    endTime,
+		}
+
+		tp := InitTilePipeline(ctx, layer.MASAddress, conf.ServiceConfig.WorkerNodes, layer.MaxGrpcRecvMsgSize, layer.WcsPolygonShardConcLimit, conf.ServiceConfig.MaxGrpcBufferSize, errChan)
+		granules, err := tp.GetFileList(geoReq, verbose)
+		if err != nil {
+			if verbose {
+				log.Printf("layer %s: failed to get units of %s: %v", layer.Name, ns, err)
+			}
+			continue
+		}
+		for v, u := range bandUnits(granules) {
+			varUnits[v] = u
+		}
+	}
+
+	return exprUnits(layer.RGBExpressions, varUnits)
+}
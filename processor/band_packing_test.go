@@ -0,0 +1,51 @@
+package processor
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestUnpackRaster(t *testing.T) {
+	if newBandPacking(&GDALDataset{ScaleFactor: 1}) != nil {
+		t.Errorf("expected no packing of unscaled values")
+	}
+
+	testCases := []struct {
+		packing  *BandPacking
+		data     []int16
+		expected []float32
+	}{
+		{newBandPacking(&GDALDataset{ScaleFactor: 0.5, AddOffset: 10, ValidRange: []float64{0, 100}}), []int16{-1, 0, 20, 101, 100}, []float32{-1, 10, 20, -1, 60}},
+		{newUnpacking(&GDALDataset{ScaleFactor: 1}), []int16{-1, 0, 20, 101, 100}, []float32{-1, 0, 20, 101, 100}},
+	}
+
+	for _, tc := range testCases {
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&tc.data))
+		headr.Len *= SizeofInt16
+		headr.Cap *= SizeofInt16
+		r := &FlexRaster{Type: "Int16", NoData: -1, Data: *(*[]uint8)(unsafe.Pointer(&headr))}
+
+		if err := unpackRaster(r, tc.packing); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if r.Type != "Float32" || len(r.Data) != len(tc.data)*SizeofFloat32 {
+			t.Fatalf("unexpected raster: %s of %d bytes", r.Type, len(r.Data))
+		}
+
+		outHeadr := *(*reflect.SliceHeader)(unsafe.Pointer(&r.Data))
+		outHeadr.Len /= SizeofFloat32
+		outHeadr.Cap /= SizeofFloat32
+		out := *(*[]float32)(unsafe.Pointer(&outHeadr))
+		if !reflect.DeepEqual(out, tc.expected) {
+			t.Errorf("%+v: got %v, expected %v", tc.packing, out, tc.expected)
+		}
+	}
+
+	p := newBandPacking(&GDALDataset{ScaleFactor: 0.5, AddOffset: 10, ValidRange: []float64{0, 100}})
+	lower, upper := p.packedClips(15, float32(math.MaxFloat32))
+	if lower != 10 || upper != 100 {
+		t.Errorf("unexpected packed clips: %v, %v", lower, upper)
+	}
+}
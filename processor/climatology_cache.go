@@ -0,0 +1,134 @@
+package processor
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nci/gsky/utils/lru"
+)
+
+// Default size of the climatology cache in bytes
+const DefaultClimatologyCacheSize = 256 * 1024 * 1024
+
+// Default lifetime of cached climatologies. Baselines rarely change but
+// reprocessed data should show up within a day.
+const DefaultClimatologyCacheTTL = 24 * time.Hour
+
+// climatology holds the baseline values of the bands of a base layer on
+// the grid of a tile request. values[band] holds the values of each
+// baseline date in row-major order, NaN where there is no data.
+type climatology struct {
+	dates  int
+	values [][]float32
+}
+
+func (c *climatology) size() int {
+	size := 0
+	for _, values := range c.values {
+		size += len(values) * SizeofFloat32
+	}
+	return size
+}
+
+// ClimatologyCache is a LRU cache of the climatology tiles of anomaly
+// layers bounded by their total size. Entries expire after the TTL.
+type ClimatologyCache struct {
+	cache *lru.Cache
+}
+
+func NewClimatologyCache(maxBytes int, ttl time.Duration) *ClimatologyCache {
+	return &ClimatologyCache{cache: lru.New(maxBytes, ttl)}
+}
+
+var climatologyCache = NewClimatologyCache(DefaultClimatologyCacheSize, DefaultClimatologyCacheTTL)
+
+// SetClimatologyCache sizes the climatology cache. A size or TTL of 0
+// disables it.
+func SetClimatologyCache(maxBytes int, ttl time.Duration) {
+	if maxBytes <= 0 || ttl <= 0 {
+		climatologyCache = nil
+		return
+	}
+	climatologyCache = NewClimatologyCache(maxBytes, ttl)
+}
+
+// GetClimatologyCache returns the climatology cache or nil if it is
+// disabled
+func GetClimatologyCache() *ClimatologyCache {
+	return climatologyCache
+}
+
+func (c *ClimatologyCache) Get(key string) (*climatology, bool) {
+	value, found := c.cache.Get(key)
+	if !found {
+		return nil, false
+	}
+	return value.(*climatology), true
+}
+
+func (c *ClimatologyCache) Put(key string, value *climatology) {
+	c.cache.Put(key, value, value.size())
+}
+
+// EvictNamespace drops the climatologies of the layers of namespace,
+// whose keys start with the namespace followed by '|'
+func (c *ClimatologyCache) EvictNamespace(namespace string) {
+	prefix := namespace + "|"
+	c.cache.RemoveIf(func(key string, value interface{}) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// Len returns the number of cached climatologies
+func (c *ClimatologyCache) Len() int {
+	return c.cache.Len()
+}
+
+// climatologyCall is a climatology being computed, which concurrent
+// requests of the same tile wait for
+type climatologyCall struct {
+	done chan struct{}
+	clim *climatology
+	err  error
+}
+
+var climatologyCalls = struct {
+	sync.Mutex
+	calls map[string]*climatologyCall
+}{calls: make(map[string]*climatologyCall)}
+
+// loadClimatology returns the cached climatology of key or else
+// computes it. Concurrent misses of the same key wait for a single
+// computation. It also reports whether the climatology was computed by
+// another request.
+func loadClimatology(key string, compute func() (*climatology, error)) (*climatology, bool, error) {
+	cache := climatologyCache
+
+	climatologyCalls.Lock()
+	if cache != nil {
+		if clim, found := cache.Get(key); found {
+			climatologyCalls.Unlock()
+			return clim, true, nil
+		}
+	}
+	if call, found := climatologyCalls.calls[key]; found {
+		climatologyCalls.Unlock()
+		<-call.done
+		return call.clim, true, call.err
+	}
+	call := &climatologyCall{done: make(chan struct{})}
+	climatologyCalls.calls[key] = call
+	climatologyCalls.Unlock()
+
+	call.clim, call.err = compute()
+	if call.err == nil && cache != nil {
+		cache.Put(key, call.clim)
+	}
+
+	climatologyCalls.Lock()
+	delete(climatologyCalls.calls, key)
+	climatologyCalls.Unlock()
+	close(call.done)
+	return call.clim, false, call.err
+}
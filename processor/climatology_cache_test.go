@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClimatologyCache(t *testing.T) {
+	newClim := func(n int) *climatology {
+		return &climatology{dates: 1, values: [][]float32{make([]float32, n)}}
+	}
+
+	c := NewClimatologyCache(40, time.Minute)
+	c.Put("a", newClim(4))
+	c.Put("b", newClim(4))
+	c.Put("c", newClim(4))
+	if _, found := c.Get("a"); found {
+		t.Errorf("expected the least recently used climatology to be evicted")
+	}
+	if clim, found := c.Get("c"); !found || len(clim.values[0]) != 4 {
+		t.Errorf("expected a cached climatology")
+	}
+
+	c.Put("d", newClim(20))
+	if c.Len() != 2 {
+		t.Errorf("expected 2 climatologies, got %d", c.Len())
+	}
+
+	c.Put("a|x", newClim(1))
+	c.Put("a/b|x", newClim(1))
+	c.EvictNamespace("a")
+	if _, found := c.Get("a|x"); found {
+		t.Errorf("expected the climatologies of the namespace to be evicted")
+	}
+	if _, found := c.Get("a/b|x"); !found {
+		t.Errorf("expected the climatologies of other namespaces to be kept")
+	}
+
+	c = NewClimatologyCache(40, time.Millisecond)
+	c.Put("a", newClim(1))
+	time.Sleep(5 * time.Millisecond)
+	if _, found := c.Get("a"); found {
+		t.Errorf("expected the climatology to expire")
+	}
+}
+
+func TestLoadClimatology(t *testing.T) {
+	oldCache := climatologyCache
+	defer func() { climatologyCache = oldCache }()
+	climatologyCache = NewClimatologyCache(1024, time.Minute)
+
+	var computed int32
+	release := make(chan struct{})
+	compute := func() (*climatology, error) {
+		atomic.AddInt32(&computed, 1)
+		<-release
+		return &climatology{dates: 1, values: [][]float32{make([]float32, 4)}}, nil
+	}
+
+	var wg sync.WaitGroup
+	var shared int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clim, isShared, err := loadClimatology("a|x", compute)
+			if err != nil || clim == nil || clim.dates != 1 {
+				t.Errorf("unexpected climatology %v: %v", clim, err)
+			}
+			if isShared {
+				atomic.AddInt32(&shared, 1)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if computed != 1 || shared != 7 {
+		t.Errorf("expected 1 computation shared by 7 requests, got %d and %d", computed, shared)
+	}
+
+	if _, shared, _ := loadClimatology("a|x", compute); !shared {
+		t.Errorf("expected the climatology to be cached")
+	}
+	if computed != 1 {
+		t.Errorf("expected no more computations, got %d", computed)
+	}
+}
@@ -21,7 +21,7 @@ func (ts *TimeSplitter) Run() {
 	for geoReq := range ts.In {
 		if ts.YearStep > 0 {
 			for t := geoReq.StartTime; t.Before(geoReq.EndTime); t = t.AddDate(ts.YearStep, 0, 0) {
-				ts.Out <- &GeoDrillRequest{geoReq.Geometry, geoReq.CRS, geoReq.Collection, geoReq.NameSpaces, geoReq.BandExpr, geoReq.Mask, "", t, t.AddDate(ts.YearStep, 0, 0), geoReq.ClipUpper, geoReq.ClipLower, geoReq.RasterXSize, geoReq.RasterYSize, geoReq.GrpcConcLimit, geoReq.IndexTileXSize, geoReq.IndexTileYSize, geoReq.MetricsCollector}
+				ts.Out <- &GeoDrillRequest{geoReq.Geometry, geoReq.CRS, geoReq.Collection, geoReq.NameSpaces, geoReq.BandExpr, geoReq.Mask, "", t, t.AddDate(ts.YearStep, 0, 0), geoReq.ClipUpper, geoReq.ClipLower, geoReq.RasterXSize, geoReq.RasterYSize, geoReq.GrpcConcLimit, geoReq.IndexTileXSize, geoReq.IndexTileYSize, geoReq.Anomaly, geoReq.Unpack, geoReq.MetricsCollector}
 			}
 		} else {
 			ts.Out <- geoReq
@@ -3,9 +3,9 @@ package processor
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"time"
 
+	"github.com/nci/gsky/utils"
 	pb "github.com/nci/gsky/worker/gdalservice"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -37,26 +37,13 @@ func (gi *GeoDrillGRPC) Run(bandStrides int, decileCount int, pixelCount int, ve
 	start := time.Now()
 
 	const DefaultWpsRecvMsgSize = 100 * 1024 * 1024
-	opts := []grpc.DialOption{
-		grpc.WithInsecure(),
-		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(DefaultWpsRecvMsgSize)),
-	}
 
-	conns := make([]*grpc.ClientConn, len(gi.Clients))
-	for i, client := range gi.Clients {
-		conn, err := grpc.Dial(client, opts...)
-		if err != nil {
-			log.Fatalf("gRPC connection problem: %v", err)
-		}
-		defer conn.Close()
-		conns[i] = conn
-	}
+	workerMgr := utils.GetWorkerManager()
 
 	var metrics []*pb.WorkerMetrics
 	var geoReq *GeoDrillGranule
 	var cLimiter *ConcLimiter
 
-	workerStart := rand.Intn(len(conns))
 	i := 0
 	for gran := range gi.In {
 		if gran.Path == "NULL" {
@@ -86,7 +73,10 @@ func (gi *GeoDrillGRPC) Run(bandStrides int, decileCount int, pixelCount int, ve
 				}
 
 				if hasStats {
-					gi.Out <- &DrillResult{NameSpace: gran.NameSpace, Data: ts, Dates: gran.TimeStamps}
+					if gran.Packing != nil {
+						unpackTimeSeries(ts, gran.Packing)
+					}
+					gi.Out <- &DrillResult{NameSpace: gran.NameSpace, Data: ts, Dates: gran.TimeStamps, Units: gran.Units}
 					continue
 				}
 			}
@@ -111,7 +101,11 @@ func (gi *GeoDrillGRPC) Run(bandStrides int, decileCount int, pixelCount int, ve
 		}
 
 		if cLimiter == nil {
-			cLimiter = NewConcLimiter(geoReq.GrpcConcLimit * len(conns))
+			nWorkers := workerMgr.AvailableCount(gi.Clients)
+			if nWorkers == 0 {
+				nWorkers = 1
+			}
+			cLimiter = NewConcLimiter(geoReq.GrpcConcLimit * nWorkers)
 		}
 
 		i++
@@ -126,11 +120,15 @@ func (gi *GeoDrillGRPC) Run(bandStrides int, decileCount int, pixelCount int, ve
 			cLimiter.Increase()
 			go func(g *GeoDrillGranule, conc *ConcLimiter, iTile int) {
 				defer conc.Decrease()
-				c := pb.NewGDALClient(conns[(iTile+workerStart)%len(conns)])
 				bands, err := getBands(g.TimeStamps)
 
-				granule := &pb.GeoRPCGranule{Operation: "drill", Path: g.Path, Geometry: g.Geometry, Bands: bands, Height: float32(gran.RasterYSize), Width: float32(gran.RasterXSize), BandStrides: int32(bandStrides), DrillDecileCount: int32(decileCount), ClipUpper: gran.ClipUpper, ClipLower: gran.ClipLower, PixelCount: int32(pixelCount), VRT: g.VRT}
-				r, err := c.Process(gi.Context, granule)
+				clipLower, clipUpper := g.ClipLower, g.ClipUpper
+				if g.Packing != nil {
+					clipLower, clipUpper = g.Packing.packedClips(clipLower, clipUpper)
+				}
+
+				granule := &pb.GeoRPCGranule{Operation: "drill", Path: g.Path, Geometry: g.Geometry, Bands: bands, Height: float32(gran.RasterYSize), Width: float32(gran.RasterXSize), BandStrides: int32(bandStrides), DrillDecileCount: int32(decileCount), ClipUpper: clipUpper, ClipLower: clipLower, PixelCount: int32(pixelCount), VRT: g.VRT, Priority: pb.Priority_BULK}
+				r, err := workerMgr.Process(gi.Context, gi.Clients, granule, grpc.MaxCallRecvMsgSize(DefaultWpsRecvMsgSize))
 				if err != nil {
 					gi.sendError(fmt.Errorf("Drill gRPC: %v", err))
 					r = &pb.Result{}
@@ -148,10 +146,13 @@ func (gi *GeoDrillGRPC) Run(bandStrides int, decileCount int, pixelCount int, ve
 					for ir := 0; ir < nRows; ir++ {
 						tsRow[ir] = r.TimeSeries[ir*nCols+i]
 					}
+					if g.Packing != nil && pixelCount == 0 {
+						unpackTimeSeries(tsRow, g.Packing)
+					}
 					if gi.checkCancellation() {
 						return
 					}
-					gi.Out <- &DrillResult{NameSpace: ns, Data: tsRow, NoData: r.Raster.NoData, Dates: g.TimeStamps}
+					gi.Out <- &DrillResult{NameSpace: ns, Data: tsRow, NoData: r.Raster.NoData, Dates: g.TimeStamps, Units: g.Units}
 				}
 
 				if geoReq.MetricsCollector != nil {
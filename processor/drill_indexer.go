@@ -11,9 +11,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
-	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -137,19 +135,12 @@ func (p *DrillIndexer) Run(verbose bool) {
 				}
 
 				start := time.Now()
-				resp, err := http.PostForm(reqURL, postBody)
+				body, err := getIndexerResponse(reqURL, postBody, geoReq.Collection, geoReq.MetricsCollector)
 				if err != nil {
 					p.sendError(fmt.Errorf("Drill Indexer: POST request to %s failed. Error: %v", reqURL, err))
 					continue
 				}
 
-				body, err := ioutil.ReadAll(resp.Body)
-				resp.Body.Close()
-				if err != nil {
-					p.sendError(fmt.Errorf("Drill Indexer: Error parsing response body from %s. Error: %v", reqURL, err))
-					break
-				}
-
 				indexTime := time.Since(start)
 				if geoReq.MetricsCollector != nil {
 					geoReq.MetricsCollector.Info.Indexer.Duration += indexTime
@@ -215,7 +206,7 @@ func (p *DrillIndexer) processDatasets(res *TiledResponse, geoReq *GeoDrillReque
 	metadata := res.Metadata
 	switch len(metadata.GDALDatasets) {
 	case 0:
-		p.Out <- &GeoDrillGranule{"NULL", utils.EmptyTileNS, "Byte", nil, geoReq.Geometry, geoReq.CRS, "", nil, nil, 0, false, 0, 0, 0, 0, 0, geoReq.MetricsCollector}
+		p.Out <- &GeoDrillGranule{"NULL", utils.EmptyTileNS, "Byte", nil, geoReq.Geometry, geoReq.CRS, "", nil, nil, 0, false, 0, 0, 0, 0, 0, "", nil, geoReq.MetricsCollector}
 	default:
 		var grans []*GeoDrillGranule
 		var effectiveDatasets []*GDALDataset
@@ -225,7 +216,11 @@ func (p *DrillIndexer) processDatasets(res *TiledResponse, geoReq *GeoDrillReque
 			}
 			dedupGranules[ds.DSName+ds.NameSpace] = struct{}{}
 
-			grans = append(grans, &GeoDrillGranule{ds.DSName, ds.NameSpace, ds.ArrayType, ds.TimeStamps, geoReq.Geometry, geoReq.CRS, "", ds.Means, ds.SampleCounts, ds.NoData, p.Approx, geoReq.ClipUpper, geoReq.ClipLower, geoReq.RasterXSize, geoReq.RasterYSize, geoReq.GrpcConcLimit, geoReq.MetricsCollector})
+			gran := &GeoDrillGranule{ds.DSName, ds.NameSpace, ds.ArrayType, ds.TimeStamps, geoReq.Geometry, geoReq.CRS, "", ds.Means, ds.SampleCounts, ds.NoData, p.Approx, geoReq.ClipUpper, geoReq.ClipLower, geoReq.RasterXSize, geoReq.RasterYSize, geoReq.GrpcConcLimit, ds.Units, nil, geoReq.MetricsCollector}
+			if geoReq.Unpack {
+				gran.Packing = newBandPacking(ds)
+			}
+			grans = append(grans, gran)
 			effectiveDatasets = append(effectiveDatasets, ds)
 		}
 		if len(grans) == 0 {
@@ -7,16 +7,32 @@ import (
 	"math"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/nci/gsky/utils"
 	pb "github.com/nci/gsky/worker/gdalservice"
 )
 
+// drillOutput is the data of the output templates of drills. It prints
+// as the CSV rows of the drill. Units holds the units of the output bands
+// that are known from the metadata of their variables.
+type drillOutput struct {
+	CSV   string
+	Units map[string]string
+}
+
+func (o drillOutput) String() string {
+	return o.CSV
+}
+
 type DrillMerger struct {
-	Context context.Context
-	In      chan *DrillResult
-	Out     chan string
-	Error   chan error
+	Context   context.Context
+	In        chan *DrillResult
+	Out       chan string
+	Error     chan error
+	Anomaly   *utils.AnomalyConfig
+	StartTime time.Time
+	EndTime   time.Time
 }
 
 func NewDrillMerger(ctx context.Context, errChan chan error) *DrillMerger {
@@ -34,9 +50,13 @@ func (dm *DrillMerger) Run(suffix string, namespaces []string, templateFileName
 	}
 	defer close(dm.Out)
 	results := make(map[string]map[string][]*pb.TimeSeries)
+	varUnits := make(map[string]string)
 
 	var drillResult *DrillResult
 	for drillRes := range dm.In {
+		if len(drillRes.Units) > 0 {
+			varUnits[drillRes.NameSpace] = drillRes.Units
+		}
 		if _, ok := results[drillRes.NameSpace]; !ok {
 			results[drillRes.NameSpace] = make(map[string][]*pb.TimeSeries)
 			nsFound := false
@@ -74,7 +94,7 @@ func (dm *DrillMerger) Run(suffix string, namespaces []string, templateFileName
 	}
 	sort.Strings(dates)
 
-	var csv strings.Builder
+	var rows [][]float64
 	for _, key := range dates {
 		values := map[string]float64{}
 		for _, ns := range namespaces {
@@ -91,17 +111,17 @@ func (dm *DrillMerger) Run(suffix string, namespaces []string, templateFileName
 			}
 		}
 
-		fmt.Fprintf(&csv, "%s", key)
-
+		var row []float64
 		if len(bandExpr.Expressions) == 0 {
 			for _, ns := range namespaces {
-				fmt.Fprint(&csv, ",")
 				if val, ok := values[ns]; ok {
-					fmt.Fprintf(&csv, "%f", val)
+					row = append(row, val)
+				} else {
+					row = append(row, math.NaN())
 				}
 			}
 
-			fmt.Fprint(&csv, "\\n")
+			rows = append(rows, row)
 			continue
 		}
 
@@ -120,9 +140,8 @@ func (dm *DrillMerger) Run(suffix string, namespaces []string, templateFileName
 					}
 				}
 
-				fmt.Fprint(&csv, ",")
-
 				if noData {
+					row = append(row, math.NaN())
 					continue
 				}
 
@@ -148,17 +167,42 @@ func (dm *DrillMerger) Run(suffix string, namespaces []string, templateFileName
 				}
 
 				if drillResult != nil && float32(drillResult.NoData) != val {
-					fmt.Fprintf(&csv, "%f", float64(val))
+					row = append(row, float64(val))
+				} else {
+					row = append(row, math.NaN())
 				}
 			}
 		}
 
+		rows = append(rows, row)
+	}
+
+	if dm.Anomaly != nil {
+		dates, rows = dm.applyAnomaly(dates, rows)
+	}
+
+	var csv strings.Builder
+	for ir, key := range dates {
+		fmt.Fprintf(&csv, "%s", key)
+		for _, val := range rows[ir] {
+			fmt.Fprint(&csv, ",")
+			if !math.IsNaN(val) {
+				fmt.Fprintf(&csv, "%f", val)
+			}
+		}
 		fmt.Fprint(&csv, "\\n")
+	}
 
+	units := varUnits
+	if len(bandExpr.Expressions) > 0 {
+		units = exprUnits(bandExpr, varUnits)
+	}
+	if dm.Anomaly != nil && dm.Anomaly.Output != utils.AnomalyOutputAnomaly {
+		units = map[string]string{}
 	}
 
 	var out strings.Builder
-	err := utils.ExecuteWriteTemplateFile(&out, csv.String(), templateFileName)
+	err := utils.ExecuteWriteTemplateFile(&out, drillOutput{CSV: csv.String(), Units: units}, templateFileName)
 	if err != nil {
 		dm.sendError(fmt.Errorf("WPS: output template error: %v", err))
 		return
@@ -170,6 +214,54 @@ func (dm *DrillMerger) Run(suffix string, namespaces []string, templateFileName
 	dm.Out <- fmt.Sprintf(out.String(), suffix)
 }
 
+// applyAnomaly replaces the rows of the dates within the requested time
+// range by their anomalies relative to the rows of the same calendar
+// period over the baseline years. Rows of other dates are dropped.
+func (dm *DrillMerger) applyAnomaly(dates []string, rows [][]float64) ([]string, [][]float64) {
+	times := make([]time.Time, len(dates))
+	baselineRows := make(map[string][]int)
+	for i, dt := range dates {
+		t, err := time.Parse(ISOFormat, dt)
+		if err != nil {
+			continue
+		}
+		times[i] = t
+		year := t.Year()
+		if year >= dm.Anomaly.BaselineStartYear && year <= dm.Anomaly.BaselineEndYear {
+			period := dm.Anomaly.PeriodKey(t)
+			baselineRows[period] = append(baselineRows[period], i)
+		}
+	}
+
+	var outDates []string
+	var outRows [][]float64
+	var baseline []float64
+	for i, t := range times {
+		if t.IsZero() || t.Before(dm.StartTime) || t.After(dm.EndTime) {
+			continue
+		}
+
+		baselineIdx := baselineRows[dm.Anomaly.PeriodKey(t)]
+		row := make([]float64, len(rows[i]))
+		for ic, val := range rows[i] {
+			baseline = baseline[:0]
+			for _, ib := range baselineIdx {
+				if ic < len(rows[ib]) && !math.IsNaN(rows[ib][ic]) {
+					baseline = append(baseline, rows[ib][ic])
+				}
+			}
+
+			row[ic] = math.NaN()
+			if anomaly, ok := dm.Anomaly.Apply(val, baseline); ok {
+				row[ic] = anomaly
+			}
+		}
+		outDates = append(outDates, dates[i])
+		outRows = append(outRows, row)
+	}
+	return outDates, outRows
+}
+
 func (dm *DrillMerger) sendError(err error) {
 	select {
 	case dm.Error <- err:
@@ -55,14 +55,23 @@ func (dp *DrillPipeline) Process(geoReq GeoDrillRequest, suffix string, template
 		dp.Error <- fmt.Errorf("Couldn't instantiate RPCDriller %s/n", dp.RPCAddrs)
 	}
 
+	dm := NewDrillMerger(dp.Context, dp.Error)
+
+	// Anomalies are relative to the values of the baseline years, so
+	// these are drilled as well
+	if geoReq.Anomaly != nil {
+		dm.Anomaly = geoReq.Anomaly
+		dm.StartTime = geoReq.StartTime
+		dm.EndTime = geoReq.EndTime
+		geoReq.StartTime, geoReq.EndTime = geoReq.Anomaly.QueryRange(geoReq.StartTime, geoReq.EndTime)
+	}
+
 	i := NewDrillIndexer(dp.Context, dp.APIAddr, dp.IdentityTol, dp.DpTol, approx, dp.Error)
 	go func() {
 		i.In <- &geoReq
 		close(i.In)
 	}()
 
-	dm := NewDrillMerger(dp.Context, dp.Error)
-
 	grpcDriller.In = i.Out
 	dm.In = grpcDriller.Out
 
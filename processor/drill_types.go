@@ -26,6 +26,8 @@ type GeoDrillRequest struct {
 	GrpcConcLimit    int
 	IndexTileXSize   float64
 	IndexTileYSize   float64
+	Anomaly          *utils.AnomalyConfig
+	Unpack           bool
 	MetricsCollector *metrics.MetricsCollector
 }
 
@@ -46,6 +48,8 @@ type GeoDrillGranule struct {
 	RasterXSize      float64
 	RasterYSize      float64
 	GrpcConcLimit    int
+	Units            string
+	Packing          *BandPacking
 	MetricsCollector *metrics.MetricsCollector
 }
 
@@ -54,6 +58,7 @@ type DrillResult struct {
 	Dates     []time.Time
 	Data      []*pb.TimeSeries
 	NoData    float64
+	Units     string
 }
 
 type DrillFileDescriptor struct {
@@ -3,8 +3,10 @@ package processor
 import (
 	"context"
 	"fmt"
+	"math"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/nci/gsky/metrics"
@@ -16,15 +18,17 @@ type featureInfo struct {
 	Namespaces []string
 	DsFiles    []string
 	DsDates    []string
+	Units      map[string]string
+	TimeSeries *utils.FeatureInfoTimeSeries
 }
 
-func GetFeatureInfo(ctx context.Context, params utils.WMSParams, conf *utils.Config, configMap map[string]*utils.Config, verbose bool, metricsCollector *metrics.MetricsCollector) (string, error) {
+func GetFeatureInfo(ctx context.Context, params utils.WMSParams, conf *utils.Config, configMap map[string]*utils.Config, verbose bool, metricsCollector *metrics.MetricsCollector) (*utils.FeatureInfo, error) {
 	ftInfo, err := getRaster(ctx, params, conf, configMap, verbose, metricsCollector)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	out := `"bands": {`
+	info := &utils.FeatureInfo{AvailableDates: ftInfo.DsDates, TimeSeries: ftInfo.TimeSeries}
 
 	hasData := true
 	if len(ftInfo.Raster) == 1 {
@@ -40,13 +44,9 @@ func GetFeatureInfo(ctx context.Context, params utils.WMSParams, conf *utils.Con
 			}
 
 			if !hasData {
-				for i, ns := range ftInfo.Namespaces {
-					out += fmt.Sprintf(`"%s":"%s"`, ns, msg)
-					if i < len(ftInfo.Namespaces)-1 {
-						out += ","
-					}
+				for _, ns := range ftInfo.Namespaces {
+					info.Bands = append(info.Bands, utils.FeatureInfoBand{Name: ns, Value: msg})
 				}
-				out += `}`
 			}
 		}
 	}
@@ -54,85 +54,33 @@ func GetFeatureInfo(ctx context.Context, params utils.WMSParams, conf *utils.Con
 	if hasData {
 		width, height, _, err := utils.ValidateRasterSlice(ftInfo.Raster)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
-		x := *params.X
-		y := *params.Y
-
-		offset := y*width + x
+		offset := *params.Y*width + *params.X
 		if offset >= width*height {
-			return "", fmt.Errorf("x or y out of bound")
+			return nil, fmt.Errorf("x or y out of bound")
 		}
 
 		for i, ns := range ftInfo.Namespaces {
-			r := ftInfo.Raster[i]
-			var valueStr string
-
-			switch t := r.(type) {
-			case *utils.SignedByteRaster:
-				noData := int8(t.NoData)
-				value := t.Data[offset]
-				if value == noData {
-					valueStr = `"n/a"`
-				} else {
-					valueStr = fmt.Sprintf("%v", value)
-				}
-
-			case *utils.ByteRaster:
-				noData := uint8(t.NoData)
-				value := t.Data[offset]
-				if value == noData {
-					valueStr = `"n/a"`
-				} else {
-					valueStr = fmt.Sprintf("%v", value)
-				}
-
-			case *utils.Int16Raster:
-				noData := int16(t.NoData)
-				value := t.Data[offset]
-				if value == noData {
-					valueStr = `"n/a"`
-				} else {
-					valueStr = fmt.Sprintf("%v", value)
-				}
-
-			case *utils.UInt16Raster:
-				noData := uint16(t.NoData)
-				value := t.Data[offset]
-				if value == noData {
-					valueStr = `"n/a"`
-				} else {
-					valueStr = fmt.Sprintf("%v", value)
-				}
-
-			case *utils.Float32Raster:
-				noData := float32(t.NoData)
-				value := t.Data[offset]
-				if value == noData {
-					valueStr = `"n/a"`
-				} else {
-					valueStr = fmt.Sprintf("%v", value)
-				}
+			var value interface{}
+			if i < len(ftInfo.Raster) {
+				value = pixelValue(ftInfo.Raster[i], offset)
 			}
-
-			out += fmt.Sprintf(`"%s": %s`, ns, valueStr)
-			if i < len(ftInfo.Namespaces)-1 {
-				out += ","
+			if value == nil {
+				value = "n/a"
 			}
+			info.Bands = append(info.Bands, utils.FeatureInfoBand{Name: ns, Value: value})
 		}
-		out += `}`
 	}
 
-	if len(ftInfo.DsDates) > 0 {
-		out += `, "data_available_for_dates":[`
-		for i, ts := range ftInfo.DsDates {
-			out += fmt.Sprintf(`"%s"`, ts)
-			if i < len(ftInfo.DsDates)-1 {
-				out += ","
+	for _, ns := range ftInfo.Namespaces {
+		if u, found := ftInfo.Units[ns]; found {
+			if info.Units == nil {
+				info.Units = make(map[string]string)
 			}
+			info.Units[ns] = u
 		}
-		out += `]`
 	}
 
 	if len(ftInfo.DsFiles) > 0 {
@@ -144,18 +92,58 @@ func GetFeatureInfo(ctx context.Context, params utils.WMSParams, conf *utils.Con
 				prefix += "/"
 			}
 		}
-		out += `, "data_links":[`
-		for i, file := range ftInfo.DsFiles {
+		for _, file := range ftInfo.DsFiles {
+			info.DataLinks = append(info.DataLinks, prefix+file)
+		}
+	}
+
+	return info, nil
+}
 
-			out += fmt.Sprintf(`"%s%s"`, prefix, file)
-			if i < len(ftInfo.DsFiles)-1 {
-				out += ","
+// pixelValue returns the value of a raster at a pixel offset or nil if
+// the pixel is nodata
+func pixelValue(r utils.Raster, offset int) interface{} {
+	switch t := r.(type) {
+	case *utils.SignedByteRaster:
+		if offset < len(t.Data) && t.Data[offset] != int8(t.NoData) {
+			return t.Data[offset]
+		}
+	case *utils.ByteRaster:
+		if offset < len(t.Data) && t.Data[offset] != uint8(t.NoData) {
+			return t.Data[offset]
+		}
+	case *utils.Int16Raster:
+		if offset < len(t.Data) && t.Data[offset] != int16(t.NoData) {
+			return t.Data[offset]
+		}
+	case *utils.UInt16Raster:
+		if offset < len(t.Data) && t.Data[offset] != uint16(t.NoData) {
+			return t.Data[offset]
+		}
+	case *utils.Float32Raster:
+		if offset < len(t.Data) {
+			value := t.Data[offset]
+			if value != float32(t.NoData) && !math.IsNaN(float64(value)) && !math.IsInf(float64(value), 0) {
+				return value
+			}
+		}
+	case *utils.Int32Raster:
+		if offset < len(t.Data) && t.Data[offset] != int32(t.NoData) {
+			return t.Data[offset]
+		}
+	case *utils.UInt32Raster:
+		if offset < len(t.Data) && t.Data[offset] != uint32(t.NoData) {
+			return t.Data[offset]
+		}
+	case *utils.Float64Raster:
+		if offset < len(t.Data) {
+			value := t.Data[offset]
+			if value != t.NoData && !math.IsNaN(value) && !math.IsInf(value, 0) {
+				return value
 			}
 		}
-		out += `]`
 	}
-
-	return out, nil
+	return nil
 }
 
 func getRaster(ctx context.Context, params utils.WMSParams, conf *utils.Config, configMap map[string]*utils.Config, verbose bool, metricsCollector *metrics.MetricsCollector) (*featureInfo, error) {
@@ -215,7 +203,10 @@ func getRaster(ctx context.Context, params utils.WMSParams, conf *utils.Config,
 	if params.X == nil || params.Y == nil {
 		return nil, fmt.Errorf("Request should contain valid 'x' and 'y' parameters.")
 	}
-	if strings.ToUpper(*params.CRS) == "EPSG:4326" && *params.Version == "1.3.0" {
+	if !utils.CheckLayerCRS(&conf.Layers[idx], *params.CRS) {
+		return nil, fmt.Errorf("Layer %s doesn't support CRS %s", conf.Layers[idx].Name, *params.CRS)
+	}
+	if utils.CRSHasLatLonOrder(*params.CRS) && *params.Version == "1.3.0" {
 		params.BBox = []float64{params.BBox[1], params.BBox[0], params.BBox[3], params.BBox[2]}
 	}
 	if strings.ToUpper(*params.CRS) == "CRS:84" && *params.Version == "1.3.0" {
@@ -232,20 +223,29 @@ func getRaster(ctx context.Context, params utils.WMSParams, conf *utils.Config,
 	bbox, err := utils.GetCanonicalBbox(*params.CRS, params.BBox)
 	if err != nil {
 		bbox = params.BBox
-	} else {
-		*params.CRS = "EPSG:3857"
 	}
 	reqRes := utils.GetPixelResolution(bbox, *params.Width, *params.Height)
 
+	// Pixels of CRSs that don't wrap around the antimeridian, e.g. polar
+	// projections, are distorted in EPSG:3857 and are thus located in the
+	// requested CRS
+	pixelRes := reqRes
+	if _, wraps := utils.GetWorldExtent(*params.CRS); wraps && err == nil {
+		*params.CRS = "EPSG:3857"
+	} else {
+		bbox = params.BBox
+		pixelRes = utils.GetPixelResolution(bbox, *params.Width, *params.Height)
+	}
+
 	// We construct a 2x2 image corresponding to an infinitesimal bounding box
 	// to approximate a pixel.
 	// We observed several order of magnitude of performance improvement as a
 	// result of such an approximation.
-	xmin := bbox[0] + float64(*params.X)*reqRes
-	ymin := bbox[3] - float64(*params.Y)*reqRes
+	xmin := bbox[0] + float64(*params.X)*pixelRes
+	ymin := bbox[3] - float64(*params.Y)*pixelRes
 
-	xmax := bbox[0] + float64(*params.X+1)*reqRes
-	ymax := bbox[3] - float64(*params.Y-1)*reqRes
+	xmax := bbox[0] + float64(*params.X+1)*pixelRes
+	ymax := bbox[3] - float64(*params.Y-1)*pixelRes
 
 	*params.Height = 2
 	*params.Width = 2
@@ -275,6 +275,7 @@ func getRaster(ctx context.Context, params utils.WMSParams, conf *utils.Config,
 		IndexTileYSize:      conf.Layers[idx].IndexTileYSize,
 		SpatialExtent:       conf.Layers[idx].SpatialExtent,
 		IndexResLimit:       conf.Layers[idx].IndexResLimit,
+		Unpack:              conf.Layers[idx].Unpack,
 		MetricsCollector:    metricsCollector,
 	},
 		Collection: styleLayer.DataSource,
@@ -334,6 +335,30 @@ func getRaster(ctx context.Context, params utils.WMSParams, conf *utils.Config,
 
 	ftInfo.Raster = outRaster
 	ftInfo.Namespaces = bandExpr.ExprNames
+
+	// Anomalies other than differences don't have the units of the data
+	if styleLayer.Anomaly == nil || styleLayer.Anomaly.Output == utils.AnomalyOutputAnomaly {
+		granules, err := tp.GetFileList(geoReq, verbose)
+		if err == nil {
+			ftInfo.Units = exprUnits(bandExpr, bandUnits(granules))
+		}
+	}
+
+	if params.TimeSeriesStart != nil && params.TimeSeriesEnd != nil {
+		if conf.Layers[idx].FeatureInfoMaxTimeSeries == 0 {
+			return nil, fmt.Errorf("Layer %s doesn't support time series", conf.Layers[idx].Name)
+		}
+
+		seriesReq := *geoReq
+		seriesReq.StartTime = params.TimeSeriesStart
+		seriesReq.EndTime = params.TimeSeriesEnd
+		ts, err := getPixelTimeSeries(ctx, conf, idx, styleLayer, configMap, &seriesReq, *params.Y**params.Width+*params.X, verbose)
+		if err != nil {
+			return nil, err
+		}
+		ftInfo.TimeSeries = ts
+	}
+
 	if conf.Layers[idx].FeatureInfoMaxAvailableDates == 0 && conf.Layers[idx].FeatureInfoMaxDataLinks == 0 {
 		return ftInfo, nil
 	}
@@ -365,30 +390,13 @@ func getRaster(ctx context.Context, params utils.WMSParams, conf *utils.Config,
 		return nil, err
 	}
 
-	var pixelFiles []*GeoTileGranule
-	timestampLookup := make(map[time.Time]struct{})
-	for _, geo := range indexerOut {
-		if geo.NameSpace == utils.EmptyTileNS {
-			continue
-		}
-
-		tm := time.Unix(int64(geo.TimeStamp), 0).UTC()
-		normalizedTm := time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, time.UTC)
-		if _, found := timestampLookup[normalizedTm]; found {
-			continue
-		}
-
-		timestampLookup[normalizedTm] = struct{}{}
-		pixelFiles = append(pixelFiles, geo)
-	}
-
-	sort.Slice(pixelFiles, func(i, j int) bool { return pixelFiles[i].TimeStamp >= pixelFiles[j].TimeStamp })
+	pixelFiles := uniquePixelDates(indexerOut, true)
 
 	var topDsDates []string
 	dateFormat := "2006-01-02"
 	if conf.Layers[idx].FeatureInfoMaxAvailableDates != 0 {
 		maxDates := conf.Layers[idx].FeatureInfoMaxAvailableDates
-		if maxDates < 0 {
+		if maxDates < 0 || maxDates > len(pixelFiles) {
 			maxDates = len(pixelFiles)
 		}
 		for i := range pixelFiles[:maxDates] {
@@ -434,3 +442,115 @@ func getRaster(ctx context.Context, params utils.WMSParams, conf *utils.Config,
 	ftInfo.DsFiles = topDsFiles
 	return ftInfo, nil
 }
+
+// uniquePixelDates returns the granules of a pixel with distinct
+// timestamps, or distinct days if byDay is set, latest first
+func uniquePixelDates(granules []*GeoTileGranule, byDay bool) []*GeoTileGranule {
+	var pixelFiles []*GeoTileGranule
+	timestampLookup := make(map[time.Time]struct{})
+	for _, geo := range granules {
+		if geo.NameSpace == utils.EmptyTileNS {
+			continue
+		}
+
+		tm := time.Unix(int64(geo.TimeStamp), 0).UTC()
+		if byDay {
+			tm = time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		if _, found := timestampLookup[tm]; found {
+			continue
+		}
+
+		timestampLookup[tm] = struct{}{}
+		pixelFiles = append(pixelFiles, geo)
+	}
+
+	sort.Slice(pixelFiles, func(i, j int) bool { return pixelFiles[i].TimeStamp >= pixelFiles[j].TimeStamp })
+	return pixelFiles
+}
+
+// getPixelTimeSeries returns the values of the bands at a pixel of a 2x2
+// request for each date with data between its start and end times. The
+// series is limited to the latest feature_info_max_time_series dates of
+// the layer and to max_feature_info_time_series dates of the server,
+// which also limits layers that allow all dates.
+func getPixelTimeSeries(ctx context.Context, conf *utils.Config, idx int, styleLayer *utils.Layer, configMap map[string]*utils.Config, geoReq *GeoTileRequest, offset int, verbose bool) (*utils.FeatureInfoTimeSeries, error) {
+	layer := &conf.Layers[idx]
+
+	errChan := make(chan error, 100)
+	tp := InitTilePipeline(ctx, styleLayer.MASAddress, conf.ServiceConfig.WorkerNodes, layer.MaxGrpcRecvMsgSize, layer.WmsPolygonShardConcLimit, conf.ServiceConfig.MaxGrpcBufferSize, errChan)
+	tp.CurrentLayer = styleLayer
+	tp.DataSources = configMap
+
+	indexerOut, err := tp.GetFileList(geoReq, verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	maxDates := conf.ServiceConfig.MaxFeatureInfoTimeSeries
+	if layer.FeatureInfoMaxTimeSeries > 0 && (maxDates <= 0 || layer.FeatureInfoMaxTimeSeries < maxDates) {
+		maxDates = layer.FeatureInfoMaxTimeSeries
+	}
+
+	pixelFiles := uniquePixelDates(indexerOut, false)
+	if maxDates > 0 && len(pixelFiles) > maxDates {
+		pixelFiles = pixelFiles[:maxDates]
+	}
+
+	nDates := len(pixelFiles)
+	names := geoReq.BandExpr.ExprNames
+	values := make([][]interface{}, len(names))
+	for ib := range values {
+		values[ib] = make([]interface{}, nDates)
+	}
+
+	ts := &utils.FeatureInfoTimeSeries{Dates: make([]string, nDates)}
+
+	var wg sync.WaitGroup
+	errList := make(chan error, nDates)
+	cLimiter := NewConcLimiter(4)
+	for id := range pixelFiles {
+		date := time.Unix(int64(pixelFiles[nDates-1-id].TimeStamp), 0).UTC()
+		ts.Dates[id] = date.Format(ISOFormat)
+
+		cLimiter.Increase()
+		wg.Add(1)
+		go func(id int, date time.Time) {
+			defer wg.Done()
+			defer cLimiter.Decrease()
+
+			dateReq := *geoReq
+			setInputTime(layer, &dateReq, date)
+
+			dateErrChan := make(chan error, 100)
+			dtp := InitTilePipeline(ctx, styleLayer.MASAddress, conf.ServiceConfig.WorkerNodes, layer.MaxGrpcRecvMsgSize, layer.WmsPolygonShardConcLimit, conf.ServiceConfig.MaxGrpcBufferSize, dateErrChan)
+			dtp.CurrentLayer = styleLayer
+			dtp.DataSources = configMap
+
+			select {
+			case res := <-dtp.Process(&dateReq, verbose):
+				for ib := range values {
+					if ib < len(res) {
+						values[ib][id] = pixelValue(res[ib], offset)
+					}
+				}
+			case err := <-dateErrChan:
+				errList <- err
+			case <-ctx.Done():
+				errList <- ctx.Err()
+			}
+		}(id, date)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errList:
+		return nil, err
+	default:
+	}
+
+	for ib, name := range names {
+		ts.Bands = append(ts.Bands, utils.FeatureInfoBand{Name: name, Value: values[ib]})
+	}
+	return ts, nil
+}
@@ -0,0 +1,37 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/nci/gsky/utils"
+)
+
+func TestUniquePixelDates(t *testing.T) {
+	// 2020-01-01 at 00:00 and 12:00 twice, and 2020-01-02
+	granules := []*GeoTileGranule{
+		{NameSpace: "a", TimeStamp: 1577836800},
+		{NameSpace: "a", TimeStamp: 1577880000},
+		{NameSpace: "b", TimeStamp: 1577880000},
+		{NameSpace: utils.EmptyTileNS, TimeStamp: 1577923200},
+		{NameSpace: "a", TimeStamp: 1577923200},
+	}
+
+	for _, tc := range []struct {
+		byDay    bool
+		expected []float64
+	}{
+		{false, []float64{1577923200, 1577880000, 1577836800}},
+		{true, []float64{1577923200, 1577836800}},
+	} {
+		files := uniquePixelDates(granules, tc.byDay)
+		if len(files) != len(tc.expected) {
+			t.Errorf("by day %v: expected %v, got %d granules", tc.byDay, tc.expected, len(files))
+			continue
+		}
+		for i, f := range files {
+			if f.TimeStamp != tc.expected[i] || f.NameSpace == utils.EmptyTileNS {
+				t.Errorf("by day %v: expected %v, got %+v at %d", tc.byDay, tc.expected, f, i)
+			}
+		}
+	}
+}
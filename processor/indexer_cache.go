@@ -0,0 +1,134 @@
+package processor
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/nci/gsky/metrics"
+	"github.com/nci/gsky/utils"
+	"github.com/nci/gsky/utils/lru"
+)
+
+// Default size of the indexer cache in bytes
+const DefaultIndexerCacheSize = 64 * 1024 * 1024
+
+// Default lifetime of cached MAS responses. Tiles of the same map view
+// repeat identical queries within seconds, while new data must show up
+// quickly even without the MAS change feed.
+const DefaultIndexerCacheTTL = 30 * time.Second
+
+type indexerCacheEntry struct {
+	gpath string
+	value []byte
+}
+
+// IndexerCache is a LRU cache of MAS intersects responses bounded by
+// the total size of the responses. Entries expire after the TTL.
+type IndexerCache struct {
+	cache *lru.Cache
+}
+
+func NewIndexerCache(maxBytes int, ttl time.Duration) *IndexerCache {
+	return &IndexerCache{cache: lru.New(maxBytes, ttl)}
+}
+
+var indexerCache = NewIndexerCache(DefaultIndexerCacheSize, DefaultIndexerCacheTTL)
+
+// SetIndexerCache sizes the indexer cache. A size or TTL of 0 disables
+// it.
+func SetIndexerCache(maxBytes int, ttl time.Duration) {
+	if maxBytes <= 0 || ttl <= 0 {
+		indexerCache = nil
+		return
+	}
+	indexerCache = NewIndexerCache(maxBytes, ttl)
+}
+
+// GetIndexerCache returns the indexer cache or nil if it is disabled
+func GetIndexerCache() *IndexerCache {
+	return indexerCache
+}
+
+func (c *IndexerCache) Get(key string) ([]byte, bool) {
+	entry, found := c.cache.Get(key)
+	if !found {
+		return nil, false
+	}
+	return entry.(*indexerCacheEntry).value, true
+}
+
+// Put caches the response to a query of gpath
+func (c *IndexerCache) Put(key string, gpath string, value []byte) {
+	c.cache.Put(key, &indexerCacheEntry{gpath: gpath, value: value}, len(value))
+}
+
+// Evict drops the responses to queries of gpaths related to any of
+// gpaths, or every response if reset is true
+func (c *IndexerCache) Evict(gpaths []string, reset bool) {
+	if reset {
+		c.cache.Purge()
+		return
+	}
+
+	c.cache.RemoveIf(func(key string, entry interface{}) bool {
+		for _, gpath := range gpaths {
+			if utils.GPathRelated(entry.(*indexerCacheEntry).gpath, gpath) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Len returns the number of cached responses
+func (c *IndexerCache) Len() int {
+	return c.cache.Len()
+}
+
+// getIndexerResponse queries MAS for the datasets of gpath. The query is
+// posted if form is not nil. Successful responses are served from the
+// indexer cache until they expire.
+func getIndexerResponse(reqURL string, form url.Values, gpath string, metricsCollector *metrics.MetricsCollector) ([]byte, error) {
+	key := reqURL
+	if form != nil {
+		key = reqURL + "\n" + form.Encode()
+	}
+
+	cache := indexerCache
+	if cache != nil {
+		if body, found := cache.Get(key); found {
+			if metricsCollector != nil {
+				atomic.AddInt64(&metricsCollector.Info.Indexer.CacheHits, 1)
+			}
+			return body, nil
+		}
+		if metricsCollector != nil {
+			atomic.AddInt64(&metricsCollector.Info.Indexer.CacheMisses, 1)
+		}
+	}
+
+	var resp *http.Response
+	var err error
+	if form != nil {
+		resp, err = http.PostForm(reqURL, form)
+	} else {
+		resp, err = http.Get(reqURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache != nil && resp.StatusCode == http.StatusOK {
+		cache.Put(key, gpath, body)
+	}
+	return body, nil
+}
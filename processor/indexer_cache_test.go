@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIndexerCache(t *testing.T) {
+	c := NewIndexerCache(10, time.Minute)
+	c.Put("a", "/g/data/ab1/prod", []byte("1234"))
+	c.Put("b", "/g/data/ab2", []byte("1234"))
+	c.Put("c", "/g/data/ab2", []byte("1234"))
+	if _, found := c.Get("a"); found {
+		t.Errorf("expected the least recently used response to be evicted")
+	}
+
+	c.Put("a", "/g/data/ab1/prod", []byte("12"))
+	c.Evict([]string{"/g/data/ab1"}, false)
+	if _, found := c.Get("a"); found {
+		t.Errorf("expected responses under a changed gpath to be evicted")
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 responses, got %d", c.Len())
+	}
+
+	c.Evict(nil, true)
+	if c.Len() != 0 {
+		t.Errorf("expected no responses after a reset, got %d", c.Len())
+	}
+
+	c = NewIndexerCache(10, time.Millisecond)
+	c.Put("a", "/g/data/ab1", []byte("1234"))
+	time.Sleep(5 * time.Millisecond)
+	if _, found := c.Get("a"); found {
+		t.Errorf("expected the response to expire")
+	}
+}
+
+func TestGetIndexerResponse(t *testing.T) {
+	nRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nRequests++
+		w.Write([]byte(`{"gdal":[]}`))
+	}))
+	defer server.Close()
+
+	defer SetIndexerCache(DefaultIndexerCacheSize, DefaultIndexerCacheTTL)
+	SetIndexerCache(1024, time.Minute)
+
+	reqURL := server.URL + "/g/data/ab1?intersects"
+	for i := 0; i < 3; i++ {
+		body, err := getIndexerResponse(reqURL, nil, "/g/data/ab1", nil)
+		if err != nil || string(body) != `{"gdal":[]}` {
+			t.Fatalf("unexpected response %s: %v", body, err)
+		}
+	}
+	if nRequests != 1 {
+		t.Errorf("expected 1 request to MAS, got %d", nRequests)
+	}
+
+	SetIndexerCache(0, 0)
+	getIndexerResponse(reqURL, nil, "/g/data/ab1", nil)
+	if nRequests != 2 {
+		t.Errorf("expected a disabled cache to query MAS, got %d requests", nRequests)
+	}
+}
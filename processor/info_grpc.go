@@ -2,14 +2,12 @@ package processor
 
 import (
 	"fmt"
-	"log"
-	"math/rand"
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
+	"github.com/nci/gsky/utils"
 	pb "github.com/nci/gsky/worker/gdalservice"
 	"golang.org/x/net/context"
-	"google.golang.org/grpc"
 )
 
 type Overview struct {
@@ -60,15 +58,7 @@ func NewInfoGRPC(ctx context.Context, serverAddress []string, errChan chan error
 func (gi *GeoInfoGRPC) Run() {
 	defer close(gi.Out)
 
-	conns := make([]*grpc.ClientConn, len(gi.Clients))
-	for i, client := range gi.Clients {
-		conn, err := grpc.Dial(client, grpc.WithInsecure())
-		if err != nil {
-			log.Fatalf("gRPC connection problem: %v", err)
-		}
-		defer conn.Close()
-		conns[i] = conn
-	}
+	workerMgr := utils.GetWorkerManager()
 
 	// Concurrency limited to the number of gRPC workers
 	cl := NewConcLimiter(16)
@@ -83,8 +73,7 @@ func (gi *GeoInfoGRPC) Run() {
 			go func(g string) {
 				defer cl.Decrease()
 
-				c := pb.NewGDALClient(conns[rand.Intn(len(conns))])
-				r, err := c.Process(gi.Context, &pb.GeoRPCGranule{Operation: "info", Path: g})
+				r, err := workerMgr.Process(gi.Context, gi.Clients, &pb.GeoRPCGranule{Operation: "info", Path: g, Priority: pb.Priority_BACKGROUND})
 				if err != nil {
 					fmt.Println(err)
 					gi.Error <- err
@@ -0,0 +1,263 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/nci/gsky/utils"
+)
+
+// anomalyNoData is the nodata value of the rasters of anomaly layers
+const anomalyNoData = -9999.0
+
+// processAnomaly renders the bands of the base layer of an anomaly layer
+// at the requested time relative to their climatology over the baseline
+// years. The climatologies are cached by tile.
+func (dp *TilePipeline) processAnomaly(geoReq *GeoTileRequest, verbose bool) ([]*FlexRaster, error) {
+	anomaly := dp.CurrentLayer.Anomaly
+
+	depLayers, err := dp.findDepLayers()
+	if err != nil {
+		return nil, err
+	}
+	if len(depLayers) == 0 {
+		return nil, fmt.Errorf("anomaly layer '%v' has no base layer", dp.CurrentLayer.Name)
+	}
+	reqCtx := depLayers[0]
+	dp.prepareInputGeoRequests(geoReq, depLayers[:1], true)
+
+	req := *reqCtx.GeoReq
+	if !resolveInputTime(reqCtx.Layer, &req) {
+		if verbose {
+			log.Printf("anomaly pipeline '%v': no dates at or before %v", reqCtx.Layer.Name, geoReq.StartTime)
+		}
+		return nil, nil
+	}
+
+	refTime := time.Now().UTC()
+	if req.StartTime != nil {
+		refTime = *req.StartTime
+	}
+
+	res, err := dp.runInputPipeline(reqCtx, &req, verbose)
+	if err != nil {
+		return nil, err
+	}
+	bands := rasterValues(res, req.Width*req.Height)
+	if len(bands) == 0 {
+		return nil, nil
+	}
+
+	key := fmt.Sprintf("%s|%s/%s|%d-%d|%s|%s|%s|%v|%dx%d", reqCtx.Layer.NameSpace, reqCtx.Layer.Name, reqCtx.StyleLayer.Name,
+		anomaly.BaselineStartYear, anomaly.BaselineEndYear, anomaly.Period, anomaly.PeriodKey(refTime), req.CRS, req.BBox, req.Width, req.Height)
+
+	clim, shared, err := loadClimatology(key, func() (*climatology, error) {
+		return dp.computeClimatology(reqCtx, req, refTime, len(bands), verbose)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shared && verbose {
+		log.Printf("anomaly pipeline '%v': cached climatology of %d dates", reqCtx.Layer.Name, clim.dates)
+	}
+
+	exprNames := reqCtx.StyleLayer.RGBExpressions.ExprNames
+	nPixels := req.Width * req.Height
+	baseline := make([]float64, 0, clim.dates)
+	var rasters []*FlexRaster
+	for ib, values := range bands {
+		if ib >= len(exprNames) || ib >= len(clim.values) {
+			break
+		}
+
+		out := make([]float32, nPixels)
+		for i := range out {
+			out[i] = anomalyNoData
+			if values == nil || math.IsNaN(float64(values[i])) {
+				continue
+			}
+
+			baseline = baseline[:0]
+			for id := 0; id < clim.dates; id++ {
+				val := clim.values[ib][id*nPixels+i]
+				if !math.IsNaN(float64(val)) {
+					baseline = append(baseline, float64(val))
+				}
+			}
+
+			if val, ok := anomaly.Apply(float64(values[i]), baseline); ok {
+				out[i] = float32(val)
+			}
+		}
+
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
+		headr.Len *= SizeofFloat32
+		headr.Cap *= SizeofFloat32
+		rasters = append(rasters, &FlexRaster{ConfigPayLoad: ConfigPayLoad{NameSpaces: geoReq.ConfigPayLoad.NameSpaces}, NameSpace: exprNames[ib],
+			TimeStamp: float64(refTime.Unix()), Polygon: "dummy_polygon", Type: "Float32", NoData: anomalyNoData,
+			Data: *(*[]uint8)(unsafe.Pointer(&headr)), Height: geoReq.Height, Width: geoReq.Width, DataHeight: geoReq.Height, DataWidth: geoReq.Width})
+	}
+
+	if verbose {
+		log.Printf("anomaly pipeline '%v' done", reqCtx.Layer.Name)
+	}
+	return rasters, nil
+}
+
+// computeClimatology renders the bands of the base layer of an anomaly
+// layer at each of the baseline dates of refTime
+func (dp *TilePipeline) computeClimatology(reqCtx *GeoReqContext, req GeoTileRequest, refTime time.Time, nBands int, verbose bool) (*climatology, error) {
+	anomaly := dp.CurrentLayer.Anomaly
+	dates := anomaly.BaselineDates(refTime, reqCtx.Layer.Dates)
+	nPixels := req.Width * req.Height
+
+	clim := &climatology{dates: len(dates), values: make([][]float32, nBands)}
+	nan := float32(math.NaN())
+	for ib := range clim.values {
+		clim.values[ib] = make([]float32, len(dates)*nPixels)
+		for i := range clim.values[ib] {
+			clim.values[ib][i] = nan
+		}
+	}
+
+	var wg sync.WaitGroup
+	errList := make(chan error, len(dates))
+	cLimiter := NewConcLimiter(4)
+	for id, date := range dates {
+		cLimiter.Increase()
+		wg.Add(1)
+		go func(id int, date time.Time) {
+			defer wg.Done()
+			defer cLimiter.Decrease()
+
+			dateReq := req
+			setInputTime(reqCtx.Layer, &dateReq, date)
+			res, err := dp.runInputPipeline(reqCtx, &dateReq, verbose)
+			if err != nil {
+				errList <- err
+				return
+			}
+
+			for ib, values := range rasterValues(res, nPixels) {
+				if ib < nBands && values != nil {
+					copy(clim.values[ib][id*nPixels:], values)
+				}
+			}
+		}(id, date)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errList:
+		return nil, err
+	default:
+	}
+
+	if verbose {
+		log.Printf("anomaly pipeline '%v': climatology of %d dates done", reqCtx.Layer.Name, len(dates))
+	}
+	return clim, nil
+}
+
+// rasterValues converts the bands of the output of a tile pipeline to
+// float32 values with NaN for nodata. Bands without data are nil.
+func rasterValues(rasters []utils.Raster, nPixels int) [][]float32 {
+	var bands [][]float32
+	for _, raster := range rasters {
+		var values []float32
+		switch t := raster.(type) {
+		case *utils.SignedByteRaster:
+			if len(t.Data) == nPixels {
+				values = make([]float32, nPixels)
+				for i, val := range t.Data {
+					values[i] = float32(val)
+				}
+			}
+		case *utils.ByteRaster:
+			if t.NameSpace != utils.EmptyTileNS && len(t.Data) == nPixels {
+				values = make([]float32, nPixels)
+				for i, val := range t.Data {
+					values[i] = float32(val)
+				}
+			}
+		case *utils.Int16Raster:
+			if len(t.Data) == nPixels {
+				values = make([]float32, nPixels)
+				for i, val := range t.Data {
+					values[i] = float32(val)
+				}
+			}
+		case *utils.UInt16Raster:
+			if len(t.Data) == nPixels {
+				values = make([]float32, nPixels)
+				for i, val := range t.Data {
+					values[i] = float32(val)
+				}
+			}
+		case *utils.Float32Raster:
+			if len(t.Data) == nPixels {
+				values = make([]float32, nPixels)
+				copy(values, t.Data)
+			}
+		case *utils.Int32Raster:
+			if len(t.Data) == nPixels {
+				values = make([]float32, nPixels)
+				noData := int32(t.NoData)
+				for i, val := range t.Data {
+					if val == noData {
+						values[i] = float32(math.NaN())
+					} else {
+						values[i] = float32(val)
+					}
+				}
+			}
+		case *utils.UInt32Raster:
+			if len(t.Data) == nPixels {
+				values = make([]float32, nPixels)
+				noData := uint32(t.NoData)
+				for i, val := range t.Data {
+					if val == noData {
+						values[i] = float32(math.NaN())
+					} else {
+						values[i] = float32(val)
+					}
+				}
+			}
+		case *utils.Float64Raster:
+			if len(t.Data) == nPixels {
+				values = make([]float32, nPixels)
+				noData := float64(t.NoData)
+				for i, val := range t.Data {
+					if val == noData {
+						values[i] = float32(math.NaN())
+					} else {
+						values[i] = float32(val)
+					}
+				}
+			}
+		}
+
+		if values != nil {
+			noData := float32(raster.GetNoData())
+			nan := float32(math.NaN())
+			for i, val := range values {
+				if val == noData {
+					values[i] = nan
+				}
+			}
+		}
+		bands = append(bands, values)
+	}
+
+	for _, values := range bands {
+		if values != nil {
+			return bands
+		}
+	}
+	return nil
+}
@@ -7,10 +7,10 @@ import "C"
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"reflect"
 	"unsafe"
 
+	"github.com/nci/gsky/utils"
 	pb "github.com/nci/gsky/worker/gdalservice"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -45,23 +45,13 @@ func ComputeReprojectionExtent(ctx context.Context, geoReq *GeoTileRequest, masA
 	const DefaultRecvMsgSize = 100 * 1024 * 1024
 	const DefaultConcLimit = 16
 
-	opts := []grpc.DialOption{
-		grpc.WithInsecure(),
-		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(DefaultRecvMsgSize)),
+	workerMgr := utils.GetWorkerManager()
+	nWorkers := workerMgr.AvailableCount(workerNodes)
+	if nWorkers == 0 {
+		nWorkers = 1
 	}
 
-	var conns []*grpc.ClientConn
-	for _, worker := range workerNodes {
-		conn, err := grpc.Dial(worker, opts...)
-		if err != nil {
-			log.Printf("gRPC connection problem: %v", err)
-			continue
-		}
-		defer conn.Close()
-		conns = append(conns, conn)
-	}
-
-	cLimiter := NewConcLimiter(DefaultConcLimit * len(conns))
+	cLimiter := NewConcLimiter(DefaultConcLimit * nWorkers)
 	type OutputSize struct {
 		Width  int
 		Height int
@@ -73,7 +63,7 @@ func ComputeReprojectionExtent(ctx context.Context, geoReq *GeoTileRequest, masA
 
 	hSRS := C.OSRNewSpatialReference(nil)
 	defer C.OSRDestroySpatialReference(hSRS)
-	crsC := C.CString(geoReq.CRS)
+	crsC := C.CString(utils.ResolveCRS(geoReq.CRS))
 	defer C.free(unsafe.Pointer(crsC))
 	C.OSRSetFromUserInput(hSRS, crsC)
 	var projWKTC *C.char
@@ -81,8 +71,6 @@ func ComputeReprojectionExtent(ctx context.Context, geoReq *GeoTileRequest, masA
 	C.OSRExportToWkt(hSRS, &projWKTC)
 	projWKT := C.GoString(projWKTC)
 
-	workerStart := rand.Intn(len(conns))
-
 	outChan := make(chan *OutputSize, len(indexGrans))
 	for ig, gran := range indexGrans {
 		select {
@@ -98,14 +86,12 @@ func ComputeReprojectionExtent(ctx context.Context, geoReq *GeoTileRequest, masA
 			cLimiter.Increase()
 			go func(g *GeoTileGranule, conc *ConcLimiter, iTile int) {
 				defer conc.Decrease()
-				c := pb.NewGDALClient(conns[(iTile+workerStart)%len(conns)])
-
 				dsPath := g.Path
 				if dsPath == "NULL" {
 					dsPath = g.RawPath
 				}
-				granule := &pb.GeoRPCGranule{Operation: "extent", Path: dsPath, DstSRS: projWKT, DstGeot: bbox}
-				res, err := c.Process(ctx, granule)
+				granule := &pb.GeoRPCGranule{Operation: "extent", Path: dsPath, DstSRS: projWKT, DstGeot: bbox, Priority: geoReq.Priority}
+				res, err := workerMgr.Process(ctx, workerNodes, granule, grpc.MaxCallRecvMsgSize(DefaultRecvMsgSize))
 				if err != nil {
 					errChan <- err
 					return
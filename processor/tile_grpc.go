@@ -8,11 +8,11 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"math/rand"
 	"sync"
 	"time"
 	"unsafe"
 
+	"github.com/nci/gsky/utils"
 	pb "github.com/nci/gsky/worker/gdalservice"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -53,9 +53,9 @@ func (gi *GeoRasterGRPC) Run(varList []string, verbose bool) {
 	var nullGrans []*GeoTileGranule
 	availNamespaces := make(map[string]struct{})
 	dedupGrans := make(map[string]struct{})
-	var connPool []*grpc.ClientConn
 	var projWKT string
 	var cLimiter *ConcLimiter
+	workerMgr := utils.GetWorkerManager()
 
 	accumMetrics := &pb.WorkerMetrics{}
 
@@ -74,7 +74,7 @@ func (gi *GeoRasterGRPC) Run(varList []string, verbose bool) {
 			continue
 		}
 
-		granKey := fmt.Sprintf("%s_%d", inGran.Path, inGran.BandIdx)
+		granKey := fmt.Sprintf("%s_%d_%d", inGran.Path, inGran.BandIdx, inGran.OffX)
 		if _, hasGran := dedupGrans[granKey]; hasGran {
 			continue
 		}
@@ -95,36 +95,15 @@ func (gi *GeoRasterGRPC) Run(varList []string, verbose bool) {
 				}
 			}()
 
-			opts := []grpc.DialOption{
-				grpc.WithInsecure(),
-				grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(gi.MaxGrpcRecvMsgSize)),
-			}
-
-			clientIdx := make([]int, len(gi.Clients))
-			for ic := range clientIdx {
-				clientIdx[ic] = ic
-			}
-			rand.Shuffle(len(clientIdx), func(i, j int) { clientIdx[i], clientIdx[j] = clientIdx[j], clientIdx[i] })
-
-			effectivePoolSize := len(gi.Clients)
-			for i := 0; i < effectivePoolSize; i++ {
-				conn, err := grpc.Dial(gi.Clients[clientIdx[i]], opts...)
-				if err != nil {
-					log.Printf("gRPC connection problem: %v", err)
-					continue
-				}
-				defer conn.Close()
-
-				connPool = append(connPool, conn)
-			}
-
-			if len(connPool) == 0 {
-				gi.Error <- fmt.Errorf("All gRPC servers offline")
-				return
+			// Ejected workers are excluded from the concurrency budget
+			// but requests still fall back to them while all are down
+			nWorkers := workerMgr.AvailableCount(gi.Clients)
+			if nWorkers == 0 {
+				nWorkers = 1
 			}
 
 			hSRS := C.OSRNewSpatialReference(nil)
-			crsC := C.CString(g0.CRS)
+			crsC := C.CString(utils.ResolveCRS(g0.CRS))
 			C.OSRSetFromUserInput(hSRS, crsC)
 			var projWKTC *C.char
 			C.OSRExportToWkt(hSRS, &projWKTC)
@@ -136,7 +115,7 @@ func (gi *GeoRasterGRPC) Run(varList []string, verbose bool) {
 
 			g0.DstGeoTransform = BBox2Geot(g0.Width, g0.Height, g0.BBox)
 
-			cLimiter = NewConcLimiter(g0.GrpcConcLimit * len(connPool))
+			cLimiter = NewConcLimiter(g0.GrpcConcLimit * nWorkers)
 		}
 
 		if g0.GrpcTileXSize > 0.0 || g0.GrpcTileYSize > 0.0 {
@@ -178,6 +157,11 @@ func (gi *GeoRasterGRPC) Run(varList []string, verbose bool) {
 				xRes := (g.BBox[2] - g.BBox[0]) / float64(g.Width)
 				yRes := (g.BBox[3] - g.BBox[1]) / float64(g.Height)
 
+				rawWidth, rawHeight := g.Width, g.Height
+				if g.RawWidth > 0 && g.RawHeight > 0 {
+					rawWidth, rawHeight = g.RawWidth, g.RawHeight
+				}
+
 				for y := 0; y < g.Height; y += maxYTileSize {
 					for x := 0; x < g.Width; x += maxXTileSize {
 						yMin := g.BBox[1] + float64(y)*yRes
@@ -190,7 +174,7 @@ func (gi *GeoRasterGRPC) Run(varList []string, verbose bool) {
 
 						tileBBox := []float64{xMin, yMin, xMax, yMax}
 						tileGeot := BBox2Geot(tileXSize, tileYSize, tileBBox)
-						tileGran := &GeoTileGranule{ConfigPayLoad: g.ConfigPayLoad, RawPath: g.RawPath, Path: g.Path, NameSpace: g.NameSpace, VarNameSpace: g.VarNameSpace, RasterType: g.RasterType, TimeStamp: g.TimeStamp, BandIdx: g.BandIdx, Polygon: g.Polygon, BBox: tileBBox, Height: tileYSize, Width: tileXSize, RawHeight: g.Height, RawWidth: g.Width, OffX: x, OffY: g.Height - y - tileYSize, CRS: g.CRS, SrcSRS: g.SrcSRS, SrcGeoTransform: g.SrcGeoTransform, DstGeoTransform: tileGeot, GeoLocation: g.GeoLocation}
+						tileGran := &GeoTileGranule{ConfigPayLoad: g.ConfigPayLoad, RawPath: g.RawPath, Path: g.Path, NameSpace: g.NameSpace, VarNameSpace: g.VarNameSpace, RasterType: g.RasterType, TimeStamp: g.TimeStamp, BandIdx: g.BandIdx, Polygon: g.Polygon, BBox: tileBBox, Height: tileYSize, Width: tileXSize, RawHeight: rawHeight, RawWidth: rawWidth, OffX: g.OffX + x, OffY: g.OffY + g.Height - y - tileYSize, CRS: g.CRS, SrcSRS: g.SrcSRS, SrcGeoTransform: g.SrcGeoTransform, DstGeoTransform: tileGeot, GeoLocation: g.GeoLocation, Packing: g.Packing, Units: g.Units}
 						grans = append(grans, tileGran)
 					}
 				}
@@ -226,7 +210,7 @@ func (gi *GeoRasterGRPC) Run(varList []string, verbose bool) {
 					} else {
 						geot = g0.DstGeoTransform
 					}
-					r, err := getRPCRaster(gi.Context, g, projWKT, geot, connPool[idx%len(connPool)])
+					r, err := getRPCRaster(gi.Context, g, projWKT, geot, gi.Clients, gi.MaxGrpcRecvMsgSize)
 					if err != nil {
 						gi.sendError(err)
 						r = &pb.Result{Raster: &pb.Raster{Data: make([]uint8, g.Width*g.Height), RasterType: "Byte", NoData: -1.}}
@@ -246,6 +230,11 @@ func (gi *GeoRasterGRPC) Run(varList []string, verbose bool) {
 						rawWidth = g.RawWidth
 					}
 					outRasters[idx] = &FlexRaster{ConfigPayLoad: g.ConfigPayLoad, Data: r.Raster.Data, Height: rawHeight, Width: rawWidth, DataHeight: rHeight, DataWidth: rWidth, OffX: rOffX, OffY: rOffY, Type: r.Raster.RasterType, NoData: r.Raster.NoData, NameSpace: g.NameSpace, TimeStamp: g.TimeStamp, Polygon: g.Polygon}
+					if g.Packing != nil && err == nil {
+						if err := unpackRaster(outRasters[idx], g.Packing); err != nil {
+							gi.sendError(err)
+						}
+					}
 				}(gran, iGran)
 			}
 			iGran++
@@ -326,22 +315,19 @@ func getDataSize(dataType string) (int, error) {
 		return 2, nil
 	case "Float32":
 		return 4, nil
-
-	// grpc workers convert any real data types other than the above to float32
-	case "Float64":
-		return 4, nil
 	case "Int32":
 		return 4, nil
 	case "UInt32":
 		return 4, nil
+	case "Float64":
+		return 8, nil
 	default:
 		return -1, fmt.Errorf("Unsupported raster type %s", dataType)
 	}
 }
 
-func getRPCRaster(ctx context.Context, g *GeoTileGranule, projWKT string, geot []float64, conn *grpc.ClientConn) (*pb.Result, error) {
-	c := pb.NewGDALClient(conn)
-	granule := &pb.GeoRPCGranule{Operation: "warp", Height: float32(g.Height), Width: float32(g.Width), Path: g.Path, DstSRS: projWKT, DstGeot: geot, Bands: []int32{int32(g.BandIdx)}}
+func getRPCRaster(ctx context.Context, g *GeoTileGranule, projWKT string, geot []float64, clients []string, maxGrpcRecvMsgSize int) (*pb.Result, error) {
+	granule := &pb.GeoRPCGranule{Operation: "warp", Height: float32(g.Height), Width: float32(g.Width), Path: g.Path, DstSRS: projWKT, DstGeot: geot, Bands: []int32{int32(g.BandIdx)}, RasterType: g.RasterType, Priority: g.Priority}
 	if g.GeoLocation != nil {
 		granule.GeoLocOpts = []string{
 			fmt.Sprintf("X_DATASET=%s", g.GeoLocation.XDSName),
@@ -374,7 +360,7 @@ func getRPCRaster(ctx context.Context, g *GeoTileGranule, projWKT string, geot [
 		granule.SRSCf = int32(g.SRSCf)
 	}
 
-	r, err := c.Process(ctx, granule)
+	r, err := utils.GetWorkerManager().ProcessStream(ctx, clients, granule, grpc.MaxCallRecvMsgSize(maxGrpcRecvMsgSize))
 	if err != nil {
 		return nil, err
 	}
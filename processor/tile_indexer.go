@@ -4,11 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math"
 	"math/rand"
-	"net/http"
 	"sort"
 	"strings"
 	"sync"
@@ -52,6 +50,10 @@ type GDALDataset struct {
 	Means        []float64      `json:"means"`
 	SampleCounts []int          `json:"sample_counts"`
 	NoData       float64        `json:"nodata"`
+	ScaleFactor  float64        `json:"scale_factor"`
+	AddOffset    float64        `json:"add_offset"`
+	Units        string         `json:"units"`
+	ValidRange   []float64      `json:"valid_range"`
 	Axes         []*DatasetAxis `json:"axes"`
 	GeoLocation  *GeoLocInfo    `json:"geo_loc"`
 	IsOutRange   bool
@@ -89,6 +91,45 @@ func BBox2WKT(bbox []float64) string {
 	return fmt.Sprintf("POLYGON ((%f %f, %f %f, %f %f, %f %f, %f %f))", bbox[0], bbox[1], bbox[2], bbox[1], bbox[2], bbox[3], bbox[0], bbox[3], bbox[0], bbox[1])
 }
 
+// splitAntimeridian splits a tile request that crosses the antimeridian
+// into requests of the parts of its bbox within the world. The granules
+// of the parts are warped onto their columns of the tile.
+func splitAntimeridian(geoReq *GeoTileRequest) []*GeoTileRequest {
+	parts := utils.SplitAntimeridian(geoReq.CRS, geoReq.BBox, geoReq.Width)
+	if len(parts) == 0 {
+		return []*GeoTileRequest{geoReq}
+	}
+
+	var reqs []*GeoTileRequest
+	for ip := range parts {
+		req := *geoReq
+		req.Part = &parts[ip]
+		reqs = append(reqs, &req)
+	}
+	return reqs
+}
+
+// queryBBox returns the bbox and CRS of the MAS query of a tile request.
+// Bboxes of projected CRSs that contain a pole are queried by their
+// extent in EPSG:4326 as their polygons can't be transformed, as are
+// bboxes of CRS aliases, which MAS can't resolve. Other bboxes are
+// queried as they are.
+func queryBBox(geoReq *GeoTileRequest) ([]float64, string) {
+	bbox := geoReq.BBox
+	if geoReq.Part != nil {
+		bbox = geoReq.Part.BBox
+	}
+
+	south, north := utils.BBoxContainsPoles(geoReq.CRS, bbox)
+	if south || north || (utils.IsCRSAlias(geoReq.CRS) && len(bbox) >= 4) {
+		llBBox, err := utils.TransformBBox(geoReq.CRS, "EPSG:4326", bbox)
+		if err == nil {
+			return llBBox, "EPSG:4326"
+		}
+	}
+	return bbox, geoReq.CRS
+}
+
 func (p *TileIndexer) Run(verbose bool) {
 	if verbose {
 		defer log.Printf("tile indexer done")
@@ -165,10 +206,11 @@ func (p *TileIndexer) Run(verbose bool) {
 				nameSpaces = ""
 			}
 			var bboxWkt string
+			bbox, queryCRS := queryBBox(geoReq)
 			if geoReq.MasQueryHint != "non_spatial" {
-				bboxWkt = BBox2WKT(geoReq.BBox)
+				bboxWkt = BBox2WKT(bbox)
 			}
-			url = p.getIndexerURL(geoReq, nameSpaces, bboxWkt, geoReq.CRS)
+			url = p.getIndexerURL(geoReq, nameSpaces, bboxWkt, queryCRS)
 			if isInit {
 				if geoReq.MetricsCollector != nil {
 					defer func() { geoReq.MetricsCollector.Info.Indexer.Duration += time.Since(t0) }()
@@ -203,7 +245,7 @@ func (p *TileIndexer) Run(verbose bool) {
 			var err error
 
 			if len(geoReq.SpatialExtent) >= 4 {
-				clippedBBox, err = utils.GetCanonicalBbox(geoReq.CRS, geoReq.BBox)
+				clippedBBox, err = utils.GetCanonicalBbox(queryCRS, bbox)
 				if err == nil {
 					clippedBBox[0] = math.Max(clippedBBox[0], geoReq.SpatialExtent[0])
 					clippedBBox[1] = math.Max(clippedBBox[1], geoReq.SpatialExtent[1])
@@ -216,7 +258,7 @@ func (p *TileIndexer) Run(verbose bool) {
 						if verbose {
 							log.Printf("Indexer error: invalid bbox: %v", clippedBBox)
 						}
-						return
+						continue
 					}
 					hasSubDivision = true
 				} else if verbose {
@@ -281,9 +323,9 @@ func (p *TileIndexer) Run(verbose bool) {
 
 				if maskCollection != geoReq.Collection || geoReq.Mask.ID != nameSpaces {
 					if geoReq.EndTime == nil {
-						url = strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&srs=%s&wkt=%s&namespace=%s&nseg=%d&limit=%d", p.APIAddress, maskCollection, geoReq.StartTime.Format(ISOFormat), geoReq.CRS, bboxWkt, geoReq.Mask.ID, geoReq.PolygonSegments, geoReq.QueryLimit), " ", "%20", -1)
+						url = strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&srs=%s&wkt=%s&namespace=%s&nseg=%d&limit=%d", p.APIAddress, maskCollection, geoReq.StartTime.Format(ISOFormat), queryCRS, bboxWkt, geoReq.Mask.ID, geoReq.PolygonSegments, geoReq.QueryLimit), " ", "%20", -1)
 					} else {
-						url = strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&until=%s&srs=%s&wkt=%s&namespace=%s&nseg=%d&limit=%d", p.APIAddress, maskCollection, geoReq.StartTime.Format(ISOFormat), geoReq.EndTime.Format(ISOFormat), geoReq.CRS, bboxWkt, geoReq.Mask.ID, geoReq.PolygonSegments, geoReq.QueryLimit), " ", "%20", -1)
+						url = strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&until=%s&srs=%s&wkt=%s&namespace=%s&nseg=%d&limit=%d", p.APIAddress, maskCollection, geoReq.StartTime.Format(ISOFormat), geoReq.EndTime.Format(ISOFormat), queryCRS, bboxWkt, geoReq.Mask.ID, geoReq.PolygonSegments, geoReq.QueryLimit), " ", "%20", -1)
 					}
 					if verbose {
 						log.Println(url)
@@ -315,20 +357,13 @@ func (p *TileIndexer) URLIndexGet(ctx context.Context, url string, geoReq *GeoTi
 		defer cLimiter.Decrease()
 	}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		p.sendError(fmt.Errorf("GET request to %s failed. Error: %v", url, err))
-		out <- &GeoTileGranule{ConfigPayLoad: ConfigPayLoad{NameSpaces: []string{utils.EmptyTileNS}, ScaleParams: geoReq.ScaleParams, Palette: geoReq.Palette}, Path: "NULL", NameSpace: utils.EmptyTileNS, RasterType: "Byte", TimeStamp: 0, BBox: geoReq.BBox, Height: geoReq.Height, Width: geoReq.Width, OffX: geoReq.OffX, OffY: geoReq.OffY, CRS: geoReq.CRS}
-		return
-	}
 	t0 := time.Now()
-	body, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
+	body, err := getIndexerResponse(url, nil, geoReq.Collection, geoReq.MetricsCollector)
 	if geoReq.MetricsCollector != nil {
 		geoReq.MetricsCollector.Info.Indexer.Duration += time.Since(t0)
 	}
 	if err != nil {
-		p.sendError(fmt.Errorf("Error parsing response body from %s. Error: %v", url, err))
+		p.sendError(fmt.Errorf("GET request to %s failed. Error: %v", url, err))
 		out <- &GeoTileGranule{ConfigPayLoad: ConfigPayLoad{NameSpaces: []string{utils.EmptyTileNS}, ScaleParams: geoReq.ScaleParams, Palette: geoReq.Palette}, Path: "NULL", NameSpace: utils.EmptyTileNS, RasterType: "Byte", TimeStamp: 0, BBox: geoReq.BBox, Height: geoReq.Height, Width: geoReq.Width, OffX: geoReq.OffX, OffY: geoReq.OffY, CRS: geoReq.CRS}
 		return
 	}
@@ -524,12 +559,22 @@ func (p *TileIndexer) URLIndexGet(ctx context.Context, url string, geoReq *GeoTi
 				}
 
 				if !isEmptyTile || (isEmptyTile && !bandFound) {
-					gran := &GeoTileGranule{ConfigPayLoad: geoReq.ConfigPayLoad, RawPath: ds.RawPath, Path: ds.DSName, NameSpace: namespace, VarNameSpace: ds.NameSpace, RasterType: ds.ArrayType, TimeStamp: float64(aggTimeStamp), BandIdx: bandIdx, Polygon: ds.Polygon, BBox: geoReq.BBox, Height: geoReq.Height, Width: geoReq.Width, CRS: geoReq.CRS, SrcSRS: ds.SRS, SrcGeoTransform: ds.GeoTransform, GeoLocation: ds.GeoLocation}
+					gran := &GeoTileGranule{ConfigPayLoad: geoReq.ConfigPayLoad, RawPath: ds.RawPath, Path: ds.DSName, NameSpace: namespace, VarNameSpace: ds.NameSpace, RasterType: ds.ArrayType, TimeStamp: float64(aggTimeStamp), BandIdx: bandIdx, Polygon: ds.Polygon, BBox: geoReq.BBox, Height: geoReq.Height, Width: geoReq.Width, CRS: geoReq.CRS, SrcSRS: ds.SRS, SrcGeoTransform: ds.GeoTransform, GeoLocation: ds.GeoLocation, Units: ds.Units}
+					if geoReq.Unpack {
+						gran.Packing = newUnpacking(ds)
+					}
 					if isEmptyTile {
 						gran.Path = "NULL"
 						gran.RasterType = "Byte"
 						gran.Height = 1
 						gran.Width = 1
+					} else if geoReq.Part != nil {
+						gran.BBox = geoReq.Part.BBox
+						gran.Width = geoReq.Part.Width
+						gran.OffX = geoReq.Part.OffX
+						gran.RawWidth = geoReq.Width
+						gran.RawHeight = geoReq.Height
+						gran.DstGeoTransform = BBox2Geot(gran.Width, gran.Height, gran.BBox)
 					}
 					granList = append(granList, gran)
 				}
@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nci/gsky/utils"
+)
+
+func TestAntimeridianSplitAndStitch(t *testing.T) {
+	var mutex sync.Mutex
+	var wkts []string
+	mas := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		wkts = append(wkts, r.FormValue("wkt"))
+		mutex.Unlock()
+		metadata := &MetadataResponse{GDALDatasets: []*GDALDataset{{
+			RawPath:    "/data/world.tif",
+			DSName:     "/data/world.tif",
+			NameSpace:  "band",
+			ArrayType:  "Byte",
+			TimeStamps: []time.Time{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+			Polygon:    "POLYGON ((-180 90,180 90,180 -90,-180 -90,-180 90))",
+		}}}
+		json.NewEncoder(w).Encode(metadata)
+	}))
+	defer mas.Close()
+
+	// A 4x2 tile from 170 to 190 is split at its third column
+	startTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	geoReq := &GeoTileRequest{ConfigPayLoad: ConfigPayLoad{NameSpaces: []string{"band"}},
+		Collection: "/data",
+		CRS:        "EPSG:4326",
+		BBox:       utils.UnwrapAntimeridian("EPSG:4326", []float64{170, -10, -170, 10}),
+		Height:     2,
+		Width:      4,
+		StartTime:  &startTime,
+	}
+
+	tp := InitTilePipeline(context.Background(), strings.TrimPrefix(mas.URL, "http://"), nil, 0, 0, 0, make(chan error, 100))
+	granules, err := tp.GetFileList(geoReq, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(granules) != 2 {
+		t.Fatalf("expected a granule of each part, got %d", len(granules))
+	}
+	sort.Slice(granules, func(i, j int) bool { return granules[i].OffX < granules[j].OffX })
+	if granules[0].OffX != 0 || granules[0].Width != 2 || !reflect.DeepEqual(granules[0].BBox, []float64{170, -10, 180, 10}) {
+		t.Errorf("unexpected west granule: %+v", granules[0])
+	}
+	if granules[1].OffX != 2 || granules[1].Width != 2 || !reflect.DeepEqual(granules[1].BBox, []float64{-180, -10, -170, 10}) {
+		t.Errorf("unexpected east granule: %+v", granules[1])
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(wkts) != 2 || !strings.Contains(wkts[0]+wkts[1], "-180") {
+		t.Errorf("expected MAS to be queried for each part, got %v", wkts)
+	}
+
+	// The rasters of the parts are stitched into the columns of the tile
+	bandExpr, err := utils.ParseBandExpressions([]string{"band"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	merger := NewRasterMerger(context.Background(), make(chan error, 10))
+	var rasters []*FlexRaster
+	for i, g := range granules {
+		data := make([]uint8, g.Width*g.Height)
+		for j := range data {
+			data[j] = uint8(i + 1)
+		}
+		rasters = append(rasters, &FlexRaster{ConfigPayLoad: g.ConfigPayLoad, Data: data,
+			Height: g.RawHeight, Width: g.RawWidth, DataHeight: g.Height, DataWidth: g.Width, OffX: g.OffX, OffY: g.OffY,
+			Type: "Byte", NoData: 0, NameSpace: g.NameSpace, TimeStamp: g.TimeStamp, Polygon: g.Polygon})
+	}
+	merger.In <- rasters
+	close(merger.In)
+	go merger.Run(bandExpr, false)
+
+	out := <-merger.Out
+	if len(out) != 1 {
+		t.Fatalf("expected 1 raster, got %d", len(out))
+	}
+	tile, ok := out[0].(*utils.ByteRaster)
+	if !ok {
+		t.Fatalf("expected a byte raster, got %T", out[0])
+	}
+	expected := []uint8{1, 1, 2, 2, 1, 1, 2, 2}
+	if tile.Width != 4 || tile.Height != 2 || !reflect.DeepEqual(tile.Data, expected) {
+		t.Errorf("expected a 4x2 tile of %v, got %dx%d of %v", expected, tile.Width, tile.Height, tile.Data)
+	}
+}
@@ -18,6 +18,9 @@ import (
 const SizeofUint16 = 2
 const SizeofInt16 = 2
 const SizeofFloat32 = 4
+const SizeofInt32 = 4
+const SizeofUint32 = 4
+const SizeofFloat64 = 8
 
 type RasterMerger struct {
 	Context context.Context
@@ -192,6 +195,120 @@ func MergeMaskedRaster(r *FlexRaster, canvasMap map[string]*FlexRaster, mask []b
 		data := *(*[]float32)(unsafe.Pointer(&header))
 		nodata := float32(r.NoData)
 
+		if r.TimeStamp < canvasMap[r.NameSpace].TimeStamp {
+			iSrc := 0
+			for ir := 0; ir < r.DataHeight; ir++ {
+				for ic := 0; ic < r.DataWidth; ic++ {
+					val := data[iSrc]
+					iDst := (ir+r.OffY)*r.Width + ic + r.OffX
+					if val != nodata && !mask[iSrc] && canvas[iDst] == nodata {
+						canvas[iDst] = val
+					}
+					iSrc++
+				}
+			}
+		} else {
+			iSrc := 0
+			for ir := 0; ir < r.DataHeight; ir++ {
+				for ic := 0; ic < r.DataWidth; ic++ {
+					val := data[iSrc]
+					if val != nodata && !mask[iSrc] {
+						iDst := (ir+r.OffY)*r.Width + ic + r.OffX
+						canvas[iDst] = val
+					}
+					iSrc++
+				}
+			}
+			canvasMap[r.NameSpace].TimeStamp = r.TimeStamp
+		}
+	case "Int32":
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&canvasMap[r.NameSpace].Data))
+		headr.Len /= SizeofInt32
+		headr.Cap /= SizeofInt32
+		canvas := *(*[]int32)(unsafe.Pointer(&headr))
+
+		header := *(*reflect.SliceHeader)(unsafe.Pointer(&r.Data))
+		header.Len /= SizeofInt32
+		header.Cap /= SizeofInt32
+		data := *(*[]int32)(unsafe.Pointer(&header))
+		nodata := int32(r.NoData)
+
+		if r.TimeStamp < canvasMap[r.NameSpace].TimeStamp {
+			iSrc := 0
+			for ir := 0; ir < r.DataHeight; ir++ {
+				for ic := 0; ic < r.DataWidth; ic++ {
+					val := data[iSrc]
+					iDst := (ir+r.OffY)*r.Width + ic + r.OffX
+					if val != nodata && !mask[iSrc] && canvas[iDst] == nodata {
+						canvas[iDst] = val
+					}
+					iSrc++
+				}
+			}
+		} else {
+			iSrc := 0
+			for ir := 0; ir < r.DataHeight; ir++ {
+				for ic := 0; ic < r.DataWidth; ic++ {
+					val := data[iSrc]
+					if val != nodata && !mask[iSrc] {
+						iDst := (ir+r.OffY)*r.Width + ic + r.OffX
+						canvas[iDst] = val
+					}
+					iSrc++
+				}
+			}
+			canvasMap[r.NameSpace].TimeStamp = r.TimeStamp
+		}
+	case "UInt32":
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&canvasMap[r.NameSpace].Data))
+		headr.Len /= SizeofUint32
+		headr.Cap /= SizeofUint32
+		canvas := *(*[]uint32)(unsafe.Pointer(&headr))
+
+		header := *(*reflect.SliceHeader)(unsafe.Pointer(&r.Data))
+		header.Len /= SizeofUint32
+		header.Cap /= SizeofUint32
+		data := *(*[]uint32)(unsafe.Pointer(&header))
+		nodata := uint32(r.NoData)
+
+		if r.TimeStamp < canvasMap[r.NameSpace].TimeStamp {
+			iSrc := 0
+			for ir := 0; ir < r.DataHeight; ir++ {
+				for ic := 0; ic < r.DataWidth; ic++ {
+					val := data[iSrc]
+					iDst := (ir+r.OffY)*r.Width + ic + r.OffX
+					if val != nodata && !mask[iSrc] && canvas[iDst] == nodata {
+						canvas[iDst] = val
+					}
+					iSrc++
+				}
+			}
+		} else {
+			iSrc := 0
+			for ir := 0; ir < r.DataHeight; ir++ {
+				for ic := 0; ic < r.DataWidth; ic++ {
+					val := data[iSrc]
+					if val != nodata && !mask[iSrc] {
+						iDst := (ir+r.OffY)*r.Width + ic + r.OffX
+						canvas[iDst] = val
+					}
+					iSrc++
+				}
+			}
+			canvasMap[r.NameSpace].TimeStamp = r.TimeStamp
+		}
+	case "Float64":
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&canvasMap[r.NameSpace].Data))
+		headr.Len /= SizeofFloat64
+		headr.Cap /= SizeofFloat64
+		canvas := *(*[]float64)(unsafe.Pointer(&headr))
+
+		header := *(*reflect.SliceHeader)(unsafe.Pointer(&r.Data))
+		header.Len /= SizeofFloat64
+		header.Cap /= SizeofFloat64
+		data := *(*[]float64)(unsafe.Pointer(&header))
+		nodata := float64(r.NoData)
+
 		if r.TimeStamp < canvasMap[r.NameSpace].TimeStamp {
 			iSrc := 0
 			for ir := 0; ir < r.DataHeight; ir++ {
@@ -272,6 +389,36 @@ func initNoDataSlice(rType string, noDataValue float64, size int) []uint8 {
 		headr.Len *= SizeofFloat32
 		headr.Cap *= SizeofFloat32
 		return *(*[]uint8)(unsafe.Pointer(&headr))
+	case "Int32":
+		out := make([]int32, size)
+		fill := int32(noDataValue)
+		for i := 0; i < size; i++ {
+			out[i] = fill
+		}
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
+		headr.Len *= SizeofInt32
+		headr.Cap *= SizeofInt32
+		return *(*[]uint8)(unsafe.Pointer(&headr))
+	case "UInt32":
+		out := make([]uint32, size)
+		fill := uint32(noDataValue)
+		for i := 0; i < size; i++ {
+			out[i] = fill
+		}
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
+		headr.Len *= SizeofUint32
+		headr.Cap *= SizeofUint32
+		return *(*[]uint8)(unsafe.Pointer(&headr))
+	case "Float64":
+		out := make([]float64, size)
+		fill := float64(noDataValue)
+		for i := 0; i < size; i++ {
+			out[i] = fill
+		}
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
+		headr.Len *= SizeofFloat64
+		headr.Cap *= SizeofFloat64
+		return *(*[]uint8)(unsafe.Pointer(&headr))
 	default:
 		return []uint8{}
 	}
@@ -437,6 +584,32 @@ func ComputeMask(mask *utils.Mask, data []byte, rType string) (out []bool, err e
 				}
 			}
 		}
+	case "Int32":
+		header.Len /= SizeofInt32
+		header.Cap /= SizeofInt32
+		data := *(*[]int32)(unsafe.Pointer(&header))
+		var maskValue uint32
+		var bitTests []uint32
+		if maskValue, bitTests, err = parseMaskBits32(mask); err != nil {
+			return
+		}
+		out = make([]bool, len(data))
+		for i, val := range data {
+			out[i] = matchMaskBits32(uint32(val), maskValue, bitTests)
+		}
+	case "UInt32":
+		header.Len /= SizeofUint32
+		header.Cap /= SizeofUint32
+		data := *(*[]uint32)(unsafe.Pointer(&header))
+		var maskValue uint32
+		var bitTests []uint32
+		if maskValue, bitTests, err = parseMaskBits32(mask); err != nil {
+			return
+		}
+		out = make([]bool, len(data))
+		for i, val := range data {
+			out[i] = matchMaskBits32(val, maskValue, bitTests)
+		}
 	default:
 		err = fmt.Errorf("Type %s cannot contain a bit mask", rType)
 
@@ -444,6 +617,47 @@ func ComputeMask(mask *utils.Mask, data []byte, rType string) (out []bool, err e
 	return
 }
 
+// parseMaskBits32 parses the binary mask value or the pairs of
+// filters and values of the bit tests of a mask of 32 bit rasters
+func parseMaskBits32(mask *utils.Mask) (uint32, []uint32, error) {
+	parse := func(bits string) (uint32, error) {
+		value, err := strconv.ParseUint(bits, 2, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid 32 bit mask '%s': %v", bits, err)
+		}
+		return uint32(value), nil
+	}
+
+	if len(mask.Value) > 0 {
+		value, err := parse(mask.Value)
+		return value, nil, err
+	}
+
+	bitTests := make([]uint32, len(mask.BitTests))
+	for i, bits := range mask.BitTests {
+		value, err := parse(bits)
+		if err != nil {
+			return 0, nil, err
+		}
+		bitTests[i] = value
+	}
+	return 0, bitTests, nil
+}
+
+// matchMaskBits32 reports whether a value has any bit of maskValue, or
+// else matches a pair of filter and value of the bit tests
+func matchMaskBits32(val uint32, maskValue uint32, bitTests []uint32) bool {
+	if bitTests == nil {
+		return (val & maskValue) > 0
+	}
+	for j := 0; j < len(bitTests); j += 2 {
+		if (val & bitTests[j]) == bitTests[j+1] {
+			return true
+		}
+	}
+	return false
+}
+
 func (enc *RasterMerger) Run(bandExpr *utils.BandExpressions, verbose bool) {
 	if verbose {
 		defer log.Printf("tile merger done")
@@ -559,8 +773,27 @@ func (enc *RasterMerger) Run(bandExpr *utils.BandExpressions, verbose bool) {
 		nOut = len(bandExpr.Expressions) * nAxis
 	}
 
+	// Bands of 32 bit integers or float64 lose precision in float32,
+	// so their expressions are evaluated in float64 if they can be
+	useFloat64 := false
+	if hasExpr && len(bandExpr.Float64Exprs) == len(bandExpr.Expressions) {
+		for _, ns := range nameSpaces {
+			if canvas, found := canvasMap[ns]; found && isWideRasterType(canvas.Type) {
+				useFloat64 = true
+				break
+			}
+		}
+		for _, expr64 := range bandExpr.Float64Exprs {
+			if expr64 == nil {
+				useFloat64 = false
+				break
+			}
+		}
+	}
+
 	out := make([]utils.Raster, nOut)
 	bandVars := make([]*utils.Float32Raster, len(nameSpaces))
+	bandVars64 := make([]*utils.Float64Raster, len(nameSpaces))
 
 	for i, ns := range nameSpaces {
 		canvas, found := canvasMap[ns]
@@ -572,6 +805,21 @@ func (enc *RasterMerger) Run(bandExpr *utils.BandExpressions, verbose bool) {
 			enc.sendError(fmt.Errorf("unknown namespace: %v, valid namespaces: %v", ns, knownNs))
 			return
 		}
+
+		if useFloat64 {
+			data, err := canvasFloat64Values(canvas)
+			if err != nil {
+				enc.sendError(err)
+				return
+			}
+			bandVars64[i] = &utils.Float64Raster{NoData: canvas.NoData, Data: data}
+			continue
+		}
+
+		// Expressions of Int32, UInt32 and Float64 bands only get here
+		// as the float32 fallback for Float64Exprs that failed to
+		// compile, so their nodata is mapped to its float32 value
+		// before conversion
 		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&canvas.Data))
 		switch canvas.Type {
 		case "SignedByte":
@@ -645,13 +893,119 @@ func (enc *RasterMerger) Run(bandExpr *utils.BandExpressions, verbose bool) {
 				bandVars[i] = &utils.Float32Raster{NoData: float64(canvas.NoData), Data: varData}
 			}
 
+		case "Int32":
+			headr.Len /= SizeofInt32
+			headr.Cap /= SizeofInt32
+			data := *(*[]int32)(unsafe.Pointer(&headr))
+			if !hasExpr {
+				out[i] = &utils.Int32Raster{NoData: canvas.NoData, Data: data,
+					Width: canvas.Width, Height: canvas.Height, NameSpace: ns}
+			} else {
+				noData := int32(canvas.NoData)
+				varNoData := float32(canvas.NoData)
+				varData := make([]float32, len(data))
+				for i, val := range data {
+					if val == noData {
+						varData[i] = varNoData
+					} else {
+						varData[i] = float32(val)
+					}
+				}
+				bandVars[i] = &utils.Float32Raster{NoData: float64(varNoData), Data: varData}
+			}
+
+		case "UInt32":
+			headr.Len /= SizeofUint32
+			headr.Cap /= SizeofUint32
+			data := *(*[]uint32)(unsafe.Pointer(&headr))
+			if !hasExpr {
+				out[i] = &utils.UInt32Raster{NoData: canvas.NoData, Data: data,
+					Width: canvas.Width, Height: canvas.Height, NameSpace: ns}
+			} else {
+				noData := uint32(canvas.NoData)
+				varNoData := float32(canvas.NoData)
+				varData := make([]float32, len(data))
+				for i, val := range data {
+					if val == noData {
+						varData[i] = varNoData
+					} else {
+						varData[i] = float32(val)
+					}
+				}
+				bandVars[i] = &utils.Float32Raster{NoData: float64(varNoData), Data: varData}
+			}
+
+		case "Float64":
+			headr.Len /= SizeofFloat64
+			headr.Cap /= SizeofFloat64
+			data := *(*[]float64)(unsafe.Pointer(&headr))
+			if !hasExpr {
+				out[i] = &utils.Float64Raster{NoData: canvas.NoData, Data: data,
+					Width: canvas.Width, Height: canvas.Height, NameSpace: ns}
+			} else {
+				noData := float64(canvas.NoData)
+				varNoData := float32(canvas.NoData)
+				varData := make([]float32, len(data))
+				for i, val := range data {
+					if val == noData {
+						varData[i] = varNoData
+					} else {
+						varData[i] = float32(val)
+					}
+				}
+				bandVars[i] = &utils.Float32Raster{NoData: float64(varNoData), Data: varData}
+			}
+
 		default:
 			enc.sendError(fmt.Errorf("raster type %s not recognised", canvas.Type))
 			return
 		}
 	}
 
-	if hasExpr {
+	if useFloat64 {
+		width := canvasMap[nameSpaces[0]].Width
+		height := canvasMap[nameSpaces[0]].Height
+		noData := bandVars64[0].NoData
+		nPixels := width * height
+
+		iOut := 0
+		for iv := range bandExpr.Expressions {
+			for _, axisNs := range axisList {
+				hasData := make([]bool, nPixels)
+				for i := range hasData {
+					hasData[i] = true
+				}
+
+				parameters := make(map[string][]float64)
+				for _, v := range axisNsLookup[axisNs] {
+					parameters[v.Name] = bandVars64[v.Idx].Data
+					for j, val := range bandVars64[v.Idx].Data {
+						if val == bandVars64[v.Idx].NoData {
+							hasData[j] = false
+						}
+					}
+				}
+
+				result, err := bandExpr.Float64Exprs[iv].Evaluate(parameters, nPixels)
+				if err != nil {
+					enc.sendError(fmt.Errorf("bandExpr '%v' error: %v", bandExpr.ExprText[iv], err))
+					return
+				}
+				for i, val := range result {
+					if !hasData[i] || math.IsInf(val, 0) || math.IsNaN(val) {
+						result[i] = noData
+					}
+				}
+
+				outNameSpace := bandExpr.ExprNames[iv]
+				if axisNs != "singular" {
+					outNameSpace += "#" + axisNs
+				}
+				out[iOut] = &utils.Float64Raster{NoData: noData, Data: result, Width: width, Height: height, NameSpace: outNameSpace}
+				iOut++
+			}
+		}
+	} else if hasExpr {
 		width := canvasMap[nameSpaces[0]].Width
 		height := canvasMap[nameSpaces[0]].Height
 		noData := bandVars[0].NoData
@@ -737,6 +1091,101 @@ func (enc *RasterMerger) Run(bandExpr *utils.BandExpressions, verbose bool) {
 	enc.Out <- out
 }
 
+// isWideRasterType reports whether values of a raster type do not all
+// fit in a float32
+func isWideRasterType(rType string) bool {
+	switch rType {
+	case "Int32", "UInt32", "Float64":
+		return true
+	default:
+		return false
+	}
+}
+
+// canvasFloat64Values converts the values of a canvas to float64.
+// Nodata is converted to the float64 nodata of the canvas.
+func canvasFloat64Values(canvas *FlexRaster) ([]float64, error) {
+	headr := *(*reflect.SliceHeader)(unsafe.Pointer(&canvas.Data))
+	var values []float64
+	switch canvas.Type {
+	case "SignedByte":
+		data := *(*[]int8)(unsafe.Pointer(&headr))
+		values = make([]float64, len(data))
+		for i, val := range data {
+			values[i] = float64(val)
+		}
+	case "Byte":
+		values = make([]float64, len(canvas.Data))
+		for i, val := range canvas.Data {
+			values[i] = float64(val)
+		}
+	case "UInt16":
+		headr.Len /= SizeofUint16
+		headr.Cap /= SizeofUint16
+		data := *(*[]uint16)(unsafe.Pointer(&headr))
+		values = make([]float64, len(data))
+		for i, val := range data {
+			values[i] = float64(val)
+		}
+	case "Int16":
+		headr.Len /= SizeofInt16
+		headr.Cap /= SizeofInt16
+		data := *(*[]int16)(unsafe.Pointer(&headr))
+		values = make([]float64, len(data))
+		for i, val := range data {
+			values[i] = float64(val)
+		}
+	case "Float32":
+		headr.Len /= SizeofFloat32
+		headr.Cap /= SizeofFloat32
+		data := *(*[]float32)(unsafe.Pointer(&headr))
+		noData := float32(canvas.NoData)
+		values = make([]float64, len(data))
+		for i, val := range data {
+			if val == noData {
+				values[i] = canvas.NoData
+			} else {
+				values[i] = float64(val)
+			}
+		}
+	case "Int32":
+		headr.Len /= SizeofInt32
+		headr.Cap /= SizeofInt32
+		data := *(*[]int32)(unsafe.Pointer(&headr))
+		noData := int32(canvas.NoData)
+		values = make([]float64, len(data))
+		for i, val := range data {
+			if val == noData {
+				values[i] = canvas.NoData
+			} else {
+				values[i] = float64(val)
+			}
+		}
+	case "UInt32":
+		headr.Len /= SizeofUint32
+		headr.Cap /= SizeofUint32
+		data := *(*[]uint32)(unsafe.Pointer(&headr))
+		noData := uint32(canvas.NoData)
+		values = make([]float64, len(data))
+		for i, val := range data {
+			if val == noData {
+				values[i] = canvas.NoData
+			} else {
+				values[i] = float64(val)
+			}
+		}
+	case "Float64":
+		headr.Len /= SizeofFloat64
+		headr.Cap /= SizeofFloat64
+		data := *(*[]float64)(unsafe.Pointer(&headr))
+		values = make([]float64, len(data))
+		copy(values, data)
+	default:
+		return nil, fmt.Errorf("raster type %s not recognised", canvas.Type)
+	}
+	return values, nil
+}
+
 func (enc *RasterMerger) sendError(err error) {
 	select {
 	case enc.Error <- err:
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/nci/gsky/utils"
+)
+
+func int32Bytes(values []int32) []byte {
+	header := *(*reflect.SliceHeader)(unsafe.Pointer(&values))
+	header.Len *= 4
+	header.Cap *= 4
+	return *(*[]byte)(unsafe.Pointer(&header))
+}
+
+func TestRasterMergerInt32Expression(t *testing.T) {
+	bandExpr, err := utils.ParseBandExpressions([]string{"b = a + 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merger := NewRasterMerger(context.Background(), make(chan error, 10))
+	merger.In <- []*FlexRaster{{
+		ConfigPayLoad: ConfigPayLoad{NameSpaces: []string{"a"}},
+		Data:          int32Bytes([]int32{16777217, -16777217, -9999}),
+		DataHeight:    1, DataWidth: 3, Height: 1, Width: 3,
+		Type: "Int32", NoData: -9999, NameSpace: "a",
+	}}
+	close(merger.In)
+	go merger.Run(bandExpr, false)
+
+	out := <-merger.Out
+	if len(out) != 1 {
+		t.Fatalf("expected 1 raster, got %d", len(out))
+	}
+	raster, ok := out[0].(*utils.Float64Raster)
+	if !ok {
+		t.Fatalf("expected a float64 raster, got %T", out[0])
+	}
+	expected := []float64{16777218, -16777216, -9999}
+	if raster.NameSpace != "b" || !reflect.DeepEqual(raster.Data, expected) {
+		t.Errorf("expected %s %v, got %s %v", "b", expected, raster.NameSpace, raster.Data)
+	}
+}
+
+func TestComputeMaskInvalid32Bit(t *testing.T) {
+	data := int32Bytes([]int32{1, 2})
+	for _, mask := range []*utils.Mask{
+		{Value: "102"},
+		{Value: strings.Repeat("1", 33)},
+		{BitTests: []string{"1", "2x"}},
+	} {
+		if _, err := ComputeMask(mask, data, "Int32"); err == nil {
+			t.Errorf("%+v: expected an error", mask)
+		}
+	}
+
+	out, err := ComputeMask(&utils.Mask{Value: "10"}, data, "UInt32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, []bool{false, true}) {
+		t.Errorf("unexpected mask %v", out)
+	}
+}
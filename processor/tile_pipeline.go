@@ -86,6 +86,17 @@ func (dp *TilePipeline) Process(geoReq *GeoTileRequest, verbose bool) chan []uti
 
 	go m.Run(geoReq.BandExpr, verbose)
 
+	if dp.CurrentLayer != nil && dp.CurrentLayer.Anomaly != nil {
+		rasters, err := dp.processAnomaly(geoReq, verbose)
+		if err != nil {
+			dp.sendError(err)
+		} else if len(rasters) > 0 {
+			m.In <- rasters
+		}
+		close(m.In)
+		return m.Out
+	}
+
 	varList := geoReq.BandExpr.VarList
 	if dp.CurrentLayer != nil && len(dp.CurrentLayer.InputLayers) > 0 {
 		otherVars, hasFusedBand, supportTimeWeighted, err := dp.checkFusedBandNames(geoReq)
@@ -95,6 +106,23 @@ func (dp *TilePipeline) Process(geoReq *GeoTileRequest, verbose bool) chan []uti
 			return m.Out
 		}
 
+		inputVars, otherVars, err := dp.checkInputVarNames(otherVars)
+		if err != nil {
+			dp.sendError(err)
+			close(m.In)
+			return m.Out
+		}
+
+		if len(inputVars) > 0 {
+			rasters, err := dp.processInputVars(geoReq, inputVars, verbose)
+			if err != nil {
+				dp.sendError(err)
+				close(m.In)
+				return m.Out
+			}
+			m.In <- rasters
+		}
+
 		if hasFusedBand {
 			var aggTime time.Duration
 			if geoReq.StartTime != nil && geoReq.EndTime != nil {
@@ -152,7 +180,9 @@ func (dp *TilePipeline) Process(geoReq *GeoTileRequest, verbose bool) chan []uti
 
 				m.In <- rasters
 			}
+		}
 
+		if hasFusedBand || len(inputVars) > 0 {
 			if len(otherVars) == 0 {
 				close(m.In)
 				return m.Out
@@ -162,7 +192,9 @@ func (dp *TilePipeline) Process(geoReq *GeoTileRequest, verbose bool) chan []uti
 	}
 
 	go func() {
-		i.In <- geoReq
+		for _, req := range splitAntimeridian(geoReq) {
+			i.In <- req
+		}
 		close(i.In)
 	}()
 
@@ -176,14 +208,44 @@ func (dp *TilePipeline) GetFileList(geoReq *GeoTileRequest, verbose bool) ([]*Ge
 	var totalGrans []*GeoTileGranule
 	i := NewTileIndexer(dp.Context, dp.MASAddress, dp.Error)
 
+	if dp.CurrentLayer != nil && dp.CurrentLayer.Anomaly != nil {
+		return dp.getDepFileList(geoReq, []int{0}, verbose)
+	}
+
 	if dp.CurrentLayer != nil && len(dp.CurrentLayer.InputLayers) > 0 {
 		otherVars, hasFusedBand, _, err := dp.checkFusedBandNames(geoReq)
 		if err != nil {
 			return nil, err
 		}
 
+		inputVars, otherVars, err := dp.checkInputVarNames(otherVars)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(inputVars) > 0 {
+			// The dates of a layer with aliased input layers are those of
+			// the first referenced input layer
+			primary := inputVars[0].layer
+			for _, v := range inputVars {
+				if v.layer < primary {
+					primary = v.layer
+				}
+			}
+
+			grans, err := dp.getDepFileList(geoReq, []int{primary}, verbose)
+			if err != nil {
+				return nil, err
+			}
+			totalGrans = append(totalGrans, grans...)
+
+			if !hasFusedBand && len(otherVars) == 0 {
+				return totalGrans, nil
+			}
+		}
+
 		if hasFusedBand {
-			grans, err := dp.getDepFileList(geoReq, verbose)
+			grans, err := dp.getDepFileList(geoReq, nil, verbose)
 
 			if err != nil {
 				return nil, err
@@ -200,7 +262,9 @@ func (dp *TilePipeline) GetFileList(geoReq *GeoTileRequest, verbose bool) ([]*Ge
 	}
 
 	go func() {
-		i.In <- geoReq
+		for _, req := range splitAntimeridian(geoReq) {
+			i.In <- req
+		}
 		close(i.In)
 	}()
 
@@ -374,7 +438,132 @@ func (dp *TilePipeline) processDeps(geoReq *GeoTileRequest, verbose bool) ([]*Fl
 
 }
 
-func (dp *TilePipeline) getDepFileList(geoReq *GeoTileRequest, verbose bool) ([]*GeoTileGranule, error) {
+// processInputVars renders the input layers referenced by their aliases
+// in the band expressions of the current layer. Each input layer is
+// processed by its own tile pipeline with its own MAS address, dates and
+// overviews on the grid of the request, and its bands are returned under
+// the names of the variables that reference them.
+func (dp *TilePipeline) processInputVars(geoReq *GeoTileRequest, inputVars []*inputVar, verbose bool) ([]*FlexRaster, error) {
+	var rasters []*FlexRaster
+
+	depLayers, err := dp.findDepLayers()
+	if err != nil {
+		return nil, err
+	}
+	dp.prepareInputGeoRequests(geoReq, depLayers, true)
+
+	var timestamp time.Time
+	if geoReq.StartTime != nil {
+		timestamp = *geoReq.StartTime
+	} else {
+		timestamp = time.Now().UTC()
+	}
+
+	var layerIdx []int
+	layerVars := make(map[int][]*inputVar)
+	for _, v := range inputVars {
+		if _, found := layerVars[v.layer]; !found {
+			layerIdx = append(layerIdx, v.layer)
+		}
+		layerVars[v.layer] = append(layerVars[v.layer], v)
+	}
+
+	for _, idx := range layerIdx {
+		reqCtx := depLayers[idx]
+		req := reqCtx.GeoReq
+		alias := dp.CurrentLayer.InputLayers[idx].Alias
+
+		var res []utils.Raster
+		if resolveInputTime(reqCtx.Layer, req) {
+			res, err = dp.runInputPipeline(reqCtx, req, verbose)
+			if err != nil {
+				return nil, err
+			}
+		} else if verbose {
+			log.Printf("input pipeline '%v' (alias %v): no dates at or before %v", reqCtx.Layer.Name, alias, geoReq.StartTime)
+		}
+
+		for _, v := range layerVars[idx] {
+			var flex *FlexRaster
+			if v.band < len(res) {
+				flex, _ = getFlexRaster(v.band, timestamp, geoReq, res[v.band], nil)
+			}
+			if flex == nil {
+				emptyRaster := &utils.ByteRaster{Data: make([]uint8, geoReq.Height*geoReq.Width), NoData: 0, Height: geoReq.Height, Width: geoReq.Width, NameSpace: utils.EmptyTileNS + "_dummy"}
+				flex, _ = getFlexRaster(v.band, timestamp, geoReq, emptyRaster, nil)
+			}
+			flex.NameSpace = v.name
+			rasters = append(rasters, flex)
+		}
+
+		if verbose {
+			log.Printf("input pipeline '%v' (alias %v) done", reqCtx.Layer.Name, alias)
+		}
+	}
+
+	return rasters, nil
+}
+
+// resolveInputTime sets the time range of the request of an aliased input
+// layer to its latest date at or before the requested time, accumulated
+// over a time step if the input layer is accumulated. It returns false
+// if the input layer has no such date.
+func resolveInputTime(layer *utils.Layer, req *GeoTileRequest) bool {
+	if req.StartTime == nil || len(layer.Dates) == 0 {
+		return true
+	}
+
+	var startTime *time.Time
+	for _, dt := range layer.Dates {
+		t, err := time.Parse(utils.ISOFormat, dt)
+		if err != nil || t.After(*req.StartTime) {
+			continue
+		}
+		if startTime == nil || t.After(*startTime) {
+			st := t
+			startTime = &st
+		}
+	}
+
+	if startTime == nil {
+		return false
+	}
+
+	setInputTime(layer, req, *startTime)
+	return true
+}
+
+// setInputTime sets the time range of the request of an input layer to
+// startTime, accumulated over a time step if the input layer is
+// accumulated
+func setInputTime(layer *utils.Layer, req *GeoTileRequest, startTime time.Time) {
+	req.StartTime = &startTime
+	req.EndTime = nil
+	if layer.Accum {
+		step := time.Minute * time.Duration(60*24*layer.StepDays+60*layer.StepHours+layer.StepMinutes)
+		endTime := startTime.Add(step)
+		req.EndTime = &endTime
+	}
+}
+
+// runInputPipeline renders an input layer by its own tile pipeline
+func (dp *TilePipeline) runInputPipeline(reqCtx *GeoReqContext, req *GeoTileRequest, verbose bool) ([]utils.Raster, error) {
+	errChan := make(chan error, 100)
+	tp := InitTilePipeline(dp.Context, reqCtx.MASAddress, reqCtx.Service.WorkerNodes, reqCtx.Layer.MaxGrpcRecvMsgSize, reqCtx.Layer.WmsPolygonShardConcLimit, reqCtx.Service.MaxGrpcBufferSize, errChan)
+	tp.CurrentLayer = reqCtx.StyleLayer
+	tp.DataSources = dp.DataSources
+
+	select {
+	case res := <-tp.Process(req, verbose):
+		return res, nil
+	case err := <-errChan:
+		return nil, fmt.Errorf("Error in the input pipeline '%v': %v", reqCtx.Layer.Name, err)
+	case <-tp.Context.Done():
+		return nil, fmt.Errorf("Context cancelled in input pipeline '%v'", reqCtx.Layer.Name)
+	}
+}
+
+func (dp *TilePipeline) getDepFileList(geoReq *GeoTileRequest, layerIdx []int, verbose bool) ([]*GeoTileGranule, error) {
 	errChan := make(chan error, 100)
 	var totalGrans []*GeoTileGranule
 
@@ -382,6 +571,13 @@ func (dp *TilePipeline) getDepFileList(geoReq *GeoTileRequest, verbose bool) ([]
 	if err != nil {
 		return nil, err
 	}
+	if len(layerIdx) > 0 {
+		var layers []*GeoReqContext
+		for _, idx := range layerIdx {
+			layers = append(layers, depLayers[idx])
+		}
+		depLayers = layers
+	}
 	dp.prepareInputGeoRequests(geoReq, depLayers, false)
 
 	type LayerGrans struct {
@@ -526,6 +722,8 @@ func (dp *TilePipeline) prepareInputGeoRequests(geoReq *GeoTileRequest, depLayer
 			IndexTileYSize:      layer.IndexTileYSize,
 			SpatialExtent:       layer.SpatialExtent,
 			IndexResLimit:       layer.IndexResLimit,
+			Unpack:              layer.Unpack,
+			Priority:            geoReq.Priority,
 			MetricsCollector:    geoReq.MetricsCollector,
 		},
 			Collection: styleLayer.DataSource,
@@ -699,6 +897,90 @@ func getFlexRaster(idx int, timestamp time.Time, req *GeoTileRequest, raster uti
 			}
 		}
 
+	case *utils.Int32Raster:
+		flex.Type = "Int32"
+		flex.NoData = t.NoData
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&t.Data))
+		headr.Len *= SizeofInt32
+		headr.Cap *= SizeofInt32
+		flex.Data = *(*[]uint8)(unsafe.Pointer(&headr))
+		noData := int32(t.NoData)
+		if normRaster != nil {
+			if r, ok := normRaster.(*utils.Int32Raster); ok {
+				normNoData = r.NoData
+				if int32(normNoData) != noData {
+					normalise = true
+					flex.NoData = normNoData
+				}
+			}
+		}
+		for i := range t.Data {
+			if t.Data[i] == noData {
+				allFilled = false
+				if !normalise {
+					break
+				} else {
+					t.Data[i] = int32(normNoData)
+				}
+			}
+		}
+
+	case *utils.UInt32Raster:
+		flex.Type = "UInt32"
+		flex.NoData = t.NoData
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&t.Data))
+		headr.Len *= SizeofUint32
+		headr.Cap *= SizeofUint32
+		flex.Data = *(*[]uint8)(unsafe.Pointer(&headr))
+		noData := uint32(t.NoData)
+		if normRaster != nil {
+			if r, ok := normRaster.(*utils.UInt32Raster); ok {
+				normNoData = r.NoData
+				if uint32(normNoData) != noData {
+					normalise = true
+					flex.NoData = normNoData
+				}
+			}
+		}
+		for i := range t.Data {
+			if t.Data[i] == noData {
+				allFilled = false
+				if !normalise {
+					break
+				} else {
+					t.Data[i] = uint32(normNoData)
+				}
+			}
+		}
+
+	case *utils.Float64Raster:
+		flex.Type = "Float64"
+		flex.NoData = t.NoData
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&t.Data))
+		headr.Len *= SizeofFloat64
+		headr.Cap *= SizeofFloat64
+		flex.Data = *(*[]uint8)(unsafe.Pointer(&headr))
+		noData := float64(t.NoData)
+		if normRaster != nil {
+			if r, ok := normRaster.(*utils.Float64Raster); ok {
+				normNoData = r.NoData
+				if float64(normNoData) != noData {
+					normalise = true
+					flex.NoData = normNoData
+				}
+			}
+		}
+		for i := range t.Data {
+			if t.Data[i] == noData {
+				allFilled = false
+				if !normalise {
+					break
+				} else {
+					t.Data[i] = float64(normNoData)
+				}
+			}
+		}
+
 	}
 
 	return flex, allFilled
@@ -727,6 +1009,62 @@ func (dp *TilePipeline) checkFusedBandNames(geoReq *GeoTileRequest) ([]string, b
 	return otherVars, hasFusedBand, isTimeWeighted, nil
 }
 
+// inputVar is a variable of the band expressions of a layer referring to
+// a band of one of its aliased input layers
+type inputVar struct {
+	name  string
+	layer int
+	band  int
+}
+
+// checkInputVarNames splits vars into the variables referring to the
+// bands of aliased input layers and the others. An input layer with a
+// single band expression is referenced by its alias, otherwise its bands
+// are referenced as <alias>_<band>.
+func (dp *TilePipeline) checkInputVarNames(vars []string) ([]*inputVar, []string, error) {
+	hasAlias := false
+	for _, refLayer := range dp.CurrentLayer.InputLayers {
+		if len(refLayer.Alias) > 0 {
+			hasAlias = true
+			break
+		}
+	}
+	if !hasAlias {
+		return nil, vars, nil
+	}
+
+	depLayers, err := dp.findDepLayers()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	varLookup := make(map[string]*inputVar)
+	for idx, refLayer := range dp.CurrentLayer.InputLayers {
+		if len(refLayer.Alias) == 0 || depLayers[idx].StyleLayer.RGBExpressions == nil {
+			continue
+		}
+		exprNames := depLayers[idx].StyleLayer.RGBExpressions.ExprNames
+		for ib, exprName := range exprNames {
+			name := refLayer.Alias
+			if len(exprNames) > 1 {
+				name += "_" + exprName
+			}
+			varLookup[name] = &inputVar{name: name, layer: idx, band: ib}
+		}
+	}
+
+	var inputVars []*inputVar
+	var otherVars []string
+	for _, ns := range vars {
+		if v, found := varLookup[ns]; found {
+			inputVars = append(inputVars, v)
+		} else {
+			otherVars = append(otherVars, ns)
+		}
+	}
+	return inputVars, otherVars, nil
+}
+
 func (dp *TilePipeline) sendError(err error) {
 	select {
 	case dp.Error <- err:
@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nci/gsky/utils"
+)
+
+func TestCheckInputVarNames(t *testing.T) {
+	config := &utils.Config{Layers: []utils.Layer{
+		{Name: "sentinel2_ndvi", RGBExpressions: &utils.BandExpressions{ExprNames: []string{"ndvi"}}},
+		{Name: "landsat_nbar", RGBExpressions: &utils.BandExpressions{ExprNames: []string{"red", "nir"}}},
+	}}
+
+	dp := &TilePipeline{
+		CurrentLayer: &utils.Layer{InputLayers: []utils.Layer{
+			{Name: "sentinel2_ndvi", Alias: "ndvi_sentinel"},
+			{Name: "landsat_nbar", Alias: "landsat"},
+		}},
+		DataSources: map[string]*utils.Config{".": config},
+	}
+
+	inputVars, otherVars, err := dp.checkInputVarNames([]string{"landsat_nir", "ndvi_sentinel", "mask"})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(otherVars) != 1 || otherVars[0] != "mask" {
+		t.Errorf("unexpected other variables: %v", otherVars)
+	}
+	if len(inputVars) != 2 {
+		t.Fatalf("unexpected input variables: %v", inputVars)
+	}
+	if v := inputVars[0]; v.name != "landsat_nir" || v.layer != 1 || v.band != 1 {
+		t.Errorf("unexpected input variable: %v", *v)
+	}
+	if v := inputVars[1]; v.name != "ndvi_sentinel" || v.layer != 0 || v.band != 0 {
+		t.Errorf("unexpected input variable: %v", *v)
+	}
+}
+
+func TestResolveInputTime(t *testing.T) {
+	layer := &utils.Layer{
+		Dates:    []string{"2020-01-01T00:00:00.000Z", "2020-02-01T00:00:00.000Z", "2020-03-01T00:00:00.000Z"},
+		Accum:    true,
+		StepDays: 1,
+	}
+
+	reqTime := time.Date(2020, 2, 15, 0, 0, 0, 0, time.UTC)
+	req := &GeoTileRequest{StartTime: &reqTime}
+	if !resolveInputTime(layer, req) {
+		t.Fatalf("expected a matching date")
+	}
+	if !req.StartTime.Equal(time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)) || req.EndTime == nil || !req.EndTime.Equal(time.Date(2020, 2, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time range: %v, %v", req.StartTime, req.EndTime)
+	}
+
+	reqTime = time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)
+	req = &GeoTileRequest{StartTime: &reqTime}
+	if resolveInputTime(layer, req) {
+		t.Errorf("expected no matching date before %v", reqTime)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/nci/gsky/metrics"
 	"github.com/nci/gsky/utils"
+	pb "github.com/nci/gsky/worker/gdalservice"
 )
 
 type ScaleParams struct {
@@ -35,10 +36,12 @@ type ConfigPayLoad struct {
 	IndexTileYSize        float64
 	SpatialExtent         []float64
 	IndexResLimit         float64
+	Unpack                bool
 	MasQueryHint          string
 	ReqRes                float64
 	SRSCf                 int
 	FusionUnscale         int
+	Priority              pb.Priority
 	MetricsCollector      *metrics.MetricsCollector
 }
 
@@ -71,6 +74,7 @@ type GeoTileRequest struct {
 	EndTime       *time.Time
 	Axes          map[string]*GeoTileAxis
 	Overview      *utils.Layer
+	Part          *utils.BBoxPart
 }
 
 type GeoTileGranule struct {
@@ -92,6 +96,8 @@ type GeoTileGranule struct {
 	Polygon             string
 	RasterType          string
 	GeoLocation         *GeoLocInfo
+	Packing             *BandPacking
+	Units               string
 }
 
 type FlexRaster struct {
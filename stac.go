@@ -0,0 +1,688 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	proc "github.com/nci/gsky/processor"
+	"github.com/nci/gsky/utils"
+)
+
+const stacWorldWKT = "POLYGON ((-180 -90,180 -90,180 90,-180 90,-180 -90))"
+
+// stacLayer is a layer served as a STAC collection
+type stacLayer struct {
+	id        string
+	namespace string
+	conf      *utils.Config
+	layer     *utils.Layer
+}
+
+// stacCollectionID qualifies the layer name with its namespace unless
+// the layer is in the root config
+func stacCollectionID(namespace string, name string) string {
+	if namespace == "." {
+		return name
+	}
+	return namespace + ":" + name
+}
+
+// stacLayers returns the layers of the loaded configs which are indexed
+// by MAS, ordered by collection ID
+func stacLayers() []*stacLayer {
+	var layers []*stacLayer
+	for ns, conf := range getConfigMap() {
+		if conf == nil {
+			continue
+		}
+		for iLayer := range conf.Layers {
+			layer := &conf.Layers[iLayer]
+			if len(strings.TrimSpace(layer.DataSource)) == 0 || len(stacMASAddress(conf, layer)) == 0 {
+				continue
+			}
+			layers = append(layers, &stacLayer{id: stacCollectionID(ns, layer.Name), namespace: ns, conf: conf, layer: layer})
+		}
+	}
+	sort.Slice(layers, func(i, j int) bool { return layers[i].id < layers[j].id })
+	return layers
+}
+
+func findSTACLayer(id string) *stacLayer {
+	for _, l := range stacLayers() {
+		if l.id == id {
+			return l
+		}
+	}
+	return nil
+}
+
+func stacMASAddress(conf *utils.Config, layer *utils.Layer) string {
+	if len(layer.MASAddress) > 0 {
+		return layer.MASAddress
+	}
+	return conf.ServiceConfig.MASAddress
+}
+
+func stacNamespaces(layer *utils.Layer) string {
+	if layer.RGBExpressions == nil {
+		return ""
+	}
+	return strings.Join(layer.RGBExpressions.VarList, ",")
+}
+
+func stacOWSURL(base string, namespace string) string {
+	if namespace == "." {
+		return base + "/ows"
+	}
+	return base + "/ows/" + namespace
+}
+
+func stacWriteJSON(w http.ResponseWriter, contentType string, v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		stacError(w, err, 500)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(payload)
+}
+
+// stacError writes an OGC API error response
+func stacError(w http.ResponseWriter, err error, status int) {
+	code := "BadRequest"
+	switch status {
+	case 404:
+		code = "NotFound"
+	case 405:
+		code = "MethodNotAllowed"
+	case 500, 502:
+		code = "InternalServerError"
+	}
+
+	payload, _ := json.Marshal(map[string]string{"code": code, "description": err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(payload)
+}
+
+// stacHandler serves the STAC landing page
+func stacHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	base := utils.GetHostURL(r)
+
+	catalog := &utils.STACCatalog{
+		Type:        "Catalog",
+		STACVersion: utils.STACVersion,
+		ID:          "gsky",
+		Title:       "GSKY",
+		Description: "Datasets indexed by GSKY",
+		ConformsTo:  utils.STACConformance,
+		Links: []utils.STACLink{
+			{Rel: "self", Href: base + "/stac", Type: "application/json"},
+			{Rel: "root", Href: base + "/stac", Type: "application/json"},
+			{Rel: "conformance", Href: base + "/conformance", Type: "application/json"},
+			{Rel: "data", Href: base + "/collections", Type: "application/json"},
+			{Rel: "search", Href: base + "/search", Type: "application/geo+json", Method: "GET"},
+			{Rel: "search", Href: base + "/search", Type: "application/geo+json", Method: "POST"},
+		},
+	}
+	for _, l := range stacLayers() {
+		catalog.Links = append(catalog.Links, utils.STACLink{Rel: "child", Href: base + "/collections/" + url.PathEscape(l.id), Type: "application/json", Title: l.layer.Title})
+	}
+	stacWriteJSON(w, "application/json", catalog)
+}
+
+func conformanceHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	stacWriteJSON(w, "application/json", map[string][]string{"conformsTo": utils.STACConformance})
+}
+
+// collectionsHandler serves /collections, /collections/{id} and
+// /collections/{id}/items
+func collectionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	base := utils.GetHostURL(r)
+
+	// Namespaces of collection IDs may contain escaped slashes
+	rest := strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/collections"), "/")
+	if len(rest) == 0 {
+		var collections []*utils.STACCollection
+		for _, l := range stacLayers() {
+			collections = append(collections, stacCollection(base, l))
+		}
+		stacWriteJSON(w, "application/json", map[string]interface{}{
+			"collections": collections,
+			"links": []utils.STACLink{
+				{Rel: "self", Href: base + "/collections", Type: "application/json"},
+				{Rel: "root", Href: base + "/stac", Type: "application/json"},
+			},
+		})
+		return
+	}
+
+	parts := strings.Split(rest, "/")
+	id, err := url.PathUnescape(parts[0])
+	if err != nil {
+		stacError(w, fmt.Errorf("invalid collection id: %s", parts[0]), 400)
+		return
+	}
+	l := findSTACLayer(id)
+	if l == nil {
+		stacError(w, fmt.Errorf("collection not found: %s", id), 404)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		stacWriteJSON(w, "application/json", stacCollection(base, l))
+
+	case len(parts) == 2 && parts[1] == "items":
+		query := r.URL.Query()
+		wkt := stacWorldWKT
+		if len(query.Get("bbox")) > 0 {
+			bbox, err := utils.ParseSTACBBox(query.Get("bbox"))
+			if err != nil {
+				stacError(w, err, 400)
+				return
+			}
+			wkt = utils.STACBBoxToWKT(bbox)
+		}
+
+		start, end, err := utils.ParseSTACDatetime(query.Get("datetime"))
+		if err != nil {
+			stacError(w, err, 400)
+			return
+		}
+
+		limit, err := parseSTACLimit(query.Get("limit"))
+		if err != nil {
+			stacError(w, err, 400)
+			return
+		}
+
+		offset := 0
+		if token := query.Get("token"); len(token) > 0 {
+			offset, err = strconv.Atoi(token)
+			if err != nil || offset < 0 {
+				stacError(w, fmt.Errorf("invalid token: %s", token), 400)
+				return
+			}
+		}
+
+		items, more, err := stacItems(base, l, wkt, start, end, limit, offset)
+		if err != nil {
+			stacError(w, err, 502)
+			return
+		}
+
+		itemsURL := base + "/collections/" + url.PathEscape(l.id) + "/items"
+		collection := &utils.STACItemCollection{
+			Type:           "FeatureCollection",
+			Features:       items,
+			NumberReturned: len(items),
+			Links: []utils.STACLink{
+				{Rel: "self", Href: itemsURL, Type: "application/geo+json"},
+				{Rel: "collection", Href: base + "/collections/" + url.PathEscape(l.id), Type: "application/json"},
+				{Rel: "root", Href: base + "/stac", Type: "application/json"},
+			},
+		}
+		if more {
+			query.Set("token", strconv.Itoa(offset+len(items)))
+			collection.Links = append(collection.Links, utils.STACLink{Rel: "next", Href: itemsURL + "?" + query.Encode(), Type: "application/geo+json"})
+		}
+		stacWriteJSON(w, "application/geo+json", collection)
+
+	default:
+		stacError(w, fmt.Errorf("not found: %s", r.URL.Path), 404)
+	}
+}
+
+// searchHandler serves item searches across collections by GET or POST
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	base := utils.GetHostURL(r)
+
+	search := &utils.STACSearch{}
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		if len(query.Get("collections")) > 0 {
+			search.Collections = strings.Split(query.Get("collections"), ",")
+		}
+		if len(query.Get("bbox")) > 0 {
+			bbox, err := utils.ParseSTACBBox(query.Get("bbox"))
+			if err != nil {
+				stacError(w, err, 400)
+				return
+			}
+			search.BBox = bbox
+		}
+		search.Datetime = query.Get("datetime")
+		if len(query.Get("intersects")) > 0 {
+			search.Intersects = json.RawMessage(query.Get("intersects"))
+		}
+		limit, err := parseSTACLimit(query.Get("limit"))
+		if err != nil {
+			stacError(w, err, 400)
+			return
+		}
+		search.Limit = limit
+		search.Token = query.Get("token")
+
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			stacError(w, err, 400)
+			return
+		}
+		if err = json.Unmarshal(body, search); err != nil {
+			stacError(w, fmt.Errorf("invalid search: %v", err), 400)
+			return
+		}
+		if len(search.BBox) > 0 {
+			if search.BBox, err = utils.CheckSTACBBox(search.BBox); err != nil {
+				stacError(w, err, 400)
+				return
+			}
+		}
+		if search.Limit <= 0 {
+			search.Limit = utils.DefaultSTACLimit
+		} else if search.Limit > utils.MaxSTACLimit {
+			search.Limit = utils.MaxSTACLimit
+		}
+
+	default:
+		stacError(w, fmt.Errorf("search requires GET or POST"), 405)
+		return
+	}
+
+	if len(search.BBox) > 0 && len(search.Intersects) > 0 {
+		stacError(w, fmt.Errorf("bbox and intersects are mutually exclusive"), 400)
+		return
+	}
+
+	wkt := stacWorldWKT
+	if len(search.BBox) > 0 {
+		wkt = utils.STACBBoxToWKT(search.BBox)
+	} else if len(search.Intersects) > 0 {
+		var err error
+		wkt, err = utils.GeoJSONToWKT(search.Intersects)
+		if err != nil {
+			stacError(w, err, 400)
+			return
+		}
+	}
+
+	start, end, err := utils.ParseSTACDatetime(search.Datetime)
+	if err != nil {
+		stacError(w, err, 400)
+		return
+	}
+
+	var layers []*stacLayer
+	if len(search.Collections) > 0 {
+		for _, id := range search.Collections {
+			l := findSTACLayer(strings.TrimSpace(id))
+			if l == nil {
+				stacError(w, fmt.Errorf("collection not found: %s", id), 404)
+				return
+			}
+			layers = append(layers, l)
+		}
+	} else {
+		layers = stacLayers()
+	}
+
+	iLayer, offset, err := parseSTACSearchToken(search.Token, len(layers))
+	if err != nil {
+		stacError(w, err, 400)
+		return
+	}
+
+	// Pages run through the files of every layer in turn
+	items := []*utils.STACItem{}
+	next := ""
+	for ; iLayer < len(layers); iLayer++ {
+		if len(items) >= search.Limit {
+			next = fmt.Sprintf("%d:0", iLayer)
+			break
+		}
+		found, more, err := stacItems(base, layers[iLayer], wkt, start, end, search.Limit-len(items), offset)
+		if err != nil {
+			stacError(w, err, 502)
+			return
+		}
+		items = append(items, found...)
+		if more {
+			next = fmt.Sprintf("%d:%d", iLayer, offset+len(found))
+			break
+		}
+		offset = 0
+	}
+
+	collection := &utils.STACItemCollection{
+		Type:           "FeatureCollection",
+		Features:       items,
+		NumberReturned: len(items),
+		Links: []utils.STACLink{
+			{Rel: "root", Href: base + "/stac", Type: "application/json"},
+		},
+	}
+	if len(next) > 0 {
+		if r.Method == http.MethodPost {
+			nextSearch := *search
+			nextSearch.Token = next
+			collection.Links = append(collection.Links, utils.STACLink{Rel: "next", Href: base + "/search", Type: "application/geo+json", Method: "POST", Body: &nextSearch})
+		} else {
+			query := r.URL.Query()
+			query.Set("token", next)
+			collection.Links = append(collection.Links, utils.STACLink{Rel: "next", Href: base + "/search?" + query.Encode(), Type: "application/geo+json", Method: "GET"})
+		}
+	}
+	stacWriteJSON(w, "application/geo+json", collection)
+}
+
+// parseSTACSearchToken parses a search token of the layer index and the
+// number of its files already returned
+func parseSTACSearchToken(token string, nLayers int) (int, int, error) {
+	if len(token) == 0 {
+		return 0, 0, nil
+	}
+	parts := strings.Split(token, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid token: %s", token)
+	}
+	iLayer, err := strconv.Atoi(parts[0])
+	if err != nil || iLayer < 0 || iLayer > nLayers {
+		return 0, 0, fmt.Errorf("invalid token: %s", token)
+	}
+	offset, err := strconv.Atoi(parts[1])
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("invalid token: %s", token)
+	}
+	return iLayer, offset, nil
+}
+
+func parseSTACLimit(limitStr string) (int, error) {
+	if len(limitStr) == 0 {
+		return utils.DefaultSTACLimit, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid limit: %s", limitStr)
+	}
+	if limit > utils.MaxSTACLimit {
+		limit = utils.MaxSTACLimit
+	}
+	return limit, nil
+}
+
+// stacCollection describes a layer. Its spatial extent is queried from
+// MAS while its temporal extent comes from the layer dates.
+func stacCollection(base string, l *stacLayer) *utils.STACCollection {
+	layer := l.layer
+	description := layer.Abstract
+	if len(strings.TrimSpace(description)) == 0 {
+		description = layer.Title
+	}
+	if len(strings.TrimSpace(description)) == 0 {
+		description = layer.Name
+	}
+
+	bbox, err := stacExtents(l)
+	if err != nil {
+		if *verbose {
+			Info.Printf("STAC: extents of %s: %v", l.id, err)
+		}
+		bbox = []float64{-180, -90, 180, 90}
+	}
+
+	startDate := layer.EffectiveStartDate
+	endDate := layer.EffectiveEndDate
+	if len(startDate) == 0 && len(layer.Dates) > 0 {
+		startDate = layer.Dates[0]
+	}
+	if len(endDate) == 0 && len(layer.Dates) > 0 {
+		endDate = layer.Dates[len(layer.Dates)-1]
+	}
+	interval := []*string{nil, nil}
+	if len(startDate) > 0 {
+		interval[0] = &startDate
+	}
+	if len(endDate) > 0 {
+		interval[1] = &endDate
+	}
+
+	collectionURL := base + "/collections/" + url.PathEscape(l.id)
+	collection := &utils.STACCollection{
+		Type:        "Collection",
+		STACVersion: utils.STACVersion,
+		ID:          l.id,
+		Title:       layer.Title,
+		Description: description,
+		License:     "proprietary",
+		Extent: utils.STACExtent{
+			Spatial:  utils.STACSpatialExtent{BBox: [][]float64{bbox}},
+			Temporal: utils.STACTemporalExtent{Interval: [][]*string{interval}},
+		},
+		Links: []utils.STACLink{
+			{Rel: "self", Href: collectionURL, Type: "application/json"},
+			{Rel: "root", Href: base + "/stac", Type: "application/json"},
+			{Rel: "parent", Href: base + "/stac", Type: "application/json"},
+			{Rel: "items", Href: collectionURL + "/items", Type: "application/geo+json"},
+		},
+	}
+
+	owsURL := stacOWSURL(base, l.namespace)
+	if !utils.CheckDisableServices(layer, "wms") {
+		collection.Links = append(collection.Links, utils.STACLink{Rel: "wms", Href: owsURL + "?service=WMS&request=GetCapabilities", Type: "application/xml", Title: "WMS"})
+	}
+	if !utils.CheckDisableServices(layer, "wcs") {
+		collection.Links = append(collection.Links, utils.STACLink{Rel: "wcs", Href: owsURL + "?service=WCS&request=GetCapabilities", Type: "application/xml", Title: "WCS"})
+	}
+	return collection
+}
+
+// stacExtents queries MAS for the bbox of a layer in EPSG:4326
+func stacExtents(l *stacLayer) ([]float64, error) {
+	reqURL := fmt.Sprintf("http://%s%s?extents&namespace=%s", stacMASAddress(l.conf, l.layer), l.layer.DataSource, url.QueryEscape(stacNamespaces(l.layer)))
+	resp, err := http.Get(reqURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var extents struct {
+		Error string   `json:"error"`
+		XMin  *float64 `json:"xmin"`
+		YMin  *float64 `json:"ymin"`
+		XMax  *float64 `json:"xmax"`
+		YMax  *float64 `json:"ymax"`
+	}
+	if err = json.Unmarshal(body, &extents); err != nil {
+		return nil, err
+	}
+	if len(extents.Error) > 0 {
+		return nil, fmt.Errorf("%s", extents.Error)
+	}
+	if extents.XMin == nil || extents.YMin == nil || extents.XMax == nil || extents.YMax == nil {
+		return nil, fmt.Errorf("no extents")
+	}
+
+	// MAS extents are in EPSG:3857
+	xMin, yMin := utils.MercatorToWGS84(*extents.XMin, *extents.YMin)
+	xMax, yMax := utils.MercatorToWGS84(*extents.XMax, *extents.YMax)
+	return []float64{xMin, yMin, xMax, yMax}, nil
+}
+
+// stacItems queries MAS for up to limit files of a layer intersecting
+// wkt in EPSG:4326, skipping the first offset files. Every file is an
+// item whose assets are its datasets. It also reports whether a full
+// page was returned, which may have more files after it.
+func stacItems(base string, l *stacLayer, wkt string, start *time.Time, end *time.Time, limit int, offset int) ([]*utils.STACItem, bool, error) {
+	layer := l.layer
+	reqURL := fmt.Sprintf("http://%s%s?intersects&metadata=gdal&srs=EPSG:4326&namespace=%s&limit=%d&offset=%d", stacMASAddress(l.conf, layer), layer.DataSource, url.QueryEscape(stacNamespaces(layer)), limit, offset)
+	if start != nil || end != nil {
+		// MAS matches an exact timestamp unless an interval is given
+		timeA := time.Time{}
+		if start != nil {
+			timeA = *start
+		}
+		timeB := time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+		if end != nil {
+			timeB = *end
+		}
+		reqURL += fmt.Sprintf("&time=%s&until=%s", timeA.Format(utils.ISOFormat), timeB.Format(utils.ISOFormat))
+	}
+
+	resp, err := http.PostForm(reqURL, url.Values{"wkt": {wkt}})
+	if err != nil {
+		return nil, false, fmt.Errorf("MAS request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var metadata proc.MetadataResponse
+	if err = json.Unmarshal(body, &metadata); err != nil {
+		return nil, false, fmt.Errorf("invalid MAS response: %v", err)
+	}
+	if len(metadata.Error) > 0 {
+		return nil, false, fmt.Errorf("MAS error: %s", metadata.Error)
+	}
+
+	var paths []string
+	datasets := make(map[string][]*proc.GDALDataset)
+	for _, ds := range metadata.GDALDatasets {
+		if _, found := datasets[ds.RawPath]; !found {
+			paths = append(paths, ds.RawPath)
+		}
+		datasets[ds.RawPath] = append(datasets[ds.RawPath], ds)
+	}
+	sort.Strings(paths)
+
+	items := []*utils.STACItem{}
+	for _, path := range paths {
+		items = append(items, stacItem(base, l, path, datasets[path]))
+	}
+	return items, len(paths) >= limit, nil
+}
+
+func stacItem(base string, l *stacLayer, path string, datasets []*proc.GDALDataset) *utils.STACItem {
+	layer := l.layer
+	collectionURL := base + "/collections/" + url.PathEscape(l.id)
+
+	id := strings.Trim(strings.TrimPrefix(path, strings.TrimRight(layer.DataSource, "/")), "/")
+	item := &utils.STACItem{
+		Type:        "Feature",
+		STACVersion: utils.STACVersion,
+		ID:          id,
+		Collection:  l.id,
+		Geometry:    json.RawMessage("null"),
+		Properties:  make(map[string]interface{}),
+		Assets:      make(map[string]*utils.STACAsset),
+		Links: []utils.STACLink{
+			{Rel: "collection", Href: collectionURL, Type: "application/json"},
+			{Rel: "parent", Href: collectionURL, Type: "application/json"},
+			{Rel: "root", Href: base + "/stac", Type: "application/json"},
+		},
+	}
+
+	stampLookup := make(map[time.Time]struct{})
+	var stamps []time.Time
+	var namespaces []string
+	for _, ds := range datasets {
+		for _, t := range ds.TimeStamps {
+			t = t.UTC()
+			if _, found := stampLookup[t]; !found {
+				stampLookup[t] = struct{}{}
+				stamps = append(stamps, t)
+			}
+		}
+
+		if item.BBox == nil && len(ds.Polygon) > 0 {
+			geometry, bbox, err := utils.WKTToGeoJSON(ds.Polygon, ds.SRS)
+			if err == nil {
+				item.Geometry = geometry
+				item.BBox = bbox
+			} else if *verbose {
+				Info.Printf("STAC: geometry of %s: %v", path, err)
+			}
+		}
+
+		asset := &utils.STACAsset{Href: path, Type: utils.STACMediaType(path), Title: ds.NameSpace, Roles: []string{"data"}}
+		if ds.DSName != path {
+			asset.DSName = ds.DSName
+		}
+		key := ds.NameSpace
+		if len(key) == 0 {
+			key = "data"
+		}
+		if _, found := item.Assets[key]; !found {
+			namespaces = append(namespaces, ds.NameSpace)
+		}
+		item.Assets[key] = asset
+	}
+	sort.Slice(stamps, func(i, j int) bool { return stamps[i].Before(stamps[j]) })
+
+	item.Properties["datetime"] = nil
+	if len(stamps) == 1 {
+		item.Properties["datetime"] = stamps[0].Format(utils.ISOFormat)
+	} else if len(stamps) > 1 {
+		item.Properties["start_datetime"] = stamps[0].Format(utils.ISOFormat)
+		item.Properties["end_datetime"] = stamps[len(stamps)-1].Format(utils.ISOFormat)
+	}
+	item.Properties["gsky:namespaces"] = namespaces
+
+	if item.BBox == nil {
+		return item
+	}
+
+	// Links rendering the granule through the layer
+	bbox := fmt.Sprintf("%f,%f,%f,%f", item.BBox[0], item.BBox[1], item.BBox[2], item.BBox[3])
+	width, height := stacPreviewSize(item.BBox)
+	timeParam := ""
+	if len(stamps) > 0 {
+		timeParam = "&time=" + url.QueryEscape(stamps[len(stamps)-1].Format(utils.ISOFormat))
+	}
+	owsURL := stacOWSURL(base, l.namespace)
+	if !utils.CheckDisableServices(layer, "wms") {
+		getMap := fmt.Sprintf("%s?service=WMS&request=GetMap&version=1.1.1&layers=%s&styles=&srs=EPSG:4326&bbox=%s&width=%d&height=%d&format=image/png&transparent=true%s", owsURL, url.QueryEscape(layer.Name), bbox, width, height, timeParam)
+		item.Links = append(item.Links, utils.STACLink{Rel: "wms", Href: getMap, Type: "image/png", Title: "WMS GetMap"})
+	}
+	if !utils.CheckDisableServices(layer, "wcs") {
+		getCoverage := fmt.Sprintf("%s?service=WCS&request=GetCoverage&version=1.0.0&coverage=%s&crs=EPSG:4326&bbox=%s&width=%d&height=%d&format=GeoTIFF%s", owsURL, url.QueryEscape(layer.Name), bbox, width, height, timeParam)
+		item.Links = append(item.Links, utils.STACLink{Rel: "wcs", Href: getCoverage, Type: "image/tiff; application=geotiff", Title: "WCS GetCoverage"})
+	}
+	return item
+}
+
+// stacPreviewSize returns a 512 pixels wide or high image size with the
+// aspect ratio of bbox
+func stacPreviewSize(bbox []float64) (int, int) {
+	const size = 512
+	dx := bbox[2] - bbox[0]
+	dy := bbox[3] - bbox[1]
+	if dx <= 0 || dy <= 0 {
+		return size, size
+	}
+	if dx >= dy {
+		return size, int(size*dy/dx + 0.5)
+	}
+	return int(size*dx/dy + 0.5), size
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	proc "github.com/nci/gsky/processor"
+	"github.com/nci/gsky/utils"
+)
+
+// fakeSTACMAS serves the files of its gpaths to intersects queries in
+// the order of their paths
+type fakeSTACMAS struct {
+	files map[string][]string
+	wkts  []string
+}
+
+func (m *fakeSTACMAS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.wkts = append(m.wkts, r.FormValue("wkt"))
+
+	files := m.files[r.URL.Path]
+	offset, _ := strconv.Atoi(r.FormValue("offset"))
+	if offset > len(files) {
+		offset = len(files)
+	}
+	files = files[offset:]
+	if limit, err := strconv.Atoi(r.FormValue("limit")); err == nil && limit < len(files) {
+		files = files[:limit]
+	}
+
+	metadata := &proc.MetadataResponse{}
+	for _, file := range files {
+		metadata.GDALDatasets = append(metadata.GDALDatasets, &proc.GDALDataset{RawPath: r.URL.Path + "/" + file, DSName: r.URL.Path + "/" + file, NameSpace: "band"})
+	}
+	json.NewEncoder(w).Encode(metadata)
+}
+
+func setupSTACTest(t *testing.T) *fakeSTACMAS {
+	mas := &fakeSTACMAS{files: map[string][]string{
+		"/data/a": {"1.nc", "2.nc"},
+		"/data/b": {"1.nc", "2.nc", "3.nc", "4.nc", "5.nc"},
+	}}
+	masServer := httptest.NewServer(mas)
+	t.Cleanup(masServer.Close)
+
+	masAddress := strings.TrimPrefix(masServer.URL, "http://")
+	conf := &utils.Config{Layers: []utils.Layer{
+		{Name: "a", DataSource: "/data/a", MASAddress: masAddress},
+		{Name: "b", DataSource: "/data/b", MASAddress: masAddress},
+	}}
+
+	oldConfigMap := configMap
+	configMap = &sync.Map{}
+	configMap.Store("config", map[string]*utils.Config{".": conf})
+	t.Cleanup(func() { configMap = oldConfigMap })
+	return mas
+}
+
+func getSTACItems(t *testing.T, handler http.HandlerFunc, req *http.Request) *utils.STACItemCollection {
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: unexpected status %d: %s", req.URL, rec.Code, rec.Body.String())
+	}
+
+	collection := &utils.STACItemCollection{}
+	if err := json.Unmarshal(rec.Body.Bytes(), collection); err != nil {
+		t.Fatal(err)
+	}
+	return collection
+}
+
+func stacNextLink(collection *utils.STACItemCollection) *utils.STACLink {
+	for i := range collection.Links {
+		if collection.Links[i].Rel == "next" {
+			return &collection.Links[i]
+		}
+	}
+	return nil
+}
+
+func stacItemIDs(collection *utils.STACItemCollection) string {
+	var ids []string
+	for _, item := range collection.Features {
+		ids = append(ids, item.Collection+"/"+item.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSTACCollectionItemsPaging(t *testing.T) {
+	setupSTACTest(t)
+
+	reqURL := "http://gsky/collections/b/items?limit=2"
+	var pages []string
+	for len(reqURL) > 0 {
+		if len(pages) > 5 {
+			t.Fatalf("too many pages: %v", pages)
+		}
+		collection := getSTACItems(t, collectionsHandler, httptest.NewRequest("GET", reqURL, nil))
+		if collection.NumberReturned != len(collection.Features) {
+			t.Errorf("numberReturned %d of %d items", collection.NumberReturned, len(collection.Features))
+		}
+		pages = append(pages, stacItemIDs(collection))
+
+		reqURL = ""
+		if next := stacNextLink(collection); next != nil {
+			reqURL = next.Href
+		}
+	}
+
+	expected := []string{"b/1.nc,b/2.nc", "b/3.nc,b/4.nc", "b/5.nc"}
+	if fmt.Sprint(pages) != fmt.Sprint(expected) {
+		t.Errorf("expected pages %v, got %v", expected, pages)
+	}
+}
+
+func TestSTACSearchPaging(t *testing.T) {
+	setupSTACTest(t)
+
+	// GET pages run from the files of a into the files of b
+	reqURL := "http://gsky/search?limit=3"
+	var pages []string
+	for len(reqURL) > 0 {
+		if len(pages) > 5 {
+			t.Fatalf("too many pages: %v", pages)
+		}
+		collection := getSTACItems(t, searchHandler, httptest.NewRequest("GET", reqURL, nil))
+		pages = append(pages, stacItemIDs(collection))
+
+		reqURL = ""
+		if next := stacNextLink(collection); next != nil {
+			if next.Method != "GET" {
+				t.Errorf("unexpected next method %q", next.Method)
+			}
+			reqURL = next.Href
+		}
+	}
+
+	expected := []string{"a/1.nc,a/2.nc,b/1.nc", "b/2.nc,b/3.nc,b/4.nc", "b/5.nc"}
+	if fmt.Sprint(pages) != fmt.Sprint(expected) {
+		t.Errorf("expected GET pages %v, got %v", expected, pages)
+	}
+
+	// POST next links carry the search with its token
+	body := `{"collections": ["b"], "limit": 4}`
+	collection := getSTACItems(t, searchHandler, httptest.NewRequest("POST", "http://gsky/search", strings.NewReader(body)))
+	if ids := stacItemIDs(collection); ids != "b/1.nc,b/2.nc,b/3.nc,b/4.nc" {
+		t.Errorf("unexpected POST page %s", ids)
+	}
+	next := stacNextLink(collection)
+	if next == nil || next.Method != "POST" {
+		t.Fatalf("expected a POST next link, got %+v", next)
+	}
+	nextBody, err := json.Marshal(next.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collection = getSTACItems(t, searchHandler, httptest.NewRequest("POST", next.Href, strings.NewReader(string(nextBody))))
+	if ids := stacItemIDs(collection); ids != "b/5.nc" {
+		t.Errorf("unexpected second POST page %s", ids)
+	}
+	if next = stacNextLink(collection); next != nil {
+		t.Errorf("unexpected next link %+v", next)
+	}
+}
+
+func TestSTACAntimeridianBBox(t *testing.T) {
+	mas := setupSTACTest(t)
+
+	getSTACItems(t, collectionsHandler, httptest.NewRequest("GET", "http://gsky/collections/a/items?bbox="+url.QueryEscape("170,-40,-170,-10"), nil))
+	expected := "MULTIPOLYGON (((170 -40,180 -40,180 -10,170 -10,170 -40)),((-180 -40,-170 -40,-170 -10,-180 -10,-180 -40)))"
+	if len(mas.wkts) != 1 || mas.wkts[0] != expected {
+		t.Errorf("expected MAS to query %s, got %v", expected, mas.wkts)
+	}
+
+	for _, reqURL := range []string{
+		"http://gsky/collections/a/items?bbox=110,-40,200,-10",
+		"http://gsky/collections/a/items?token=x",
+		"http://gsky/search?bbox=110,-10,150,-40",
+		"http://gsky/search?token=9:0",
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", reqURL, nil)
+		if strings.Contains(reqURL, "/search") {
+			searchHandler(rec, req)
+		} else {
+			collectionsHandler(rec, req)
+		}
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", reqURL, rec.Code)
+		}
+	}
+}
@@ -53,14 +53,28 @@
         <label>
           {{ .Title }}
         </label>
+        {{ if .Bands }}
+        <axisDescription>
+          {{ range .Bands }}
+          <AxisDescription{{ if .Units }} refSysLabel="{{ .Units }}"{{ end }}>
+            <name>{{ .Name }}</name>
+            <label>{{ .Name }}</label>
+            <values>
+              <singleValue>{{ .Name }}</singleValue>
+            </values>
+          </AxisDescription>
+          {{ end }}
+        </axisDescription>
+        {{ end }}
         <nullValues>
           <singleValue>NaN</singleValue>
         </nullValues>
       </RangeSet>
     </rangeSet>
     <supportedCRSs>
-      <requestCRSs>EPSG:4326</requestCRSs>
-      <responseCRSs>EPSG:4326</responseCRSs>
+      {{ range .CRS }}<requestCRSs>{{ . }}</requestCRSs>
+      {{ end }}{{ range .CRS }}<responseCRSs>{{ . }}</responseCRSs>
+      {{ end }}
     </supportedCRSs>
     <supportedFormats>
       <formats>GeoTIFF</formats>
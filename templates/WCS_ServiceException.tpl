@@ -1,4 +1,4 @@
-<?xml version="1.0" encoding="UTF-8" standalone="no"?><!DOCTYPE ServiceExceptionReport SYSTEM "http://gsky.nci.org.au/schemas/wms/1.1.1/WMS_exception_1_1_1.dtd"> 
-<ServiceExceptionReport version="1.1.1" >   
-	<ServiceException code="LayerNotDefined" locator="MapLayerInfoKvpParser">{{ . }} no such layer on this server.</ServiceException>
+<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<ServiceExceptionReport version="1.2.0" xmlns="http://www.opengis.net/ogc" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.opengis.net/ogc http://schemas.opengis.net/wcs/1.0.0/OGC-exception.xsd">
+	<ServiceException{{ if .Code }} code="{{ .Code }}"{{ end }}{{ if .Locator }} locator="{{ html .Locator }}"{{ end }}>{{ html .Message }}</ServiceException>
 </ServiceExceptionReport>
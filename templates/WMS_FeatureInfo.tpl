@@ -0,0 +1,58 @@
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<title>GetFeatureInfo</title>
+	<style>
+		table { border-collapse: collapse; margin-bottom: 1em; }
+		th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: left; }
+	</style>
+</head>
+<body>
+	<table>
+		<tr><th>x</th><td>{{ .X }}</td></tr>
+		<tr><th>y</th><td>{{ .Y }}</td></tr>
+		{{- if .Time }}
+		<tr><th>time</th><td>{{ html .Time }}</td></tr>
+		{{- end }}
+		{{- range .Times }}
+		<tr><th>time</th><td>{{ html . }}</td></tr>
+		{{- end }}
+		{{- if .Error }}
+		<tr><th>error</th><td>{{ html .Error }}</td></tr>
+		{{- end }}
+	</table>
+	{{- if .Bands }}
+	<table>
+		<tr><th>band</th><th>value</th><th>units</th></tr>
+		{{- range .Bands }}
+		<tr><td>{{ html .Name }}</td><td>{{ html .Value }}</td><td>{{ html (index $.Units .Name) }}</td></tr>
+		{{- end }}
+	</table>
+	{{- end }}
+	{{- if .AvailableDates }}
+	<table>
+		<tr><th>data available for dates</th></tr>
+		{{- range .AvailableDates }}
+		<tr><td>{{ html . }}</td></tr>
+		{{- end }}
+	</table>
+	{{- end }}
+	{{- if .DataLinks }}
+	<table>
+		<tr><th>data links</th></tr>
+		{{- range .DataLinks }}
+		<tr><td><a href="{{ html . }}">{{ html . }}</a></td></tr>
+		{{- end }}
+	</table>
+	{{- end }}
+	{{- with .TimeSeries }}
+	<table>
+		<tr><th>date</th>{{ range .Bands }}<th>{{ html .Name }}</th>{{ end }}</tr>
+		{{- range .Rows }}
+		<tr>{{ range . }}<td>{{ html . }}</td>{{ end }}</tr>
+		{{- end }}
+	</table>
+	{{- end }}
+</body>
+</html>
@@ -52,6 +52,9 @@
 			</GetMap>
 			<GetFeatureInfo>
 				<Format>application/json</Format>
+				<Format>text/html</Format>
+				<Format>text/plain</Format>
+				<Format>application/vnd.ogc.gml</Format>
 				<DCPType>
 				  <HTTP>
 				    <Get>
@@ -82,9 +85,6 @@
 		<Layer>
 			<Title>GSKY Web Map Service</Title>
 			<Abstract>A compliant implementation of WMS</Abstract>
-			<!--All supported EPSG projections:-->
-			<CRS>EPSG:3857</CRS>
-			<CRS>EPSG:4326</CRS>
 			<EX_GeographicBoundingBox>
 				<westBoundLongitude>-180.0</westBoundLongitude>
 				<eastBoundLongitude>180.0</eastBoundLongitude>
@@ -97,7 +97,9 @@
 				<Name>{{ .Name }}</Name>
 				<Title>{{ .Title }}</Title>
 				<Abstract>{{ .Abstract }}</Abstract>
-				<CRS>EPSG:4326</CRS>
+				{{ if .SupportedCRS }}{{ range .SupportedCRS }}<CRS>{{ . }}</CRS>
+				{{ end }}{{ else }}<CRS>EPSG:3857</CRS>
+				<CRS>EPSG:4326</CRS>{{ end }}
 				<EX_GeographicBoundingBox>
 					<westBoundLongitude>-180.0</westBoundLongitude>
 					<eastBoundLongitude>180.0</eastBoundLongitude>
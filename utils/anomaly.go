@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Periods of the year over which climatologies are computed
+const (
+	AnomalyPeriodMonth     = "month"
+	AnomalyPeriodDayOfYear = "day_of_year"
+)
+
+// Statistics of the baseline values a value is compared to
+const (
+	AnomalyStatMean   = "mean"
+	AnomalyStatMedian = "median"
+)
+
+// Outputs of anomaly layers
+const (
+	AnomalyOutputAnomaly        = "anomaly"
+	AnomalyOutputPercentNormal  = "percent_of_normal"
+	AnomalyOutputStandardised   = "standardised_anomaly"
+	AnomalyOutputPercentileRank = "percentile_rank"
+)
+
+// AnomalyConfig compares the values of a base layer to its climatology,
+// i.e. its values of the same calendar month or day of year over the
+// years of a baseline period
+type AnomalyConfig struct {
+	BaselineStartYear int    `json:"baseline_start_year"`
+	BaselineEndYear   int    `json:"baseline_end_year"`
+	Period            string `json:"period"`
+	Statistic         string `json:"statistic"`
+	Output            string `json:"output"`
+}
+
+// Validate checks the anomaly config and fills in the default period,
+// statistic and output
+func (a *AnomalyConfig) Validate() error {
+	if a.BaselineStartYear <= 0 || a.BaselineEndYear < a.BaselineStartYear {
+		return fmt.Errorf("invalid baseline years: %d-%d", a.BaselineStartYear, a.BaselineEndYear)
+	}
+
+	a.Period = strings.ToLower(strings.TrimSpace(a.Period))
+	switch a.Period {
+	case "":
+		a.Period = AnomalyPeriodMonth
+	case AnomalyPeriodMonth, AnomalyPeriodDayOfYear:
+	default:
+		return fmt.Errorf("invalid period: %s", a.Period)
+	}
+
+	a.Statistic = strings.ToLower(strings.TrimSpace(a.Statistic))
+	switch a.Statistic {
+	case "":
+		a.Statistic = AnomalyStatMean
+	case AnomalyStatMean, AnomalyStatMedian:
+	default:
+		return fmt.Errorf("invalid statistic: %s", a.Statistic)
+	}
+
+	a.Output = strings.ToLower(strings.TrimSpace(a.Output))
+	switch a.Output {
+	case "":
+		a.Output = AnomalyOutputAnomaly
+	case AnomalyOutputAnomaly, AnomalyOutputPercentNormal, AnomalyOutputStandardised, AnomalyOutputPercentileRank:
+	default:
+		return fmt.Errorf("invalid output: %s", a.Output)
+	}
+	return nil
+}
+
+// PeriodKey returns the calendar period of t. Days of year are keyed by
+// month and day so that they match across leap years.
+func (a *AnomalyConfig) PeriodKey(t time.Time) string {
+	t = t.UTC()
+	if a.Period == AnomalyPeriodDayOfYear {
+		return fmt.Sprintf("%02d-%02d", t.Month(), t.Day())
+	}
+	return fmt.Sprintf("%02d", t.Month())
+}
+
+// IsBaseline returns whether t is within the baseline years and the
+// calendar period of ref
+func (a *AnomalyConfig) IsBaseline(t time.Time, ref time.Time) bool {
+	year := t.UTC().Year()
+	return year >= a.BaselineStartYear && year <= a.BaselineEndYear && a.PeriodKey(t) == a.PeriodKey(ref)
+}
+
+// BaselineDates returns the dates of the climatology of ref. These are
+// the ISO dates in the baseline years and the period of ref, or ref
+// shifted to each of the baseline years if there are no dates.
+func (a *AnomalyConfig) BaselineDates(ref time.Time, dates []string) []time.Time {
+	var baseline []time.Time
+	if len(dates) == 0 {
+		ref = ref.UTC()
+		for year := a.BaselineStartYear; year <= a.BaselineEndYear; year++ {
+			t := time.Date(year, ref.Month(), ref.Day(), ref.Hour(), ref.Minute(), ref.Second(), 0, time.UTC)
+			if t.Month() == ref.Month() {
+				baseline = append(baseline, t)
+			}
+		}
+		return baseline
+	}
+
+	for _, dt := range dates {
+		t, err := time.Parse(ISOFormat, dt)
+		if err != nil {
+			continue
+		}
+		if a.IsBaseline(t, ref) {
+			baseline = append(baseline, t)
+		}
+	}
+	sort.Slice(baseline, func(i, j int) bool { return baseline[i].Before(baseline[j]) })
+	return baseline
+}
+
+// QueryRange extends the time range [start, end] to the baseline years
+func (a *AnomalyConfig) QueryRange(start time.Time, end time.Time) (time.Time, time.Time) {
+	baselineStart := time.Date(a.BaselineStartYear, 1, 1, 0, 0, 0, 0, time.UTC)
+	baselineEnd := time.Date(a.BaselineEndYear+1, 1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Second)
+	if baselineStart.Before(start) {
+		start = baselineStart
+	}
+	if baselineEnd.After(end) {
+		end = baselineEnd
+	}
+	return start, end
+}
+
+// Apply compares value to its baseline values according to the output
+// of the anomaly config. The baseline values are sorted in place. It
+// returns false if the comparison is undefined, e.g. there are no
+// baseline values or the normal is zero.
+func (a *AnomalyConfig) Apply(value float64, baseline []float64) (float64, bool) {
+	n := len(baseline)
+	if n == 0 || math.IsNaN(value) {
+		return 0, false
+	}
+
+	if a.Output == AnomalyOutputPercentileRank {
+		below := 0.0
+		for _, v := range baseline {
+			if v < value {
+				below++
+			} else if v == value {
+				below += 0.5
+			}
+		}
+		return 100 * below / float64(n), true
+	}
+
+	mean := 0.0
+	for _, v := range baseline {
+		mean += v
+	}
+	mean /= float64(n)
+
+	normal := mean
+	if a.Statistic == AnomalyStatMedian {
+		sort.Float64s(baseline)
+		if n%2 == 1 {
+			normal = baseline[n/2]
+		} else {
+			normal = (baseline[n/2-1] + baseline[n/2]) / 2
+		}
+	}
+
+	switch a.Output {
+	case AnomalyOutputPercentNormal:
+		if normal == 0 {
+			return 0, false
+		}
+		return 100 * value / normal, true
+	case AnomalyOutputStandardised:
+		if n < 2 {
+			return 0, false
+		}
+		variance := 0.0
+		for _, v := range baseline {
+			variance += (v - mean) * (v - mean)
+		}
+		std := math.Sqrt(variance / float64(n-1))
+		if std == 0 {
+			return 0, false
+		}
+		return (value - normal) / std, true
+	default:
+		return value - normal, true
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAnomalyBaselineDates(t *testing.T) {
+	a := &AnomalyConfig{BaselineStartYear: 1991, BaselineEndYear: 1993}
+	if err := a.Validate(); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	ref := time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)
+	dates := []string{
+		"1990-02-01T00:00:00.000Z",
+		"1992-02-01T00:00:00.000Z",
+		"1991-02-01T00:00:00.000Z",
+		"1991-03-01T00:00:00.000Z",
+		"1993-02-15T00:00:00.000Z",
+		"1994-02-01T00:00:00.000Z",
+	}
+	baseline := a.BaselineDates(ref, dates)
+	if len(baseline) != 3 || baseline[0].Year() != 1991 || baseline[2].Year() != 1993 {
+		t.Errorf("unexpected monthly baseline: %v", baseline)
+	}
+
+	a.Period = AnomalyPeriodDayOfYear
+	baseline = a.BaselineDates(ref, nil)
+	if len(baseline) != 1 || !baseline[0].Equal(time.Date(1992, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected daily baseline: %v", baseline)
+	}
+
+	start, end := a.QueryRange(ref, ref)
+	if start.Year() != 1991 || !end.Equal(ref) {
+		t.Errorf("unexpected query range: %v, %v", start, end)
+	}
+
+	for _, cfg := range []*AnomalyConfig{
+		{BaselineStartYear: 2000, BaselineEndYear: 1990},
+		{BaselineStartYear: 1990, BaselineEndYear: 2000, Period: "week"},
+		{BaselineStartYear: 1990, BaselineEndYear: 2000, Statistic: "mode"},
+		{BaselineStartYear: 1990, BaselineEndYear: 2000, Output: "ratio"},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected error for %+v", *cfg)
+		}
+	}
+}
+
+func TestAnomalyApply(t *testing.T) {
+	testCases := []struct {
+		statistic string
+		output    string
+		value     float64
+		expected  float64
+	}{
+		{AnomalyStatMean, AnomalyOutputAnomaly, 10, 5},
+		{AnomalyStatMedian, AnomalyOutputAnomaly, 10, 4.5},
+		{AnomalyStatMean, AnomalyOutputPercentNormal, 10, 200},
+		{AnomalyStatMean, AnomalyOutputStandardised, 10, 5 / math.Sqrt(10)},
+		{AnomalyStatMean, AnomalyOutputPercentileRank, 4, 37.5},
+	}
+
+	for _, tc := range testCases {
+		a := &AnomalyConfig{BaselineStartYear: 1991, BaselineEndYear: 2020, Statistic: tc.statistic, Output: tc.output}
+		val, ok := a.Apply(tc.value, []float64{8, 1, 4, 7})
+		if !ok || math.Abs(val-tc.expected) > 1e-9 {
+			t.Errorf("%s %s: got %v, expected %v", tc.statistic, tc.output, val, tc.expected)
+		}
+	}
+
+	a := &AnomalyConfig{Statistic: AnomalyStatMean, Output: AnomalyOutputPercentNormal}
+	if _, ok := a.Apply(1, []float64{-1, 1}); ok {
+		t.Errorf("expected undefined percent of a zero normal")
+	}
+	if _, ok := a.Apply(1, nil); ok {
+		t.Errorf("expected undefined anomaly without baseline")
+	}
+}
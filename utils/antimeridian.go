@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"math"
+	"strings"
+)
+
+// Half the circumference of the EPSG:3857 world
+const webMercatorExtent = 20037508.342789244
+
+// worldExtents are the extents of the world in the CRSs in which it
+// wraps around the antimeridian
+var worldExtents = map[string][]float64{
+	"EPSG:4326":   {-180, -90, 180, 90},
+	"CRS:84":      {-180, -90, 180, 90},
+	"EPSG:4283":   {-180, -90, 180, 90},
+	"EPSG:4269":   {-180, -90, 180, 90},
+	"EPSG:4258":   {-180, -90, 180, 90},
+	"EPSG:3857":   {-webMercatorExtent, -webMercatorExtent, webMercatorExtent, webMercatorExtent},
+	"EPSG:900913": {-webMercatorExtent, -webMercatorExtent, webMercatorExtent, webMercatorExtent},
+	"EPSG:3785":   {-webMercatorExtent, -webMercatorExtent, webMercatorExtent, webMercatorExtent},
+	"EPSG:102100": {-webMercatorExtent, -webMercatorExtent, webMercatorExtent, webMercatorExtent},
+}
+
+// GetWorldExtent returns the extent of the world in a CRS that wraps
+// around the antimeridian
+func GetWorldExtent(crs string) ([]float64, bool) {
+	extent, found := worldExtents[strings.ToUpper(strings.TrimSpace(crs))]
+	return extent, found
+}
+
+// BBoxPart is the part of a bbox on one side of the antimeridian and the
+// columns of the bbox it covers
+type BBoxPart struct {
+	BBox  []float64
+	OffX  int
+	Width int
+}
+
+// UnwrapAntimeridian returns a bbox that crosses the antimeridian with
+// its max x beyond it if its min x is greater than its max x
+func UnwrapAntimeridian(crs string, bbox []float64) []float64 {
+	extent, found := GetWorldExtent(crs)
+	if !found || len(bbox) < 4 || bbox[0] <= bbox[2] {
+		return bbox
+	}
+
+	box := make([]float64, len(bbox))
+	copy(box, bbox)
+	box[2] += extent[2] - extent[0]
+	return box
+}
+
+// SplitAntimeridian splits a bbox of width pixels that crosses or lies
+// beyond the antimeridian into parts within the world. The parts are
+// aligned with the pixels of the bbox. It returns nil if the bbox is
+// within the world, is wider than the world or the CRS doesn't wrap.
+func SplitAntimeridian(crs string, bbox []float64, width int) []BBoxPart {
+	extent, found := GetWorldExtent(crs)
+	if !found || len(bbox) < 4 || width <= 0 {
+		return nil
+	}
+
+	box := UnwrapAntimeridian(crs, bbox)
+	worldWidth := extent[2] - extent[0]
+	xMin, xMax := box[0], box[2]
+	if xMin >= extent[0] && xMax <= extent[2] {
+		return nil
+	}
+	if xMax-xMin > worldWidth || xMax <= xMin {
+		return nil
+	}
+
+	shift := math.Floor((xMin-extent[0])/worldWidth) * worldWidth
+	xMin -= shift
+	xMax -= shift
+	if xMax <= extent[2] {
+		return []BBoxPart{{BBox: []float64{xMin, box[1], xMax, box[3]}, OffX: 0, Width: width}}
+	}
+
+	xRes := (xMax - xMin) / float64(width)
+	splitX := int(math.Round((extent[2] - xMin) / xRes))
+	if splitX <= 0 {
+		return []BBoxPart{{BBox: []float64{xMin - worldWidth, box[1], xMax - worldWidth, box[3]}, OffX: 0, Width: width}}
+	}
+	if splitX >= width {
+		return []BBoxPart{{BBox: []float64{xMin, box[1], xMax, box[3]}, OffX: 0, Width: width}}
+	}
+
+	xSplit := xMin + float64(splitX)*xRes
+	return []BBoxPart{
+		{BBox: []float64{xMin, box[1], xSplit, box[3]}, OffX: 0, Width: splitX},
+		{BBox: []float64{xSplit - worldWidth, box[1], xMax - worldWidth, box[3]}, OffX: splitX, Width: width - splitX},
+	}
+}
+
+// DensifyBBox returns the coordinates of n points along each edge of a
+// bbox
+func DensifyBBox(bbox []float64, n int) ([]float64, []float64) {
+	if n < 2 {
+		n = 2
+	}
+
+	var xs, ys []float64
+	for i := 0; i < n; i++ {
+		t := float64(i) / float64(n-1)
+		x := bbox[0] + t*(bbox[2]-bbox[0])
+		y := bbox[1] + t*(bbox[3]-bbox[1])
+
+		xs = append(xs, x, x)
+		ys = append(ys, bbox[1], bbox[3])
+		if i > 0 && i < n-1 {
+			xs = append(xs, bbox[0], bbox[2])
+			ys = append(ys, y, y)
+		}
+	}
+	return xs, ys
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSplitAntimeridian(t *testing.T) {
+	if parts := SplitAntimeridian("EPSG:4326", []float64{100, -50, 170, 10}, 256); parts != nil {
+		t.Errorf("expected no parts within the world, got %v", parts)
+	}
+	if parts := SplitAntimeridian("EPSG:3577", []float64{100, -50, 200, 10}, 256); parts != nil {
+		t.Errorf("expected no parts of a CRS that doesn't wrap, got %v", parts)
+	}
+
+	bbox := UnwrapAntimeridian("EPSG:4326", []float64{170, -50, -170, 10})
+	if bbox[0] != 170 || bbox[2] != 190 {
+		t.Errorf("unexpected unwrapped bbox: %v", bbox)
+	}
+
+	parts := SplitAntimeridian("EPSG:4326", []float64{170, -50, -170, 10}, 200)
+	if len(parts) != 2 {
+		t.Fatalf("expected 2 parts, got %v", parts)
+	}
+	if parts[0].OffX != 0 || parts[0].Width != 100 || parts[0].BBox[0] != 170 || parts[0].BBox[2] != 180 {
+		t.Errorf("unexpected west part: %+v", parts[0])
+	}
+	if parts[1].OffX != 100 || parts[1].Width != 100 || parts[1].BBox[0] != -180 || parts[1].BBox[2] != -170 {
+		t.Errorf("unexpected east part: %+v", parts[1])
+	}
+
+	parts = SplitAntimeridian("EPSG:3857", []float64{2.5e7, 0, 3e7, 1e6}, 100)
+	if len(parts) != 1 || parts[0].Width != 100 || math.Abs(parts[0].BBox[0]-(2.5e7-2*webMercatorExtent)) > 1e-6 {
+		t.Errorf("expected a single shifted part, got %v", parts)
+	}
+}
+
+func TestDensifyBBox(t *testing.T) {
+	xs, ys := DensifyBBox([]float64{0, 0, 10, 20}, 3)
+	if len(xs) != 8 || len(ys) != 8 {
+		t.Fatalf("expected 8 points, got %d", len(xs))
+	}
+	for i := range xs {
+		onEdge := xs[i] == 0 || xs[i] == 10 || ys[i] == 0 || ys[i] == 20
+		if !onEdge {
+			t.Errorf("point (%v, %v) isn't on an edge", xs[i], ys[i])
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Float64Expr is a band expression evaluated in float64 so that bands
+// of 32 bit integers and float64 keep their precision, which the
+// float32 evaluation of band expressions loses. It supports the
+// numeric, comparison, logical, bitwise and ternary operators of band
+// expressions but no functions or strings.
+//
+// As in the float32 evaluation, "c ? a" is a where c is true and
+// ":" or "??" replace the pixels without a value by their right
+// operand. Pixels without a value are NaN.
+type Float64Expr struct {
+	root expr64Node
+}
+
+// expr64Value is either an array of pixel values or a scalar if arr is
+// nil. Booleans are 1 or 0.
+type expr64Value struct {
+	arr    []float64
+	scalar float64
+}
+
+func (v expr64Value) at(i int) float64 {
+	if v.arr == nil {
+		return v.scalar
+	}
+	return v.arr[i]
+}
+
+type expr64Node func(vars map[string][]float64, n int) (expr64Value, error)
+
+// NewFloat64Expr parses a band expression without its name
+func NewFloat64Expr(expr string) (*Float64Expr, error) {
+	p := &expr64Parser{}
+	if err := p.tokenize(expr); err != nil {
+		return nil, err
+	}
+	root, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token '%s' in expression: %s", p.tokens[p.pos].text, expr)
+	}
+	return &Float64Expr{root: root}, nil
+}
+
+// Evaluate evaluates the expression over variables of n pixels. The
+// result never shares the arrays of the variables.
+func (e *Float64Expr) Evaluate(vars map[string][]float64, n int) ([]float64, error) {
+	res, err := e.root(vars, n)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = res.at(i)
+	}
+	return out, nil
+}
+
+type expr64Token struct {
+	text     string
+	isNumber bool
+	isVar    bool
+	value    float64
+}
+
+type expr64Parser struct {
+	tokens []expr64Token
+	pos    int
+}
+
+// expr64Operators are the operators of band expressions, longest first
+var expr64Operators = []string{"**", "<<", ">>", "&&", "||", "==", "!=", ">=", "<=", "??",
+	"+", "-", "*", "/", "%", "&", "|", "^", ">", "<", "!", "~", "?", ":", "(", ")"}
+
+func isExpr64VarRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+}
+
+func (p *expr64Parser) tokenize(expr string) error {
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || unicode.IsLetter(runes[j]) ||
+				((runes[j] == '-' || runes[j] == '+') && (runes[j-1] == 'e' || runes[j-1] == 'E') && !strings.HasPrefix(string(runes[i:j]), "0x"))) {
+				j++
+			}
+			text := string(runes[i:j])
+			var value float64
+			if strings.HasPrefix(text, "0x") {
+				v, err := strconv.ParseUint(text[2:], 16, 64)
+				if err != nil {
+					return fmt.Errorf("invalid number: %s", text)
+				}
+				value = float64(v)
+			} else {
+				v, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					return fmt.Errorf("invalid number: %s", text)
+				}
+				value = v
+			}
+			p.tokens = append(p.tokens, expr64Token{text: text, isNumber: true, value: value})
+			i = j
+
+		case r == '[':
+			j := i + 1
+			for j < len(runes) && runes[j] != ']' {
+				j++
+			}
+			if j >= len(runes) {
+				return fmt.Errorf("unclosed parameter bracket: %s", expr)
+			}
+			p.tokens = append(p.tokens, expr64Token{text: string(runes[i+1 : j]), isVar: true})
+			i = j + 1
+
+		case isExpr64VarRune(r):
+			j := i
+			for j < len(runes) && isExpr64VarRune(runes[j]) {
+				j++
+			}
+			text := string(runes[i:j])
+			switch text {
+			case "true":
+				p.tokens = append(p.tokens, expr64Token{text: text, isNumber: true, value: 1})
+			case "false":
+				p.tokens = append(p.tokens, expr64Token{text: text, isNumber: true})
+			default:
+				if j < len(runes) && runes[j] == '(' {
+					return fmt.Errorf("functions are not supported: %s", text)
+				}
+				p.tokens = append(p.tokens, expr64Token{text: text, isVar: true})
+			}
+			i = j
+
+		default:
+			found := false
+			for _, op := range expr64Operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					p.tokens = append(p.tokens, expr64Token{text: op})
+					i += len([]rune(op))
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("unsupported character '%c' in expression: %s", r, expr)
+			}
+		}
+	}
+	return nil
+}
+
+// accept consumes the next token if it is one of ops
+func (p *expr64Parser) accept(ops ...string) (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	tok := p.tokens[p.pos]
+	if tok.isNumber || tok.isVar {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func boolFloat64(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// expr64BinaryOps are the binary operators by precedence, lowest
+// first. Operators of the same precedence are left associative.
+var expr64BinaryOps = []map[string]func(x, y float64) float64{
+	{
+		"?": func(x, y float64) float64 {
+			if x != 0 && !math.IsNaN(x) {
+				return y
+			}
+			return math.NaN()
+		},
+		":":  expr64Else,
+		"??": expr64Else,
+	},
+	{"||": func(x, y float64) float64 { return boolFloat64(x != 0 || y != 0) }},
+	{"&&": func(x, y float64) float64 { return boolFloat64(x != 0 && y != 0) }},
+	{
+		"==": func(x, y float64) float64 { return boolFloat64(x == y) },
+		"!=": func(x, y float64) float64 { return boolFloat64(x != y) },
+		">":  func(x, y float64) float64 { return boolFloat64(x > y) },
+		">=": func(x, y float64) float64 { return boolFloat64(x >= y) },
+		"<":  func(x, y float64) float64 { return boolFloat64(x < y) },
+		"<=": func(x, y float64) float64 { return boolFloat64(x <= y) },
+	},
+	{
+		"&": func(x, y float64) float64 { return float64(int64(x) & int64(y)) },
+		"|": func(x, y float64) float64 { return float64(int64(x) | int64(y)) },
+		"^": func(x, y float64) float64 { return float64(int64(x) ^ int64(y)) },
+	},
+	{
+		"<<": func(x, y float64) float64 { return float64(int64(x) << uint64(y)) },
+		">>": func(x, y float64) float64 { return float64(int64(x) >> uint64(y)) },
+	},
+	{
+		"+": func(x, y float64) float64 { return x + y },
+		"-": func(x, y float64) float64 { return x - y },
+	},
+	{
+		"*": func(x, y float64) float64 { return x * y },
+		"/": func(x, y float64) float64 { return x / y },
+		"%": math.Mod,
+	},
+	{"**": math.Pow},
+}
+
+func expr64Else(x, y float64) float64 {
+	if math.IsNaN(x) {
+		return y
+	}
+	return x
+}
+
+func (p *expr64Parser) parseTernary() (expr64Node, error) {
+	return p.parseBinary(0)
+}
+
+func (p *expr64Parser) parseBinary(level int) (expr64Node, error) {
+	if level >= len(expr64BinaryOps) {
+		return p.parsePrefix()
+	}
+
+	ops := expr64BinaryOps[level]
+	var names []string
+	for name := range ops {
+		names = append(names, name)
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		name, found := p.accept(names...)
+		if !found {
+			return left, nil
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = makeExpr64Binary(left, right, ops[name])
+	}
+}
+
+func makeExpr64Binary(left, right expr64Node, op func(x, y float64) float64) expr64Node {
+	return func(vars map[string][]float64, n int) (expr64Value, error) {
+		l, err := left(vars, n)
+		if err != nil {
+			return expr64Value{}, err
+		}
+		r, err := right(vars, n)
+		if err != nil {
+			return expr64Value{}, err
+		}
+		if l.arr == nil && r.arr == nil {
+			return expr64Value{scalar: op(l.scalar, r.scalar)}, nil
+		}
+		out := make([]float64, n)
+		for i := range out {
+			out[i] = op(l.at(i), r.at(i))
+		}
+		return expr64Value{arr: out}, nil
+	}
+}
+
+func (p *expr64Parser) parsePrefix() (expr64Node, error) {
+	name, found := p.accept("-", "!", "~")
+	if !found {
+		return p.parsePrimary()
+	}
+	operand, err := p.parsePrefix()
+	if err != nil {
+		return nil, err
+	}
+
+	var op func(x float64) float64
+	switch name {
+	case "-":
+		op = func(x float64) float64 { return -x }
+	case "!":
+		op = func(x float64) float64 { return boolFloat64(x == 0) }
+	default:
+		op = func(x float64) float64 { return float64(^int64(x)) }
+	}
+	return func(vars map[string][]float64, n int) (expr64Value, error) {
+		v, err := operand(vars, n)
+		if err != nil {
+			return expr64Value{}, err
+		}
+		if v.arr == nil {
+			return expr64Value{scalar: op(v.scalar)}, nil
+		}
+		out := make([]float64, n)
+		for i, x := range v.arr {
+			out[i] = op(x)
+		}
+		return expr64Value{arr: out}, nil
+	}, nil
+}
+
+func (p *expr64Parser) parsePrimary() (expr64Node, error) {
+	if _, found := p.accept("("); found {
+		node, err := p.parseTernary()
+		if err != nil {
+			return nil, err
+		}
+		if _, found := p.accept(")"); !found {
+			return nil, fmt.Errorf("unbalanced parenthesis")
+		}
+		return node, nil
+	}
+
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	switch {
+	case tok.isNumber:
+		value := tok.value
+		return func(vars map[string][]float64, n int) (expr64Value, error) {
+			return expr64Value{scalar: value}, nil
+		}, nil
+	case tok.isVar:
+		name := tok.text
+		return func(vars map[string][]float64, n int) (expr64Value, error) {
+			data, found := vars[name]
+			if !found {
+				return expr64Value{}, fmt.Errorf("no parameter '%s' found", name)
+			}
+			if len(data) != n {
+				return expr64Value{}, fmt.Errorf("parameter '%s' has %d values rather than %d", name, len(data), n)
+			}
+			return expr64Value{arr: data}, nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unexpected token '%s'", tok.text)
+	}
+}
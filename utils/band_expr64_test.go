@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloat64Expr(t *testing.T) {
+	vars := map[string][]float64{
+		"a":      {16777217, 2, 3},
+		"b":      {1, 0, math.NaN()},
+		"red.b1": {4, 5, 6},
+	}
+
+	testCases := []struct {
+		expr     string
+		expected []float64
+	}{
+		{"a + 1", []float64{16777218, 3, 4}},
+		{"1 + 2 * a ** 2", []float64{1 + 2*16777217.0*16777217, 9, 19}},
+		{"(1 + 2) * a", []float64{3 * 16777217, 6, 9}},
+		{"-a + 0x10", []float64{16 - 16777217, 14, 13}},
+		{"a & 3 | 4", []float64{5, 6, 7}},
+		{"1 << 2 + 1", []float64{8, 8, 8}},
+		{"a > 2 && b == 1", []float64{1, 0, 0}},
+		{"!(a > 2) || b == 1", []float64{1, 1, 0}},
+		{"a > 2 ? a : -1", []float64{16777217, -1, 3}},
+		{"b ?? 7", []float64{1, 0, 7}},
+		{"[red.b1] * 1e-1", []float64{0.4, 0.5, 0.6000000000000001}},
+		{"~a", []float64{-16777218, -3, -4}},
+	}
+
+	for _, tc := range testCases {
+		expr, err := NewFloat64Expr(tc.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		res, err := expr.Evaluate(vars, 3)
+		if err != nil {
+			t.Errorf("%s: %v", tc.expr, err)
+			continue
+		}
+		for i := range res {
+			if res[i] != tc.expected[i] {
+				t.Errorf("%s: expected %v, got %v", tc.expr, tc.expected, res)
+				break
+			}
+		}
+	}
+
+	res, err := mustFloat64Expr(t, "a > 2 ? a").Evaluate(vars, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res[0] != 16777217 || !math.IsNaN(res[1]) || res[2] != 3 {
+		t.Errorf("expected NaN where the condition is false, got %v", res)
+	}
+
+	for _, expr := range []string{"max(a, b)", "a +", "(a + b", "a $ b", "'a'"} {
+		if _, err := NewFloat64Expr(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+	if _, err := mustFloat64Expr(t, "a + c").Evaluate(vars, 3); err == nil {
+		t.Errorf("expected an error for an unknown parameter")
+	}
+}
+
+func mustFloat64Expr(t *testing.T, expr string) *Float64Expr {
+	e, err := NewFloat64Expr(expr)
+	if err != nil {
+		t.Fatalf("%s: %v", expr, err)
+	}
+	return e
+}
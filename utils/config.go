@@ -21,9 +21,7 @@ import (
 
 	goeval "github.com/edisonguo/govaluate"
 	"github.com/edisonguo/jet"
-	pb "github.com/nci/gsky/worker/gdalservice"
-	"golang.org/x/net/context"
-	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
 )
 
 var EtcDir = "."
@@ -66,6 +64,8 @@ const DefaultWmsMaxBandVariables = 6
 const DefaultWmsMaxBandTokens = 75
 const DefaultWmsMaxBandExpressions = 3
 
+const DefaultMaxFeatureInfoTimeSeries = 500
+
 const DefaultWcsMaxBandVariables = 10
 const DefaultWcsMaxBandTokens = 300
 const DefaultWcsMaxBandExpressions = 10
@@ -74,13 +74,20 @@ type ServiceConfig struct {
 	OWSHostname       string `json:"ows_hostname"`
 	OWSProtocol       string `json:"ows_protocol"`
 	NameSpace         string
-	MASAddress        string   `json:"mas_address"`
-	WorkerNodes       []string `json:"worker_nodes"`
-	OWSClusterNodes   []string `json:"ows_cluster_nodes"`
-	TempDir           string   `json:"temp_dir"`
-	MaxGrpcBufferSize int      `json:"max_grpc_buffer_size"`
-	EnableAutoLayers  bool     `json:"enable_auto_layers"`
-	OWSCacheGPath     string   `json:"ows_cache_gpath"`
+	MASAddress        string            `json:"mas_address"`
+	WorkerNodes       []string          `json:"worker_nodes"`
+	OWSClusterNodes   []string          `json:"ows_cluster_nodes"`
+	TempDir           string            `json:"temp_dir"`
+	MaxGrpcBufferSize int               `json:"max_grpc_buffer_size"`
+	EnableAutoLayers  bool              `json:"enable_auto_layers"`
+	OWSCacheGPath     string            `json:"ows_cache_gpath"`
+	GrpcSecurity      *GrpcSecurity     `json:"grpc_security"`
+	GrpcCompression   string            `json:"grpc_compression"`
+	CRSDefinitions    map[string]string `json:"crs_definitions"`
+
+	// Maximum number of dates of the GetFeatureInfo time series of any
+	// layer, including those that allow all dates
+	MaxFeatureInfoTimeSeries int `json:"max_feature_info_time_series"`
 }
 
 type Mask struct {
@@ -112,6 +119,10 @@ type BandExpressions struct {
 	VarList     []string
 	ExprNames   []string
 	ExprVarRef  [][]string
+
+	// Float64Exprs evaluate Expressions in float64. Entries are nil
+	// for expressions which only the float32 evaluation supports.
+	Float64Exprs []*Float64Expr
 }
 
 type LayerAxis struct {
@@ -135,6 +146,7 @@ type Layer struct {
 	DataURL                      string   `json:"data_url"`
 	Overviews                    []Layer  `json:"overviews"`
 	InputLayers                  []Layer  `json:"input_layers"`
+	Alias                        string   `json:"alias"`
 	DisableServices              []string `json:"disable_services"`
 	DisableServicesMap           map[string]struct{}
 	DataSource                   string `json:"data_source"`
@@ -182,6 +194,7 @@ type Layer struct {
 	FeatureInfoMaxAvailableDates int        `json:"feature_info_max_dates"`
 	FeatureInfoMaxDataLinks      int        `json:"feature_info_max_data_links"`
 	FeatureInfoDataLinkUrl       string     `json:"feature_info_data_link_url"`
+	FeatureInfoMaxTimeSeries     int        `json:"feature_info_max_time_series"`
 	FeatureInfoBands             []string   `json:"feature_info_bands"`
 	FeatureInfoExpressions       *BandExpressions
 	NoDataLegendPath             string                            `json:"nodata_legend_path"`
@@ -206,6 +219,9 @@ type Layer struct {
 	RasterYSize                  float64                           `json:"raster_y_size"`
 	WmsBandExpressionCriteria    *BandExpressionComplexityCriteria `json:"wms_band_expr_criteria"`
 	WcsBandExpressionCriteria    *BandExpressionComplexityCriteria `json:"wcs_band_expr_criteria"`
+	Anomaly                      *AnomalyConfig                    `json:"anomaly"`
+	Unpack                       bool                              `json:"unpack"`
+	SupportedCRS                 []string                          `json:"supported_crs"`
 }
 
 // Process contains all the details that a WPS needs
@@ -646,8 +662,10 @@ func LoadConfigFromMAS(masAddress, namespace string, rootConfig *Config, verbose
 
 	config := &Config{
 		ServiceConfig: ServiceConfig{
-			MASAddress:  rootConfig.ServiceConfig.MASAddress,
-			WorkerNodes: rootConfig.ServiceConfig.WorkerNodes,
+			MASAddress:      rootConfig.ServiceConfig.MASAddress,
+			WorkerNodes:     rootConfig.ServiceConfig.WorkerNodes,
+			GrpcSecurity:    rootConfig.ServiceConfig.GrpcSecurity,
+			GrpcCompression: rootConfig.ServiceConfig.GrpcCompression,
 		},
 	}
 
@@ -879,6 +897,8 @@ func (config *Config) copyClusterInfo(conf *Config) {
 	}
 	if len(config.ServiceConfig.WorkerNodes) == 0 {
 		config.ServiceConfig.WorkerNodes = conf.ServiceConfig.WorkerNodes
+		config.ServiceConfig.GrpcSecurity = conf.ServiceConfig.GrpcSecurity
+		config.ServiceConfig.GrpcCompression = conf.ServiceConfig.GrpcCompression
 	}
 }
 
@@ -944,7 +964,24 @@ func (config *Config) postprocessConfig(ns string) error {
 			sort.Slice(config.Layers[i].Overviews, func(m, n int) bool { return config.Layers[m].ZoomLimit < config.Layers[n].ZoomLimit })
 		}
 		config.Layers[i].NameSpace = ns
+		if err := checkInputLayerAliases(config.Layers[i].InputLayers); err != nil {
+			return fmt.Errorf("%s, %s: %v", config.Layers[i].Name, ns, err)
+		}
+		if err := checkSupportedCRS(config.Layers[i].SupportedCRS); err != nil {
+			return fmt.Errorf("%s, %s: %v", config.Layers[i].Name, ns, err)
+		}
+		if config.Layers[i].Anomaly != nil {
+			if len(config.Layers[i].InputLayers) != 1 {
+				return fmt.Errorf("%s, %s: anomaly layer must have exactly one input layer", config.Layers[i].Name, ns)
+			}
+			if err := config.Layers[i].Anomaly.Validate(); err != nil {
+				return fmt.Errorf("%s, %s: anomaly: %v", config.Layers[i].Name, ns, err)
+			}
+		}
 		for j := range config.Layers[i].Styles {
+			if err := checkInputLayerAliases(config.Layers[i].Styles[j].InputLayers); err != nil {
+				return fmt.Errorf("%s, %s, style %s: %v", config.Layers[i].Name, ns, config.Layers[i].Styles[j].Name, err)
+			}
 			config.Layers[i].Styles[j].OWSHostname = config.Layers[i].OWSHostname
 			config.Layers[i].Styles[j].NameSpace = config.Layers[i].NameSpace
 			if len(config.Layers[i].Styles[j].DataSource) == 0 {
@@ -986,6 +1023,16 @@ func (config *Config) postprocessConfig(ns string) error {
 				}
 			}
 
+			if config.Layers[i].Styles[j].Anomaly == nil {
+				config.Layers[i].Styles[j].Anomaly = config.Layers[i].Anomaly
+			} else if err := config.Layers[i].Styles[j].Anomaly.Validate(); err != nil {
+				return fmt.Errorf("Layer %v, style %v, anomaly: %v", config.Layers[i].Name, config.Layers[i].Styles[j].Name, err)
+			}
+
+			if len(config.Layers[i].Styles[j].SupportedCRS) == 0 && len(config.Layers[i].SupportedCRS) > 0 {
+				config.Layers[i].Styles[j].SupportedCRS = config.Layers[i].SupportedCRS
+			}
+
 			if len(config.Layers[i].Styles[j].DisableServices) == 0 && len(config.Layers[i].DisableServices) > 0 {
 				config.Layers[i].Styles[j].DisableServices = config.Layers[i].DisableServices
 			}
@@ -1007,6 +1054,25 @@ func (config *Config) postprocessConfig(ns string) error {
 	return nil
 }
 
+// checkInputLayerAliases checks that the aliases of input layers are
+// unique variable names of band expressions
+func checkInputLayerAliases(inputLayers []Layer) error {
+	aliases := make(map[string]struct{})
+	for k, refLayer := range inputLayers {
+		if len(refLayer.Alias) == 0 {
+			continue
+		}
+		if !varNameRegex.MatchString(refLayer.Alias) || strings.HasPrefix(refLayer.Alias, "fuse") {
+			return fmt.Errorf("input_layers[%d] has invalid alias: %s", k, refLayer.Alias)
+		}
+		if _, found := aliases[refLayer.Alias]; found {
+			return fmt.Errorf("input_layers[%d] has duplicate alias: %s", k, refLayer.Alias)
+		}
+		aliases[refLayer.Alias] = struct{}{}
+	}
+	return nil
+}
+
 func hasBlendedService(layer *Layer) bool {
 	if len(layer.InputLayers) > 0 && len(strings.TrimSpace(layer.DataSource)) == 0 {
 		return true
@@ -1069,7 +1135,17 @@ func (config *Config) processFusionTimestamps(i int, configMap map[string]*Confi
 			}
 		}
 
+		hasAlias := false
 		for _, refLayer := range inputLayers {
+			// Aliased input layers other than the first one are
+			// resolved against the dates of the first one
+			if len(refLayer.Alias) > 0 {
+				if hasAlias {
+					continue
+				}
+				hasAlias = true
+			}
+
 			layerIdx, _, refNameSpace, err := config.getFusionRefLayer(i, &refLayer, configMap)
 			if err != nil {
 				return err
@@ -1224,6 +1300,7 @@ func (config *Config) Copy(r *http.Request) *Config {
 			Dates:              layer.Dates,
 			EffectiveStartDate: layer.EffectiveStartDate,
 			EffectiveEndDate:   layer.EffectiveEndDate,
+			SupportedCRS:       layer.SupportedCRS,
 		}
 		if !hasOWSHostname {
 			newConf.Layers[i].OWSHostname = r.Host
@@ -1476,6 +1553,9 @@ func ParseBandExpressions(bands []string) (*BandExpressions, error) {
 		}
 		bandExpr.Expressions = append(bandExpr.Expressions, expr)
 
+		expr64, _ := NewFloat64Expr(band)
+		bandExpr.Float64Exprs = append(bandExpr.Float64Exprs, expr64)
+
 		bandExpr.ExprVarRef = append(bandExpr.ExprVarRef, []string{})
 		bandVarFound := make(map[string]struct{})
 		for _, token := range expr.Tokens() {
@@ -1509,6 +1589,7 @@ func ParseBandExpressions(bands []string) (*BandExpressions, error) {
 
 	if !hasExprAll {
 		bandExpr.Expressions = nil
+		bandExpr.Float64Exprs = nil
 	}
 	return bandExpr, nil
 }
@@ -1574,68 +1655,9 @@ func LoadConfigFileTemplate(configFile string) ([]byte, error) {
 }
 
 func getGrpcPoolSize(config *Config, verbose bool) int {
-	opts := []grpc.DialOption{
-		grpc.WithInsecure(),
-		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(DefaultRecvMsgSize)),
-	}
-
-	workerNodes := config.ServiceConfig.WorkerNodes
-	var connPool []*grpc.ClientConn
-	var effectiveWorkerNodes []string
-	for i := 0; i < len(workerNodes); i++ {
-		conn, err := grpc.Dial(workerNodes[i], opts...)
-		if err != nil {
-			log.Printf("gRPC connection problem: %v", err)
-			continue
-		}
-		defer conn.Close()
-
-		connPool = append(connPool, conn)
-		effectiveWorkerNodes = append(effectiveWorkerNodes, workerNodes[i])
-	}
-
-	var wg sync.WaitGroup
-	wg.Add(len(connPool))
-
-	concLimit := make(chan bool, DefaultConcGrpcWorkerQuery)
-	workerPoolSizes := make([]int, len(connPool))
-	for i := 0; i < len(connPool); i++ {
-		concLimit <- true
-		go func(i int) {
-			defer wg.Done()
-			defer func() { <-concLimit }()
-			c := pb.NewGDALClient(connPool[i])
-			req := &pb.GeoRPCGranule{Operation: "worker_info"}
-
-			ctx := context.Background()
-			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
-			r, err := c.Process(ctx, req)
-			cancel()
-			if err == nil {
-				workerPoolSizes[i] = int(r.WorkerInfo.PoolSize)
-			} else {
-				if verbose {
-					log.Printf("Failed to query gRPC worker %s, %v", effectiveWorkerNodes[i], err)
-				}
-			}
-		}(i)
-	}
-	wg.Wait()
-
-	avgPoolSize := 0.0
-	cnt := 0.0
-	for _, ps := range workerPoolSizes {
-		if ps > 0 {
-			avgPoolSize += float64(ps)
-			cnt++
-		}
-	}
-
-	if cnt >= 1 {
-		avgPoolSize /= cnt
-	}
-
-	return int(avgPoolSize + 0.5)
+	workerMgr := GetWorkerManager()
+	config.ServiceConfig.WorkerNodes = workerMgr.RegisterWorkers(config.ServiceConfig.WorkerNodes, config.ServiceConfig.GrpcSecurity, config.ServiceConfig.GrpcCompression)
+	return workerMgr.AvgPoolSize(config.ServiceConfig.WorkerNodes, verbose)
 }
 
 func addBandMathVariableConstraints(config *Config, layer *Layer, criteria *BandExpressionComplexityCriteria) {
@@ -1707,6 +1729,22 @@ func (config *Config) LoadConfigString(cfg []byte, verbose bool) error {
 
 	config.ServiceConfig.MaxGrpcBufferSize = config.ServiceConfig.MaxGrpcBufferSize * 1024 * 1024
 
+	if config.ServiceConfig.MaxFeatureInfoTimeSeries <= 0 {
+		config.ServiceConfig.MaxFeatureInfoTimeSeries = DefaultMaxFeatureInfoTimeSeries
+	}
+
+	if len(config.ServiceConfig.GrpcCompression) > 0 && encoding.GetCompressor(config.ServiceConfig.GrpcCompression) == nil {
+		return fmt.Errorf("Unsupported grpc_compression: %s", config.ServiceConfig.GrpcCompression)
+	}
+
+	if err := config.ServiceConfig.GrpcSecurity.Check(); err != nil {
+		return fmt.Errorf("grpc_security: %v", err)
+	}
+
+	if err := RegisterCRSDefinitions(config.ServiceConfig.CRSDefinitions); err != nil {
+		return fmt.Errorf("crs_definitions: %v", err)
+	}
+
 	grpcPoolSize := getGrpcPoolSize(config, verbose)
 	if verbose {
 		log.Printf("average grpc worker pool size: %d", grpcPoolSize)
@@ -1881,6 +1919,12 @@ func (config *Config) LoadConfigString(cfg []byte, verbose bool) error {
 			}
 			config.Processes[i].DataSources[ids].RGBExpressions = bandExpr
 
+			if ds.Anomaly != nil {
+				if err := config.Processes[i].DataSources[ids].Anomaly.Validate(); err != nil {
+					return fmt.Errorf("Process %v, data source %v, anomaly: %v", proc.Identifier, ids, err)
+				}
+			}
+
 			if ds.Mask != nil {
 				maskBands := []string{ds.Mask.ID}
 				bandExpr, err := ParseBandExpressions(maskBands)
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	crsURNRegexp = regexp.MustCompile(`^(?i)urn:ogc:def:crs:([A-Z]+):[0-9.]*:([A-Z0-9]+)$`)
+	crsURLRegexp = regexp.MustCompile(`^(?i)https?://www\.opengis\.net/def/crs/([A-Z]+)/[0-9.]+/([A-Z0-9]+)$`)
+)
+
+// crsDefinitions maps server-side CRS aliases such as GSKY:1 to their WKT
+// or PROJ definitions
+var crsDefinitions sync.Map
+
+// NormaliseCRS converts the OGC URN and URL forms of a CRS to AUTH:CODE in
+// upper case, e.g. urn:ogc:def:crs:EPSG::3031 to EPSG:3031 and
+// urn:ogc:def:crs:OGC:1.3:CRS84 to CRS:84
+func NormaliseCRS(crs string) string {
+	crs = strings.TrimSpace(crs)
+	for _, re := range []*regexp.Regexp{crsURNRegexp, crsURLRegexp} {
+		if m := re.FindStringSubmatch(crs); m != nil {
+			crs = m[1] + ":" + m[2]
+			break
+		}
+	}
+
+	crs = strings.ToUpper(crs)
+	if crs == "OGC:CRS84" {
+		crs = "CRS:84"
+	}
+	return crs
+}
+
+// RegisterCRSDefinitions registers WKT or PROJ definitions of CRSs under
+// their aliases. Definitions are checked by GDAL.
+func RegisterCRSDefinitions(defs map[string]string) error {
+	for alias, def := range defs {
+		alias = NormaliseCRS(alias)
+		if !strings.Contains(alias, ":") {
+			return fmt.Errorf("invalid CRS alias '%s', expected AUTH:CODE", alias)
+		}
+		if err := checkCRSDefinition(def); err != nil {
+			return fmt.Errorf("CRS %s: %v", alias, err)
+		}
+		crsDefinitions.Store(alias, def)
+	}
+	return nil
+}
+
+// IsCRSAlias returns whether a CRS is a registered alias of a definition
+func IsCRSAlias(crs string) bool {
+	_, found := crsDefinitions.Load(NormaliseCRS(crs))
+	return found
+}
+
+// ResolveCRS returns the definition of a CRS alias or the CRS itself if it
+// isn't an alias
+func ResolveCRS(crs string) string {
+	if def, found := crsDefinitions.Load(NormaliseCRS(crs)); found {
+		return def.(string)
+	}
+	return crs
+}
+
+// checkSupportedCRS checks that the supported CRSs of a layer are either
+// aliases or CRSs known to GDAL
+func checkSupportedCRS(supportedCRS []string) error {
+	for _, crs := range supportedCRS {
+		if IsCRSAlias(crs) {
+			continue
+		}
+		if err := checkCRSDefinition(NormaliseCRS(crs)); err != nil {
+			return fmt.Errorf("supported_crs: unknown CRS %s", crs)
+		}
+	}
+	return nil
+}
+
+// CheckLayerCRS returns whether a layer supports a CRS. Layers that don't
+// declare their supported CRSs support all CRSs known to GDAL.
+func CheckLayerCRS(layer *Layer, crs string) bool {
+	if len(layer.SupportedCRS) == 0 {
+		return true
+	}
+
+	crs = NormaliseCRS(crs)
+	for _, supported := range layer.SupportedCRS {
+		if NormaliseCRS(supported) == crs {
+			return true
+		}
+	}
+	return false
+}
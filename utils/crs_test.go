@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestNormaliseCRS(t *testing.T) {
+	cases := map[string]string{
+		"epsg:3031":                                  "EPSG:3031",
+		"urn:ogc:def:crs:EPSG::3031":                 "EPSG:3031",
+		"urn:ogc:def:crs:OGC:1.3:CRS84":              "CRS:84",
+		"http://www.opengis.net/def/crs/EPSG/0/4326": "EPSG:4326",
+		"gsky:1": "GSKY:1",
+	}
+	for crs, expected := range cases {
+		if got := NormaliseCRS(crs); got != expected {
+			t.Errorf("%s: got %s, expected %s", crs, got, expected)
+		}
+	}
+}
+
+func TestCheckLayerCRS(t *testing.T) {
+	crsDefinitions.Store("GSKY:1", "+proj=lcc +lat_1=-18 +lat_2=-36 +lon_0=134")
+	defer crsDefinitions.Delete("GSKY:1")
+
+	if !IsCRSAlias("gsky:1") || ResolveCRS("GSKY:1") == "GSKY:1" || ResolveCRS("EPSG:3031") != "EPSG:3031" {
+		t.Errorf("unexpected resolution of CRS aliases")
+	}
+
+	layer := &Layer{}
+	if !CheckLayerCRS(layer, "EPSG:3577") {
+		t.Errorf("expected layers without supported CRSs to support any CRS")
+	}
+
+	layer.SupportedCRS = []string{"EPSG:3031", "GSKY:1"}
+	if !CheckLayerCRS(layer, "urn:ogc:def:crs:EPSG::3031") || !CheckLayerCRS(layer, "gsky:1") {
+		t.Errorf("expected supported CRSs to be accepted")
+	}
+	if CheckLayerCRS(layer, "EPSG:4326") {
+		t.Errorf("expected EPSG:4326 to be rejected")
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DapPathExts are the extensions of the URL paths of the DAP metadata
+// and DAP2 data responses of a layer
+var DapPathExts = []string{".dmr", ".dds", ".das", ".dods"}
+
+var dap2ProjRegex = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)((?:\[[^\[\]]*\])*)$`)
+
+// ParseDapPath splits a /ows/<namespace>/<layer>.<ext> path below /ows/
+// into the namespace, the layer and the DAP extension. Layers of the
+// root namespace are /ows/<layer>.<ext>. The extension is empty if the
+// path is not a DAP path.
+func ParseDapPath(urlPath string) (string, string, string) {
+	urlPath = strings.Trim(urlPath, "/")
+	ext := path.Ext(urlPath)
+	isDap := false
+	for _, dapExt := range DapPathExts {
+		if ext == dapExt {
+			isDap = true
+			break
+		}
+	}
+	if !isDap {
+		return "", "", ""
+	}
+
+	urlPath = strings.TrimSuffix(urlPath, ext)
+	namespace, layer := path.Split(urlPath)
+	namespace = strings.Trim(namespace, "/")
+	if len(namespace) == 0 {
+		namespace = "."
+	}
+	return namespace, layer, ext
+}
+
+// ParseDap2ConstraintExpr parses the projections of a DAP2 constraint
+// expression such as time[0],sst[0:1:9][10:2:20][0:359]. Selection
+// clauses are not supported.
+func ParseDap2ConstraintExpr(ce string) ([]*DapProjection, error) {
+	ce, err := url.QueryUnescape(ce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid constraint expression: %v", err)
+	}
+
+	ce = strings.TrimSpace(ce)
+	if strings.Contains(ce, "&") {
+		parts := strings.SplitN(ce, "&", 2)
+		if len(strings.TrimSpace(parts[1])) > 0 {
+			return nil, fmt.Errorf("selection clauses are not supported: %s", parts[1])
+		}
+		ce = strings.TrimSpace(parts[0])
+	}
+
+	var projs []*DapProjection
+	if len(ce) == 0 {
+		return projs, nil
+	}
+
+	for _, projStr := range strings.Split(ce, ",") {
+		projStr = strings.TrimSpace(projStr)
+		matches := dap2ProjRegex.FindStringSubmatch(projStr)
+		if matches == nil {
+			return nil, fmt.Errorf("invalid projection: %s", projStr)
+		}
+
+		proj := &DapProjection{Name: matches[1]}
+		hyperslabs := strings.TrimSuffix(strings.TrimPrefix(matches[2], "["), "]")
+		if len(hyperslabs) > 0 {
+			for _, hs := range strings.Split(hyperslabs, "][") {
+				slice, err := parseDap2Hyperslab(hs)
+				if err != nil {
+					return nil, fmt.Errorf("invalid hyperslab in %s: %v", projStr, err)
+				}
+				proj.Slices = append(proj.Slices, slice)
+			}
+		}
+		projs = append(projs, proj)
+	}
+
+	return projs, nil
+}
+
+func parseDap2Hyperslab(hs string) (*DapSlice, error) {
+	parts := strings.Split(hs, ":")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("too many fields: [%s]", hs)
+	}
+
+	vals := make([]int, len(parts))
+	for i, p := range parts {
+		val, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("invalid index: [%s]", hs)
+		}
+		vals[i] = val
+	}
+
+	switch len(vals) {
+	case 1:
+		return &DapSlice{Start: vals[0], Stride: 1, Stop: vals[0]}, nil
+	case 2:
+		return &DapSlice{Start: vals[0], Stride: 1, Stop: vals[1]}, nil
+	default:
+		return &DapSlice{Start: vals[0], Stride: vals[1], Stop: vals[2]}, nil
+	}
+}
@@ -0,0 +1,55 @@
+package utils
+
+// #include "gdal.h"
+// #cgo pkg-config: gdal
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"unsafe"
+)
+
+// ReadDapBand reads a band of a GeoTIFF as float32 values in row-major
+// order with its nodata values replaced by NaN, which is the
+// _FillValue of the DAP data variables
+func ReadDapBand(dataFile string, band int) ([]float32, int, int, error) {
+	dataFileC := C.CString(dataFile)
+	defer C.free(unsafe.Pointer(dataFileC))
+
+	driverList := []*C.char{C.CString("GTiff"), nil}
+	defer C.free(unsafe.Pointer(driverList[0]))
+
+	hSrcDS := C.GDALOpenEx(dataFileC, C.GDAL_OF_READONLY, &driverList[0], nil, nil)
+	if hSrcDS == nil {
+		return nil, 0, 0, fmt.Errorf("Failed to open data file: %v", dataFile)
+	}
+	defer C.GDALClose(hSrcDS)
+
+	if band < 1 || band > int(C.GDALGetRasterCount(hSrcDS)) {
+		return nil, 0, 0, fmt.Errorf("band %d not found in %v", band, dataFile)
+	}
+	hBand := C.GDALGetRasterBand(hSrcDS, C.int(band))
+
+	width := int(C.GDALGetRasterBandXSize(hBand))
+	height := int(C.GDALGetRasterBandYSize(hBand))
+
+	data := make([]float32, width*height)
+	gerr := C.GDALRasterIO(hBand, C.GF_Read, 0, 0, C.int(width), C.int(height), unsafe.Pointer(&data[0]), C.int(width), C.int(height), C.GDT_Float32, 0, 0)
+	if gerr != 0 {
+		return nil, 0, 0, fmt.Errorf("Error reading raster band: %d", band)
+	}
+
+	var hasNoData C.int
+	noData := float32(C.GDALGetRasterNoDataValue(hBand, &hasNoData))
+	if hasNoData != 0 && !math.IsNaN(float64(noData)) {
+		nan := float32(math.NaN())
+		for i, val := range data {
+			if val == noData {
+				data[i] = nan
+			}
+		}
+	}
+
+	return data, width, height, nil
+}
@@ -95,18 +95,10 @@ func DumpDap4CE(ce *DapConstraints) {
 
 func ParseDap4ConstraintExpr(ceStr string) (*DapConstraints, error) {
 	selection := strings.Split(strings.TrimSpace(ceStr), "|")
-	if len(selection) > 2 {
-		return nil, fmt.Errorf("only a single filter expression is supported")
-	}
 
 	ce := &DapConstraints{}
 	subset := strings.TrimSpace(selection[0])
 
-	var filters string
-	if len(selection) == 2 {
-		filters = strings.TrimSpace(selection[1])
-	}
-
 	var dataset string
 	iDs := -1
 	for i := 0; i < len(subset); i++ {
@@ -133,9 +125,11 @@ func ParseDap4ConstraintExpr(ceStr string) (*DapConstraints, error) {
 		return nil, err
 	}
 
-	err = parseFilters(filters, ce)
-	if err != nil {
-		return nil, err
+	for _, filters := range selection[1:] {
+		err = parseFilters(strings.TrimSpace(filters), ce)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	varLookup := make(map[string]struct{})
@@ -356,7 +350,7 @@ func parseFilters(fltStr string, ce *DapConstraints) error {
 				return err
 			}
 
-			lowerVal := math.SmallestNonzeroFloat64
+			lowerVal := -math.MaxFloat64
 			upperVal := math.MaxFloat64
 
 			if relOps[relOp] == 0 {
@@ -419,12 +413,64 @@ func parseFilters(fltStr string, ce *DapConstraints) error {
 			varParam.ValEnd = &upperVal
 		}
 
-		ce.VarParams = append(ce.VarParams, varParam)
+		merged, err := mergeFilter(ce, varParam)
+		if err != nil {
+			return fmt.Errorf("%v: %v", err, flt)
+		}
+		if !merged {
+			ce.VarParams = append(ce.VarParams, varParam)
+		}
 	}
 
 	return nil
 }
 
+// mergeFilter intersects a filter with the previous filters of the same
+// variable. Filters are merged across filter clauses, e.g.
+// time>=a|time<=b is a<=time<=b.
+func mergeFilter(ce *DapConstraints, varParam *DapVarParam) (bool, error) {
+	var prev *DapVarParam
+	for _, vp := range ce.VarParams {
+		if vp.Name == varParam.Name && vp.ValStart != nil && len(vp.IdxSelectors) == 0 {
+			prev = vp
+			break
+		}
+	}
+	if prev == nil {
+		return false, nil
+	}
+
+	isEqual := func(vp *DapVarParam) bool { return vp.ValEnd == nil }
+	inRange := func(val float64, vp *DapVarParam) bool { return val >= *vp.ValStart && val <= *vp.ValEnd }
+
+	switch {
+	case isEqual(prev) && isEqual(varParam):
+		if *prev.ValStart != *varParam.ValStart {
+			return true, fmt.Errorf("filters select no values")
+		}
+	case isEqual(prev):
+		if !inRange(*prev.ValStart, varParam) {
+			return true, fmt.Errorf("filters select no values")
+		}
+	case isEqual(varParam):
+		if !inRange(*varParam.ValStart, prev) {
+			return true, fmt.Errorf("filters select no values")
+		}
+		prev.ValStart = varParam.ValStart
+		prev.ValEnd = nil
+	default:
+		lowerVal := math.Max(*prev.ValStart, *varParam.ValStart)
+		upperVal := math.Min(*prev.ValEnd, *varParam.ValEnd)
+		if lowerVal > upperVal {
+			return true, fmt.Errorf("filters select no values")
+		}
+		prev.ValStart = &lowerVal
+		prev.ValEnd = &upperVal
+	}
+
+	return true, nil
+}
+
 func parseEndpoint(valStr string) (float64, error) {
 	fVal, err := strconv.ParseFloat(valStr, 64)
 	if err != nil {
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseDap4ConstraintExprFilters(t *testing.T) {
+	ce, err := ParseDap4ConstraintExpr("sst{sst;depth[0:2:4]}|time>=1577836800,time<1580515200|-10<y<10|y>-5|x<=120")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	params := make(map[string]*DapVarParam)
+	for _, vp := range ce.VarParams {
+		params[vp.Name] = vp
+	}
+	if len(ce.VarParams) != 5 {
+		t.Fatalf("unexpected variables: %d", len(ce.VarParams))
+	}
+
+	if vp := params["time"]; *vp.ValStart != 1577836800 || *vp.ValEnd != 1580515200 {
+		t.Errorf("unexpected time range: %v, %v", *vp.ValStart, *vp.ValEnd)
+	}
+	if vp := params["y"]; *vp.ValStart != -5 || *vp.ValEnd != 10 {
+		t.Errorf("unexpected y range: %v, %v", *vp.ValStart, *vp.ValEnd)
+	}
+	if vp := params["x"]; *vp.ValStart != -math.MaxFloat64 || *vp.ValEnd != 120 {
+		t.Errorf("unexpected x range: %v, %v", *vp.ValStart, *vp.ValEnd)
+	}
+	if vp := params["depth"]; len(vp.IdxSelectors) != 1 || *vp.IdxSelectors[0].Step != 2 {
+		t.Errorf("unexpected depth selectors")
+	}
+
+	ce, err = ParseDap4ConstraintExpr("sst{sst}|time>=1577836800|time=1577923200")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if vp := ce.VarParams[1]; *vp.ValStart != 1577923200 || vp.ValEnd != nil {
+		t.Errorf("unexpected time: %v", *vp.ValStart)
+	}
+
+	for _, ceStr := range []string{
+		"sst{sst}|time>10|time<5",
+		"sst{sst}|time=10|time=11",
+		"sst{sst}|x=10|x>20",
+		"sst{sst;time[0]}|time>10",
+	} {
+		if _, err := ParseDap4ConstraintExpr(ceStr); err == nil {
+			t.Errorf("%s: expected error", ceStr)
+		}
+	}
+}
@@ -8,10 +8,13 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"log"
+	"math"
 	"net/http"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
@@ -19,13 +22,20 @@ import (
 	"unsafe"
 )
 
-func EncodeDap4(w http.ResponseWriter, dataFile string, bandNames []string, verbose bool) error {
+// EncodeDap4 writes the bands of a GeoTIFF as a DAP4 data response.
+// Band names are variable names optionally followed by the axis values
+// of the band, e.g. sst#time=2020-01-01T00:00:00.000Z,depth=10. Every
+// variable has its own axes, over which its bands are written in
+// row-major order with missing bands filled with nodata. If checksum is
+// true, every variable is followed by the CRC32 of its data.
+func EncodeDap4(w http.ResponseWriter, dataFile string, bandNames []string, checksum bool, verbose bool) error {
 	w.Header().Set("Content-Type", "application/vnd.opendap.org.dap4.data")
-	defer writeLastChunk(w)
+	dw := &dap4Writer{w: w, checksum: checksum}
+	defer dw.writeLastChunk()
 
-	varNames, axisNames, axisVals, err := getDimensions(bandNames)
+	layout, err := getDimensions(bandNames)
 	if err != nil {
-		handleError(w)
+		dw.writeErrChunk()
 		return err
 	}
 
@@ -38,11 +48,17 @@ func EncodeDap4(w http.ResponseWriter, dataFile string, bandNames []string, verb
 
 	hSrcDS := C.GDALOpenEx(dataFileC, C.GDAL_OF_READONLY, &driverList[0], nil, nil)
 	if hSrcDS == nil {
-		handleError(w)
+		dw.writeErrChunk()
 		return fmt.Errorf("Failed to open data file: %v", dataFile)
 	}
 	defer C.GDALClose(hSrcDS)
 
+	nBands := int(C.GDALGetRasterCount(hSrcDS))
+	if nBands < len(bandNames) {
+		dw.writeErrChunk()
+		return fmt.Errorf("data file has %d bands for %d band names", nBands, len(bandNames))
+	}
+
 	hBand := C.GDALGetRasterBand(hSrcDS, C.int(1))
 
 	width := int(C.GDALGetRasterBandXSize(hBand))
@@ -51,13 +67,20 @@ func EncodeDap4(w http.ResponseWriter, dataFile string, bandNames []string, verb
 	dataType := C.GDALGetRasterDataType(hBand)
 	varDataType := getDataType(dataType)
 	if len(varDataType) == 0 {
-		handleError(w)
+		dw.writeErrChunk()
 		return fmt.Errorf("unknown gdal data type: %v", int(dataType))
 	}
 
-	mdrBytes, err := buildMdr(axisNames, axisVals, varNames, varDataType, width, height)
+	var hasNoDataC C.int
+	noData := float64(C.GDALGetRasterNoDataValue(hBand, &hasNoDataC))
+	hasNoData := hasNoDataC != 0
+	if !hasNoData && (varDataType == "Float32" || varDataType == "Float64") {
+		noData = math.NaN()
+	}
+
+	mdrBytes, err := buildMdr(layout, varDataType, hasNoData, noData, width, height)
 	if err != nil {
-		handleError(w)
+		dw.writeErrChunk()
 		return err
 	}
 
@@ -68,22 +91,26 @@ func EncodeDap4(w http.ResponseWriter, dataFile string, bandNames []string, verb
 	mdrStr = strings.Replace(mdrStr, "\n", "", -1)
 	mdrBytes = []byte(mdrStr)
 
-	err = writeChunk(w, mdrBytes)
+	err = dw.writeChunk(mdrBytes)
 	if err != nil {
-		handleError(w)
+		dw.writeErrChunk()
 		return err
 	}
 
-	for _, ns := range axisNames {
-		axisValsBytes := floatArrToBytes(axisVals[ns])
-		err = writeChunk(w, axisValsBytes)
+	for _, ns := range layout.AxisNames {
+		err = dw.writeVariable(floatArrToBytes(layout.AxisVals[ns]))
 		if err != nil {
-			handleError(w)
+			dw.writeErrChunk()
+			return err
+		}
+		err = dw.endVariable()
+		if err != nil {
+			dw.writeErrChunk()
 			return err
 		}
 	}
 
-	if len(varNames) == 0 {
+	if len(layout.VarNames) == 0 {
 		return nil
 	}
 
@@ -100,9 +127,12 @@ func EncodeDap4(w http.ResponseWriter, dataFile string, bandNames []string, verb
 	}
 	blockYSize *= nYBlocks
 
-	nBands := int(C.GDALGetRasterCount(hSrcDS))
-	for ib := 0; ib < nBands; ib++ {
-		hBand := C.GDALGetRasterBand(hSrcDS, C.int(ib+1))
+	var fillBuf []uint8
+	writeBand := func(ib int) error {
+		var hBand C.GDALRasterBandH
+		if ib > 0 {
+			hBand = C.GDALGetRasterBand(hSrcDS, C.int(ib))
+		}
 		xOff := 0
 
 		for yOff := 0; yOff < height; yOff += blockYSize {
@@ -110,12 +140,21 @@ func EncodeDap4(w http.ResponseWriter, dataFile string, bandNames []string, verb
 			if yOff+blockYSize > height {
 				ySize -= yOff + blockYSize - height
 			}
-			//log.Printf("%d: %d, %d, %d    %d, %d", ib, xOff, yOff, ySize, height, width)
-			dataBuf := make([]uint8, dataSize*width*ySize)
-			gerr := C.GDALRasterIO(hBand, C.GF_Read, C.int(xOff), C.int(yOff), C.int(width), C.int(ySize), unsafe.Pointer(&dataBuf[0]), C.int(width), C.int(ySize), dataType, 0, 0)
-			if gerr != 0 {
-				handleError(w)
-				return fmt.Errorf("Error reading raster band: %d, xOff: %d, yOff:%d", ib, xOff, yOff)
+
+			var dataBuf []uint8
+			if ib > 0 {
+				dataBuf = make([]uint8, dataSize*width*ySize)
+				gerr := C.GDALRasterIO(hBand, C.GF_Read, C.int(xOff), C.int(yOff), C.int(width), C.int(ySize), unsafe.Pointer(&dataBuf[0]), C.int(width), C.int(ySize), dataType, 0, 0)
+				if gerr != 0 {
+					return fmt.Errorf("Error reading raster band: %d, xOff: %d, yOff:%d", ib, xOff, yOff)
+				}
+			} else {
+				if len(fillBuf) < dataSize*width*ySize {
+					fillBuf = make([]uint8, dataSize*width*ySize)
+					noDataC := C.double(noData)
+					C.GDALCopyWords(unsafe.Pointer(&noDataC), C.GDT_Float64, 0, unsafe.Pointer(&fillBuf[0]), dataType, C.int(dataSize), C.int(width*ySize))
+				}
+				dataBuf = fillBuf[:dataSize*width*ySize]
 			}
 
 			nWords := maxChunkSize
@@ -129,30 +168,61 @@ func EncodeDap4(w http.ResponseWriter, dataFile string, bandNames []string, verb
 				if bufEnd > len(dataBuf) {
 					bufEnd = len(dataBuf)
 				}
-				//log.Printf("    %d, %d, %d", bufBgn, bufEnd, nWords)
-				err := writeChunk(w, dataBuf[bufBgn:bufEnd])
+				err := dw.writeVariable(dataBuf[bufBgn:bufEnd])
 				if err != nil {
-					handleError(w)
 					return err
 				}
 			}
 		}
+		return nil
+	}
 
-		if verbose {
-			progress := nBands / 10
-			if progress < 1 {
-				progress = 1
+	nBandsDone := 0
+	for _, varName := range layout.VarNames {
+		varAxes := layout.VarAxes[varName]
+		axisIdx := make([]int, len(varAxes))
+		for {
+			err := writeBand(layout.band(varName, axisIdx))
+			if err != nil {
+				dw.writeErrChunk()
+				return err
 			}
-			if ib%progress == 0 {
-				log.Printf("DAP: %d of %d bands done", ib+1, nBands)
+			nBandsDone++
+
+			if verbose {
+				progress := nBands / 10
+				if progress < 1 {
+					progress = 1
+				}
+				if nBandsDone%progress == 0 {
+					log.Printf("DAP: %d of %d bands done", nBandsDone, nBands)
+				}
+			}
+
+			ia := len(varAxes) - 1
+			for ; ia >= 0; ia-- {
+				axisIdx[ia]++
+				if axisIdx[ia] < len(layout.AxisVals[varAxes[ia]]) {
+					break
+				}
+				axisIdx[ia] = 0
+			}
+			if ia < 0 {
+				break
 			}
 		}
+
+		err = dw.endVariable()
+		if err != nil {
+			dw.writeErrChunk()
+			return err
+		}
 	}
 
 	return nil
 }
 
-func buildMdr(axisNames []string, axisVals map[string][]float64, varNames []string, varDataType string, varWidth int, varHeight int) ([]byte, error) {
+func buildMdr(layout *dap4Layout, varDataType string, hasNoData bool, noData float64, varWidth int, varHeight int) ([]byte, error) {
 	mdrTpl := `<Dataset name="D"
   dapVersion="4.0" 
   dmrVersion="1.0" 
@@ -163,7 +233,7 @@ func buildMdr(axisNames []string, axisVals map[string][]float64, varNames []stri
 {{ range $index, $value := .Axes }}
 <Dimension name="{{ .Name }}" size="{{ .Size }}"/>
 {{ end }}
-{{ $length := len .VarNames }} {{ if ne $length 0 }}
+{{ $length := len .Vars }} {{ if ne $length 0 }}
 <Dimension name="y" size="{{ .VarHeight }}"/>
 <Dimension name="x" size="{{ .VarWidth }}"/>
 {{ end }}
@@ -173,13 +243,16 @@ func buildMdr(axisNames []string, axisVals map[string][]float64, varNames []stri
 </Float64>
 {{ end }}
 {{ with $ds := . }}
-{{ range $index, $value := .VarNames }}
-<{{ $ds.VarDataType }} name="{{ $value }}">
-{{ range $idx, $val := $ds.Axes }}
-<Dim name="{{ $val.Name }}"/>
+{{ range $index, $value := .Vars }}
+<{{ $ds.VarDataType }} name="{{ $value.Name }}">
+{{ range $idx, $val := $value.Axes }}
+<Dim name="{{ $val }}"/>
 {{ end }}
 <Dim name="y"/>
 <Dim name="x"/>
+{{ if $ds.HasNoData }}
+<Attribute name="_FillValue" type="{{ $ds.VarDataType }}"><Value value="{{ $ds.NoData }}"/></Attribute>
+{{ end }}
 </{{ $ds.VarDataType }}>
 {{ end }}
 {{ end }}
@@ -195,18 +268,28 @@ func buildMdr(axisNames []string, axisVals map[string][]float64, varNames []stri
 		Size int
 	}
 
+	type VarInfo struct {
+		Name string
+		Axes []string
+	}
+
 	type DatasetInfo struct {
 		Axes        []*AxisInfo
+		Vars        []*VarInfo
 		VarDataType string
-		VarNames    []string
+		HasNoData   bool
+		NoData      string
 		VarHeight   int
 		VarWidth    int
 	}
 
-	dsInfo := &DatasetInfo{VarDataType: varDataType, VarNames: varNames, VarHeight: varHeight, VarWidth: varWidth}
-	dsInfo.Axes = make([]*AxisInfo, len(axisNames))
-	for i, ns := range axisNames {
-		dsInfo.Axes[i] = &AxisInfo{Name: ns, Size: len(axisVals[ns])}
+	dsInfo := &DatasetInfo{VarDataType: varDataType, HasNoData: hasNoData, NoData: strconv.FormatFloat(noData, 'g', -1, 64), VarHeight: varHeight, VarWidth: varWidth}
+	dsInfo.Axes = make([]*AxisInfo, len(layout.AxisNames))
+	for i, ns := range layout.AxisNames {
+		dsInfo.Axes[i] = &AxisInfo{Name: ns, Size: len(layout.AxisVals[ns])}
+	}
+	for _, varName := range layout.VarNames {
+		dsInfo.Vars = append(dsInfo.Vars, &VarInfo{Name: varName, Axes: layout.VarAxes[varName]})
 	}
 
 	buf := new(bytes.Buffer)
@@ -226,34 +309,58 @@ func floatArrToBytes(arr []float64) []byte {
 	return data
 }
 
-func getDimensions(dims []string) ([]string, []string, map[string][]float64, error) {
-	varLookup := make(map[string]struct{})
-	varNames := make([]string, 0)
+// dap4Layout maps the bands of a data file to the variables and axes of
+// a DAP4 response
+type dap4Layout struct {
+	VarNames  []string
+	VarAxes   map[string][]string
+	AxisNames []string
+	AxisVals  map[string][]float64
+	Bands     map[string]int
+}
+
+// band returns the 1-based band of a variable at axis value indices, or
+// 0 if the band is missing
+func (l *dap4Layout) band(varName string, axisIdx []int) int {
+	return l.Bands[bandKey(varName, axisIdx)]
+}
 
+func bandKey(varName string, axisIdx []int) string {
+	return fmt.Sprintf("%s%v", varName, axisIdx)
+}
+
+func getDimensions(dims []string) (*dap4Layout, error) {
+	layout := &dap4Layout{VarAxes: make(map[string][]string), AxisVals: make(map[string][]float64), Bands: make(map[string]int)}
+
+	varLookup := make(map[string]string)
 	valsLookup := make(map[string]map[float64]struct{})
-	axisVals := make(map[string][]float64)
-	axisNames := make([]string, 0)
+
+	type bandAxes struct {
+		varName string
+		vals    map[string]float64
+	}
+	bands := make([]*bandAxes, len(dims))
 
 	varNameRegex := regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 	iVar := 0
-	for _, dim := range dims {
+	for ib, dim := range dims {
 		parts := strings.Split(dim, "#")
 		if len(parts) > 2 {
-			return varNames, axisNames, axisVals, fmt.Errorf("invalid dim format: %v", dim)
+			return nil, fmt.Errorf("invalid dim format: %v", dim)
 		}
 
 		varPart := parts[0]
-		if _, found := varLookup[varPart]; !found {
-			if varPart != EmptyTileNS {
-				varLookup[varPart] = struct{}{}
-				varName := varPart
-				if !varNameRegex.MatchString(varName) {
-					iVar++
-					varName = fmt.Sprintf("var%d", iVar)
-				}
-				varNames = append(varNames, varName)
+		varName, found := varLookup[varPart]
+		if !found && varPart != EmptyTileNS {
+			varName = varPart
+			if !varNameRegex.MatchString(varName) {
+				iVar++
+				varName = fmt.Sprintf("var%d", iVar)
 			}
+			varLookup[varPart] = varName
+			layout.VarNames = append(layout.VarNames, varName)
 		}
+		bands[ib] = &bandAxes{varName: varName, vals: make(map[string]float64)}
 
 		if len(parts) == 1 {
 			continue
@@ -264,14 +371,13 @@ func getDimensions(dims []string) ([]string, []string, map[string][]float64, err
 		for _, axis := range axes {
 			kv := strings.Split(axis, "=")
 			if len(kv) != 2 {
-				return varNames, axisNames, axisVals, fmt.Errorf("invalid axis format: %v", dim)
+				return nil, fmt.Errorf("invalid axis format: %v", dim)
 			}
 
 			axisName := kv[0]
 			if _, found := valsLookup[axisName]; !found {
 				valsLookup[axisName] = make(map[float64]struct{})
-				axisVals[axisName] = make([]float64, 0)
-				axisNames = append(axisNames, axisName)
+				layout.AxisNames = append(layout.AxisNames, axisName)
 			}
 			axisVal := kv[1]
 
@@ -279,23 +385,86 @@ func getDimensions(dims []string) ([]string, []string, map[string][]float64, err
 			if err != nil {
 				timeVal, tErr := time.Parse(ISOFormat, axisVal)
 				if tErr != nil {
-					return varNames, axisNames, axisVals, fmt.Errorf("unknown data type: %v", dim)
+					return nil, fmt.Errorf("unknown data type: %v", dim)
 				}
 				val = float64(timeVal.Unix())
 			}
 
 			if _, found := valsLookup[axisName][val]; !found {
 				valsLookup[axisName][val] = struct{}{}
-				axisVals[axisName] = append(axisVals[axisName], val)
+				layout.AxisVals[axisName] = append(layout.AxisVals[axisName], val)
+			}
+			bands[ib].vals[axisName] = val
+		}
+	}
+
+	for _, axisName := range layout.AxisNames {
+		sort.Float64s(layout.AxisVals[axisName])
+	}
+
+	for ib, band := range bands {
+		if band.varName == "" {
+			continue
+		}
+
+		var varAxes []string
+		for _, axisName := range layout.AxisNames {
+			if _, found := band.vals[axisName]; found {
+				varAxes = append(varAxes, axisName)
+			}
+		}
+
+		if prevAxes, found := layout.VarAxes[band.varName]; found {
+			if strings.Join(prevAxes, ",") != strings.Join(varAxes, ",") {
+				return nil, fmt.Errorf("bands of variable %s have different axes: %v", band.varName, dims[ib])
 			}
+		} else {
+			layout.VarAxes[band.varName] = varAxes
+		}
 
+		axisIdx := make([]int, len(varAxes))
+		for ia, axisName := range varAxes {
+			axisIdx[ia] = sort.SearchFloat64s(layout.AxisVals[axisName], band.vals[axisName])
 		}
+
+		key := bandKey(band.varName, axisIdx)
+		if _, found := layout.Bands[key]; found {
+			return nil, fmt.Errorf("duplicated band: %v", dims[ib])
+		}
+		layout.Bands[key] = ib + 1
 	}
 
-	return varNames, axisNames, axisVals, nil
+	return layout, nil
+}
+
+// dap4Writer writes the chunks of a DAP4 data response
+type dap4Writer struct {
+	w        http.ResponseWriter
+	checksum bool
+	crc      uint32
+}
+
+// writeVariable writes data of the current variable
+func (dw *dap4Writer) writeVariable(data []byte) error {
+	if dw.checksum {
+		dw.crc = crc32.Update(dw.crc, crc32.IEEETable, data)
+	}
+	return dw.writeChunk(data)
 }
 
-func writeChunk(w http.ResponseWriter, data []byte) error {
+// endVariable writes the checksum of the current variable
+func (dw *dap4Writer) endVariable() error {
+	if !dw.checksum {
+		return nil
+	}
+
+	crc := make([]byte, 4)
+	binary.LittleEndian.PutUint32(crc, dw.crc)
+	dw.crc = 0
+	return dw.writeChunk(crc)
+}
+
+func (dw *dap4Writer) writeChunk(data []byte) error {
 	if len(data) > 0xffffff {
 		return fmt.Errorf("exceeding maximum chunk size")
 	}
@@ -311,28 +480,27 @@ func writeChunk(w http.ResponseWriter, data []byte) error {
 	//#define LITTLE_ENDIAN_CHUNK (4)
 	//#define NOCHECKSUM_CHUNK    (8)
 
-	hdr[0] = byte(8 | 4)
-	_, err := w.Write(hdr)
+	hdr[0] = byte(4)
+	if !dw.checksum {
+		hdr[0] |= 8
+	}
+	_, err := dw.w.Write(hdr)
 	if err != nil {
 		return err
 	}
 
-	_, err = w.Write(data)
+	_, err = dw.w.Write(data)
 	return err
 }
 
-func writeLastChunk(w http.ResponseWriter) {
+func (dw *dap4Writer) writeLastChunk() {
 	lastChunk := []byte{1, 0, 0, 0}
-	w.Write(lastChunk)
-}
-
-func handleError(w http.ResponseWriter) {
-	writeErrChunk(w)
+	dw.w.Write(lastChunk)
 }
 
-func writeErrChunk(w http.ResponseWriter) {
+func (dw *dap4Writer) writeErrChunk() {
 	errChunk := []byte{2, 0, 0, 0}
-	w.Write(errChunk)
+	dw.w.Write(errChunk)
 }
 
 func getDataType(gdalDataType C.GDALDataType) string {
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestGetDimensions(t *testing.T) {
+	bandNames := []string{
+		"sst#time=2020-01-02T00:00:00.000Z,depth=10",
+		"sst#time=2020-01-01T00:00:00.000Z,depth=10",
+		"sst#time=2020-01-01T00:00:00.000Z,depth=0",
+		"chl#time=2020-01-02T00:00:00.000Z",
+		"mask",
+	}
+
+	layout, err := getDimensions(bandNames)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if len(layout.VarNames) != 3 || len(layout.AxisNames) != 2 {
+		t.Fatalf("unexpected variables %v and axes %v", layout.VarNames, layout.AxisNames)
+	}
+	if times := layout.AxisVals["time"]; len(times) != 2 || times[0] != 1577836800 || times[1] != 1577923200 {
+		t.Errorf("unexpected time values: %v", times)
+	}
+	if depths := layout.AxisVals["depth"]; len(depths) != 2 || depths[0] != 0 {
+		t.Errorf("unexpected depth values: %v", depths)
+	}
+
+	if axes := layout.VarAxes["sst"]; len(axes) != 2 || axes[0] != "time" || axes[1] != "depth" {
+		t.Errorf("unexpected sst axes: %v", axes)
+	}
+	if axes := layout.VarAxes["chl"]; len(axes) != 1 || axes[0] != "time" {
+		t.Errorf("unexpected chl axes: %v", axes)
+	}
+	if axes := layout.VarAxes["mask"]; len(axes) != 0 {
+		t.Errorf("unexpected mask axes: %v", axes)
+	}
+
+	testCases := []struct {
+		varName string
+		axisIdx []int
+		band    int
+	}{
+		{"sst", []int{0, 0}, 3},
+		{"sst", []int{0, 1}, 2},
+		{"sst", []int{1, 0}, 0},
+		{"sst", []int{1, 1}, 1},
+		{"chl", []int{0}, 0},
+		{"chl", []int{1}, 4},
+		{"mask", []int{}, 5},
+	}
+	for _, tc := range testCases {
+		if band := layout.band(tc.varName, tc.axisIdx); band != tc.band {
+			t.Errorf("%s%v: got band %d, expected %d", tc.varName, tc.axisIdx, band, tc.band)
+		}
+	}
+
+	if _, err := getDimensions([]string{"sst#time=2020-01-01T00:00:00.000Z", "sst#depth=0"}); err == nil {
+		t.Errorf("expected error for bands of different axes")
+	}
+}
@@ -0,0 +1,466 @@
+package utils
+
+import (
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DapTimeUnits are the units of the time coordinate of DAP datasets,
+// which match the axis values of the DAP4 data responses
+const DapTimeUnits = "seconds since 1970-01-01T00:00:00Z"
+
+const dapCRSVar = "crs"
+
+const wgs84WKT = `GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AXIS["Latitude",NORTH],AXIS["Longitude",EAST],AUTHORITY["EPSG","4326"]]`
+
+var dapNameRegex = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+var dasEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// DapAttribute is a DAP attribute of a variable or a dataset. Values
+// are kept as text, numbers included.
+type DapAttribute struct {
+	Name   string
+	Type   string
+	Values []string
+}
+
+// DapDimension is a shared dimension of a DAP dataset
+type DapDimension struct {
+	Name string
+	Size int
+}
+
+// DapVariable is a variable of a DAP dataset. Coordinate variables hold
+// their values while data variables refer to the band expression of the
+// layer rendering them.
+type DapVariable struct {
+	Name   string
+	Type   string
+	Dims   []string
+	Values []float64
+	Attrs  []*DapAttribute
+	Expr   string
+	IsData bool
+}
+
+// DapDataset describes a layer as a DAP dataset on a regular EPSG:4326
+// grid of pixel centres
+type DapDataset struct {
+	Name       string
+	Dimensions []*DapDimension
+	Variables  []*DapVariable
+	Attrs      []*DapAttribute
+	BBox       []float64
+	Width      int
+	Height     int
+	Times      []time.Time
+	Axes       []*LayerAxis
+}
+
+// NewDapDataset builds the DAP dataset of a layer from its config. The
+// grid is default_geo_bbox sampled at default_geo_size, the time
+// coordinate comes from the layer dates, the extra axes from the axes
+// of the layer and the data variables from its band expressions.
+func NewDapDataset(layer *Layer) (*DapDataset, error) {
+	ds := &DapDataset{Name: DapName(layer.Name), BBox: []float64{-180, -90, 180, 90}}
+	if len(layer.DefaultGeoBbox) == 4 {
+		ds.BBox = layer.DefaultGeoBbox
+	}
+	if ds.BBox[0] >= ds.BBox[2] || ds.BBox[1] >= ds.BBox[3] {
+		return nil, fmt.Errorf("invalid default_geo_bbox: %v", ds.BBox)
+	}
+	if len(layer.DefaultGeoSize) != 2 || layer.DefaultGeoSize[0] <= 0 || layer.DefaultGeoSize[1] <= 0 {
+		return nil, fmt.Errorf("layer %s has no valid default_geo_size", layer.Name)
+	}
+	ds.Height = layer.DefaultGeoSize[0]
+	ds.Width = layer.DefaultGeoSize[1]
+
+	bandExpr := layer.RGBExpressions
+	if (bandExpr == nil || len(bandExpr.ExprNames) == 0) && len(layer.Styles) > 0 {
+		bandExpr = layer.Styles[0].RGBExpressions
+	}
+	if bandExpr == nil || len(bandExpr.ExprNames) == 0 {
+		return nil, fmt.Errorf("layer %s has no band expressions", layer.Name)
+	}
+
+	var varDims []string
+	if len(layer.Dates) > 0 {
+		timeVals := make([]float64, len(layer.Dates))
+		for i, date := range layer.Dates {
+			t, err := time.Parse(ISOFormat, date)
+			if err != nil {
+				return nil, fmt.Errorf("invalid layer date %s: %v", date, err)
+			}
+			ds.Times = append(ds.Times, t)
+			timeVals[i] = float64(t.Unix())
+		}
+
+		ds.addCoordinate("time", timeVals, []*DapAttribute{
+			dapString("standard_name", "time"),
+			dapString("units", DapTimeUnits),
+			dapString("calendar", "standard"),
+			dapString("axis", "T"),
+		})
+		varDims = append(varDims, "time")
+	}
+
+	for _, axis := range layer.AxesInfo {
+		if len(axis.Values) == 0 {
+			continue
+		}
+		axisVals := make([]float64, len(axis.Values))
+		for i, val := range axis.Values {
+			v, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return nil, fmt.Errorf("axis %s has non-numeric value: %s", axis.Name, val)
+			}
+			axisVals[i] = v
+		}
+
+		name := DapName(axis.Name)
+		ds.addCoordinate(name, axisVals, []*DapAttribute{dapString("long_name", axis.Name)})
+		ds.Axes = append(ds.Axes, axis)
+		varDims = append(varDims, name)
+	}
+
+	xRes := (ds.BBox[2] - ds.BBox[0]) / float64(ds.Width)
+	yRes := (ds.BBox[3] - ds.BBox[1]) / float64(ds.Height)
+	lats := make([]float64, ds.Height)
+	for i := range lats {
+		lats[i] = ds.BBox[3] - (float64(i)+0.5)*yRes
+	}
+	lons := make([]float64, ds.Width)
+	for i := range lons {
+		lons[i] = ds.BBox[0] + (float64(i)+0.5)*xRes
+	}
+
+	ds.addCoordinate("lat", lats, []*DapAttribute{
+		dapString("standard_name", "latitude"),
+		dapString("long_name", "latitude"),
+		dapString("units", "degrees_north"),
+		dapString("axis", "Y"),
+	})
+	ds.addCoordinate("lon", lons, []*DapAttribute{
+		dapString("standard_name", "longitude"),
+		dapString("long_name", "longitude"),
+		dapString("units", "degrees_east"),
+		dapString("axis", "X"),
+	})
+	varDims = append(varDims, "lat", "lon")
+
+	ds.Variables = append(ds.Variables, &DapVariable{Name: dapCRSVar, Type: "Int32", Attrs: []*DapAttribute{
+		dapString("grid_mapping_name", "latitude_longitude"),
+		dapString("epsg_code", "EPSG:4326"),
+		dapString("crs_wkt", wgs84WKT),
+		dapString("spatial_ref", wgs84WKT),
+		{Name: "semi_major_axis", Type: "Float64", Values: []string{"6378137"}},
+		{Name: "inverse_flattening", Type: "Float64", Values: []string{"298.257223563"}},
+	}})
+
+	for i, exprName := range bandExpr.ExprNames {
+		exprText := exprName
+		if i < len(bandExpr.ExprText) {
+			exprText = bandExpr.ExprText[i]
+		}
+		ds.Variables = append(ds.Variables, &DapVariable{
+			Name:   ds.uniqueName(DapName(exprName)),
+			Type:   "Float32",
+			Dims:   varDims,
+			Expr:   exprText,
+			IsData: true,
+			Attrs: []*DapAttribute{
+				dapString("long_name", exprName),
+				{Name: "_FillValue", Type: "Float32", Values: []string{"NaN"}},
+				dapString("grid_mapping", dapCRSVar),
+			},
+		})
+	}
+
+	ds.Attrs = append(ds.Attrs, dapString("Conventions", "CF-1.6"))
+	if len(layer.Title) > 0 {
+		ds.Attrs = append(ds.Attrs, dapString("title", layer.Title))
+	}
+	if len(layer.Abstract) > 0 {
+		ds.Attrs = append(ds.Attrs, dapString("summary", layer.Abstract))
+	}
+	ds.Attrs = append(ds.Attrs, dapString("source", "GSKY layer "+layer.Name))
+
+	return ds, nil
+}
+
+// DapName turns a layer, axis or band name into a DAP identifier
+func DapName(name string) string {
+	name = dapNameRegex.ReplaceAllString(name, "_")
+	if len(name) == 0 || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+func dapString(name string, value string) *DapAttribute {
+	return &DapAttribute{Name: name, Type: "String", Values: []string{value}}
+}
+
+func (ds *DapDataset) addCoordinate(name string, values []float64, attrs []*DapAttribute) {
+	ds.Dimensions = append(ds.Dimensions, &DapDimension{Name: name, Size: len(values)})
+	ds.Variables = append(ds.Variables, &DapVariable{Name: name, Type: "Float64", Dims: []string{name}, Values: values, Attrs: attrs})
+}
+
+func (ds *DapDataset) uniqueName(name string) string {
+	unique := name
+	for i := 1; ds.Variable(unique) != nil; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	return unique
+}
+
+// Variable returns the variable of the given name or nil
+func (ds *DapDataset) Variable(name string) *DapVariable {
+	for _, v := range ds.Variables {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// DimensionSize returns the size of a dimension of the dataset
+func (ds *DapDataset) DimensionSize(name string) int {
+	for _, dim := range ds.Dimensions {
+		if dim.Name == name {
+			return dim.Size
+		}
+	}
+	return 0
+}
+
+// DapSlice is a start:stride:stop hyperslab of a dimension with an
+// inclusive stop
+type DapSlice struct {
+	Start  int
+	Stride int
+	Stop   int
+}
+
+// Count is the number of indices of the slice
+func (s *DapSlice) Count() int {
+	return (s.Stop-s.Start)/s.Stride + 1
+}
+
+// Indices lists the indices of the slice
+func (s *DapSlice) Indices() []int {
+	indices := make([]int, 0, s.Count())
+	for i := s.Start; i <= s.Stop; i += s.Stride {
+		indices = append(indices, i)
+	}
+	return indices
+}
+
+// DapProjection is a variable of a constraint expression with a slice
+// per dimension
+type DapProjection struct {
+	Name   string
+	Slices []*DapSlice
+}
+
+// Count is the number of values of the projected variable
+func (p *DapProjection) Count() int {
+	count := 1
+	for _, s := range p.Slices {
+		count *= s.Count()
+	}
+	return count
+}
+
+// Project validates projections against the dataset and completes
+// their missing slices with whole dimensions. No projections select
+// every variable. The projections are returned in dataset order.
+func (ds *DapDataset) Project(projs []*DapProjection) ([]*DapProjection, error) {
+	projMap := make(map[string]*DapProjection)
+	for _, p := range projs {
+		v := ds.Variable(p.Name)
+		if v == nil {
+			return nil, fmt.Errorf("variable not found: %s", p.Name)
+		}
+		if _, found := projMap[p.Name]; found {
+			return nil, fmt.Errorf("variable projected more than once: %s", p.Name)
+		}
+		if len(p.Slices) > 0 && len(p.Slices) != len(v.Dims) {
+			return nil, fmt.Errorf("variable %s has %d dimensions but %d were constrained", p.Name, len(v.Dims), len(p.Slices))
+		}
+		for i, s := range p.Slices {
+			size := ds.DimensionSize(v.Dims[i])
+			if s.Start < 0 || s.Stride <= 0 || s.Start > s.Stop || s.Stop >= size {
+				return nil, fmt.Errorf("invalid hyperslab [%d:%d:%d] of %s for dimension %s of size %d", s.Start, s.Stride, s.Stop, p.Name, v.Dims[i], size)
+			}
+		}
+		projMap[p.Name] = p
+	}
+
+	var result []*DapProjection
+	for _, v := range ds.Variables {
+		p, found := projMap[v.Name]
+		if len(projs) > 0 && !found {
+			continue
+		}
+		if !found || len(p.Slices) == 0 {
+			p = &DapProjection{Name: v.Name}
+			for _, dim := range v.Dims {
+				p.Slices = append(p.Slices, &DapSlice{Start: 0, Stride: 1, Stop: ds.DimensionSize(dim) - 1})
+			}
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// WriteDDS writes the DAP2 dataset descriptor of the projected variables
+func (ds *DapDataset) WriteDDS(w io.Writer, projs []*DapProjection) error {
+	var sb strings.Builder
+	sb.WriteString("Dataset {\n")
+	for _, p := range projs {
+		v := ds.Variable(p.Name)
+		fmt.Fprintf(&sb, "    %s %s", v.Type, v.Name)
+		for i, dim := range v.Dims {
+			fmt.Fprintf(&sb, "[%s = %d]", dim, p.Slices[i].Count())
+		}
+		sb.WriteString(";\n")
+	}
+	fmt.Fprintf(&sb, "} %s;\n", ds.Name)
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteDAS writes the DAP2 attributes of every variable of the dataset
+// followed by the global attributes
+func (ds *DapDataset) WriteDAS(w io.Writer) error {
+	var sb strings.Builder
+	writeAttrs := func(name string, attrs []*DapAttribute) {
+		fmt.Fprintf(&sb, "    %s {\n", name)
+		for _, attr := range attrs {
+			vals := make([]string, len(attr.Values))
+			for i, val := range attr.Values {
+				if attr.Type == "String" {
+					val = `"` + dasEscaper.Replace(val) + `"`
+				}
+				vals[i] = val
+			}
+			fmt.Fprintf(&sb, "        %s %s %s;\n", attr.Type, attr.Name, strings.Join(vals, ", "))
+		}
+		sb.WriteString("    }\n")
+	}
+
+	sb.WriteString("Attributes {\n")
+	for _, v := range ds.Variables {
+		writeAttrs(v.Name, v.Attrs)
+	}
+	writeAttrs("NC_GLOBAL", ds.Attrs)
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteDMR writes the DAP4 dataset metadata response
+func (ds *DapDataset) WriteDMR(w io.Writer) error {
+	var sb strings.Builder
+	escape := func(s string) string {
+		var buf strings.Builder
+		xml.EscapeText(&buf, []byte(s))
+		return buf.String()
+	}
+	writeAttrs := func(indent string, attrs []*DapAttribute) {
+		for _, attr := range attrs {
+			fmt.Fprintf(&sb, "%s<Attribute name=\"%s\" type=\"%s\">\n", indent, escape(attr.Name), attr.Type)
+			for _, val := range attr.Values {
+				fmt.Fprintf(&sb, "%s    <Value>%s</Value>\n", indent, escape(val))
+			}
+			fmt.Fprintf(&sb, "%s</Attribute>\n", indent)
+		}
+	}
+
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&sb, `<Dataset name="%s" dapVersion="4.0" dmrVersion="1.0" xmlns="http://xml.opendap.org/ns/DAP/4.0#" xmlns:dap="http://xml.opendap.org/ns/DAP/4.0#">`+"\n", escape(ds.Name))
+	for _, dim := range ds.Dimensions {
+		fmt.Fprintf(&sb, "    <Dimension name=\"%s\" size=\"%d\"/>\n", dim.Name, dim.Size)
+	}
+	for _, v := range ds.Variables {
+		fmt.Fprintf(&sb, "    <%s name=\"%s\">\n", v.Type, v.Name)
+		for _, dim := range v.Dims {
+			fmt.Fprintf(&sb, "        <Dim name=\"/%s\"/>\n", dim)
+		}
+		writeAttrs("        ", v.Attrs)
+		if v.IsData {
+			for _, dim := range v.Dims {
+				fmt.Fprintf(&sb, "        <Map name=\"/%s\"/>\n", dim)
+			}
+		}
+		fmt.Fprintf(&sb, "    </%s>\n", v.Type)
+	}
+	writeAttrs("    ", ds.Attrs)
+	sb.WriteString("</Dataset>\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteCoordinateXDR writes the projected values of a coordinate
+// variable, or the value of a scalar variable, in XDR
+func (ds *DapDataset) WriteCoordinateXDR(w io.Writer, proj *DapProjection) error {
+	v := ds.Variable(proj.Name)
+	if v == nil || v.IsData {
+		return fmt.Errorf("not a coordinate variable: %s", proj.Name)
+	}
+	if len(v.Dims) == 0 {
+		return binary.Write(w, binary.BigEndian, int32(0))
+	}
+
+	var vals []float64
+	for _, i := range proj.Slices[0].Indices() {
+		vals = append(vals, v.Values[i])
+	}
+	if err := WriteXDRLength(w, len(vals)); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, vals)
+}
+
+// WriteXDRLength writes the length prefix of a DAP2 array, which XDR
+// repeats twice
+func WriteXDRLength(w io.Writer, n int) error {
+	return binary.Write(w, binary.BigEndian, []uint32{uint32(n), uint32(n)})
+}
+
+// WriteXDRFloat32 writes float32 values in XDR without length prefix
+func WriteXDRFloat32(w io.Writer, vals []float32) error {
+	return binary.Write(w, binary.BigEndian, vals)
+}
+
+// DapGridWindow returns the bbox and size of the raster whose pixel
+// centres are the lat and lon coordinates selected by the slices. The
+// pixels of strided slices are strided pixels of the grid, centred on
+// the selected coordinates.
+func (ds *DapDataset) DapGridWindow(latSlice *DapSlice, lonSlice *DapSlice) ([]float64, int, int) {
+	xRes := (ds.BBox[2] - ds.BBox[0]) / float64(ds.Width)
+	yRes := (ds.BBox[3] - ds.BBox[1]) / float64(ds.Height)
+
+	width := lonSlice.Count()
+	height := latSlice.Count()
+
+	xMin := ds.BBox[0] + (float64(lonSlice.Start)-float64(lonSlice.Stride-1)/2)*xRes
+	xMax := xMin + float64(width*lonSlice.Stride)*xRes
+	yMax := ds.BBox[3] - (float64(latSlice.Start)-float64(latSlice.Stride-1)/2)*yRes
+	yMin := yMax - float64(height*latSlice.Stride)*yRes
+
+	round := func(v float64) float64 { return math.Round(v*1e9) / 1e9 }
+	return []float64{round(xMin), round(yMin), round(xMax), round(yMax)}, width, height
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+func testDapLayer() *Layer {
+	return &Layer{
+		Name:           "sst.daily",
+		Title:          "Sea \"surface\" temperature",
+		Dates:          []string{"2020-01-01T00:00:00.000Z", "2020-01-02T00:00:00.000Z"},
+		AxesInfo:       []*LayerAxis{{Name: "depth", Values: []string{"0", "10", "20"}}},
+		DefaultGeoBbox: []float64{100, -40, 160, 0},
+		DefaultGeoSize: []int{4, 6},
+		RGBExpressions: &BandExpressions{ExprNames: []string{"sst"}, ExprText: []string{"sst = analysed_sst - 273.15"}},
+	}
+}
+
+func TestParseDapPath(t *testing.T) {
+	testCases := []struct {
+		path      string
+		namespace string
+		layer     string
+		ext       string
+	}{
+		{"sst.dds", ".", "sst", ".dds"},
+		{"/geoglam/sst.daily.dods", "geoglam", "sst.daily", ".dods"},
+		{"a/b/sst.dmr", "a/b", "sst", ".dmr"},
+		{"geoglam.dap", "", "", ""},
+		{"geoglam", "", "", ""},
+	}
+
+	for _, tc := range testCases {
+		namespace, layer, ext := ParseDapPath(tc.path)
+		if namespace != tc.namespace || layer != tc.layer || ext != tc.ext {
+			t.Errorf("%s: got %q, %q, %q", tc.path, namespace, layer, ext)
+		}
+	}
+}
+
+func TestParseDap2ConstraintExpr(t *testing.T) {
+	projs, err := ParseDap2ConstraintExpr("time,sst%5B0%5D%5B1:2%5D%5B0:2:3%5D%5B1:5%5D")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(projs) != 2 || projs[0].Name != "time" || len(projs[0].Slices) != 0 {
+		t.Fatalf("unexpected projections: %v", projs)
+	}
+
+	expected := []DapSlice{{0, 1, 0}, {1, 1, 2}, {0, 2, 3}, {1, 1, 5}}
+	if len(projs[1].Slices) != len(expected) {
+		t.Fatalf("unexpected slices: %v", projs[1].Slices)
+	}
+	for i, s := range projs[1].Slices {
+		if *s != expected[i] {
+			t.Errorf("slice %d: got %v, expected %v", i, *s, expected[i])
+		}
+	}
+
+	for _, ce := range []string{"sst[0", "sst[a]", "sst[0:1:2:3]", "sst&sst>0", "1sst"} {
+		if _, err := ParseDap2ConstraintExpr(ce); err == nil {
+			t.Errorf("%s: expected error", ce)
+		}
+	}
+}
+
+func TestDapDataset(t *testing.T) {
+	ds, err := NewDapDataset(testDapLayer())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if ds.Name != "sst_daily" {
+		t.Errorf("unexpected dataset name: %s", ds.Name)
+	}
+
+	lat := ds.Variable("lat")
+	if lat == nil || len(lat.Values) != 4 || lat.Values[0] != -5 || lat.Values[3] != -35 {
+		t.Errorf("unexpected lat: %v", lat)
+	}
+	lon := ds.Variable("lon")
+	if lon == nil || len(lon.Values) != 6 || lon.Values[0] != 105 || lon.Values[5] != 155 {
+		t.Errorf("unexpected lon: %v", lon)
+	}
+	timeVar := ds.Variable("time")
+	if timeVar == nil || timeVar.Values[1] != 1577923200 {
+		t.Errorf("unexpected time: %v", timeVar)
+	}
+
+	projs, err := ds.Project(nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var dds bytes.Buffer
+	ds.WriteDDS(&dds, projs)
+	for _, decl := range []string{"Float64 time[time = 2];", "Float64 depth[depth = 3];", "Int32 crs;", "Float32 sst[time = 2][depth = 3][lat = 4][lon = 6];", "} sst_daily;"} {
+		if !strings.Contains(dds.String(), decl) {
+			t.Errorf("DDS misses %q:\n%s", decl, dds.String())
+		}
+	}
+
+	projs, err = ParseDap2ConstraintExpr("sst[1][0:2:2][1:2][0:2:5]")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	projs, err = ds.Project(projs)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	dds.Reset()
+	ds.WriteDDS(&dds, projs)
+	if !strings.Contains(dds.String(), "Float32 sst[time = 1][depth = 2][lat = 2][lon = 3];") || strings.Contains(dds.String(), "lat[") {
+		t.Errorf("unexpected constrained DDS:\n%s", dds.String())
+	}
+
+	bbox, width, height := ds.DapGridWindow(projs[0].Slices[2], projs[0].Slices[3])
+	if width != 3 || height != 2 || bbox[0] != 95 || bbox[2] != 155 || bbox[1] != -30 || bbox[3] != -10 {
+		t.Errorf("unexpected window %v %dx%d", bbox, width, height)
+	}
+
+	for _, ce := range []string{"foo", "sst[0][0][0]", "sst[2][0][0][0]", "sst[0][0][0][0:0:1]", "time,time"} {
+		projs, err := ParseDap2ConstraintExpr(ce)
+		if err != nil {
+			t.Fatalf("%s: %v", ce, err)
+		}
+		if _, err := ds.Project(projs); err == nil {
+			t.Errorf("%s: expected error", ce)
+		}
+	}
+
+	var das bytes.Buffer
+	ds.WriteDAS(&das)
+	for _, attr := range []string{`String units "seconds since 1970-01-01T00:00:00Z";`, "Float32 _FillValue NaN;", `String grid_mapping "crs";`, `String title "Sea \"surface\" temperature";`, "NC_GLOBAL {"} {
+		if !strings.Contains(das.String(), attr) {
+			t.Errorf("DAS misses %q:\n%s", attr, das.String())
+		}
+	}
+
+	var dmr bytes.Buffer
+	ds.WriteDMR(&dmr)
+	for _, elem := range []string{`<Dimension name="lat" size="4"/>`, `<Float32 name="sst">`, `<Dim name="/depth"/>`, `<Value>Sea &#34;surface&#34; temperature</Value>`} {
+		if !strings.Contains(dmr.String(), elem) {
+			t.Errorf("DMR misses %q:\n%s", elem, dmr.String())
+		}
+	}
+}
+
+func TestDapCoordinateXDR(t *testing.T) {
+	ds, err := NewDapDataset(testDapLayer())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ds.WriteCoordinateXDR(&buf, &DapProjection{Name: "lon", Slices: []*DapSlice{{1, 2, 5}}}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if buf.Len() != 8+3*8 {
+		t.Fatalf("unexpected XDR length: %d", buf.Len())
+	}
+	b := buf.Bytes()
+	if binary.BigEndian.Uint32(b[0:]) != 3 || binary.BigEndian.Uint32(b[4:]) != 3 {
+		t.Errorf("unexpected XDR array length")
+	}
+	if val := math.Float64frombits(binary.BigEndian.Uint64(b[16:])); val != 135 {
+		t.Errorf("unexpected lon value: %v", val)
+	}
+
+	if err := ds.WriteCoordinateXDR(&buf, &DapProjection{Name: "sst"}); err == nil {
+		t.Errorf("expected error for data variable")
+	}
+}
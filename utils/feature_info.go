@@ -0,0 +1,296 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// Formats of WMS GetFeatureInfo responses
+const (
+	FeatureInfoJSON = "application/json"
+	FeatureInfoHTML = "text/html"
+	FeatureInfoText = "text/plain"
+	FeatureInfoGML  = "application/vnd.ogc.gml"
+)
+
+// ParseFeatureInfoFormat returns the format of the INFO_FORMAT parameter
+// of a GetFeatureInfo request. It returns an empty string if the format
+// isn't supported.
+func ParseFeatureInfoFormat(infoFormat string) string {
+	format := strings.ToLower(strings.TrimSpace(infoFormat))
+	if i := strings.Index(format, ";"); i >= 0 {
+		format = strings.TrimSpace(format[:i])
+	}
+
+	switch format {
+	case FeatureInfoJSON, "application/geo+json":
+		return FeatureInfoJSON
+	case FeatureInfoHTML:
+		return FeatureInfoHTML
+	case FeatureInfoText:
+		return FeatureInfoText
+	case FeatureInfoGML, "application/vnd.ogc.gml/3.1.1":
+		return FeatureInfoGML
+	}
+	return ""
+}
+
+// FeatureInfoBand is the value of a band at a pixel. The value is either
+// a number or a message such as "n/a".
+type FeatureInfoBand struct {
+	Name  string
+	Value interface{}
+}
+
+// FeatureInfoBands are marshalled to a JSON object with the bands in
+// their order
+type FeatureInfoBands []FeatureInfoBand
+
+func (bands FeatureInfoBands) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, band := range bands {
+		key, err := json.Marshal(band.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(band.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// FeatureInfoTimeSeries is the values of the bands at a pixel at each of
+// its dates. The value of each band is a slice of the values at the
+// dates, which are nil at the dates without data.
+type FeatureInfoTimeSeries struct {
+	Dates []string
+	Bands FeatureInfoBands
+}
+
+func (ts *FeatureInfoTimeSeries) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Dates  []string         `json:"dates"`
+		Values FeatureInfoBands `json:"values"`
+	}{ts.Dates, ts.Bands})
+}
+
+// Rows returns the time series as rows of the date followed by the
+// values of the bands at the date
+func (ts *FeatureInfoTimeSeries) Rows() [][]string {
+	rows := make([][]string, len(ts.Dates))
+	for i, date := range ts.Dates {
+		rows[i] = append(rows[i], date)
+		for _, band := range ts.Bands {
+			var value interface{}
+			if values, ok := band.Value.([]interface{}); ok && i < len(values) {
+				value = values[i]
+			}
+			rows[i] = append(rows[i], formatFeatureInfoValue(value))
+		}
+	}
+	return rows
+}
+
+// FeatureInfo is the information of the pixel of a WMS GetFeatureInfo
+// request
+type FeatureInfo struct {
+	X              float64
+	Y              float64
+	Time           string
+	Times          []string
+	Bands          FeatureInfoBands
+	Units          map[string]string
+	AvailableDates []string
+	DataLinks      []string
+	TimeSeries     *FeatureInfoTimeSeries
+	Error          string
+}
+
+// MarshalJSON keeps the bands as keys of the feature info object, next
+// to its coordinates and times
+func (info *FeatureInfo) MarshalJSON() ([]byte, error) {
+	head, err := json.Marshal(struct {
+		X     float64  `json:"x"`
+		Y     float64  `json:"y"`
+		Time  string   `json:"time,omitempty"`
+		Times []string `json:"times,omitempty"`
+	}{info.X, info.Y, info.Time, info.Times})
+	if err != nil {
+		return nil, err
+	}
+
+	bands, err := json.Marshal(info.Bands)
+	if err != nil {
+		return nil, err
+	}
+
+	tail, err := json.Marshal(struct {
+		Units          map[string]string      `json:"units,omitempty"`
+		AvailableDates []string               `json:"data_available_for_dates,omitempty"`
+		DataLinks      []string               `json:"data_links,omitempty"`
+		TimeSeries     *FeatureInfoTimeSeries `json:"time_series,omitempty"`
+		Error          string                 `json:"error,omitempty"`
+	}{info.Units, info.AvailableDates, info.DataLinks, info.TimeSeries, info.Error})
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for _, obj := range [][]byte{head, bands, tail} {
+		members := obj[1 : len(obj)-1]
+		if len(members) == 0 {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(members)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func formatFeatureInfoValue(value interface{}) string {
+	if value == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// EncodeFeatureInfo encodes the information of a pixel in the JSON, text
+// or GML format of GetFeatureInfo responses
+func EncodeFeatureInfo(info *FeatureInfo, format string) ([]byte, error) {
+	switch format {
+	case FeatureInfoJSON:
+		return encodeFeatureInfoJSON(info)
+	case FeatureInfoText:
+		return encodeFeatureInfoText(info), nil
+	case FeatureInfoGML:
+		return encodeFeatureInfoGML(info), nil
+	}
+	return nil, fmt.Errorf("unsupported GetFeatureInfo format: %s", format)
+}
+
+func encodeFeatureInfoJSON(info *FeatureInfo) ([]byte, error) {
+	type feature struct {
+		Type       string       `json:"type"`
+		Properties *FeatureInfo `json:"properties"`
+	}
+	type featureCollection struct {
+		Type     string    `json:"type"`
+		Features []feature `json:"features"`
+	}
+
+	return json.Marshal(&featureCollection{Type: "FeatureCollection",
+		Features: []feature{{Type: "Feature", Properties: info}}})
+}
+
+func encodeFeatureInfoText(info *FeatureInfo) []byte {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "x = %v\ny = %v\n", info.X, info.Y)
+	if len(info.Time) > 0 {
+		fmt.Fprintf(buf, "time = %s\n", info.Time)
+	}
+	if len(info.Times) > 0 {
+		fmt.Fprintf(buf, "times = %s\n", strings.Join(info.Times, ", "))
+	}
+	if len(info.Error) > 0 {
+		fmt.Fprintf(buf, "error = %s\n", info.Error)
+	}
+
+	for _, band := range info.Bands {
+		fmt.Fprintf(buf, "%s = %s", band.Name, formatFeatureInfoValue(band.Value))
+		if u, found := info.Units[band.Name]; found {
+			fmt.Fprintf(buf, " %s", u)
+		}
+		buf.WriteByte('\n')
+	}
+
+	if len(info.AvailableDates) > 0 {
+		fmt.Fprintf(buf, "data_available_for_dates = %s\n", strings.Join(info.AvailableDates, ", "))
+	}
+	for _, link := range info.DataLinks {
+		fmt.Fprintf(buf, "data_link = %s\n", link)
+	}
+
+	if info.TimeSeries != nil {
+		buf.WriteString("\ndate")
+		for _, band := range info.TimeSeries.Bands {
+			fmt.Fprintf(buf, "\t%s", band.Name)
+		}
+		buf.WriteByte('\n')
+		for _, row := range info.TimeSeries.Rows() {
+			buf.WriteString(strings.Join(row, "\t"))
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func xmlEscape(s string) string {
+	buf := new(bytes.Buffer)
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
+
+func encodeFeatureInfoGML(info *FeatureInfo) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<gml:FeatureCollection xmlns:gml="http://www.opengis.net/gml" xmlns:gsky="http://gsky.nci.org.au/gml">` + "\n")
+	buf.WriteString("  <gml:featureMember>\n    <gsky:pixel>\n")
+	fmt.Fprintf(buf, "      <gml:pointProperty><gml:Point><gml:coordinates>%v,%v</gml:coordinates></gml:Point></gml:pointProperty>\n", info.X, info.Y)
+	if len(info.Time) > 0 {
+		fmt.Fprintf(buf, "      <gsky:time>%s</gsky:time>\n", xmlEscape(info.Time))
+	}
+	for _, t := range info.Times {
+		fmt.Fprintf(buf, "      <gsky:time>%s</gsky:time>\n", xmlEscape(t))
+	}
+	if len(info.Error) > 0 {
+		fmt.Fprintf(buf, "      <gsky:error>%s</gsky:error>\n", xmlEscape(info.Error))
+	}
+
+	for _, band := range info.Bands {
+		fmt.Fprintf(buf, `      <gsky:band name="%s"`, xmlEscape(band.Name))
+		if u, found := info.Units[band.Name]; found {
+			fmt.Fprintf(buf, ` units="%s"`, xmlEscape(u))
+		}
+		fmt.Fprintf(buf, ">%s</gsky:band>\n", xmlEscape(formatFeatureInfoValue(band.Value)))
+	}
+
+	for _, date := range info.AvailableDates {
+		fmt.Fprintf(buf, "      <gsky:availableDate>%s</gsky:availableDate>\n", xmlEscape(date))
+	}
+	for _, link := range info.DataLinks {
+		fmt.Fprintf(buf, "      <gsky:dataLink>%s</gsky:dataLink>\n", xmlEscape(link))
+	}
+
+	if info.TimeSeries != nil {
+		buf.WriteString("      <gsky:timeSeries>\n")
+		for _, row := range info.TimeSeries.Rows() {
+			fmt.Fprintf(buf, `        <gsky:sample date="%s">`, xmlEscape(row[0]))
+			for ib, band := range info.TimeSeries.Bands {
+				fmt.Fprintf(buf, `<gsky:band name="%s">%s</gsky:band>`, xmlEscape(band.Name), xmlEscape(row[ib+1]))
+			}
+			buf.WriteString("</gsky:sample>\n")
+		}
+		buf.WriteString("      </gsky:timeSeries>\n")
+	}
+
+	buf.WriteString("    </gsky:pixel>\n  </gml:featureMember>\n</gml:FeatureCollection>\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFeatureInfoFormat(t *testing.T) {
+	cases := map[string]string{
+		"application/json":            FeatureInfoJSON,
+		"text/html; charset=UTF-8":    FeatureInfoHTML,
+		"TEXT/PLAIN":                  FeatureInfoText,
+		"application/vnd.ogc.gml":     FeatureInfoGML,
+		"application/vnd.ogc.wms_xml": "",
+	}
+	for infoFormat, expected := range cases {
+		if got := ParseFeatureInfoFormat(infoFormat); got != expected {
+			t.Errorf("%s: got '%s', expected '%s'", infoFormat, got, expected)
+		}
+	}
+}
+
+func TestEncodeFeatureInfo(t *testing.T) {
+	info := &FeatureInfo{X: 150.5, Y: -35.25, Time: "2020-01-01T00:00:00.000Z",
+		Bands: FeatureInfoBands{{Name: "sst", Value: float32(0.1)}, {Name: "mask", Value: "n/a"}},
+		Units: map[string]string{"sst": "degC"},
+		TimeSeries: &FeatureInfoTimeSeries{Dates: []string{"2020-01-01T00:00:00.000Z", "2020-01-02T00:00:00.000Z"},
+			Bands: FeatureInfoBands{{Name: "sst", Value: []interface{}{float32(1.5), nil}}},
+		},
+	}
+
+	out, err := EncodeFeatureInfo(info, FeatureInfoJSON)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{"x":150.5,"y":-35.25,"time":"2020-01-01T00:00:00.000Z",` +
+		`"sst":0.1,"mask":"n/a","units":{"sst":"degC"},` +
+		`"time_series":{"dates":["2020-01-01T00:00:00.000Z","2020-01-02T00:00:00.000Z"],"values":{"sst":[1.5,null]}}}}]}`
+	if string(out) != expected {
+		t.Errorf("unexpected JSON:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	out, err = EncodeFeatureInfo(&FeatureInfo{Error: "no data"}, FeatureInfoJSON)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected = `{"type":"FeatureCollection","features":[{"type":"Feature","properties":{"x":0,"y":0,"error":"no data"}}]}`
+	if string(out) != expected {
+		t.Errorf("unexpected JSON:\n%s\nexpected:\n%s", out, expected)
+	}
+
+	out, err = EncodeFeatureInfo(info, FeatureInfoText)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, line := range []string{"sst = 0.1 degC\n", "mask = n/a\n", "2020-01-02T00:00:00.000Z\tn/a\n"} {
+		if !strings.Contains(string(out), line) {
+			t.Errorf("expected %q in text:\n%s", line, out)
+		}
+	}
+
+	info.Error = "a < b"
+	out, err = EncodeFeatureInfo(info, FeatureInfoGML)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, elem := range []string{`<gsky:band name="sst" units="degC">0.1</gsky:band>`, `<gsky:error>a &lt; b</gsky:error>`} {
+		if !strings.Contains(string(out), elem) {
+			t.Errorf("expected %s in GML:\n%s", elem, out)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// GrpcSecurity configures TLS and bearer token authentication of the
+// connections from OWS to the gRPC workers. Secrets are referenced by
+// file path so that they never end up in dumped or cached configs.
+type GrpcSecurity struct {
+	TLS           bool   `json:"tls"`
+	CACertFile    string `json:"ca_cert_file"`
+	CertFile      string `json:"cert_file"`
+	KeyFile       string `json:"key_file"`
+	ServerName    string `json:"server_name"`
+	AuthTokenFile string `json:"auth_token_file"`
+
+	// AllowInsecureToken permits sending the auth token over plaintext
+	// connections
+	AllowInsecureToken bool `json:"allow_insecure_token"`
+}
+
+func (s *GrpcSecurity) tlsEnabled() bool {
+	return s.TLS || len(s.CACertFile) > 0 || len(s.CertFile) > 0
+}
+
+// Check returns an error if the auth token would be sent over plaintext
+// connections without AllowInsecureToken
+func (s *GrpcSecurity) Check() error {
+	if s == nil || len(s.AuthTokenFile) == 0 || s.tlsEnabled() || s.AllowInsecureToken {
+		return nil
+	}
+	return fmt.Errorf("auth_token_file requires TLS unless allow_insecure_token is set")
+}
+
+// DialOptions returns the gRPC dial options implementing the
+// security settings. A nil GrpcSecurity yields a plaintext connection.
+func (s *GrpcSecurity) DialOptions() ([]grpc.DialOption, error) {
+	if s == nil {
+		return []grpc.DialOption{grpc.WithInsecure()}, nil
+	}
+
+	if err := s.Check(); err != nil {
+		return nil, err
+	}
+
+	var opts []grpc.DialOption
+	if s.tlsEnabled() {
+		tlsConfig := &tls.Config{ServerName: s.ServerName}
+		if len(s.CACertFile) > 0 {
+			certPool, err := loadCertPool(s.CACertFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = certPool
+		}
+
+		if len(s.CertFile) > 0 || len(s.KeyFile) > 0 {
+			cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load gRPC client certificate: %v", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+
+	if len(s.AuthTokenFile) > 0 {
+		token, err := ReadAuthToken(s.AuthTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(&tokenCredentials{token: token, requireTLS: s.tlsEnabled()}))
+	}
+
+	return opts, nil
+}
+
+// ReadAuthToken reads a bearer token from file, ignoring surrounding
+// whitespace.
+func ReadAuthToken(tokenFile string) (string, error) {
+	data, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read gRPC auth token: %v", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if len(token) == 0 {
+		return "", fmt.Errorf("gRPC auth token file is empty: %s", tokenFile)
+	}
+	return token, nil
+}
+
+// LoadServerTLSConfig returns the TLS config of a gRPC worker. Client
+// certificates are required and verified if clientCAFile is set.
+func LoadServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gRPC server certificate: %v", err)
+	}
+
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if len(clientCAFile) > 0 {
+		certPool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = certPool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// TokenAuthUnaryInterceptor rejects requests which do not carry the
+// bearer token in their authorization metadata.
+func TokenAuthUnaryInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkAuthToken(ctx, token); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// TokenAuthStreamInterceptor is the streaming counterpart of
+// TokenAuthUnaryInterceptor.
+func TokenAuthStreamInterceptor(token string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkAuthToken(ss.Context(), token); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func checkAuthToken(ctx context.Context, token string) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "missing metadata")
+	}
+
+	expected := []byte("Bearer " + token)
+	for _, auth := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(auth), expected) == 1 {
+			return nil
+		}
+	}
+	return status.Errorf(codes.Unauthenticated, "invalid auth token")
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %v", err)
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid CA certificate found in %s", caFile)
+	}
+	return certPool, nil
+}
+
+type tokenCredentials struct {
+	token      string
+	requireTLS bool
+}
+
+func (t *tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t *tokenCredentials) RequireTransportSecurity() bool {
+	return t.requireTLS
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckAuthToken(t *testing.T) {
+	token := "s3cr3t"
+	cases := []struct {
+		md   metadata.MD
+		code codes.Code
+	}{
+		{metadata.Pairs("authorization", "Bearer "+token), codes.OK},
+		{metadata.Pairs("authorization", "Bearer wrong"), codes.Unauthenticated},
+		{metadata.Pairs("authorization", token), codes.Unauthenticated},
+		{metadata.MD{}, codes.Unauthenticated},
+	}
+
+	for i, c := range cases {
+		ctx := metadata.NewIncomingContext(context.Background(), c.md)
+		if code := status.Code(checkAuthToken(ctx, token)); code != c.code {
+			t.Errorf("case %d: expected %v, got %v", i, c.code, code)
+		}
+	}
+
+	if status.Code(checkAuthToken(context.Background(), token)) != codes.Unauthenticated {
+		t.Errorf("request without metadata was accepted")
+	}
+}
+
+func TestGrpcSecurityCheck(t *testing.T) {
+	if err := (&GrpcSecurity{AuthTokenFile: "worker.token"}).Check(); err == nil {
+		t.Errorf("auth token over plaintext was accepted")
+	}
+	if err := (&GrpcSecurity{AuthTokenFile: "worker.token", AllowInsecureToken: true}).Check(); err != nil {
+		t.Errorf("%v", err)
+	}
+	if err := (&GrpcSecurity{AuthTokenFile: "worker.token", TLS: true}).Check(); err != nil {
+		t.Errorf("%v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+
+	pb "github.com/nci/gsky/worker/gdalservice"
+
+	// Registers the gzip compressor for grpc_compression
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+const DefaultGrpcChunkSize = 1024 * 1024
+
+// SendRasterStream sends a Result over a ProcessStream call. The raster
+// data is split into chunks of at most chunkSize bytes.
+func SendRasterStream(stream pb.GDAL_ProcessStreamServer, out *pb.Result, chunkSize int) error {
+	if chunkSize <= 0 {
+		chunkSize = DefaultGrpcChunkSize
+	}
+
+	var data []byte
+	if out.Raster != nil {
+		data = out.Raster.Data
+		out.Raster.Data = nil
+	}
+
+	err := stream.Send(&pb.RasterChunk{Header: out, TotalSize: int64(len(data))})
+	if err != nil {
+		return err
+	}
+
+	for len(data) > 0 {
+		n := chunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+
+		err = stream.Send(&pb.RasterChunk{Data: data[:n]})
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+
+	return nil
+}
+
+// RecvRasterStream reassembles the Result sent by SendRasterStream.
+func RecvRasterStream(stream pb.GDAL_ProcessStreamClient) (*pb.Result, error) {
+	chunk, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	out := chunk.Header
+	if out == nil {
+		return nil, fmt.Errorf("raster stream is missing its header")
+	}
+
+	if chunk.TotalSize < 0 {
+		return nil, fmt.Errorf("invalid raster stream size: %d", chunk.TotalSize)
+	}
+
+	totalSize := int(chunk.TotalSize)
+	data := make([]byte, 0, totalSize)
+	for {
+		chunk, err = stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(data)+len(chunk.Data) > totalSize {
+			return nil, fmt.Errorf("raster stream exceeds its size of %d bytes", totalSize)
+		}
+		data = append(data, chunk.Data...)
+	}
+
+	if len(data) != totalSize {
+		return nil, fmt.Errorf("raster stream truncated: %d of %d bytes received", len(data), totalSize)
+	}
+
+	if out.Raster != nil {
+		out.Raster.Data = data
+	} else if len(data) > 0 {
+		return nil, fmt.Errorf("raster stream has data but no raster")
+	}
+
+	return out, nil
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	pb "github.com/nci/gsky/worker/gdalservice"
+	"google.golang.org/grpc"
+)
+
+type testChunkSender struct {
+	grpc.ServerStream
+	chunks []*pb.RasterChunk
+}
+
+func (s *testChunkSender) Send(c *pb.RasterChunk) error {
+	s.chunks = append(s.chunks, c)
+	return nil
+}
+
+type testChunkReceiver struct {
+	grpc.ClientStream
+	chunks []*pb.RasterChunk
+}
+
+func (s *testChunkReceiver) Recv() (*pb.RasterChunk, error) {
+	if len(s.chunks) == 0 {
+		return nil, io.EOF
+	}
+	c := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return c, nil
+}
+
+func TestRasterStream(t *testing.T) {
+	data := make([]byte, 2500)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	sender := &testChunkSender{}
+	out := &pb.Result{Raster: &pb.Raster{Data: data, RasterType: "Byte", NoData: 255}, Shape: []int32{50, 50}}
+	if err := SendRasterStream(sender, out, 1000); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if len(sender.chunks) != 4 {
+		t.Errorf("expected 4 chunks, got %d", len(sender.chunks))
+	}
+
+	r, err := RecvRasterStream(&testChunkReceiver{chunks: sender.chunks})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(r.Raster.Data, data) || r.Raster.RasterType != "Byte" || len(r.Shape) != 2 {
+		t.Errorf("raster was not reassembled")
+	}
+
+	sender = &testChunkSender{}
+	SendRasterStream(sender, &pb.Result{Raster: &pb.Raster{Data: data}}, 1000)
+	truncated := sender.chunks[:len(sender.chunks)-1]
+	if _, err := RecvRasterStream(&testChunkReceiver{chunks: truncated}); err == nil {
+		t.Errorf("truncated stream was accepted")
+	}
+
+	sender = &testChunkSender{}
+	SendRasterStream(sender, &pb.Result{Error: "OK"}, 1000)
+	r, err = RecvRasterStream(&testChunkReceiver{chunks: sender.chunks})
+	if err != nil || r.Error != "OK" || r.Raster != nil {
+		t.Errorf("result without raster was not passed through: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/encoding"
+)
+
+// ZstdCompressorName is the grpc_compression of the zstd compressor
+const ZstdCompressorName = "zstd"
+
+func init() {
+	encoding.RegisterCompressor(&zstdCompressor{})
+}
+
+// zstdCompressor is a gRPC compressor of zstd messages. Its encoders and
+// decoders run synchronously, i.e. without goroutines of their own, so
+// that they are reused through pools without being closed.
+type zstdCompressor struct {
+	encoders sync.Pool
+	decoders sync.Pool
+}
+
+type zstdWriter struct {
+	*zstd.Encoder
+	pool *sync.Pool
+}
+
+// Close flushes the message and returns the encoder to its pool
+func (w *zstdWriter) Close() error {
+	err := w.Encoder.Close()
+	w.Encoder.Reset(nil)
+	w.pool.Put(w)
+	return err
+}
+
+type zstdReader struct {
+	*zstd.Decoder
+	pool *sync.Pool
+}
+
+// Read returns the decoder to its pool at the end of the message
+func (r *zstdReader) Read(p []byte) (int, error) {
+	n, err := r.Decoder.Read(p)
+	if err == io.EOF {
+		r.Decoder.Reset(nil)
+		r.pool.Put(r)
+	}
+	return n, err
+}
+
+func (c *zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	if zw, ok := c.encoders.Get().(*zstdWriter); ok {
+		zw.Encoder.Reset(w)
+		return zw, nil
+	}
+
+	enc, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdWriter{Encoder: enc, pool: &c.encoders}, nil
+}
+
+func (c *zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	if zr, ok := c.decoders.Get().(*zstdReader); ok {
+		if err := zr.Decoder.Reset(r); err != nil {
+			return nil, err
+		}
+		return zr, nil
+	}
+
+	dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdReader{Decoder: dec, pool: &c.decoders}, nil
+}
+
+func (c *zstdCompressor) Name() string {
+	return ZstdCompressorName
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"google.golang.org/grpc/encoding"
+)
+
+func TestZstdCompressor(t *testing.T) {
+	c := encoding.GetCompressor(ZstdCompressorName)
+	if c == nil {
+		t.Fatalf("expected %s compressor to be registered", ZstdCompressorName)
+	}
+
+	data := make([]byte, 100000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	// Twice so that the pooled encoder and decoder are reused
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		w, err := c.Compress(&buf)
+		if err != nil {
+			t.Fatalf("Compress failed: %v", err)
+		}
+		if _, err = w.Write(data); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if err = w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if buf.Len() >= len(data) {
+			t.Errorf("expected fewer than %d compressed bytes, got %d", len(data), buf.Len())
+		}
+
+		r, err := c.Decompress(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Decompress failed: %v", err)
+		}
+		out, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Errorf("expected %d decompressed bytes to match, got %d", len(data), len(out))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package lru provides the size bounded LRU cache shared by the response
+// caches of MAS and the OWS server. It is kept apart from utils so that
+// it can be used without linking GDAL.
+package lru
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	key     string
+	value   interface{}
+	size    int
+	expires time.Time
+}
+
+// Cache is a LRU cache bounded by the total size of its values. Entries
+// expire after the TTL if it is positive.
+type Cache struct {
+	maxBytes int
+	ttl      time.Duration
+
+	mutex   sync.Mutex
+	nBytes  int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func New(maxBytes int, ttl time.Duration) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+
+	e := elem.Value.(*entry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return e.value, true
+}
+
+// Put caches value, whose size is size bytes, under key. Values larger
+// than the whole cache are not cached.
+func (c *Cache) Put(key string, value interface{}, size int) {
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, found := c.entries[key]; found {
+		c.remove(elem)
+	}
+
+	e := &entry{key: key, value: value, size: size}
+	if c.ttl > 0 {
+		e.expires = time.Now().Add(c.ttl)
+	}
+	c.entries[key] = c.order.PushFront(e)
+	c.nBytes += size
+
+	for c.nBytes > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	e := c.order.Remove(elem).(*entry)
+	delete(c.entries, e.key)
+	c.nBytes -= e.size
+}
+
+// RemoveIf drops the entries for which fn returns true
+func (c *Cache) RemoveIf(fn func(key string, value interface{}) bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		e := elem.Value.(*entry)
+		if fn(e.key, e.value) {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// Purge drops every entry
+func (c *Cache) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	c.nBytes = 0
+}
+
+// Len returns the number of entries
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.entries)
+}
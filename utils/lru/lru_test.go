@@ -0,0 +1,45 @@
+package lru
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := New(10, 0)
+	c.Put("a", "1234", 4)
+	c.Put("b", "1234", 4)
+	if _, found := c.Get("a"); !found {
+		t.Errorf("expected a to be cached")
+	}
+
+	// b is the least recently used once a was read
+	c.Put("c", "1234", 4)
+	if _, found := c.Get("b"); found {
+		t.Errorf("expected b to be evicted")
+	}
+
+	c.Put("d", "01234567890", 11)
+	if _, found := c.Get("d"); found {
+		t.Errorf("expected a value larger than the cache not to be cached")
+	}
+
+	c.Put("ns|e", "1", 1)
+	c.RemoveIf(func(key string, value interface{}) bool { return strings.HasPrefix(key, "ns|") })
+	if _, found := c.Get("ns|e"); found || c.Len() != 2 {
+		t.Errorf("expected only ns|e to be removed, got %d entries", c.Len())
+	}
+
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("expected an empty cache after purge, got %d entries", c.Len())
+	}
+
+	c = New(10, time.Millisecond)
+	c.Put("a", "1234", 4)
+	time.Sleep(5 * time.Millisecond)
+	if _, found := c.Get("a"); found {
+		t.Errorf("expected a to expire")
+	}
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Time a MAS changes request waits for a change
+const MASChangesTimeout = 60 * time.Second
+
+// Longest delay before retrying a failed MAS changes request
+const MASChangesMaxBackoff = time.Minute
+
+// MASChange announces that files were added or removed under GPath
+type MASChange struct {
+	Seq   int64     `json:"seq"`
+	GPath string    `json:"gpath"`
+	Time  time.Time `json:"time"`
+}
+
+type masChanges struct {
+	Error   string       `json:"error"`
+	Seq     int64        `json:"seq"`
+	Changes []*MASChange `json:"changes"`
+	Reset   bool         `json:"reset"`
+}
+
+// MASChangeSubscriber long polls the change feed of a MAS server
+type MASChangeSubscriber struct {
+	MASAddress string
+	Timeout    time.Duration
+	client     *http.Client
+	verbose    bool
+}
+
+func NewMASChangeSubscriber(masAddress string, verbose bool) *MASChangeSubscriber {
+	return &MASChangeSubscriber{
+		MASAddress: masAddress,
+		Timeout:    MASChangesTimeout,
+		client:     &http.Client{Timeout: MASChangesTimeout + 30*time.Second},
+		verbose:    verbose,
+	}
+}
+
+func (s *MASChangeSubscriber) poll(since *int64) (*masChanges, error) {
+	reqURL := fmt.Sprintf("http://%s/?changes&timeout=%v", s.MASAddress, s.Timeout)
+	if since != nil {
+		reqURL += fmt.Sprintf("&since=%d", *since)
+	}
+	if s.verbose {
+		log.Printf("querying MAS for changes: %v", reqURL)
+	}
+
+	resp, err := s.client.Get(reqURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &masChanges{}
+	if err = json.Unmarshal(body, result); err != nil {
+		return nil, err
+	}
+	if len(result.Error) > 0 {
+		return nil, fmt.Errorf("%s", result.Error)
+	}
+	return result, nil
+}
+
+// Run calls fn with the changed gpaths announced by MAS until the
+// process exits. reset is true if changes might have been missed, in
+// which case every gpath must be considered changed. Requests failing
+// are retried with backoff, after which fn is called with reset.
+func (s *MASChangeSubscriber) Run(fn func(gpaths []string, reset bool)) {
+	go func() {
+		var since *int64
+		backoff := time.Second
+		failed := false
+		for {
+			result, err := s.poll(since)
+			if err != nil {
+				if !failed {
+					log.Printf("MAS changes %s: %v", s.MASAddress, err)
+				}
+				failed = true
+				time.Sleep(backoff)
+				backoff *= 2
+				if backoff > MASChangesMaxBackoff {
+					backoff = MASChangesMaxBackoff
+				}
+				continue
+			}
+			backoff = time.Second
+
+			reset := result.Reset || (failed && since != nil)
+			failed = false
+
+			var gpaths []string
+			for _, change := range result.Changes {
+				gpaths = append(gpaths, change.GPath)
+			}
+			if len(gpaths) > 0 || reset {
+				fn(gpaths, reset)
+			}
+
+			seq := result.Seq
+			since = &seq
+		}
+	}()
+}
+
+// GPathRelated reports whether a layer reading dataSource is affected
+// by changes under gpath, i.e. either path contains the other
+func GPathRelated(dataSource string, gpath string) bool {
+	dataSource = "/" + strings.Trim(dataSource, "/")
+	gpath = "/" + strings.Trim(gpath, "/")
+	if gpath == "/" || dataSource == gpath {
+		return true
+	}
+	return strings.HasPrefix(dataSource, gpath+"/") || strings.HasPrefix(gpath, dataSource+"/")
+}
+
+// ChangedNamespaces returns the namespaces of the configs with layers
+// indexed by masAddress and affected by changes under gpaths
+func ChangedNamespaces(confMap map[string]*Config, masAddress string, gpaths []string, reset bool) []string {
+	var namespaces []string
+	for ns, config := range confMap {
+		if config == nil {
+			continue
+		}
+
+		changed := false
+		for iLayer := range config.Layers {
+			layer := &config.Layers[iLayer]
+			layerMAS := layer.MASAddress
+			if len(layerMAS) == 0 {
+				layerMAS = config.ServiceConfig.MASAddress
+			}
+			if layerMAS != masAddress || len(strings.TrimSpace(layer.DataSource)) == 0 {
+				continue
+			}
+
+			if reset {
+				changed = true
+				break
+			}
+			for _, gpath := range gpaths {
+				if GPathRelated(layer.DataSource, gpath) {
+					changed = true
+					break
+				}
+			}
+			if changed {
+				break
+			}
+		}
+
+		if changed {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// ReloadConfigTimestamps returns a copy of config with the layer dates
+// reloaded. config is left untouched as it may be serving requests.
+func ReloadConfigTimestamps(config *Config, verbose bool) (*Config, error) {
+	newConf := *config
+	newConf.Layers = make([]Layer, len(config.Layers))
+	copy(newConf.Layers, config.Layers)
+
+	err := LoadConfigTimestamps(&newConf, verbose)
+	return &newConf, err
+}
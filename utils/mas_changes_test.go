@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestGPathRelated(t *testing.T) {
+	testCases := []struct {
+		dataSource string
+		gpath      string
+		related    bool
+	}{
+		{"/g/data/ab1/prod", "/g/data/ab1/prod", true},
+		{"/g/data/ab1/prod", "/g/data/ab1/prod/2020", true},
+		{"/g/data/ab1/prod/", "/g/data/ab1", true},
+		{"/g/data/ab1/prod", "/", true},
+		{"/g/data/ab1/prod", "/g/data/ab1/production", false},
+		{"/g/data/ab1/prod", "/g/data/ab2", false},
+	}
+
+	for _, tc := range testCases {
+		if related := GPathRelated(tc.dataSource, tc.gpath); related != tc.related {
+			t.Errorf("GPathRelated(%s, %s) = %v, expected %v", tc.dataSource, tc.gpath, related, tc.related)
+		}
+	}
+}
+
+func TestChangedNamespaces(t *testing.T) {
+	confMap := map[string]*Config{
+		"ab1": {
+			ServiceConfig: ServiceConfig{MASAddress: "mas:8888"},
+			Layers:        []Layer{{Name: "a", DataSource: "/g/data/ab1/prod"}},
+		},
+		"ab2": {
+			ServiceConfig: ServiceConfig{MASAddress: "mas:8888"},
+			Layers:        []Layer{{Name: "b", DataSource: "/g/data/ab2"}},
+		},
+		"other": {
+			ServiceConfig: ServiceConfig{MASAddress: "mas2:8888"},
+			Layers:        []Layer{{Name: "c", DataSource: "/g/data/ab1/prod"}},
+		},
+	}
+
+	namespaces := ChangedNamespaces(confMap, "mas:8888", []string{"/g/data/ab1/prod/2020"}, false)
+	if len(namespaces) != 1 || namespaces[0] != "ab1" {
+		t.Errorf("expected namespace ab1 to change, got %v", namespaces)
+	}
+
+	namespaces = ChangedNamespaces(confMap, "mas:8888", nil, true)
+	if len(namespaces) != 2 {
+		t.Errorf("expected a reset to change 2 namespaces, got %v", namespaces)
+	}
+}
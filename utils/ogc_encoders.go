@@ -77,6 +77,39 @@ func (r *Float32Raster) GetNoData() float64 {
 	return r.NoData
 }
 
+type Int32Raster struct {
+	NameSpace     string
+	Data          []int32
+	Height, Width int
+	NoData        float64
+}
+
+func (r *Int32Raster) GetNoData() float64 {
+	return r.NoData
+}
+
+type UInt32Raster struct {
+	NameSpace     string
+	Data          []uint32
+	Height, Width int
+	NoData        float64
+}
+
+func (r *UInt32Raster) GetNoData() float64 {
+	return r.NoData
+}
+
+type Float64Raster struct {
+	NameSpace     string
+	Data          []float64
+	Height, Width int
+	NoData        float64
+}
+
+func (r *Float64Raster) GetNoData() float64 {
+	return r.NoData
+}
+
 const EmptyTileNS = "EmptyTile"
 
 func EncodePNG(br []*ByteRaster, palette *Palette) ([]byte, error) {
@@ -233,6 +266,60 @@ func ValidateRasterSlice(rs []Raster) (int, int, string, error) {
 				err = fmt.Errorf("Mixed width sizes")
 			}
 
+			if height == 0 {
+				height = t.Height
+			} else if height != t.Height {
+				err = fmt.Errorf("Mixed height sizes")
+			}
+		case *Int32Raster:
+			if rasterType == "" {
+				rasterType = "Int32"
+			} else if rasterType != "Int32" {
+				err = fmt.Errorf("Mixed types")
+			}
+
+			if width == 0 {
+				width = t.Width
+			} else if width != t.Width {
+				err = fmt.Errorf("Mixed width sizes")
+			}
+
+			if height == 0 {
+				height = t.Height
+			} else if height != t.Height {
+				err = fmt.Errorf("Mixed height sizes")
+			}
+		case *UInt32Raster:
+			if rasterType == "" {
+				rasterType = "UInt32"
+			} else if rasterType != "UInt32" {
+				err = fmt.Errorf("Mixed types")
+			}
+
+			if width == 0 {
+				width = t.Width
+			} else if width != t.Width {
+				err = fmt.Errorf("Mixed width sizes")
+			}
+
+			if height == 0 {
+				height = t.Height
+			} else if height != t.Height {
+				err = fmt.Errorf("Mixed height sizes")
+			}
+		case *Float64Raster:
+			if rasterType == "" {
+				rasterType = "Float64"
+			} else if rasterType != "Float64" {
+				err = fmt.Errorf("Mixed types")
+			}
+
+			if width == 0 {
+				width = t.Width
+			} else if width != t.Width {
+				err = fmt.Errorf("Mixed width sizes")
+			}
+
 			if height == 0 {
 				height = t.Height
 			} else if height != t.Height {
@@ -435,6 +522,51 @@ func EncodeGdal(hDstDS C.GDALDatasetH, rs []Raster, xOff int, yOff int) ([]strin
 
 			gerr = C.GDALRasterIO(hBand, C.GF_Write, C.int(xOff), C.int(yOff), C.int(t.Width), C.int(t.Height), unsafe.Pointer(&t.Data[0]), C.int(t.Width), C.int(t.Height), C.GDT_Float32, 0, 0)
 
+		case *Int32Raster:
+			bandNames[i] = t.NameSpace
+			if isEmptyTile(t.NameSpace) {
+				continue
+			}
+			C.GDALSetRasterNoDataValue(hBand, C.double(t.NoData))
+			varNameC := C.CString(t.NameSpace)
+			gerr = C.GDALSetMetadataItem(C.GDALMajorObjectH(hBand), resNameSpaceC, varNameC, nil)
+			C.free(unsafe.Pointer(varNameC))
+			if gerr != 0 {
+				break
+			}
+
+			gerr = C.GDALRasterIO(hBand, C.GF_Write, C.int(xOff), C.int(yOff), C.int(t.Width), C.int(t.Height), unsafe.Pointer(&t.Data[0]), C.int(t.Width), C.int(t.Height), C.GDT_Int32, 0, 0)
+
+		case *UInt32Raster:
+			bandNames[i] = t.NameSpace
+			if isEmptyTile(t.NameSpace) {
+				continue
+			}
+			C.GDALSetRasterNoDataValue(hBand, C.double(t.NoData))
+			varNameC := C.CString(t.NameSpace)
+			gerr = C.GDALSetMetadataItem(C.GDALMajorObjectH(hBand), resNameSpaceC, varNameC, nil)
+			C.free(unsafe.Pointer(varNameC))
+			if gerr != 0 {
+				break
+			}
+
+			gerr = C.GDALRasterIO(hBand, C.GF_Write, C.int(xOff), C.int(yOff), C.int(t.Width), C.int(t.Height), unsafe.Pointer(&t.Data[0]), C.int(t.Width), C.int(t.Height), C.GDT_UInt32, 0, 0)
+
+		case *Float64Raster:
+			bandNames[i] = t.NameSpace
+			if isEmptyTile(t.NameSpace) {
+				continue
+			}
+			C.GDALSetRasterNoDataValue(hBand, C.double(t.NoData))
+			varNameC := C.CString(t.NameSpace)
+			gerr = C.GDALSetMetadataItem(C.GDALMajorObjectH(hBand), resNameSpaceC, varNameC, nil)
+			C.free(unsafe.Pointer(varNameC))
+			if gerr != 0 {
+				break
+			}
+
+			gerr = C.GDALRasterIO(hBand, C.GF_Write, C.int(xOff), C.int(yOff), C.int(t.Width), C.int(t.Height), unsafe.Pointer(&t.Data[0]), C.int(t.Width), C.int(t.Height), C.GDT_Float64, 0, 0)
+
 		default:
 			C.GDALClose(hDstDS)
 			return []string{}, fmt.Errorf("Unsupported gdal data type")
@@ -539,6 +671,10 @@ func RemoveGdalTempFile(tempFile string) {
 // ExtractEPSGCode parses an SRS string and gets
 // the EPSG code
 func ExtractEPSGCode(srs string) (int, error) {
+	srs = NormaliseCRS(srs)
+	if !strings.HasPrefix(srs, "EPSG:") {
+		return -1, fmt.Errorf("not an EPSG code: %s", srs)
+	}
 	return strconv.Atoi(srs[5:])
 }
 
@@ -565,6 +701,18 @@ func CheckEmptyTile(rs []Raster) (bool, error) {
 			if isEmptyTile(t.NameSpace) {
 				return true, nil
 			}
+		case *Int32Raster:
+			if isEmptyTile(t.NameSpace) {
+				return true, nil
+			}
+		case *UInt32Raster:
+			if isEmptyTile(t.NameSpace) {
+				return true, nil
+			}
+		case *Float64Raster:
+			if isEmptyTile(t.NameSpace) {
+				return true, nil
+			}
 		default:
 			return false, fmt.Errorf("Raster type not implemented")
 		}
@@ -43,42 +43,70 @@ func TestEncodeGdal(t *testing.T) {
 	}
 
 	// we test all the four corner cases
-	err = EncodeGdal(hDstDS, rs, 0, 0)
+	_, err = EncodeGdal(hDstDS, rs, 0, 0)
 	if err != nil {
 		t.Errorf("failed to write to gdal dataset file: %v", err)
 		return
 	}
 
-	err = EncodeGdal(hDstDS, rs, width-raster.Width, 0)
+	_, err = EncodeGdal(hDstDS, rs, width-raster.Width, 0)
 	if err != nil {
 		t.Errorf("failed to write to gdal dataset file: %v", err)
 		return
 	}
 
-	err = EncodeGdal(hDstDS, rs, width-raster.Width, height-raster.Height)
+	_, err = EncodeGdal(hDstDS, rs, width-raster.Width, height-raster.Height)
 	if err != nil {
 		t.Errorf("failed to write to gdal dataset file: %v", err)
 		return
 	}
 
-	err = EncodeGdal(hDstDS, rs, 0, height-raster.Height)
+	_, err = EncodeGdal(hDstDS, rs, 0, height-raster.Height)
 	if err != nil {
 		t.Errorf("failed to write to gdal dataset file: %v", err)
 		return
 	}
 }
 
+func TestEncodeGdalTypes(t *testing.T) {
+	for _, raster := range []Raster{
+		&Int32Raster{NameSpace: "test-ns", Data: []int32{-100000, 0, 100000, -1}, Width: 2, Height: 2, NoData: -1},
+		&UInt32Raster{NameSpace: "test-ns", Data: []uint32{4000000000, 0, 1, 2}, Width: 2, Height: 2},
+		&Float64Raster{NameSpace: "test-ns", Data: []float64{1e-300, 1e300, 0.5, -9999}, Width: 2, Height: 2, NoData: -9999},
+	} {
+		rs := []Raster{raster}
+		_, _, rType, err := ValidateRasterSlice(rs)
+		if err != nil {
+			t.Errorf("failed to validate raster: %v", err)
+			continue
+		}
+
+		hDstDS, tempFile, err := EncodeGdalOpen("/tmp", 256, 256, "geotiff", []float64{-179, 0.359, 0, 80, 0, -0.16}, 4326, rs, 2, 2, 1)
+		if err != nil {
+			t.Errorf("failed to create %s gdal file: %v", rType, err)
+			continue
+		}
+
+		_, err = EncodeGdal(hDstDS, rs, 0, 0)
+		os.Remove(tempFile)
+		if err != nil {
+			t.Errorf("failed to write %s to gdal dataset file: %v", rType, err)
+		}
+	}
+}
+
 func testEncodeGdalFlush(t *testing.T) {
 	raster := ByteRaster{NameSpace: "test-ns", Data: []uint8{}, Width: 5, Height: 5}
 	rs := []Raster{&raster}
 	hDstDS, tempFile, err := EncodeGdalOpen("/tmp", 256, 256, "geotiff", []float64{-179, 0.359, 0, 80, 0, -0.16}, 4326, rs, 1000, 1000, 1)
 	defer os.Remove(tempFile)
-
-	newDstDS, err := EncodeGdalFlush(hDstDS, tempFile, "geotiff")
-	if err != nil || newDstDS == nil {
-		t.Errorf("Failed to re-open existing dataset file: %v", err)
+	if err != nil {
+		t.Errorf("Failed to create dataset file: %v", err)
 		return
 	}
+
+	EncodeGdalFlush(hDstDS)
+	EncodeGdalClose(&hDstDS)
 }
 
 func testEncodeGdalMerge(t *testing.T) {
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// OGC exception codes
+const (
+	InvalidParameterValue    = "InvalidParameterValue"
+	MissingParameterValue    = "MissingParameterValue"
+	InvalidFormat            = "InvalidFormat"
+	InvalidCRS               = "InvalidCRS"
+	LayerNotDefined          = "LayerNotDefined"
+	StyleNotDefined          = "StyleNotDefined"
+	InvalidDimensionValue    = "InvalidDimensionValue"
+	MissingDimensionValue    = "MissingDimensionValue"
+	OperationNotSupported    = "OperationNotSupported"
+	CoverageNotDefined       = "CoverageNotDefined"
+	VersionNegotiationFailed = "VersionNegotiationFailed"
+	NoApplicableCode         = "NoApplicableCode"
+)
+
+// Formats of WMS exceptions
+const (
+	ExceptionsXML     = "XML"
+	ExceptionsInImage = "INIMAGE"
+	ExceptionsBlank   = "BLANK"
+)
+
+// OGCException is an error reported to OWS clients with an OGC
+// exception code and the parameter that caused it
+type OGCException struct {
+	HTTPStatus int
+	Code       string
+	Locator    string
+	Message    string
+}
+
+func (e *OGCException) Error() string {
+	return e.Message
+}
+
+// NewOGCException returns an OGC exception
+func NewOGCException(status int, code string, locator string, msg string) *OGCException {
+	return &OGCException{HTTPStatus: status, Code: code, Locator: locator, Message: msg}
+}
+
+// OGCExceptionReport is the data of the exception report templates
+type OGCExceptionReport struct {
+	Version string
+	*OGCException
+}
+
+// ParseWMSExceptionsFormat returns the format of the WMS EXCEPTIONS
+// parameter of either WMS 1.3.0, e.g. INIMAGE, or WMS 1.1.1, e.g.
+// application/vnd.ogc.se_inimage. It returns an empty string if the
+// format isn't supported.
+func ParseWMSExceptionsFormat(exceptions string) string {
+	format := strings.ToUpper(strings.TrimSpace(exceptions))
+	format = strings.TrimPrefix(format, "APPLICATION/VND.OGC.SE_")
+	switch format {
+	case ExceptionsXML, ExceptionsInImage, ExceptionsBlank:
+		return format
+	}
+	return ""
+}
+
+// Image formats of GetMap requests that INIMAGE and BLANK exceptions
+// are encoded in
+const (
+	ExceptionImagePNG  = "image/png"
+	ExceptionImageJPEG = "image/jpeg"
+)
+
+// ExceptionImageFormat returns the image format that the INIMAGE and
+// BLANK exceptions of a GetMap request of a FORMAT are encoded in.
+// Formats other than JPEG are PNG, the default format of GetMap.
+func ExceptionImageFormat(format string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "image/jpeg", "image/jpg":
+		return ExceptionImageJPEG
+	}
+	return ExceptionImagePNG
+}
+
+// Margin in pixels around the text of exception images
+const textMargin = 4
+
+// wrapText splits a message into lines of at most maxChars characters,
+// breaking lines between words where possible
+func wrapText(msg string, maxChars int) []string {
+	if maxChars < 1 {
+		maxChars = 1
+	}
+
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(msg) {
+		for len(word) > maxChars {
+			if len(line) > 0 {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, word[:maxChars])
+			word = word[maxChars:]
+		}
+
+		if len(line) == 0 {
+			line = word
+		} else if len(line)+1+len(word) <= maxChars {
+			line += " " + word
+		} else {
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// GetExceptionImage returns an image of width by height pixels in a
+// format of ExceptionImageFormat with the message of an exception drawn
+// into it, i.e. the INIMAGE format of WMS exceptions, or a blank image
+// if the message is empty, i.e. the BLANK format. The message is
+// truncated to the lines that fit into the image. The background of PNG
+// images is transparent and that of JPEG images is white.
+func GetExceptionImage(msg string, width, height int, format string) ([]byte, error) {
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if format == ExceptionImageJPEG {
+		draw.Draw(canvas, canvas.Bounds(), image.White, image.ZP, draw.Src)
+	}
+
+	face := basicfont.Face7x13
+	lineHeight := face.Height + 2
+	lines := wrapText(msg, (width-2*textMargin)/face.Advance)
+	maxLines := (height - 2*textMargin) / lineHeight
+	if maxLines < 0 {
+		maxLines = 0
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+
+	if len(lines) > 0 {
+		background := image.NewUniform(color.NRGBA{R: 255, G: 255, B: 255, A: 200})
+		textHeight := len(lines)*lineHeight + 2*textMargin
+		draw.Draw(canvas, image.Rect(0, 0, width, textHeight), background, image.ZP, draw.Over)
+	}
+
+	drawer := &font.Drawer{Dst: canvas, Src: image.NewUniform(color.NRGBA{R: 170, G: 0, B: 0, A: 255}), Face: face}
+	for il, line := range lines {
+		// Characters missing from the font are drawn as '?' so that
+		// the line keeps its width
+		line = strings.Map(func(r rune) rune {
+			if _, found := face.GlyphAdvance(r); !found {
+				return '?'
+			}
+			return r
+		}, line)
+		drawer.Dot = fixed.P(textMargin, textMargin+il*lineHeight+face.Ascent)
+		drawer.DrawString(line)
+	}
+
+	buf := new(bytes.Buffer)
+	var err error
+	if format == ExceptionImageJPEG {
+		err = jpeg.Encode(buf, canvas, nil)
+	} else {
+		err = png.Encode(buf, canvas)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
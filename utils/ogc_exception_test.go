@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestParseWMSExceptionsFormat(t *testing.T) {
+	cases := map[string]string{
+		"XML":                            ExceptionsXML,
+		"inimage":                        ExceptionsInImage,
+		"application/vnd.ogc.se_blank":   ExceptionsBlank,
+		"application/vnd.ogc.se_inimage": ExceptionsInImage,
+		"application/json":               "",
+	}
+	for exceptions, expected := range cases {
+		if got := ParseWMSExceptionsFormat(exceptions); got != expected {
+			t.Errorf("%s: got '%s', expected '%s'", exceptions, got, expected)
+		}
+	}
+}
+
+func TestGetExceptionImage(t *testing.T) {
+	lines := wrapText("Layer frac_cover doesn't support CRS EPSG:3031", 20)
+	if len(lines) != 3 || lines[0] != "Layer frac_cover" {
+		t.Errorf("unexpected lines: %q", lines)
+	}
+
+	out, err := GetExceptionImage("Layer frac_cover doesn't support CRS EPSG:3031", 100, 60, ExceptionImagePNG)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if img.Bounds().Dx() != 100 || img.Bounds().Dy() != 60 {
+		t.Fatalf("unexpected image size: %v", img.Bounds())
+	}
+
+	hasText := false
+	for y := 0; y < 60 && !hasText; y++ {
+		for x := 0; x < 100; x++ {
+			if r, _, _, a := img.At(x, y).RGBA(); a == 0xffff && r > 0x8000 {
+				hasText = true
+				break
+			}
+		}
+	}
+	if !hasText {
+		t.Errorf("expected the message to be drawn")
+	}
+
+	if _, err := GetExceptionImage("message", 4, 4, ExceptionImagePNG); err != nil {
+		t.Errorf("%v", err)
+	}
+
+	// Blank PNG images are transparent
+	out, err = GetExceptionImage("", 8, 8, ExceptionImagePNG)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	img, err = png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, _, _, a := img.At(4, 4).RGBA(); a != 0 {
+		t.Errorf("expected a transparent blank image")
+	}
+
+	// JPEG images are white rather than transparent
+	if format := ExceptionImageFormat(" IMAGE/JPEG"); format != ExceptionImageJPEG {
+		t.Fatalf("unexpected exception image format %s", format)
+	}
+	if format := ExceptionImageFormat("image/tiff"); format != ExceptionImagePNG {
+		t.Fatalf("unexpected exception image format %s", format)
+	}
+	out, err = GetExceptionImage("", 8, 8, ExceptionImageJPEG)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	img, err = jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if r, g, b, _ := img.At(4, 4).RGBA(); r < 0xf000 || g < 0xf000 || b < 0xf000 {
+		t.Errorf("expected a white blank JPEG image")
+	}
+}
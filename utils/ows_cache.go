@@ -58,6 +58,12 @@ func (o *OWSCache) Put(query string, value string) error {
 	return nil
 }
 
+// Invalidate overwrites the cached value of query with null, which
+// readers treat as a cache miss
+func (o *OWSCache) Invalidate(query string) error {
+	return o.Put(query, "null")
+}
+
 func (o *OWSCache) Get(query string) ([]byte, error) {
 	var result []byte
 	url := fmt.Sprintf("http://%s%s?get_ows_cache&query=%s", o.MASAddress, o.GPath, query)
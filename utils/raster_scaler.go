@@ -326,11 +326,101 @@ func scale(r Raster, params ScaleParams) (*ByteRaster, error) {
 		}
 		return out, nil
 
+	case *Int32Raster:
+		data := make([]float64, len(t.Data))
+		for i, value := range t.Data {
+			data[i] = float64(value)
+		}
+		return scaleFloat64(t.NameSpace, data, t.Width, t.Height, float64(int32(t.NoData)), t.NoData, params, scale), nil
+
+	case *UInt32Raster:
+		data := make([]float64, len(t.Data))
+		for i, value := range t.Data {
+			data[i] = float64(value)
+		}
+		return scaleFloat64(t.NameSpace, data, t.Width, t.Height, float64(uint32(t.NoData)), t.NoData, params, scale), nil
+
+	case *Float64Raster:
+		return scaleFloat64(t.NameSpace, t.Data, t.Width, t.Height, t.NoData, t.NoData, params, scale), nil
+
 	default:
 		return &ByteRaster{}, fmt.Errorf("Raster type not implemented")
 	}
 }
 
+// scaleFloat64 scales the values of 32-bit integer and 64-bit float
+// rasters. The arithmetic is done in float64 to avoid overflows of the
+// integer types.
+func scaleFloat64(nameSpace string, data []float64, width int, height int, noData float64, outNoData float64, params ScaleParams, scale32 float32) *ByteRaster {
+	out := &ByteRaster{NameSpace: nameSpace, NoData: outNoData, Data: make([]uint8, height*width), Width: width, Height: height}
+	scale := float64(scale32)
+	offset := params.Offset
+	clip := params.Clip
+
+	if params.Scale == 0.0 && params.Clip == 0.0 && params.Offset == 0.0 {
+		var minVal, maxVal float64
+		first := true
+		for _, value := range data {
+			if value == noData {
+				continue
+			}
+
+			if params.ColourScale > 0 {
+				value = normalise(value, params.ColourScale, noData)
+				if value == noData {
+					continue
+				}
+			}
+
+			if first {
+				minVal = value
+				maxVal = value
+				first = false
+			} else {
+				if value < minVal {
+					minVal = value
+				}
+
+				if value > maxVal {
+					maxVal = value
+				}
+			}
+		}
+
+		if minVal == maxVal {
+			maxVal += 0.1
+		}
+
+		scale = 254.0 / (maxVal - minVal)
+		offset = -minVal
+
+		clip = maxVal + offset
+	}
+
+	for i, value := range data {
+		if value == noData {
+			out.Data[i] = 0xFF
+		} else {
+			if params.ColourScale > 0 {
+				value = normalise(value, params.ColourScale, noData)
+				if value == noData {
+					out.Data[i] = 0xFF
+					continue
+				}
+			}
+			value += offset
+			if value > clip {
+				value = clip
+			}
+			if value < 0.0 {
+				value = 0.0
+			}
+			out.Data[i] = uint8(value * scale)
+		}
+	}
+	return out
+}
+
 func Scale(rs []Raster, params ScaleParams) ([]*ByteRaster, error) {
 	out := make([]*ByteRaster, len(rs))
 
@@ -143,9 +143,75 @@ func testFloat32Raster(t *testing.T) {
 	assert(t, out[0], expOut, err)
 }
 
+func testInt32Raster(t *testing.T) {
+	inRaster := make([]Raster, 1)
+
+	sp := ScaleParams{Offset: 1, Scale: 1, Clip: 1000}
+
+	inRaster[0] = &Int32Raster{Data: []int32{int32(1), int32(2)}, Height: 2, Width: 1}
+	expOut := &ByteRaster{Data: []uint8{uint8(2), uint8(3)}}
+	out, err := Scale(inRaster, sp)
+	assert(t, out[0], expOut, err)
+
+	inRaster[0] = &Int32Raster{Data: []int32{int32(1), int32(2)}, Height: 2, Width: 1}
+	sp = ScaleParams{Offset: 3, Scale: 2, Clip: 2}
+	expOut = &ByteRaster{Data: []uint8{uint8(4), uint8(4)}}
+	out, err = Scale(inRaster, sp)
+	assert(t, out[0], expOut, err)
+
+	inRaster[0] = &Int32Raster{Data: []int32{int32(-100000), int32(100000), int32(-9999)}, Height: 3, Width: 1, NoData: -9999}
+	sp = ScaleParams{}
+	expOut = &ByteRaster{Data: []uint8{uint8(0), uint8(254), uint8(0xFF)}}
+	out, err = Scale(inRaster, sp)
+	assert(t, out[0], expOut, err)
+}
+
+func testUInt32Raster(t *testing.T) {
+	inRaster := make([]Raster, 1)
+
+	sp := ScaleParams{Offset: 3, Scale: 2, Clip: 1000}
+
+	inRaster[0] = &UInt32Raster{Data: []uint32{uint32(1), uint32(2)}, Height: 2, Width: 1}
+	expOut := &ByteRaster{Data: []uint8{uint8(8), uint8(10)}}
+	out, err := Scale(inRaster, sp)
+	assert(t, out[0], expOut, err)
+
+	inRaster[0] = &UInt32Raster{Data: []uint32{uint32(4000000000), uint32(4000000254)}, Height: 2, Width: 1}
+	sp = ScaleParams{}
+	expOut = &ByteRaster{Data: []uint8{uint8(0), uint8(254)}}
+	out, err = Scale(inRaster, sp)
+	assert(t, out[0], expOut, err)
+}
+
+func testFloat64Raster(t *testing.T) {
+	inRaster := make([]Raster, 1)
+
+	sp := ScaleParams{Offset: 1, Scale: 1, Clip: 1000}
+
+	inRaster[0] = &Float64Raster{Data: []float64{float64(1), float64(2)}, Height: 2, Width: 1}
+	expOut := &ByteRaster{Data: []uint8{uint8(2), uint8(3)}}
+	out, err := Scale(inRaster, sp)
+	assert(t, out[0], expOut, err)
+
+	inRaster[0] = &Float64Raster{Data: []float64{float64(1), float64(2)}, Height: 2, Width: 1}
+	sp = ScaleParams{Offset: 0, Scale: 0, Clip: 2}
+	expOut = &ByteRaster{Data: []uint8{uint8(127), uint8(254)}}
+	out, err = Scale(inRaster, sp)
+	assert(t, out[0], expOut, err)
+
+	inRaster[0] = &Float64Raster{Data: []float64{float64(-100), float64(-200)}, Height: 2, Width: 1}
+	sp = ScaleParams{Offset: 3, Scale: 2, Clip: 2}
+	expOut = &ByteRaster{Data: []uint8{uint8(0), uint8(0)}}
+	out, err = Scale(inRaster, sp)
+	assert(t, out[0], expOut, err)
+}
+
 func TestScale(t *testing.T) {
 	testByteRaster(t)
 	testInt16Raster(t)
 	testUInt16Raster(t)
 	testFloat32Raster(t)
+	testInt32Raster(t)
+	testUInt32Raster(t)
+	testFloat64Raster(t)
 }
@@ -0,0 +1,321 @@
+package utils
+
+//#include "ogr_api.h"
+//#include "ogr_srs_api.h"
+//#include "cpl_conv.h"
+//#cgo pkg-config: gdal
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+const STACVersion = "1.0.0"
+
+// STACConformance lists the STAC API conformance classes served by OWS
+var STACConformance = []string{
+	"https://api.stacspec.org/v1.0.0/core",
+	"https://api.stacspec.org/v1.0.0/collections",
+	"https://api.stacspec.org/v1.0.0/ogcapi-features",
+	"https://api.stacspec.org/v1.0.0/item-search",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/core",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/geojson",
+}
+
+// Default and maximum number of items of a STAC response
+const (
+	DefaultSTACLimit = 10
+	MaxSTACLimit     = 10000
+)
+
+type STACLink struct {
+	Rel    string `json:"rel"`
+	Href   string `json:"href"`
+	Type   string `json:"type,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Method string `json:"method,omitempty"`
+
+	// Body is the request body of a POST link
+	Body interface{} `json:"body,omitempty"`
+}
+
+type STACCatalog struct {
+	Type        string     `json:"type"`
+	STACVersion string     `json:"stac_version"`
+	ID          string     `json:"id"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description"`
+	ConformsTo  []string   `json:"conformsTo,omitempty"`
+	Links       []STACLink `json:"links"`
+}
+
+type STACSpatialExtent struct {
+	BBox [][]float64 `json:"bbox"`
+}
+
+type STACTemporalExtent struct {
+	Interval [][]*string `json:"interval"`
+}
+
+type STACExtent struct {
+	Spatial  STACSpatialExtent  `json:"spatial"`
+	Temporal STACTemporalExtent `json:"temporal"`
+}
+
+type STACCollection struct {
+	Type        string     `json:"type"`
+	STACVersion string     `json:"stac_version"`
+	ID          string     `json:"id"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description"`
+	License     string     `json:"license"`
+	Extent      STACExtent `json:"extent"`
+	Links       []STACLink `json:"links"`
+}
+
+type STACAsset struct {
+	Href   string   `json:"href"`
+	Type   string   `json:"type,omitempty"`
+	Title  string   `json:"title,omitempty"`
+	Roles  []string `json:"roles,omitempty"`
+	DSName string   `json:"gsky:ds_name,omitempty"`
+}
+
+type STACItem struct {
+	Type        string                 `json:"type"`
+	STACVersion string                 `json:"stac_version"`
+	ID          string                 `json:"id"`
+	Collection  string                 `json:"collection"`
+	Geometry    json.RawMessage        `json:"geometry"`
+	BBox        []float64              `json:"bbox,omitempty"`
+	Properties  map[string]interface{} `json:"properties"`
+	Assets      map[string]*STACAsset  `json:"assets"`
+	Links       []STACLink             `json:"links"`
+}
+
+type STACItemCollection struct {
+	Type           string      `json:"type"`
+	Features       []*STACItem `json:"features"`
+	NumberReturned int         `json:"numberReturned"`
+	Links          []STACLink  `json:"links"`
+}
+
+// STACSearch is the body of a POST /search request
+type STACSearch struct {
+	Collections []string        `json:"collections"`
+	BBox        []float64       `json:"bbox"`
+	Datetime    string          `json:"datetime"`
+	Intersects  json.RawMessage `json:"intersects"`
+	Limit       int             `json:"limit"`
+	Token       string          `json:"token,omitempty"`
+}
+
+// ParseSTACBBox parses a minx,miny,maxx,maxy bbox in EPSG:4326. 3D
+// bboxes are accepted and flattened.
+func ParseSTACBBox(bboxStr string) ([]float64, error) {
+	var bbox []float64
+	for _, s := range strings.Split(bboxStr, ",") {
+		val, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bbox: %s", bboxStr)
+		}
+		bbox = append(bbox, val)
+	}
+	return CheckSTACBBox(bbox)
+}
+
+// CheckSTACBBox validates a bbox and flattens 3D bboxes. A minx
+// greater than maxx is a bbox crossing the antimeridian.
+func CheckSTACBBox(bbox []float64) ([]float64, error) {
+	switch len(bbox) {
+	case 4:
+	case 6:
+		bbox = []float64{bbox[0], bbox[1], bbox[3], bbox[4]}
+	default:
+		return nil, fmt.Errorf("bbox must have 4 or 6 numbers")
+	}
+	for i, val := range bbox {
+		limit := 180.0
+		if i%2 == 1 {
+			limit = 90
+		}
+		if math.IsNaN(val) || val < -limit || val > limit {
+			return nil, fmt.Errorf("bbox is out of the EPSG:4326 bounds")
+		}
+	}
+	if bbox[1] > bbox[3] {
+		return nil, fmt.Errorf("bbox miny is greater than maxy")
+	}
+	return bbox, nil
+}
+
+// STACBBoxToWKT converts a bbox checked by CheckSTACBBox into a WKT
+// polygon. Bboxes crossing the antimeridian are split into a
+// multipolygon on either side of it.
+func STACBBoxToWKT(bbox []float64) string {
+	polygon := func(minX, minY, maxX, maxY float64) string {
+		return fmt.Sprintf("((%[1]v %[2]v,%[3]v %[2]v,%[3]v %[4]v,%[1]v %[4]v,%[1]v %[2]v))", minX, minY, maxX, maxY)
+	}
+	if bbox[0] <= bbox[2] {
+		return "POLYGON " + polygon(bbox[0], bbox[1], bbox[2], bbox[3])
+	}
+	return "MULTIPOLYGON (" + polygon(bbox[0], bbox[1], 180, bbox[3]) + "," + polygon(-180, bbox[1], bbox[2], bbox[3]) + ")"
+}
+
+// ParseSTACDatetime parses a RFC 3339 datetime or an interval of them,
+// whose ends may be open with '..' or empty. Nil times are unbounded.
+func ParseSTACDatetime(datetime string) (*time.Time, *time.Time, error) {
+	datetime = strings.TrimSpace(datetime)
+	if len(datetime) == 0 {
+		return nil, nil, nil
+	}
+
+	parse := func(s string) (*time.Time, error) {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 || s == ".." {
+			return nil, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid datetime: %s", s)
+		}
+		t = t.UTC()
+		return &t, nil
+	}
+
+	parts := strings.Split(datetime, "/")
+	switch len(parts) {
+	case 1:
+		t, err := parse(parts[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		if t == nil {
+			return nil, nil, fmt.Errorf("invalid datetime: %s", datetime)
+		}
+		return t, t, nil
+	case 2:
+		start, err := parse(parts[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		end, err := parse(parts[1])
+		if err != nil {
+			return nil, nil, err
+		}
+		if start != nil && end != nil && end.Before(*start) {
+			return nil, nil, fmt.Errorf("datetime interval ends before it starts: %s", datetime)
+		}
+		return start, end, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid datetime: %s", datetime)
+	}
+}
+
+// MercatorToWGS84 converts EPSG:3857 coordinates into longitude and
+// latitude
+func MercatorToWGS84(x float64, y float64) (float64, float64) {
+	const radius = 6378137.0
+	lon := x / radius * 180 / math.Pi
+	lat := math.Atan(math.Sinh(y/radius)) * 180 / math.Pi
+	return lon, lat
+}
+
+// STACMediaType guesses the media type of a data file from its
+// extension
+func STACMediaType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".tif", ".tiff":
+		return "image/tiff; application=geotiff"
+	case ".nc", ".nc4":
+		return "application/netcdf"
+	case ".h5", ".hdf5", ".hdf", ".he5":
+		return "application/x-hdf5"
+	case ".jp2":
+		return "image/jp2"
+	case ".png":
+		return "image/png"
+	case ".vrt":
+		return "application/xml"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// GeoJSONToWKT converts a GeoJSON geometry into WKT
+func GeoJSONToWKT(geoJSON []byte) (string, error) {
+	geoJSONC := C.CString(string(geoJSON))
+	defer C.free(unsafe.Pointer(geoJSONC))
+
+	geom := C.OGR_G_CreateGeometryFromJson(geoJSONC)
+	if geom == nil {
+		return "", fmt.Errorf("invalid GeoJSON geometry")
+	}
+	defer C.OGR_G_DestroyGeometry(geom)
+
+	var wktC *C.char
+	if C.OGR_G_ExportToWkt(geom, &wktC) != C.OGRERR_NONE {
+		return "", fmt.Errorf("failed to export geometry to WKT")
+	}
+	defer C.VSIFree(unsafe.Pointer(wktC))
+	return C.GoString(wktC), nil
+}
+
+// WKTToGeoJSON transforms a WKT geometry in srs into a GeoJSON geometry
+// in EPSG:4326 and returns it with its bbox
+func WKTToGeoJSON(wkt string, srs string) (json.RawMessage, []float64, error) {
+	wktC := C.CString(wkt)
+	wktCP := wktC
+	defer C.free(unsafe.Pointer(wktCP))
+
+	var geom C.OGRGeometryH
+	// OGR_G_CreateFromWkt intrnally updates &wktC pointer value
+	if C.OGR_G_CreateFromWkt(&wktC, nil, &geom) != C.OGRERR_NONE {
+		return nil, nil, fmt.Errorf("invalid WKT geometry")
+	}
+	defer C.OGR_G_DestroyGeometry(geom)
+
+	if len(strings.TrimSpace(srs)) > 0 {
+		srcSRS := C.OSRNewSpatialReference(nil)
+		defer C.OSRDestroySpatialReference(srcSRS)
+		srsC := C.CString(srs)
+		defer C.free(unsafe.Pointer(srsC))
+		if C.OSRSetFromUserInput(srcSRS, srsC) != C.OGRERR_NONE {
+			return nil, nil, fmt.Errorf("invalid SRS: %s", srs)
+		}
+		C.OSRSetAxisMappingStrategy(srcSRS, C.OAMS_TRADITIONAL_GIS_ORDER)
+
+		dstSRS := C.OSRNewSpatialReference(nil)
+		defer C.OSRDestroySpatialReference(dstSRS)
+		C.OSRImportFromEPSG(dstSRS, 4326)
+		C.OSRSetAxisMappingStrategy(dstSRS, C.OAMS_TRADITIONAL_GIS_ORDER)
+
+		trans := C.OCTNewCoordinateTransformation(srcSRS, dstSRS)
+		if trans == nil {
+			return nil, nil, fmt.Errorf("failed to transform %s to EPSG:4326", srs)
+		}
+		defer C.OCTDestroyCoordinateTransformation(trans)
+
+		if C.OGR_G_Transform(geom, trans) != C.OGRERR_NONE {
+			return nil, nil, fmt.Errorf("failed to transform %s to EPSG:4326", srs)
+		}
+	}
+
+	geoJSONC := C.OGR_G_ExportToJson(geom)
+	if geoJSONC == nil {
+		return nil, nil, fmt.Errorf("failed to export geometry to GeoJSON")
+	}
+	defer C.VSIFree(unsafe.Pointer(geoJSONC))
+
+	var env C.OGREnvelope
+	C.OGR_G_GetEnvelope(geom, &env)
+	bbox := []float64{float64(env.MinX), float64(env.MinY), float64(env.MaxX), float64(env.MaxY)}
+	return json.RawMessage(C.GoString(geoJSONC)), bbox, nil
+}
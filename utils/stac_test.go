@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseSTACDatetime(t *testing.T) {
+	testCases := []struct {
+		datetime string
+		hasStart bool
+		hasEnd   bool
+		isErr    bool
+	}{
+		{"", false, false, false},
+		{"2020-01-01T00:00:00Z", true, true, false},
+		{"2020-01-01T00:00:00Z/2020-02-01T00:00:00Z", true, true, false},
+		{"../2020-02-01T00:00:00Z", false, true, false},
+		{"2020-01-01T00:00:00Z/", true, false, false},
+		{"2020-02-01T00:00:00Z/2020-01-01T00:00:00Z", false, false, true},
+		{"2020-01-01", false, false, true},
+		{"..", false, false, true},
+	}
+
+	for _, tc := range testCases {
+		start, end, err := ParseSTACDatetime(tc.datetime)
+		if (err != nil) != tc.isErr {
+			t.Errorf("%q: unexpected error: %v", tc.datetime, err)
+			continue
+		}
+		if (start != nil) != tc.hasStart || (end != nil) != tc.hasEnd {
+			t.Errorf("%q: unexpected interval %v/%v", tc.datetime, start, end)
+		}
+	}
+}
+
+func TestParseSTACBBox(t *testing.T) {
+	bbox, err := ParseSTACBBox("110,-40,150,-10")
+	if err != nil || len(bbox) != 4 {
+		t.Errorf("unexpected bbox %v: %v", bbox, err)
+	}
+
+	bbox, err = ParseSTACBBox("110,-40,0,150,-10,100")
+	if err != nil || bbox[2] != 150 || bbox[3] != -10 {
+		t.Errorf("unexpected 3D bbox %v: %v", bbox, err)
+	}
+
+	bbox, err = ParseSTACBBox("170,-40,-170,-10")
+	if err != nil || bbox[0] != 170 || bbox[2] != -170 {
+		t.Errorf("unexpected antimeridian bbox %v: %v", bbox, err)
+	}
+
+	for _, bboxStr := range []string{"110,-40,150", "110,-10,150,-40", "a,b,c,d", "110,-40,190,-10", "110,-100,150,-10"} {
+		if _, err = ParseSTACBBox(bboxStr); err == nil {
+			t.Errorf("%s: expected an error", bboxStr)
+		}
+	}
+}
+
+func TestSTACBBoxToWKT(t *testing.T) {
+	testCases := []struct {
+		bbox []float64
+		wkt  string
+	}{
+		{[]float64{110, -40, 150, -10}, "POLYGON ((110 -40,150 -40,150 -10,110 -10,110 -40))"},
+		{[]float64{170, -40, -170, -10}, "MULTIPOLYGON (((170 -40,180 -40,180 -10,170 -10,170 -40)),((-180 -40,-170 -40,-170 -10,-180 -10,-180 -40)))"},
+	}
+	for _, tc := range testCases {
+		if wkt := STACBBoxToWKT(tc.bbox); wkt != tc.wkt {
+			t.Errorf("%v: expected %s, got %s", tc.bbox, tc.wkt, wkt)
+		}
+	}
+}
+
+func TestMercatorToWGS84(t *testing.T) {
+	lon, lat := MercatorToWGS84(20037508.342789244, 0)
+	if math.Abs(lon-180) > 1e-9 || lat != 0 {
+		t.Errorf("unexpected coordinates %v, %v", lon, lat)
+	}
+
+	_, lat = MercatorToWGS84(0, 20037508.342789244)
+	if math.Abs(lat-85.0511287798066) > 1e-9 {
+		t.Errorf("unexpected latitude %v", lat)
+	}
+}
@@ -30,6 +30,7 @@ type WCSParams struct {
 	BandExpr       *BandExpressions
 	NoReprojection bool
 	AxisMapping    int
+	Dap4Checksum   bool
 }
 
 // WCSRegexpMap maps WCS request parameters to
@@ -42,7 +43,7 @@ type WCSParams struct {
 var WCSRegexpMap = map[string]string{"service": `^WCS$`,
 	"request":  `^GetCapabilities$|^DescribeCoverage$|^GetCoverage$`,
 	"coverage": `^[A-Za-z.:0-9\s_-]+$`,
-	"crs":      `^(?i)(?:[A-Z]+):(?:[0-9]+)$`,
+	"crs":      `^(?i)(?:[A-Z]+:[A-Z0-9]+|urn:ogc:def:crs:[A-Z]+:[0-9.]*:[A-Z0-9]+|https?://www\.opengis\.net/def/crs/[A-Z]+/[0-9.]+/[A-Z0-9]+)$`,
 	"bbox":     `^[-+]?[0-9]*\.?[0-9]*([eE][-+]?[0-9]+)?(,[-+]?[0-9]*\.?[0-9]*([eE][-+]?[0-9]+)?){3}$`,
 	"time":     `^\d{4}-(?:1[0-2]|0[1-9])-(?:3[01]|0[1-9]|[12][0-9])T[0-2]\d:[0-5]\d:[0-5]\d(\.\d+)?Z$`,
 	"width":    `^[-+]?[0-9]+$`,
@@ -96,7 +97,7 @@ func WCSParamsChecker(params map[string][]string, compREMap map[string]*regexp.R
 
 	if crs, crsOK := params["crs"]; crsOK {
 		if compREMap["crs"].MatchString(crs[0]) {
-			jsonFields = append(jsonFields, fmt.Sprintf(`"crs":"%s"`, crs[0]))
+			jsonFields = append(jsonFields, fmt.Sprintf(`"crs":"%s"`, NormaliseCRS(crs[0])))
 		}
 	}
 
@@ -1,6 +1,7 @@
 package utils
 
 //#include "gdal_alg.h"
+//#include "ogr_srs_api.h"
 //#cgo pkg-config: gdal
 import "C"
 
@@ -10,12 +11,15 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
 	"text/template"
 	"time"
 	"unsafe"
+
+	"github.com/nci/gsky/utils/lru"
 )
 
 const ISOZeroTime = "0001-01-01T00:00:00.000Z"
@@ -47,6 +51,8 @@ type WMSParams struct {
 	CRS         *string      `json:"crs,omitempty"`
 	BBox        []float64    `json:"bbox,omitempty"`
 	Format      *string      `json:"format,omitempty"`
+	Exceptions  *string      `json:"exceptions,omitempty"`
+	InfoFormat  *string      `json:"info_format,omitempty"`
 	X           *int         `json:"x,omitempty"`
 	Y           *int         `json:"y,omitempty"`
 	Height      *int         `json:"height,omitempty"`
@@ -61,6 +67,10 @@ type WMSParams struct {
 	Palette     *string      `json:"palette,omitempty"`
 	ColourScale *int         `json:"colour_scale,omitempty"`
 	BandExpr    *BandExpressions
+
+	// Start and end times of the time series of GetFeatureInfo
+	TimeSeriesStart *time.Time
+	TimeSeriesEnd   *time.Time
 }
 
 // WMSRegexpMap maps WMS request parameters to
@@ -72,7 +82,7 @@ type WMSParams struct {
 // --- also validates correct values.
 var WMSRegexpMap = map[string]string{"service": `^WMS$`,
 	"request": `^GetCapabilities$|^GetFeatureInfo$|^DescribeLayer$|^GetMap$|^GetLegendGraphic$`,
-	"crs":     `^(?i)(?:[A-Z]+):(?:[0-9]+)$`,
+	"crs":     `^(?i)(?:[A-Z]+:[A-Z0-9]+|urn:ogc:def:crs:[A-Z]+:[0-9.]*:[A-Z0-9]+|https?://www\.opengis\.net/def/crs/[A-Z]+/[0-9.]+/[A-Z0-9]+)$`,
 	"bbox":    `^[-+]?[0-9]*\.?[0-9]*([eE][-+]?[0-9]+)?(,[-+]?[0-9]*\.?[0-9]*([eE][-+]?[0-9]+)?){3}$`,
 	"x":       `^[0-9]+$`,
 	"y":       `^[0-9]+$`,
@@ -131,7 +141,7 @@ func WMSParamsChecker(params map[string][]string, compREMap map[string]*regexp.R
 
 	if crs, crsOK := params["crs"]; crsOK {
 		if compREMap["crs"].MatchString(crs[0]) {
-			jsonFields = append(jsonFields, fmt.Sprintf(`"crs":"%s"`, crs[0]))
+			jsonFields = append(jsonFields, fmt.Sprintf(`"crs":"%s"`, NormaliseCRS(crs[0])))
 		}
 	}
 
@@ -173,6 +183,26 @@ func WMSParamsChecker(params map[string][]string, compREMap map[string]*regexp.R
 		}
 	}
 
+	if exceptions, exceptionsOK := params["exceptions"]; exceptionsOK {
+		if format := ParseWMSExceptionsFormat(exceptions[0]); len(format) > 0 {
+			jsonFields = append(jsonFields, fmt.Sprintf(`"exceptions":"%s"`, format))
+		}
+	}
+
+	if format, formatOK := params["format"]; formatOK {
+		value, err := json.Marshal(format[0])
+		if err == nil {
+			jsonFields = append(jsonFields, fmt.Sprintf(`"format":%s`, value))
+		}
+	}
+
+	if infoFormat, infoFormatOK := params["info_format"]; infoFormatOK {
+		value, err := json.Marshal(infoFormat[0])
+		if err == nil {
+			jsonFields = append(jsonFields, fmt.Sprintf(`"info_format":%s`, value))
+		}
+	}
+
 	if timeRaw, timeOK := params["time"]; timeOK {
 		var times []string
 		for _, t := range strings.Split(timeRaw[0], ",") {
@@ -348,6 +378,31 @@ func WMSParamsChecker(params map[string][]string, compREMap map[string]*regexp.R
 		wmsParams.Axes = append(wmsParams.Axes, &AxisParam{Name: "time", Aggregate: 1})
 	}
 
+	if timeSeries, timeSeriesOK := params["time_series"]; timeSeriesOK {
+		parts := strings.Split(timeSeries[0], "/")
+		if len(parts) != 2 {
+			return wmsParams, fmt.Errorf("time_series must be in the format of 'start/end'")
+		}
+
+		var times []time.Time
+		for _, t := range parts {
+			t = strings.TrimSpace(t)
+			if !compREMap["time"].MatchString(t) {
+				return wmsParams, fmt.Errorf("invalid time_series time format: %s", t)
+			}
+			tm, err := time.Parse(ISOFormat, t)
+			if err != nil {
+				return wmsParams, fmt.Errorf("invalid time_series time format: %v", err)
+			}
+			times = append(times, tm)
+		}
+		if times[1].Before(times[0]) {
+			return wmsParams, fmt.Errorf("time_series must start before it ends")
+		}
+		wmsParams.TimeSeriesStart = &times[0]
+		wmsParams.TimeSeriesEnd = &times[1]
+	}
+
 	codeFormats, codeFormatOK := params["code_format"]
 	var codeFormat string
 	if codeFormatOK {
@@ -520,9 +575,16 @@ func CheckDisableServices(layer *Layer, service string) bool {
 	return false
 }
 
+// Number of points along each edge of a bbox that are transformed to
+// find its extent in another CRS
+const bboxEdgePoints = 21
+
+// GetCanonicalBbox returns the extent of a bbox in EPSG:3857. Bboxes that
+// cross the antimeridian are unwrapped beyond the edge of the world.
 func GetCanonicalBbox(srs string, bbox []float64) ([]float64, error) {
 	srs = strings.ToUpper(strings.TrimSpace(srs))
 	dst := "EPSG:3857"
+	bbox = UnwrapAntimeridian(srs, bbox)
 	if srs == dst {
 		box := make([]float64, len(bbox))
 		for i := 0; i < len(bbox); i++ {
@@ -531,30 +593,193 @@ func GetCanonicalBbox(srs string, bbox []float64) ([]float64, error) {
 		return box, nil
 	}
 
+	if srcWorld, wraps := GetWorldExtent(srs); wraps && (bbox[0] < srcWorld[0] || bbox[2] > srcWorld[2]) {
+		// Transforms wrap x beyond the antimeridian but the x of the CRSs
+		// in which the world wraps are proportional to each other
+		box, err := TransformBBox(srs, dst, []float64{srcWorld[0], bbox[1], srcWorld[2], bbox[3]})
+		if err != nil {
+			return bbox, err
+		}
+		dstWorld, _ := GetWorldExtent(dst)
+		scale := (dstWorld[2] - dstWorld[0]) / (srcWorld[2] - srcWorld[0])
+		box[0] = dstWorld[0] + (bbox[0]-srcWorld[0])*scale
+		box[2] = dstWorld[0] + (bbox[2]-srcWorld[0])*scale
+		return box, nil
+	}
+
+	return TransformBBox(srs, dst, bbox)
+}
+
+// TransformBBox returns the extent of a bbox in another CRS. The edges
+// of the bbox are densified so that curved edges, e.g. those of polar
+// projections, are covered. The extent of a bbox that contains a pole
+// reaches the poleward edge of the world of dst if dst wraps around the
+// antimeridian, to which the extent is clipped.
+func TransformBBox(srs string, dst string, bbox []float64) ([]float64, error) {
+	if len(bbox) < 4 {
+		return bbox, fmt.Errorf("invalid bbox: %v", bbox)
+	}
+
+	xs, ys := DensifyBBox(bbox, bboxEdgePoints)
+	ok := transformPoints(srs, dst, xs, ys)
+
+	box := []float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for i := range xs {
+		if !ok[i] {
+			continue
+		}
+		box[0] = math.Min(box[0], xs[i])
+		box[1] = math.Min(box[1], ys[i])
+		box[2] = math.Max(box[2], xs[i])
+		box[3] = math.Max(box[3], ys[i])
+	}
+	if box[0] > box[2] || box[1] > box[3] {
+		return bbox, fmt.Errorf("GDALGenImgProjTransform failed")
+	}
+
+	world, found := GetWorldExtent(dst)
+	if !found {
+		return box, nil
+	}
+	box[1] = math.Max(box[1], world[1])
+	box[3] = math.Min(box[3], world[3])
+
+	south, north := BBoxContainsPoles(srs, bbox)
+	if south || north {
+		box[0] = world[0]
+		box[2] = world[2]
+	}
+	if south {
+		box[1] = world[1]
+	}
+	if north {
+		box[3] = world[3]
+	}
+
+	return box, nil
+}
+
+// Maximum number of CRSs whose properties are cached. The entries of the
+// CRS caches are of size 1 so that they are bounded by the number of
+// CRSs, which come from requests.
+const crsCacheSize = 1024
+
+// polesCache caches the coordinates of the south and north poles in
+// the CRSs that don't wrap around the antimeridian
+var polesCache = lru.New(crsCacheSize, 0)
+
+type crsPoles struct {
+	xs, ys []float64
+	ok     []bool
+}
+
+// BBoxContainsPoles returns whether a bbox contains the south and the
+// north poles. Bboxes of CRSs that wrap around the antimeridian contain
+// neither as their poles are edges rather than points.
+func BBoxContainsPoles(srs string, bbox []float64) (bool, bool) {
+	if _, wraps := GetWorldExtent(srs); wraps || len(bbox) < 4 {
+		return false, false
+	}
+
+	var poles *crsPoles
+	// Aliases are keyed by their definitions, which config reloads may
+	// change
+	key := ResolveCRS(srs)
+	if cached, found := polesCache.Get(key); found {
+		poles = cached.(*crsPoles)
+	} else {
+		poles = &crsPoles{xs: []float64{0, 0}, ys: []float64{-90, 90}}
+		poles.ok = transformPoints("EPSG:4326", srs, poles.xs, poles.ys)
+		polesCache.Put(key, poles, 1)
+	}
+
+	var contains [2]bool
+	for i := range contains {
+		contains[i] = poles.ok[i] && poles.xs[i] >= bbox[0] && poles.xs[i] <= bbox[2] && poles.ys[i] >= bbox[1] && poles.ys[i] <= bbox[3]
+	}
+	return contains[0], contains[1]
+}
+
+// transformPoints transforms points between CRSs in place and returns
+// whether each point was transformed
+func transformPoints(srs string, dst string, xs []float64, ys []float64) []bool {
+	ok := make([]bool, len(xs))
+	if len(xs) == 0 {
+		return ok
+	}
+
 	var opts []*C.char
-	opts = append(opts, C.CString(fmt.Sprintf("SRC_SRS=%s", srs)))
-	opts = append(opts, C.CString(fmt.Sprintf("DST_SRS=%s", dst)))
+	opts = append(opts, C.CString(fmt.Sprintf("SRC_SRS=%s", ResolveCRS(srs))))
+	opts = append(opts, C.CString(fmt.Sprintf("DST_SRS=%s", ResolveCRS(dst))))
 	for _, opt := range opts {
 		defer C.free(unsafe.Pointer(opt))
 	}
 	opts = append(opts, nil)
 	transformArg := C.GDALCreateGenImgProjTransformer2(nil, nil, &opts[0])
 	if transformArg == nil {
-		return bbox, fmt.Errorf("GDALCreateGenImgProjTransformer2 failed")
+		return ok
 	}
 	defer C.GDALDestroyGenImgProjTransformer(transformArg)
 
-	dx := []C.double{C.double(bbox[0]), C.double(bbox[2])}
-	dy := []C.double{C.double(bbox[1]), C.double(bbox[3])}
-	dz := make([]C.double, 2)
-	bSuccess := make([]C.int, 2)
+	dx := make([]C.double, len(xs))
+	dy := make([]C.double, len(xs))
+	dz := make([]C.double, len(xs))
+	bSuccess := make([]C.int, len(xs))
+	for i := range xs {
+		dx[i] = C.double(xs[i])
+		dy[i] = C.double(ys[i])
+	}
 
-	C.GDALGenImgProjTransform(transformArg, C.int(0), 2, &dx[0], &dy[0], &dz[0], &bSuccess[0])
-	if bSuccess[0] != 0 && bSuccess[1] != 0 {
-		return []float64{float64(dx[0]), float64(dy[0]), float64(dx[1]), float64(dy[1])}, nil
-	} else {
-		return bbox, fmt.Errorf("GDALGenImgProjTransform failed")
+	C.GDALGenImgProjTransform(transformArg, C.int(0), C.int(len(xs)), &dx[0], &dy[0], &dz[0], &bSuccess[0])
+	for i := range xs {
+		xs[i] = float64(dx[i])
+		ys[i] = float64(dy[i])
+		ok[i] = bSuccess[i] != 0 && !math.IsInf(xs[i], 0) && !math.IsInf(ys[i], 0) && !math.IsNaN(xs[i]) && !math.IsNaN(ys[i])
+	}
+	return ok
+}
+
+// checkCRSDefinition checks that GDAL understands a WKT or PROJ
+// definition of a CRS
+func checkCRSDefinition(def string) error {
+	hSRS := C.OSRNewSpatialReference(nil)
+	defer C.OSRDestroySpatialReference(hSRS)
+
+	cDef := C.CString(def)
+	defer C.free(unsafe.Pointer(cDef))
+	if C.OSRSetFromUserInput(hSRS, cDef) != C.OGRERR_NONE {
+		return fmt.Errorf("invalid CRS definition: %s", def)
+	}
+	return nil
+}
+
+// latLonOrderCache caches the axis order of the CRSs that GDAL resolves
+var latLonOrderCache = lru.New(crsCacheSize, 0)
+
+// CRSHasLatLonOrder returns whether the authority of a CRS defines its
+// axes in lat/lon or northing/easting order, e.g. EPSG:4326 and
+// EPSG:4283, in which case WMS 1.3.0 bboxes are in that order. CRS:84
+// and server-side CRS aliases are in x/y order.
+func CRSHasLatLonOrder(crs string) bool {
+	crs = NormaliseCRS(crs)
+	if crs == "CRS:84" || IsCRSAlias(crs) {
+		return false
+	}
+	if latLon, found := latLonOrderCache.Get(crs); found {
+		return latLon.(bool)
+	}
+
+	hSRS := C.OSRNewSpatialReference(nil)
+	defer C.OSRDestroySpatialReference(hSRS)
+
+	cCRS := C.CString(crs)
+	defer C.free(unsafe.Pointer(cCRS))
+	if C.OSRSetFromUserInput(hSRS, cCRS) != C.OGRERR_NONE {
+		return false
 	}
+	latLon := C.OSREPSGTreatsAsLatLong(hSRS) != 0 || C.OSREPSGTreatsAsNorthingEasting(hSRS) != 0
+	latLonOrderCache.Put(crs, latLon, 1)
+	return latLon
 }
 
 func GetPixelResolution(bbox []float64, width int, height int) float64 {
@@ -0,0 +1,577 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/nci/gsky/worker/gdalservice"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const DefaultWorkerProbeInterval = 5 * time.Second
+const DefaultWorkerProbeTimeout = 2 * time.Second
+const DefaultWorkerMinBackoff = 1 * time.Second
+const DefaultWorkerMaxBackoff = 60 * time.Second
+
+// LocalWorkerAddress selects the in-process worker of the embedded mode.
+// It is also used when no worker nodes are configured.
+const LocalWorkerAddress = "local"
+
+var localWorkers = []string{LocalWorkerAddress}
+
+// workerKeySep separates the address of a worker from the ID of its
+// connection settings in the keys returned by RegisterWorkers
+const workerKeySep = "#"
+
+// LocalExecutor runs granules in the OWS process for the embedded mode
+type LocalExecutor interface {
+	Process(ctx context.Context, granule *pb.GeoRPCGranule) (*pb.Result, error)
+	WorkerInfo() *pb.WorkerInfo
+}
+
+// WorkerNode is a persistent connection to a gRPC worker together
+// with the health and load information gathered by the WorkerManager.
+type WorkerNode struct {
+	Address     string
+	Conn        *grpc.ClientConn
+	Security    GrpcSecurity
+	Compression string
+
+	inFlight int64
+
+	mutex       sync.RWMutex
+	poolSize    int
+	activeTasks int
+	healthy     bool
+	probed      bool
+	failures    int
+	retryAt     time.Time
+	noStream    bool
+}
+
+// workerSettings are the security and compression settings of the
+// connections to a set of workers
+type workerSettings struct {
+	security    GrpcSecurity
+	compression string
+}
+
+// WorkerManager keeps one connection per gRPC worker and connection
+// settings for the lifetime of the process. Workers are polled in the
+// background via worker_info and the standard gRPC health service.
+// Failed workers are ejected with exponential backoff and requests are
+// routed to the least-loaded healthy workers.
+type WorkerManager struct {
+	nodes     map[string]*WorkerNode
+	settings  []workerSettings
+	mutex     sync.RWMutex
+	probeOnce sync.Once
+	local     LocalExecutor
+	Verbose   bool
+}
+
+var workerManager = &WorkerManager{
+	nodes: make(map[string]*WorkerNode),
+}
+
+// GetWorkerManager returns the process-wide WorkerManager
+func GetWorkerManager() *WorkerManager {
+	return workerManager
+}
+
+// SetLocalExecutor enables the embedded mode, in which granules sent to
+// LocalWorkerAddress or to an empty list of workers are run by exec
+func (m *WorkerManager) SetLocalExecutor(exec LocalExecutor) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.local = exec
+	delete(m.nodes, LocalWorkerAddress)
+}
+
+func resolveWorkers(addresses []string) []string {
+	if len(addresses) == 0 {
+		return localWorkers
+	}
+	return addresses
+}
+
+// parseWorkerKey returns the address of a worker and the ID of its
+// connection settings, which is -1 for the default settings
+func parseWorkerKey(key string) (string, int) {
+	i := strings.LastIndex(key, workerKeySep)
+	if i < 0 {
+		return key, -1
+	}
+
+	id, err := strconv.Atoi(key[i+len(workerKeySep):])
+	if err != nil {
+		return key, -1
+	}
+	return key[:i], id
+}
+
+func (m *WorkerManager) getNode(key string) (*WorkerNode, error) {
+	m.mutex.RLock()
+	node, found := m.nodes[key]
+	m.mutex.RUnlock()
+	if found {
+		return node, nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if node, found := m.nodes[key]; found {
+		return node, nil
+	}
+
+	address, settingsID := parseWorkerKey(key)
+	if address == LocalWorkerAddress {
+		if m.local == nil {
+			return nil, fmt.Errorf("no gRPC worker nodes configured")
+		}
+
+		node = &WorkerNode{Address: address, healthy: true, probed: true}
+		node.setWorkerInfo(m.local.WorkerInfo())
+		m.nodes[address] = node
+		return node, nil
+	}
+
+	var settings workerSettings
+	if settingsID >= 0 {
+		if settingsID >= len(m.settings) {
+			return nil, fmt.Errorf("unregistered gRPC worker: %s", key)
+		}
+		settings = m.settings[settingsID]
+	}
+
+	sec := settings.security
+	opts, err := sec.DialOptions()
+	if err != nil {
+		return nil, fmt.Errorf("gRPC connection problem: %v", err)
+	}
+	opts = append(opts, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(DefaultRecvMsgSize)))
+
+	conn, err := grpc.Dial(address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC connection problem: %v", err)
+	}
+
+	node = &WorkerNode{Address: address, Conn: conn, Security: sec, Compression: settings.compression, healthy: true}
+	m.nodes[key] = node
+
+	m.probeOnce.Do(func() { go m.probeLoop() })
+	go m.probe(node)
+
+	return node, nil
+}
+
+// RegisterWorkers returns the keys of the given workers with the
+// security settings and the gRPC compressor used to talk to them. The
+// keys are passed to the other methods in place of the addresses, so
+// that configs listing the same worker with different settings keep
+// separate connections. The keys of the default settings are the
+// addresses.
+func (m *WorkerManager) RegisterWorkers(addresses []string, security *GrpcSecurity, compression string) []string {
+	var settings workerSettings
+	if security != nil {
+		settings.security = *security
+	}
+	settings.compression = compression
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	settingsID := -1
+	if settings != (workerSettings{}) {
+		for i, s := range m.settings {
+			if s == settings {
+				settingsID = i
+				break
+			}
+		}
+		if settingsID < 0 {
+			m.settings = append(m.settings, settings)
+			settingsID = len(m.settings) - 1
+		}
+	}
+
+	keys := make([]string, len(addresses))
+	for i, key := range addresses {
+		addr, _ := parseWorkerKey(key)
+		keys[i] = addr
+		if settingsID >= 0 && addr != LocalWorkerAddress {
+			keys[i] = addr + workerKeySep + strconv.Itoa(settingsID)
+		}
+	}
+	return keys
+}
+
+// Acquire returns the least-loaded healthy worker among the given
+// addresses. If every worker is ejected, the worker which is due to be
+// retried first is returned so that requests keep flowing while the
+// cluster recovers. Callers must pass the node back to Release.
+func (m *WorkerManager) Acquire(addresses []string) (*WorkerNode, error) {
+	addresses = resolveWorkers(addresses)
+
+	var best, fallback *WorkerNode
+	bestLoad := math.MaxFloat64
+	now := time.Now()
+
+	// Randomise the starting point so that equally loaded workers
+	// share the requests
+	iStart := rand.Intn(len(addresses))
+	for i := range addresses {
+		node, err := m.getNode(addresses[(iStart+i)%len(addresses)])
+		if err != nil {
+			if m.Verbose {
+				log.Printf("%v", err)
+			}
+			continue
+		}
+
+		if !node.isAvailable(now) {
+			if fallback == nil || node.getRetryAt().Before(fallback.getRetryAt()) {
+				fallback = node
+			}
+			continue
+		}
+
+		load := node.load()
+		if load < bestLoad {
+			best = node
+			bestLoad = load
+		}
+	}
+
+	if best == nil {
+		best = fallback
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("All gRPC servers offline")
+	}
+
+	atomic.AddInt64(&best.inFlight, 1)
+	return best, nil
+}
+
+// Release hands back a node obtained from Acquire along with the error
+// of the request sent to it, if any. Transport failures eject the node.
+func (m *WorkerManager) Release(node *WorkerNode, err error) {
+	atomic.AddInt64(&node.inFlight, -1)
+	if err == nil {
+		node.markHealthy()
+		return
+	}
+
+	if status.Code(err) == codes.Unavailable {
+		m.markFailed(node, err)
+	}
+}
+
+// Process sends a granule to the least-loaded healthy worker. Requests
+// failing because a worker is unavailable are retried on other workers.
+func (m *WorkerManager) Process(ctx context.Context, addresses []string, granule *pb.GeoRPCGranule, opts ...grpc.CallOption) (*pb.Result, error) {
+	return m.process(ctx, addresses, func(node *WorkerNode) (*pb.Result, error) {
+		if node.isLocal() {
+			return m.processLocal(ctx, granule)
+		}
+
+		c := pb.NewGDALClient(node.Conn)
+		return c.Process(ctx, granule, m.callOptions(node, opts)...)
+	})
+}
+
+// ProcessStream is like Process but receives the result through the
+// streaming RPC so that large rasters are not bound by the maximum gRPC
+// message size. Workers without the streaming RPC are sent unary
+// requests instead.
+func (m *WorkerManager) ProcessStream(ctx context.Context, addresses []string, granule *pb.GeoRPCGranule, opts ...grpc.CallOption) (*pb.Result, error) {
+	return m.process(ctx, addresses, func(node *WorkerNode) (*pb.Result, error) {
+		if node.isLocal() {
+			return m.processLocal(ctx, granule)
+		}
+
+		c := pb.NewGDALClient(node.Conn)
+		callOpts := m.callOptions(node, opts)
+		if !node.streamSupported() {
+			return c.Process(ctx, granule, callOpts...)
+		}
+
+		stream, err := c.ProcessStream(ctx, granule, callOpts...)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := RecvRasterStream(stream)
+		if status.Code(err) == codes.Unimplemented {
+			node.mutex.Lock()
+			node.noStream = true
+			node.mutex.Unlock()
+			return c.Process(ctx, granule, callOpts...)
+		}
+		return r, err
+	})
+}
+
+// processLocal runs a granule in the embedded mode. As gsky-rpc does for
+// the results of gsky-gdal-process, results reporting an error are
+// returned as errors.
+func (m *WorkerManager) processLocal(ctx context.Context, granule *pb.GeoRPCGranule) (*pb.Result, error) {
+	r, err := m.local.Process(ctx, granule)
+	if err != nil {
+		return nil, err
+	}
+	if r.Error != "OK" {
+		return nil, fmt.Errorf("%s", r.Error)
+	}
+	return r, nil
+}
+
+func (m *WorkerManager) process(ctx context.Context, addresses []string, call func(*WorkerNode) (*pb.Result, error)) (*pb.Result, error) {
+	var lastErr error
+	for i := 0; i < len(addresses) || i == 0; i++ {
+		node, err := m.Acquire(addresses)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := call(node)
+		m.Release(node, err)
+		if err == nil {
+			return r, nil
+		}
+
+		lastErr = err
+		if status.Code(err) != codes.Unavailable || ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+func (m *WorkerManager) callOptions(node *WorkerNode, opts []grpc.CallOption) []grpc.CallOption {
+	if len(node.Compression) == 0 {
+		return opts
+	}
+
+	callOpts := make([]grpc.CallOption, 0, len(opts)+1)
+	callOpts = append(callOpts, opts...)
+	return append(callOpts, grpc.UseCompressor(node.Compression))
+}
+
+// AvailableCount returns the number of workers currently accepting
+// requests among the given addresses.
+func (m *WorkerManager) AvailableCount(addresses []string) int {
+	addresses = resolveWorkers(addresses)
+	now := time.Now()
+	cnt := 0
+	for _, addr := range addresses {
+		node, err := m.getNode(addr)
+		if err != nil {
+			continue
+		}
+		if node.isAvailable(now) {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// AvgPoolSize returns the average process pool size reported by the
+// reachable workers among the given addresses.
+func (m *WorkerManager) AvgPoolSize(addresses []string, verbose bool) int {
+	addresses = resolveWorkers(addresses)
+	var wg sync.WaitGroup
+	concLimit := make(chan bool, DefaultConcGrpcWorkerQuery)
+	workerPoolSizes := make([]int, len(addresses))
+	for i, addr := range addresses {
+		node, err := m.getNode(addr)
+		if err != nil {
+			log.Printf("%v", err)
+			continue
+		}
+
+		wg.Add(1)
+		concLimit <- true
+		go func(i int, node *WorkerNode) {
+			defer wg.Done()
+			defer func() { <-concLimit }()
+
+			node.mutex.RLock()
+			probed := node.probed
+			node.mutex.RUnlock()
+			if !probed {
+				err := m.probe(node)
+				if err != nil && verbose {
+					log.Printf("Failed to query gRPC worker %s, %v", node.Address, err)
+				}
+			}
+
+			node.mutex.RLock()
+			if node.healthy {
+				workerPoolSizes[i] = node.poolSize
+			}
+			node.mutex.RUnlock()
+		}(i, node)
+	}
+	wg.Wait()
+
+	avgPoolSize := 0.0
+	cnt := 0.0
+	for _, ps := range workerPoolSizes {
+		if ps > 0 {
+			avgPoolSize += float64(ps)
+			cnt++
+		}
+	}
+
+	if cnt >= 1 {
+		avgPoolSize /= cnt
+	}
+
+	return int(avgPoolSize + 0.5)
+}
+
+func (m *WorkerManager) probeLoop() {
+	ticker := time.NewTicker(DefaultWorkerProbeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.mutex.RLock()
+		nodes := make([]*WorkerNode, 0, len(m.nodes))
+		for _, node := range m.nodes {
+			nodes = append(nodes, node)
+		}
+		m.mutex.RUnlock()
+
+		now := time.Now()
+		concLimit := make(chan bool, DefaultConcGrpcWorkerQuery)
+		for _, node := range nodes {
+			if !node.isAvailable(now) {
+				continue
+			}
+			concLimit <- true
+			go func(node *WorkerNode) {
+				defer func() { <-concLimit }()
+				m.probe(node)
+			}(node)
+		}
+	}
+}
+
+func (m *WorkerManager) probe(node *WorkerNode) error {
+	if node.isLocal() {
+		node.setWorkerInfo(m.local.WorkerInfo())
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultWorkerProbeTimeout)
+	defer cancel()
+
+	hc := healthpb.NewHealthClient(node.Conn)
+	hr, err := hc.Check(ctx, &healthpb.HealthCheckRequest{})
+	if err == nil && hr.Status != healthpb.HealthCheckResponse_SERVING {
+		err = fmt.Errorf("health status: %v", hr.Status)
+	}
+
+	// Workers prior to the health service do not implement it
+	if err != nil && status.Code(err) != codes.Unimplemented {
+		m.markFailed(node, err)
+		return err
+	}
+
+	c := pb.NewGDALClient(node.Conn)
+	r, err := c.Process(ctx, &pb.GeoRPCGranule{Operation: "worker_info"})
+	if err != nil {
+		m.markFailed(node, err)
+		return err
+	}
+
+	node.setWorkerInfo(r.WorkerInfo)
+	node.markHealthy()
+	return nil
+}
+
+func (m *WorkerManager) markFailed(node *WorkerNode, err error) {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+
+	node.probed = true
+	node.failures++
+	backoff := DefaultWorkerMinBackoff * time.Duration(1<<uint(node.failures-1))
+	if node.failures > 16 || backoff > DefaultWorkerMaxBackoff {
+		backoff = DefaultWorkerMaxBackoff
+	}
+	node.retryAt = time.Now().Add(backoff)
+
+	if node.healthy || m.Verbose {
+		log.Printf("gRPC worker %s ejected for %v: %v", node.Address, backoff, err)
+	}
+	node.healthy = false
+}
+
+func (n *WorkerNode) isLocal() bool {
+	return n.Address == LocalWorkerAddress
+}
+
+func (n *WorkerNode) setWorkerInfo(info *pb.WorkerInfo) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if info != nil {
+		n.poolSize = int(info.PoolSize)
+		n.activeTasks = int(info.ActiveTasks)
+	}
+	n.probed = true
+}
+
+func (n *WorkerNode) markHealthy() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if !n.healthy {
+		log.Printf("gRPC worker %s is back online", n.Address)
+	}
+	n.healthy = true
+	n.failures = 0
+}
+
+func (n *WorkerNode) isAvailable(now time.Time) bool {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.healthy || now.After(n.retryAt)
+}
+
+func (n *WorkerNode) streamSupported() bool {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return !n.noStream
+}
+
+func (n *WorkerNode) getRetryAt() time.Time {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.retryAt
+}
+
+func (n *WorkerNode) load() float64 {
+	n.mutex.RLock()
+	poolSize := n.poolSize
+	activeTasks := n.activeTasks
+	n.mutex.RUnlock()
+
+	if poolSize <= 0 {
+		poolSize = 1
+	}
+	return float64(atomic.LoadInt64(&n.inFlight)+int64(activeTasks)) / float64(poolSize)
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/nci/gsky/worker/gdalservice"
+	"golang.org/x/net/context"
+)
+
+func newTestWorkerManager(nodes ...*WorkerNode) *WorkerManager {
+	m := &WorkerManager{nodes: make(map[string]*WorkerNode)}
+	m.probeOnce.Do(func() {})
+	for _, node := range nodes {
+		m.nodes[node.Address] = node
+	}
+	return m
+}
+
+func TestWorkerManagerAcquire(t *testing.T) {
+	busy := &WorkerNode{Address: "busy:6000", healthy: true, poolSize: 4, activeTasks: 3}
+	idle := &WorkerNode{Address: "idle:6000", healthy: true, poolSize: 4, activeTasks: 1}
+	m := newTestWorkerManager(busy, idle)
+	addrs := []string{busy.Address, idle.Address}
+
+	for i := 0; i < 2; i++ {
+		node, err := m.Acquire(addrs)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if node != idle {
+			t.Errorf("expected %s, got %s", idle.Address, node.Address)
+		}
+	}
+
+	// idle now carries 1 + 2 in-flight requests against busy's 3
+	node, _ := m.Acquire(addrs)
+	m.Release(node, nil)
+
+	m.markFailed(idle, fmt.Errorf("unavailable"))
+	node, err := m.Acquire(addrs)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if node != busy {
+		t.Errorf("ejected worker %s was selected", node.Address)
+	}
+	m.Release(node, nil)
+
+	if cnt := m.AvailableCount(addrs); cnt != 1 {
+		t.Errorf("expected 1 available worker, got %d", cnt)
+	}
+}
+
+func TestWorkerManagerBackoff(t *testing.T) {
+	w0 := &WorkerNode{Address: "w0:6000", healthy: true}
+	w1 := &WorkerNode{Address: "w1:6000", healthy: true}
+	m := newTestWorkerManager(w0, w1)
+
+	m.markFailed(w0, fmt.Errorf("unavailable"))
+	m.markFailed(w0, fmt.Errorf("unavailable"))
+	m.markFailed(w1, fmt.Errorf("unavailable"))
+
+	if w0.retryAt.Sub(w1.retryAt) < DefaultWorkerMinBackoff/2 {
+		t.Errorf("backoff did not grow with repeated failures")
+	}
+
+	// All workers ejected, fall back to the one due for retry first
+	node, err := m.Acquire([]string{w0.Address, w1.Address})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if node != w1 {
+		t.Errorf("expected fallback to %s, got %s", w1.Address, node.Address)
+	}
+
+	w0.retryAt = time.Now().Add(-time.Second)
+	if !w0.isAvailable(time.Now()) {
+		t.Errorf("worker should be retried once its backoff has expired")
+	}
+
+	m.Release(node, nil)
+	if !w1.healthy || w1.failures != 0 {
+		t.Errorf("successful request did not restore worker")
+	}
+}
+
+type testLocalExecutor struct {
+	calls int
+	err   string
+}
+
+func (e *testLocalExecutor) Process(ctx context.Context, granule *pb.GeoRPCGranule) (*pb.Result, error) {
+	e.calls++
+	if len(e.err) > 0 {
+		return &pb.Result{Error: e.err}, nil
+	}
+	return &pb.Result{Error: "OK", Shape: []int32{1, 1}}, nil
+}
+
+func (e *testLocalExecutor) WorkerInfo() *pb.WorkerInfo {
+	return &pb.WorkerInfo{PoolSize: 3}
+}
+
+func TestWorkerManagerLocal(t *testing.T) {
+	m := newTestWorkerManager()
+	if _, err := m.Process(context.Background(), nil, &pb.GeoRPCGranule{}); err == nil {
+		t.Errorf("expected error without worker nodes or local executor")
+	}
+
+	exec := &testLocalExecutor{}
+	m.SetLocalExecutor(exec)
+	for _, addrs := range [][]string{nil, {LocalWorkerAddress}} {
+		r, err := m.Process(context.Background(), addrs, &pb.GeoRPCGranule{Operation: "warp"})
+		if err != nil || len(r.Shape) != 2 {
+			t.Errorf("local execution failed: %v", err)
+		}
+	}
+
+	exec.err = "Task cancelled"
+	if _, err := m.Process(context.Background(), nil, &pb.GeoRPCGranule{Operation: "warp"}); err == nil || err.Error() != exec.err {
+		t.Errorf("expected the error of the result, got %v", err)
+	}
+
+	if exec.calls != 3 {
+		t.Errorf("expected 2 local calls, got %d", exec.calls)
+	}
+	if m.AvailableCount(nil) != 1 || m.AvgPoolSize(nil, false) != 3 {
+		t.Errorf("local worker not reported")
+	}
+}
+
+func TestWorkerManagerRegister(t *testing.T) {
+	m := newTestWorkerManager()
+	plain := m.RegisterWorkers([]string{"w0:6000", LocalWorkerAddress}, nil, "")
+	gzip := m.RegisterWorkers([]string{"w0:6000"}, nil, "gzip")
+	secure := m.RegisterWorkers(gzip, &GrpcSecurity{TLS: true}, "gzip")
+
+	if plain[0] != "w0:6000" || plain[1] != LocalWorkerAddress {
+		t.Errorf("default settings changed the addresses: %v", plain)
+	}
+	if gzip[0] == plain[0] || secure[0] == gzip[0] {
+		t.Errorf("different settings share a worker key: %v, %v, %v", plain, gzip, secure)
+	}
+	if again := m.RegisterWorkers([]string{"w0:6000"}, nil, "gzip"); again[0] != gzip[0] {
+		t.Errorf("same settings got different keys: %v, %v", gzip, again)
+	}
+	if addr, _ := parseWorkerKey(secure[0]); addr != "w0:6000" {
+		t.Errorf("unexpected address of %s: %s", secure[0], addr)
+	}
+}
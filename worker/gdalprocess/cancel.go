@@ -0,0 +1,89 @@
+package gdalprocess
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tasks are cancelled by their id, either with a "cancel" operation sent
+// over the unix socket of gsky-gdal-process or by the context of the
+// embedded mode. Long running operations poll the cancellation flag of
+// their task and bail out once it is set. Cancels arriving before their
+// task begins are kept for pendingCancelTTL and cancel the task as soon
+// as it begins.
+const pendingCancelTTL = 10 * time.Minute
+
+var tasks = struct {
+	sync.Mutex
+	running map[int64]*int32
+	pending map[int64]time.Time
+}{
+	running: make(map[int64]*int32),
+	pending: make(map[int64]time.Time),
+}
+
+const TaskCancelledError = "Task cancelled"
+
+// BeginTask marks the task with the given id as running. The task is
+// cancelled right away if a cancel for it arrived before.
+func BeginTask(id int64) {
+	if id == 0 {
+		return
+	}
+
+	tasks.Lock()
+	defer tasks.Unlock()
+	flag := new(int32)
+	if _, found := tasks.pending[id]; found {
+		*flag = 1
+		delete(tasks.pending, id)
+	}
+	tasks.running[id] = flag
+}
+
+// EndTask marks the task with the given id as finished
+func EndTask(id int64) {
+	tasks.Lock()
+	defer tasks.Unlock()
+	delete(tasks.running, id)
+}
+
+// CancelTask cancels the task with the given id. It returns false if the
+// task is not running, in which case it is cancelled once it begins.
+func CancelTask(id int64) bool {
+	if id == 0 {
+		return false
+	}
+
+	tasks.Lock()
+	defer tasks.Unlock()
+	if flag, found := tasks.running[id]; found {
+		atomic.StoreInt32(flag, 1)
+		return true
+	}
+
+	now := time.Now()
+	for pendingID, t := range tasks.pending {
+		if now.Sub(t) > pendingCancelTTL {
+			delete(tasks.pending, pendingID)
+		}
+	}
+	tasks.pending[id] = now
+	return false
+}
+
+// taskCancelFlag returns the cancellation flag of a running task. Tasks
+// which have not begun get a flag that is never set.
+func taskCancelFlag(id int64) *int32 {
+	tasks.Lock()
+	defer tasks.Unlock()
+	if flag, found := tasks.running[id]; found {
+		return flag
+	}
+	return new(int32)
+}
+
+func isTaskCancelled(flag *int32) bool {
+	return atomic.LoadInt32(flag) != 0
+}
@@ -0,0 +1,28 @@
+package gdalprocess
+
+import "testing"
+
+func TestCancelTask(t *testing.T) {
+	BeginTask(1)
+	if CancelTask(2) || isTaskCancelled(taskCancelFlag(1)) {
+		t.Errorf("cancelled a task which is not running")
+	}
+
+	if !CancelTask(1) || !isTaskCancelled(taskCancelFlag(1)) {
+		t.Errorf("running task was not cancelled")
+	}
+	EndTask(1)
+
+	// The cancel of task 2 arrived before it began
+	BeginTask(2)
+	if !isTaskCancelled(taskCancelFlag(2)) {
+		t.Errorf("pending cancel was lost")
+	}
+	EndTask(2)
+
+	BeginTask(3)
+	if isTaskCancelled(taskCancelFlag(3)) {
+		t.Errorf("cancellation leaked into the next task")
+	}
+	EndTask(3)
+}
@@ -0,0 +1,188 @@
+package gdalprocess
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const DefaultCgroupRoot = "/sys/fs/cgroup"
+
+// cgroup v1 reports an unlimited memory.limit_in_bytes as a number
+// close to the maximum int64
+const cgroupV1Unlimited = int64(1) << 62
+
+// cgroupMemory is the memory accounting of the cgroup of a process.
+// Limit is negative if the cgroup has no memory limit. Usage excludes
+// the inactive page cache, which the kernel reclaims before an OOM.
+type cgroupMemory struct {
+	Limit int64
+	Usage int64
+}
+
+// cgroupMemoryDir locates the memory controller directory of a cgroup
+type cgroupMemoryDir struct {
+	Dir string
+	V2  bool
+}
+
+var selfCgroup struct {
+	sync.Once
+	dir *cgroupMemoryDir
+}
+
+func getSelfCgroupMemoryDir() *cgroupMemoryDir {
+	selfCgroup.Do(func() {
+		dir, err := findCgroupMemoryDir(DefaultCgroupRoot, "/proc/self/cgroup")
+		if err == nil {
+			selfCgroup.dir = dir
+		}
+	})
+	return selfCgroup.dir
+}
+
+// findCgroupMemoryDir finds the memory controller directory given the
+// cgroup mount point and a /proc/<pid>/cgroup file
+func findCgroupMemoryDir(root string, procCgroup string) (*cgroupMemoryDir, error) {
+	data, err := ioutil.ReadFile(procCgroup)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(filepath.Join(root, "cgroup.controllers"))
+	isV2 := err == nil
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		var dirs []string
+		var limitFile string
+		if isV2 && fields[0] == "0" && len(fields[1]) == 0 {
+			dirs = []string{filepath.Join(root, fields[2]), root}
+			limitFile = "memory.max"
+		} else if !isV2 && hasController(fields[1], "memory") {
+			dirs = []string{filepath.Join(root, "memory", fields[2]), filepath.Join(root, "memory")}
+			limitFile = "memory.limit_in_bytes"
+		} else {
+			continue
+		}
+
+		// Inside containers the cgroup of the process is usually
+		// mounted as the root
+		for _, dir := range dirs {
+			if _, err := os.Stat(filepath.Join(dir, limitFile)); err == nil {
+				return &cgroupMemoryDir{Dir: dir, V2: isV2}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("memory cgroup not found")
+}
+
+func hasController(controllers string, name string) bool {
+	for _, c := range strings.Split(controllers, ",") {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (cg *cgroupMemoryDir) readMemory() (*cgroupMemory, error) {
+	limitFile, usageFile, inactiveKey := "memory.limit_in_bytes", "memory.usage_in_bytes", "total_inactive_file"
+	if cg.V2 {
+		limitFile, usageFile, inactiveKey = "memory.max", "memory.current", "inactive_file"
+	}
+
+	mem := &cgroupMemory{Limit: -1}
+
+	limitStr, err := readCgroupValue(filepath.Join(cg.Dir, limitFile))
+	if err != nil {
+		return nil, err
+	}
+	if limitStr != "max" {
+		limit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", limitFile, err)
+		}
+		if limit < cgroupV1Unlimited {
+			mem.Limit = limit
+		}
+	}
+
+	usageStr, err := readCgroupValue(filepath.Join(cg.Dir, usageFile))
+	if err != nil {
+		return nil, err
+	}
+	mem.Usage, err = strconv.ParseInt(usageStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", usageFile, err)
+	}
+
+	stat, err := ioutil.ReadFile(filepath.Join(cg.Dir, "memory.stat"))
+	if err == nil {
+		for _, line := range strings.Split(string(stat), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 || fields[0] != inactiveKey {
+				continue
+			}
+			inactive, err := strconv.ParseInt(fields[1], 10, 64)
+			if err == nil && inactive < mem.Usage {
+				mem.Usage -= inactive
+			}
+			break
+		}
+	}
+
+	return mem, nil
+}
+
+// pressurePath returns the PSI file of the cgroup, falling back to the
+// system-wide PSI
+func (cg *cgroupMemoryDir) pressurePath() string {
+	if cg != nil && cg.V2 {
+		path := filepath.Join(cg.Dir, "memory.pressure")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return "/proc/pressure/memory"
+}
+
+// readMemoryPressure returns the percentage of time in the last 10
+// seconds during which all tasks were stalled on memory
+func readMemoryPressure(path string) (float64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "full" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "avg10=") {
+				return strconv.ParseFloat(strings.TrimPrefix(field, "avg10="), 64)
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("%s: full avg10 not found", path)
+}
+
+func readCgroupValue(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
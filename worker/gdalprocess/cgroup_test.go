@@ -0,0 +1,90 @@
+package gdalprocess
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+}
+
+func TestCgroupV2Memory(t *testing.T) {
+	root, err := ioutil.TempDir("", "gsky_cgroup_")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(root)
+
+	writeTestFiles(t, root, map[string]string{
+		"proc_cgroup":                  "0::/kubepods/pod1\n",
+		"cgroup.controllers":           "cpu memory\n",
+		"kubepods/pod1/memory.max":     "1073741824\n",
+		"kubepods/pod1/memory.current": "536870912\n",
+		"kubepods/pod1/memory.stat":    "anon 1000\ninactive_file 268435456\n",
+		"kubepods/pod1/memory.pressure": "some avg10=1.50 avg60=0.50 avg300=0.10 total=100\n" +
+			"full avg10=0.75 avg60=0.25 avg300=0.05 total=50\n",
+	})
+
+	cg, err := findCgroupMemoryDir(root, filepath.Join(root, "proc_cgroup"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !cg.V2 || cg.Dir != filepath.Join(root, "kubepods/pod1") {
+		t.Errorf("unexpected cgroup: %+v", cg)
+	}
+
+	mem, err := cg.readMemory()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if mem.Limit != 1<<30 || mem.Usage != 1<<28 {
+		t.Errorf("unexpected cgroup memory: %+v", mem)
+	}
+
+	pressure, err := readMemoryPressure(cg.pressurePath())
+	if err != nil || pressure != 0.75 {
+		t.Errorf("unexpected memory pressure: %v, %v", pressure, err)
+	}
+}
+
+func TestCgroupV1Memory(t *testing.T) {
+	root, err := ioutil.TempDir("", "gsky_cgroup_")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(root)
+
+	// The cgroup of the process is mounted as the root in containers
+	writeTestFiles(t, root, map[string]string{
+		"proc_cgroup":                  "5:cpuacct,cpu:/docker/abc\n4:memory:/docker/abc\n",
+		"memory/memory.limit_in_bytes": "9223372036854771712\n",
+		"memory/memory.usage_in_bytes": "4096\n",
+	})
+
+	cg, err := findCgroupMemoryDir(root, filepath.Join(root, "proc_cgroup"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if cg.V2 || cg.Dir != filepath.Join(root, "memory") {
+		t.Errorf("unexpected cgroup: %+v", cg)
+	}
+
+	mem, err := cg.readMemory()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if mem.Limit >= 0 || mem.Usage != 4096 {
+		t.Errorf("unlimited cgroup not detected: %+v", mem)
+	}
+}
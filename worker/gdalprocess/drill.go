@@ -82,12 +82,12 @@ func DrillDataset(in *pb.GeoRPCGranule) *pb.Result {
 
 	C.OGR_G_AssignSpatialReference(geom, selSRS)
 
-	res := readData(ds, float64(in.Width), float64(in.Height), in.Bands, geom, int(in.BandStrides), int(in.DrillDecileCount), int(in.PixelCount), in.ClipUpper, in.ClipLower)
+	res := readData(ds, float64(in.Width), float64(in.Height), in.Bands, geom, int(in.BandStrides), int(in.DrillDecileCount), int(in.PixelCount), in.ClipUpper, in.ClipLower, taskCancelFlag(in.TaskId))
 	C.OGR_G_DestroyGeometry(geom)
 	return res
 }
 
-func readData(ds C.GDALDatasetH, rasterXSize float64, rasterYSize float64, bands []int32, geom C.OGRGeometryH, bandStrides int, decileCount int, pixelCount int, clipUpper float32, clipLower float32) *pb.Result {
+func readData(ds C.GDALDatasetH, rasterXSize float64, rasterYSize float64, bands []int32, geom C.OGRGeometryH, bandStrides int, decileCount int, pixelCount int, clipUpper float32, clipLower float32, cancelled *int32) *pb.Result {
 	nCols := 1 + decileCount
 
 	avgs := []*pb.TimeSeries{}
@@ -126,6 +126,10 @@ func readData(ds C.GDALDatasetH, rasterXSize float64, rasterYSize float64, bands
 	// 2) Load band 3 and compute average for band 3 (i.e. avg3)
 	// 3) Linearly interpolate avg2 using avg1 and avg3
 	for ibBgn := 0; ibBgn < len(bands); ibBgn += bandStrides {
+		if isTaskCancelled(cancelled) {
+			return &pb.Result{Error: TaskCancelledError, Metrics: metrics}
+		}
+
 		ibEnd := ibBgn + bandStrides
 		if ibEnd > len(bands) {
 			ibEnd = len(bands)
@@ -0,0 +1,86 @@
+package gdalprocess
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	pb "github.com/nci/gsky/worker/gdalservice"
+)
+
+// LocalExecutor runs granules in the calling process for the embedded
+// mode, at most PoolSize at a time. Unlike gsky-gdal-process, a task
+// crashing GDAL takes the whole process down.
+type LocalExecutor struct {
+	PoolSize int
+
+	slots       chan struct{}
+	activeTasks int32
+	queued      int32
+}
+
+// IDs of the tasks run by local executors, which share the cancellation
+// flags of the process
+var lastLocalTaskID int64
+
+func NewLocalExecutor(poolSize int) *LocalExecutor {
+	if poolSize < 1 {
+		poolSize = 1
+	}
+	return &LocalExecutor{PoolSize: poolSize, slots: make(chan struct{}, poolSize)}
+}
+
+func (e *LocalExecutor) Process(ctx context.Context, in *pb.GeoRPCGranule) (*pb.Result, error) {
+	atomic.AddInt32(&e.queued, 1)
+	select {
+	case e.slots <- struct{}{}:
+		atomic.AddInt32(&e.queued, -1)
+	case <-ctx.Done():
+		atomic.AddInt32(&e.queued, -1)
+		return nil, ctx.Err()
+	}
+	defer func() { <-e.slots }()
+
+	atomic.AddInt32(&e.activeTasks, 1)
+	defer atomic.AddInt32(&e.activeTasks, -1)
+
+	// Cancel the task once its request is cancelled
+	in.TaskId = atomic.AddInt64(&lastLocalTaskID, 1)
+	BeginTask(in.TaskId)
+	defer EndTask(in.TaskId)
+
+	taskDone := make(chan struct{})
+	defer close(taskDone)
+	go func(taskID int64) {
+		select {
+		case <-ctx.Done():
+			CancelTask(taskID)
+		case <-taskDone:
+		}
+	}(in.TaskId)
+
+	var out *pb.Result
+	switch in.Operation {
+	case "warp":
+		out = WarpRaster(in)
+	case "drill":
+		out = DrillDataset(in)
+	case "extent":
+		out = ComputeReprojectExtent(in)
+	case "info":
+		out = ExtractGDALInfo(in)
+	case "worker_info":
+		return &pb.Result{WorkerInfo: e.WorkerInfo(), Error: "OK"}, nil
+	default:
+		return nil, fmt.Errorf("Unknown operation: %s", in.Operation)
+	}
+	return out, nil
+}
+
+func (e *LocalExecutor) WorkerInfo() *pb.WorkerInfo {
+	return &pb.WorkerInfo{
+		PoolSize:    int32(e.PoolSize),
+		ActiveTasks: atomic.LoadInt32(&e.activeTasks),
+		QueueLength: atomic.LoadInt32(&e.queued),
+	}
+}
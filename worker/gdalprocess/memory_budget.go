@@ -0,0 +1,119 @@
+package gdalprocess
+
+import (
+	"sync"
+
+	pb "github.com/nci/gsky/worker/gdalservice"
+)
+
+// DefaultTaskBaseMemory is the memory in bytes assumed for any task on
+// top of the size of its rasters, accounting for GDAL block caches,
+// dataset handles and protobuf encoding
+const DefaultTaskBaseMemory = 32 * 1024 * 1024
+
+// rasterTypeSize returns the bytes per pixel of a GDAL raster type. The
+// largest size is assumed for unknown types.
+func rasterTypeSize(rasterType string) int64 {
+	switch rasterType {
+	case "Byte", "SignedByte":
+		return 1
+	case "Int16", "UInt16":
+		return 2
+	case "Int32", "UInt32", "Float32":
+		return 4
+	default:
+		return 8
+	}
+}
+
+// EstimateTaskMemory estimates the peak memory in bytes gsky-gdal-process
+// needs to run a task
+func EstimateTaskMemory(in *pb.GeoRPCGranule) int64 {
+	pixels := int64(in.Width) * int64(in.Height)
+	if pixels < 0 {
+		pixels = 0
+	}
+
+	switch in.Operation {
+	case "warp":
+		// The warped raster of the granule's type is copied twice on its
+		// way out. The warper keeps two size_t indices per pixel to group
+		// the pixels by source block.
+		return DefaultTaskBaseMemory + pixels*(3*rasterTypeSize(in.RasterType)+2*8)
+	case "drill":
+		// Two float32 bands are read at a time along with a byte mask
+		return DefaultTaskBaseMemory + pixels*(2*4+1)
+	default:
+		return DefaultTaskBaseMemory
+	}
+}
+
+// MemoryBudget holds tasks back while the memory estimated for the
+// running tasks would exceed Limit. A task is always admitted when no
+// other task is running so that oversized tasks are not starved. A nil
+// MemoryBudget or a non-positive Limit admits every task.
+type MemoryBudget struct {
+	Limit int64
+
+	mutex    sync.Mutex
+	reserved int64
+	running  int
+	released chan struct{}
+}
+
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{Limit: limit, released: make(chan struct{})}
+}
+
+// TryAcquire reserves size bytes if they are available. Otherwise it
+// returns false along with a channel which is closed once memory is
+// released.
+func (b *MemoryBudget) TryAcquire(size int64) (bool, <-chan struct{}) {
+	if b == nil || b.Limit <= 0 {
+		return true, nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.running == 0 || b.reserved+size <= b.Limit {
+		b.reserved += size
+		b.running++
+		return true, nil
+	}
+	return false, b.released
+}
+
+// Release returns the memory reserved by Acquire
+func (b *MemoryBudget) Release(size int64) {
+	if b == nil || b.Limit <= 0 {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.reserved -= size
+	b.running--
+	close(b.released)
+	b.released = make(chan struct{})
+}
+
+// Reserved returns the memory in bytes reserved by the running tasks
+func (b *MemoryBudget) Reserved() int64 {
+	if b == nil {
+		return 0
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.reserved
+}
+
+// GetMemoryBudgetLimit returns the memory in bytes available to tasks,
+// i.e. the memory of the host or cgroup less the OOM threshold in KB
+func GetMemoryBudgetLimit(oomThreshold int) (int64, error) {
+	memInfo, err := getMemoryInfo()
+	if err != nil {
+		return 0, err
+	}
+	return (memInfo.TotalMemory - int64(oomThreshold)) * 1024, nil
+}
@@ -0,0 +1,59 @@
+package gdalprocess
+
+import (
+	"testing"
+
+	pb "github.com/nci/gsky/worker/gdalservice"
+)
+
+func TestEstimateTaskMemory(t *testing.T) {
+	small := EstimateTaskMemory(&pb.GeoRPCGranule{Operation: "warp", Width: 256, Height: 256, RasterType: "Float32"})
+	large := EstimateTaskMemory(&pb.GeoRPCGranule{Operation: "warp", Width: 4096, Height: 4096, RasterType: "Float32"})
+	if small <= DefaultTaskBaseMemory || large-DefaultTaskBaseMemory != (small-DefaultTaskBaseMemory)*256 {
+		t.Errorf("warp estimates do not scale with size: %d, %d", small, large)
+	}
+
+	float64Size := EstimateTaskMemory(&pb.GeoRPCGranule{Operation: "warp", Width: 256, Height: 256, RasterType: "Float64"})
+	byteSize := EstimateTaskMemory(&pb.GeoRPCGranule{Operation: "warp", Width: 256, Height: 256, RasterType: "Byte"})
+	if float64Size <= small || byteSize >= small {
+		t.Errorf("warp estimates do not scale with the raster type: %d, %d, %d", byteSize, small, float64Size)
+	}
+
+	if EstimateTaskMemory(&pb.GeoRPCGranule{Operation: "info"}) != DefaultTaskBaseMemory {
+		t.Errorf("unexpected estimate for info")
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	b := NewMemoryBudget(100)
+
+	// Oversized tasks are admitted when nothing else is running
+	if ok, _ := b.TryAcquire(150); !ok {
+		t.Fatalf("oversized task was not admitted")
+	}
+	b.Release(150)
+
+	b.TryAcquire(60)
+	ok, released := b.TryAcquire(60)
+	if ok {
+		t.Errorf("budget was over-committed")
+	}
+
+	b.Release(60)
+	select {
+	case <-released:
+	default:
+		t.Errorf("release was not signalled")
+	}
+	if ok, _ := b.TryAcquire(60); !ok {
+		t.Errorf("held back task was not admitted after release")
+	}
+	if b.Reserved() != 60 {
+		t.Errorf("unexpected reserved memory: %d", b.Reserved())
+	}
+
+	var unlimited *MemoryBudget
+	if ok, _ := unlimited.TryAcquire(1 << 40); !ok {
+		t.Errorf("nil budget held a task back")
+	}
+}
@@ -80,14 +80,40 @@ func parseProcInfo(procPath string, lookupKeys []string) (*procInfo, error) {
 type memoryInfo struct {
 	TotalMemory     int64
 	AvailableMemory int64
+	CgroupLimited   bool
 }
 
+// getMemoryInfo returns the memory in KB available to this process.
+// In containers, /proc/meminfo reports the host's memory and the memory
+// limit of the cgroup is applied on top of it.
 func getMemoryInfo() (*memoryInfo, error) {
 	info, err := parseProcInfo("/proc/meminfo", []string{"MemTotal", "MemAvailable"})
 	if err != nil {
 		return nil, err
 	}
-	return &memoryInfo{TotalMemory: info.KBytes["MemTotal"], AvailableMemory: info.KBytes["MemAvailable"]}, nil
+	memInfo := &memoryInfo{TotalMemory: info.KBytes["MemTotal"], AvailableMemory: info.KBytes["MemAvailable"]}
+
+	cg := getSelfCgroupMemoryDir()
+	if cg == nil {
+		return memInfo, nil
+	}
+
+	cgMem, err := cg.readMemory()
+	if err != nil || cgMem.Limit < 0 {
+		return memInfo, nil
+	}
+
+	limit := cgMem.Limit / 1024
+	available := (cgMem.Limit - cgMem.Usage) / 1024
+	if limit < memInfo.TotalMemory {
+		memInfo.TotalMemory = limit
+		memInfo.CgroupLimited = true
+	}
+	if available < memInfo.AvailableMemory {
+		memInfo.AvailableMemory = available
+	}
+
+	return memInfo, nil
 }
 
 type processStatus struct {
@@ -137,10 +163,19 @@ func findProcessStatus(pattern *regexp.Regexp) ([]*processStatus, error) {
 	return procStatus, nil
 }
 
+// DefaultPressureHoldoff is the time a kill triggered by memory
+// pressure is given to take effect on the PSI averages
+const DefaultPressureHoldoff = 10 * time.Second
+
 type OOMMonitor struct {
 	ExecMatch    string
 	OOMThreshold int
 	Verbose      bool
+
+	// PressureThreshold is the percentage of time all tasks were stalled
+	// on memory over the last 10 seconds which triggers a kill. Zero
+	// disables the PSI check.
+	PressureThreshold float64
 }
 
 func NewOOMMonitor(execMatch string, oomThreshold int, verbose bool) *OOMMonitor {
@@ -176,6 +211,9 @@ func (mon *OOMMonitor) getPollInterval(memInfo *memoryInfo) int {
 func (mon *OOMMonitor) StartMonitorLoop() error {
 	pattern := regexp.MustCompile(mon.ExecMatch)
 
+	pressurePath := getSelfCgroupMemoryDir().pressurePath()
+	var pressureHoldoff time.Time
+
 	isMemInfoFirst := true
 	isNoProcessFound := true
 	for {
@@ -185,12 +223,24 @@ func (mon *OOMMonitor) StartMonitorLoop() error {
 		}
 
 		if mon.Verbose && isMemInfoFirst {
-			log.Printf("meminfo (KB), total: %d, available: %d, OOM threshold: %d", memInfo.TotalMemory, memInfo.AvailableMemory, mon.OOMThreshold)
+			log.Printf("meminfo (KB), total: %d, available: %d, OOM threshold: %d, cgroup limited: %v", memInfo.TotalMemory, memInfo.AvailableMemory, mon.OOMThreshold, memInfo.CgroupLimited)
 			isMemInfoFirst = false
 		}
 
+		underPressure := false
+		if mon.PressureThreshold > 0 && time.Now().After(pressureHoldoff) {
+			pressure, err := readMemoryPressure(pressurePath)
+			if err == nil && pressure >= mon.PressureThreshold {
+				underPressure = true
+				pressureHoldoff = time.Now().Add(DefaultPressureHoldoff)
+				if mon.Verbose {
+					log.Printf("memory pressure %.2f%% exceeds threshold %.2f%%", pressure, mon.PressureThreshold)
+				}
+			}
+		}
+
 		interval := mon.getPollInterval(memInfo)
-		if interval >= 100 {
+		if interval >= 100 && !underPressure {
 			time.Sleep(time.Duration(interval) * time.Millisecond)
 			continue
 		}
@@ -1,7 +1,6 @@
 package gdalprocess
 
 import (
-	"fmt"
 	"log"
 	"math/rand"
 )
@@ -11,31 +10,50 @@ const DefaultQueueSizePerProcess = 200
 type ProcessPool struct {
 	Pool             []*Process
 	PoolSize         int
-	TaskQueue        chan *Task
+	TaskQueue        *TaskQueue
 	MaxTaskProcessed int
 	ErrorMsg         chan *ErrorMsg
+	MemoryBudget     *MemoryBudget
 }
 
 func (p *ProcessPool) AddQueue(task *Task) {
-	if len(p.TaskQueue) > DefaultQueueSizePerProcess*len(p.Pool)-10 {
-		task.Error <- fmt.Errorf("Pool TaskQueue is full")
-		return
+	err := p.TaskQueue.Push(task)
+	if err != nil {
+		task.Error <- err
 	}
-	p.TaskQueue <- task
+}
+
+func (p *ProcessPool) QueueLength() int {
+	return p.TaskQueue.Len()
+}
+
+// QueueLengths returns the number of queued tasks per priority class
+func (p *ProcessPool) QueueLengths() []int {
+	return p.TaskQueue.Lengths()
 }
 
 func (p *ProcessPool) CreateProcess(executable string, port int, verbose bool) (*Process, error) {
 
 	randTasks := rand.Intn(p.PoolSize)
 	proc := NewProcess(p.TaskQueue, executable, port, p.ErrorMsg, p.MaxTaskProcessed+randTasks, verbose)
+	proc.MemoryBudget = p.MemoryBudget
 	err := proc.Start()
 
 	return proc, err
 }
 
-func CreateProcessPool(n int, executable string, port int, maxTaskProcessed int, verbose bool) (*ProcessPool, error) {
+// CreateProcessPool starts n gsky-gdal-process. Tasks are held back while
+// their estimated memory would exceed memBudget bytes, unless memBudget
+// is not positive.
+func CreateProcessPool(n int, executable string, port int, maxTaskProcessed int, memBudget int64, verbose bool) (*ProcessPool, error) {
+
+	queueSizes := make([]int, len(DefaultPriorityQueueSizes))
+	for i, size := range DefaultPriorityQueueSizes {
+		queueSizes[i] = size * n
+	}
+	taskQueue := NewTaskQueue(queueSizes, DefaultPriorityWeights)
 
-	p := &ProcessPool{[]*Process{}, n, make(chan *Task, DefaultQueueSizePerProcess*n), maxTaskProcessed, make(chan *ErrorMsg)}
+	p := &ProcessPool{[]*Process{}, n, taskQueue, maxTaskProcessed, make(chan *ErrorMsg), NewMemoryBudget(memBudget)}
 
 	go func() {
 		for {
@@ -1,6 +1,7 @@
 package gdalprocess
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -10,7 +11,6 @@ import (
 	"time"
 
 	"bufio"
-	"bytes"
 	"io"
 	"log"
 
@@ -24,15 +24,42 @@ type ErrorMsg struct {
 	Error   error
 }
 
+// Task is a granule queued for gsky-gdal-process. The result is sent
+// to Resp, or to Chunks for streaming tasks, in which case the header
+// of the result is followed by the raster data in chunks of ChunkSize
+// bytes and Chunks is closed once all the data has been sent.
 type Task struct {
+	Context   context.Context
 	Payload   *pb.GeoRPCGranule
 	Resp      chan *pb.Result
+	Chunks    chan *pb.RasterChunk
+	ChunkSize int
 	Error     chan error
 	NumTrials int
 }
 
+// Done returns the cancellation channel of the task's context, which
+// is nil if the task has no context.
+func (t *Task) Done() <-chan struct{} {
+	if t.Context == nil {
+		return nil
+	}
+	return t.Context.Done()
+}
+
+// sendChunk sends a chunk of a streaming task unless its request has
+// been cancelled
+func (t *Task) sendChunk(chunk *pb.RasterChunk) bool {
+	select {
+	case t.Chunks <- chunk:
+		return true
+	case <-t.Done():
+		return false
+	}
+}
+
 type Process struct {
-	TaskQueue        chan *Task
+	TaskQueue        *TaskQueue
 	Address          string
 	TempFile         string
 	Cmd              *exec.Cmd
@@ -40,9 +67,10 @@ type Process struct {
 	MaxTaskProcessed int
 	ErrorMsg         chan *ErrorMsg
 	Verbose          bool
+	MemoryBudget     *MemoryBudget
 }
 
-func NewProcess(tQueue chan *Task, binary string, port int, errChan chan *ErrorMsg, maxTaskProcessed int, verbose bool) *Process {
+func NewProcess(tQueue *TaskQueue, binary string, port int, errChan chan *ErrorMsg, maxTaskProcessed int, verbose bool) *Process {
 	verboseArg := ""
 	if verbose {
 		verboseArg = "-verbose"
@@ -69,7 +97,7 @@ func NewProcess(tQueue chan *Task, binary string, port int, errChan chan *ErrorM
 		cmd.Stdout = cmd.Stderr
 	}
 
-	return &Process{tQueue, addr, tmpFileName, cmd, combinedOutput, maxTaskProcessed, errChan, verbose}
+	return &Process{tQueue, addr, tmpFileName, cmd, combinedOutput, maxTaskProcessed, errChan, verbose, nil}
 }
 
 func (p *Process) Start() error {
@@ -106,16 +134,42 @@ func (p *Process) Start() error {
 		}
 
 		taskProcessed := 0
-		for task := range p.TaskQueue {
+		var taskID int64
+		for {
+			task := p.TaskQueue.Pop()
+
+			// Drop tasks whose requests were cancelled while queued
+			select {
+			case <-task.Done():
+				task.Error <- fmt.Errorf("task dropped: %v", task.Context.Err())
+				continue
+			default:
+			}
+
+			// Put the task back while the memory budget is short rather
+			// than holding on to it, and wait for running tasks to
+			// release memory
+			taskMemory := EstimateTaskMemory(task.Payload)
+			acquired, released := p.MemoryBudget.TryAcquire(taskMemory)
+			if !acquired {
+				p.TaskQueue.Requeue(task)
+				<-released
+				continue
+			}
+
 			conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: p.Address, Net: "unix"})
 			if err != nil {
+				p.MemoryBudget.Release(taskMemory)
 				p.retryTask(task, fmt.Errorf("dial failed: %v", err))
 				break
 			}
 
+			taskID++
+			task.Payload.TaskId = taskID
 			inb, err := proto.Marshal(task.Payload)
 			if err != nil {
 				conn.Close()
+				p.MemoryBudget.Release(taskMemory)
 				task.Error <- fmt.Errorf("encode failed: %v", err)
 				continue
 			}
@@ -123,33 +177,58 @@ func (p *Process) Start() error {
 			n, err := conn.Write(inb)
 			if err != nil {
 				conn.Close()
+				p.MemoryBudget.Release(taskMemory)
 				p.retryTask(task, fmt.Errorf("conn.write failed: %v, bytes written: %v", err, n))
 				break
 			}
 			conn.CloseWrite()
 
-			var buf bytes.Buffer
-			nr, err := io.Copy(&buf, conn)
+			taskDone := make(chan struct{})
+			go p.watchCancel(task, taskID, taskDone)
+
+			reader := bufio.NewReader(conn)
+			header, err := ReadResultHeader(reader)
 			if err != nil {
+				close(taskDone)
 				conn.Close()
-				p.retryTask(task, fmt.Errorf("io.copy failed: %v, bytes read: %v", err, nr))
+				p.MemoryBudget.Release(taskMemory)
+				p.retryTask(task, fmt.Errorf("reading result failed: %v", err))
 				break
 			}
-			conn.Close()
 
-			out := new(pb.Result)
-			err = proto.Unmarshal(buf.Bytes(), out)
-			if err != nil {
-				task.Error <- fmt.Errorf("error decoding data: %v", err)
-				continue
-			}
-
-			if len(out.Error) == 0 {
+			if len(header.Header.Error) == 0 {
+				close(taskDone)
+				conn.Close()
+				p.MemoryBudget.Release(taskMemory)
 				p.retryTask(task, fmt.Errorf("process communication error"))
 				break
 			}
 
-			task.Resp <- out
+			if task.Chunks != nil {
+				err = p.streamResult(task, header, reader)
+			} else {
+				var out *pb.Result
+				out, err = ReadResult(reader, header)
+				if err == nil {
+					task.Resp <- out
+				}
+			}
+			close(taskDone)
+			conn.Close()
+			p.MemoryBudget.Release(taskMemory)
+
+			// Streaming tasks can't be retried once their header has
+			// been sent
+			if err != nil && task.Chunks != nil {
+				syscall.Kill(p.Cmd.Process.Pid, syscall.SIGKILL)
+				task.Error <- fmt.Errorf("streaming result failed: %v", err)
+				p.ErrorMsg <- &ErrorMsg{p.Address, false, fmt.Errorf("Process IO failed: %v", err)}
+				break
+			}
+			if err != nil {
+				p.retryTask(task, fmt.Errorf("reading result failed: %v", err))
+				break
+			}
 
 			taskProcessed++
 			if taskProcessed >= p.MaxTaskProcessed {
@@ -186,13 +265,86 @@ func (p *Process) Start() error {
 	return nil
 }
 
+// streamResult forwards the result of a streaming task, reading its
+// raster data from the subprocess a chunk at a time. Nothing more is
+// read once the request of the task has been cancelled.
+func (p *Process) streamResult(task *Task, header *pb.RasterChunk, r io.Reader) error {
+	if !task.sendChunk(header) {
+		return nil
+	}
+
+	chunkSize := int64(task.ChunkSize)
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	remaining := header.TotalSize
+	for remaining > 0 {
+		n := chunkSize
+		if n > remaining {
+			n = remaining
+		}
+
+		data := make([]byte, n)
+		_, err := io.ReadFull(r, data)
+		if err != nil {
+			return err
+		}
+
+		if !task.sendChunk(&pb.RasterChunk{Data: data}) {
+			return nil
+		}
+		remaining -= n
+	}
+
+	close(task.Chunks)
+	return nil
+}
+
+// watchCancel asks the subprocess to abort the task once its context is
+// cancelled, until taskDone is closed.
+func (p *Process) watchCancel(task *Task, taskID int64, taskDone chan struct{}) {
+	select {
+	case <-taskDone:
+		return
+	case <-task.Done():
+	}
+
+	err := p.sendCancel(taskID)
+	if err != nil && p.Verbose {
+		log.Printf("Failed to cancel task %d: %v", taskID, err)
+	}
+}
+
+func (p *Process) sendCancel(taskID int64) error {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: p.Address, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	inb, err := proto.Marshal(&pb.GeoRPCGranule{Operation: "cancel", TaskId: taskID})
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write(inb)
+	if err != nil {
+		return err
+	}
+	conn.CloseWrite()
+
+	_, err = io.Copy(ioutil.Discard, conn)
+	return err
+}
+
 func (p *Process) retryTask(task *Task, taskErr error) {
 	syscall.Kill(p.Cmd.Process.Pid, syscall.SIGKILL)
 	task.NumTrials++
 	if task.NumTrials >= 5 {
 		task.Error <- taskErr
 	} else {
-		p.TaskQueue <- task
+		p.TaskQueue.Requeue(task)
 	}
 	p.ErrorMsg <- &ErrorMsg{p.Address, false, fmt.Errorf("Process IO failed: %v, retrying task: %v", taskErr, task.NumTrials)}
 }
@@ -0,0 +1,90 @@
+package gdalprocess
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/nci/gsky/worker/gdalservice"
+)
+
+// DefaultChunkSize is the size of the raster chunks read from
+// gsky-gdal-process for streaming tasks
+const DefaultChunkSize = 1024 * 1024
+
+// Headers larger than this are treated as a corrupt connection
+const maxResultHeaderSize = 256 * 1024 * 1024
+
+// WriteResult writes a result to the connection of a task as the
+// length-delimited header of a RasterChunk followed by the raster data,
+// so that the data can be forwarded in chunks without buffering.
+func WriteResult(w io.Writer, out *pb.Result) error {
+	var data []byte
+	if out.Raster != nil {
+		data = out.Raster.Data
+		out.Raster.Data = nil
+		defer func() { out.Raster.Data = data }()
+	}
+
+	header, err := proto.Marshal(&pb.RasterChunk{Header: out, TotalSize: int64(len(data))})
+	if err != nil {
+		return err
+	}
+
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(len(header)))
+
+	bw := bufio.NewWriter(w)
+	bw.Write(size[:n])
+	bw.Write(header)
+	bw.Write(data)
+	return bw.Flush()
+}
+
+// ReadResultHeader reads the header written by WriteResult. The raster
+// data of TotalSize bytes follows in r.
+func ReadResultHeader(r *bufio.Reader) (*pb.RasterChunk, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxResultHeaderSize {
+		return nil, fmt.Errorf("invalid result header size: %d", size)
+	}
+
+	buf := make([]byte, size)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	header := new(pb.RasterChunk)
+	err = proto.Unmarshal(buf, header)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding result header: %v", err)
+	}
+
+	if header.Header == nil {
+		return nil, fmt.Errorf("result is missing its header")
+	}
+	if header.TotalSize < 0 || (header.TotalSize > 0 && header.Header.Raster == nil) {
+		return nil, fmt.Errorf("invalid result size: %d", header.TotalSize)
+	}
+	return header, nil
+}
+
+// ReadResult reads the raster data following a header into its result
+func ReadResult(r *bufio.Reader, header *pb.RasterChunk) (*pb.Result, error) {
+	out := header.Header
+	if header.TotalSize > 0 {
+		data := make([]byte, header.TotalSize)
+		_, err := io.ReadFull(r, data)
+		if err != nil {
+			return nil, err
+		}
+		out.Raster.Data = data
+	}
+	return out, nil
+}
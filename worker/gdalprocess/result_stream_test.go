@@ -0,0 +1,50 @@
+package gdalprocess
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	pb "github.com/nci/gsky/worker/gdalservice"
+)
+
+func TestResultStream(t *testing.T) {
+	data := []byte("0123456789")
+	var conn bytes.Buffer
+	err := WriteResult(&conn, &pb.Result{Error: "OK", Raster: &pb.Raster{Data: data, RasterType: "Byte"}})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	r := bufio.NewReader(bytes.NewReader(conn.Bytes()))
+	header, err := ReadResultHeader(r)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if header.TotalSize != int64(len(data)) || len(header.Header.Raster.Data) != 0 {
+		t.Fatalf("unexpected header: %v", header)
+	}
+
+	task := &Task{Chunks: make(chan *pb.RasterChunk, 10), ChunkSize: 4}
+	if err := (&Process{}).streamResult(task, header, r); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var sizes []int
+	var out []byte
+	for chunk := range task.Chunks {
+		if chunk.Header == nil {
+			sizes = append(sizes, len(chunk.Data))
+			out = append(out, chunk.Data...)
+		}
+	}
+	if !bytes.Equal(out, data) || len(sizes) != 3 || sizes[2] != 2 {
+		t.Errorf("unexpected chunks %v of %q", sizes, out)
+	}
+
+	r = bufio.NewReader(bytes.NewReader(conn.Bytes()[:conn.Len()-1]))
+	header, _ = ReadResultHeader(r)
+	if _, err := ReadResult(r, header); err == nil {
+		t.Errorf("truncated result was accepted")
+	}
+}
@@ -0,0 +1,140 @@
+package gdalprocess
+
+import (
+	"fmt"
+	"sync"
+
+	pb "github.com/nci/gsky/worker/gdalservice"
+)
+
+// Scheduling weights and per process queue depth limits of the priority
+// classes, indexed by pb.Priority
+var DefaultPriorityWeights = []int{8, 2, 1}
+var DefaultPriorityQueueSizes = []int{DefaultQueueSizePerProcess, DefaultQueueSizePerProcess / 2, DefaultQueueSizePerProcess / 4}
+
+// TaskQueue holds one FIFO queue per priority class. Tasks are dequeued
+// by weighted fair queuing so that each backlogged class receives a share
+// of the processes proportional to its weight. Tasks are rejected as soon
+// as the queue of their class is full.
+type TaskQueue struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	queues  [][]*Task
+	limits  []int
+	weights []int
+
+	// Stride scheduling: pass is the virtual time at which a class is
+	// next due and vtime is the virtual time of the last dequeue
+	pass  []float64
+	vtime float64
+}
+
+func NewTaskQueue(limits []int, weights []int) *TaskQueue {
+	q := &TaskQueue{
+		queues:  make([][]*Task, len(limits)),
+		limits:  limits,
+		weights: weights,
+		pass:    make([]float64, len(limits)),
+	}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+func (q *TaskQueue) class(task *Task) int {
+	c := int(task.Payload.GetPriority())
+	if c < 0 || c >= len(q.queues) {
+		c = len(q.queues) - 1
+	}
+	return c
+}
+
+// Push appends a task to the queue of its priority class
+func (q *TaskQueue) Push(task *Task) error {
+	c := q.class(task)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.queues[c]) >= q.limits[c] {
+		return fmt.Errorf("Pool TaskQueue is full for %v tasks", pb.Priority(c))
+	}
+	q.push(c, task, false)
+	return nil
+}
+
+// Requeue puts a task back at the head of the queue of its priority
+// class regardless of the queue depth limit
+func (q *TaskQueue) Requeue(task *Task) {
+	c := q.class(task)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.push(c, task, true)
+}
+
+func (q *TaskQueue) push(c int, task *Task, head bool) {
+	// A class becoming backlogged must not claim the share it did not
+	// use while idle
+	if len(q.queues[c]) == 0 && q.pass[c] < q.vtime {
+		q.pass[c] = q.vtime
+	}
+
+	if head {
+		q.queues[c] = append([]*Task{task}, q.queues[c]...)
+	} else {
+		q.queues[c] = append(q.queues[c], task)
+	}
+	q.cond.Signal()
+}
+
+// Pop blocks until a task is available and returns the task of the
+// backlogged class which is due first
+func (q *TaskQueue) Pop() *Task {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for {
+		best := -1
+		for c, queue := range q.queues {
+			if len(queue) == 0 {
+				continue
+			}
+			if best < 0 || q.pass[c] < q.pass[best] {
+				best = c
+			}
+		}
+
+		if best >= 0 {
+			task := q.queues[best][0]
+			q.queues[best][0] = nil
+			q.queues[best] = q.queues[best][1:]
+
+			q.vtime = q.pass[best]
+			q.pass[best] += 1.0 / float64(q.weights[best])
+			return task
+		}
+
+		q.cond.Wait()
+	}
+}
+
+// Lengths returns the number of queued tasks of each priority class
+func (q *TaskQueue) Lengths() []int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	lengths := make([]int, len(q.queues))
+	for c, queue := range q.queues {
+		lengths[c] = len(queue)
+	}
+	return lengths
+}
+
+// Len returns the total number of queued tasks
+func (q *TaskQueue) Len() int {
+	n := 0
+	for _, l := range q.Lengths() {
+		n += l
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package gdalprocess
+
+import (
+	"testing"
+
+	pb "github.com/nci/gsky/worker/gdalservice"
+)
+
+func newTestTask(priority pb.Priority) *Task {
+	return &Task{Payload: &pb.GeoRPCGranule{Priority: priority}, Error: make(chan error, 1)}
+}
+
+func TestTaskQueueFairness(t *testing.T) {
+	q := NewTaskQueue([]int{100, 100, 100}, []int{8, 2, 1})
+	for i := 0; i < 50; i++ {
+		for _, p := range []pb.Priority{pb.Priority_INTERACTIVE, pb.Priority_BULK, pb.Priority_BACKGROUND} {
+			if err := q.Push(newTestTask(p)); err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+	}
+
+	served := make([]int, 3)
+	for i := 0; i < 22; i++ {
+		served[q.Pop().Payload.Priority]++
+	}
+
+	if served[0] != 16 || served[1] != 4 || served[2] != 2 {
+		t.Errorf("tasks not served by weight: %v", served)
+	}
+
+	lengths := q.Lengths()
+	if lengths[0] != 34 || lengths[1] != 46 || lengths[2] != 48 || q.Len() != 128 {
+		t.Errorf("unexpected queue lengths: %v", lengths)
+	}
+}
+
+func TestTaskQueueLimits(t *testing.T) {
+	q := NewTaskQueue([]int{2, 1, 1}, []int{8, 2, 1})
+	if err := q.Push(newTestTask(pb.Priority_BULK)); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := q.Push(newTestTask(pb.Priority_BULK)); err == nil {
+		t.Errorf("full bulk queue accepted a task")
+	}
+	if err := q.Push(newTestTask(pb.Priority_INTERACTIVE)); err != nil {
+		t.Errorf("interactive task rejected by the bulk limit: %v", err)
+	}
+
+	// Retried tasks bypass the limit and are served first in their class
+	retry := newTestTask(pb.Priority_BULK)
+	q.Requeue(retry)
+	q.Pop()
+	if task := q.Pop(); task != retry {
+		t.Errorf("requeued task was not served first")
+	}
+}
+
+func TestTaskQueueIdleClass(t *testing.T) {
+	q := NewTaskQueue([]int{100, 100, 100}, []int{8, 2, 1})
+	for i := 0; i < 40; i++ {
+		q.Push(newTestTask(pb.Priority_BULK))
+	}
+	for i := 0; i < 20; i++ {
+		q.Pop()
+	}
+
+	// A class which was idle does not get to monopolise the processes
+	for i := 0; i < 20; i++ {
+		q.Push(newTestTask(pb.Priority_BACKGROUND))
+	}
+	served := make([]int, 3)
+	for i := 0; i < 6; i++ {
+		served[q.Pop().Payload.Priority]++
+	}
+	if served[1] != 4 || served[2] != 2 {
+		t.Errorf("tasks not served by weight after idling: %v", served)
+	}
+}
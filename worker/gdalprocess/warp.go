@@ -178,7 +178,7 @@ func WarpRaster(in *pb.GeoRPCGranule) *pb.Result {
 
 	var resUsage0, resUsage1 syscall.Rusage
 	syscall.Getrusage(syscall.RUSAGE_SELF, &resUsage0)
-	cErr := C.warp_operation_fast(filePathC, srcProjRefC, pSrcGeot, pGeoLoc, dstProjRefC, (*C.double)(&in.DstGeot[0]), C.int(in.Width), C.int(in.Height), C.int(in.Bands[0]), C.int(in.SRSCf), (*unsafe.Pointer)(&dstBufC), (*C.int)(&dstBufSize), (*C.int)(&dstBboxC[0]), (*C.double)(&noData), (*C.GDALDataType)(&dType), &bytesReadC)
+	cErr := C.warp_operation_fast(filePathC, srcProjRefC, pSrcGeot, pGeoLoc, dstProjRefC, (*C.double)(&in.DstGeot[0]), C.int(in.Width), C.int(in.Height), C.int(in.Bands[0]), C.int(in.SRSCf), (*unsafe.Pointer)(&dstBufC), (*C.int)(&dstBufSize), (*C.int)(&dstBboxC[0]), (*C.double)(&noData), (*C.GDALDataType)(&dType), &bytesReadC, (*C.int)(unsafe.Pointer(taskCancelFlag(in.TaskId))))
 	syscall.Getrusage(syscall.RUSAGE_SELF, &resUsage1)
 
 	metrics := &pb.WorkerMetrics{
@@ -187,6 +187,10 @@ func WarpRaster(in *pb.GeoRPCGranule) *pb.Result {
 		SysTime:   resUsage1.Stime.Nano() - resUsage0.Stime.Nano(),
 	}
 
+	if cErr == C.WARP_CANCELLED {
+		return &pb.Result{Error: TaskCancelledError, Metrics: metrics}
+	}
+
 	if cErr != 0 {
 		return &pb.Result{Error: dump(fmt.Sprintf("warp_operation() fail: %v", int(cErr))), Metrics: metrics}
 	}
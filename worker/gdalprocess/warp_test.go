@@ -20,7 +20,7 @@ func TestComputeReprojectExtent(t *testing.T) {
 	_, tempFile, _ := utils.EncodeGdalOpen("/tmp", 256, 256, "geotiff", geot, 4326, rs, 1000, 1000, 1)
 	defer os.Remove(tempFile)
 
-	geo := &pb.GeoRPCGranule{Path: "NETCDF:\"/g/data2/tc43/modis-fc/v310/tiles/monthly/cover/FC_Monthly_Medoid.v310.MCD43A4.h29v12.2017.006.nc\":phot_veg", EPSG: 4326, Geot: geot}
+	geo := &pb.GeoRPCGranule{Path: "NETCDF:\"/g/data2/tc43/modis-fc/v310/tiles/monthly/cover/FC_Monthly_Medoid.v310.MCD43A4.h29v12.2017.006.nc\":phot_veg", DstSRS: "EPSG:4326", DstGeot: geot}
 	res := ComputeReprojectExtent(geo)
 	expected := []uint8{210, 75, 0, 0, 0, 0, 0, 0, 188, 33, 0, 0, 0, 0, 0, 0}
 	for i, val := range res.Raster.Data {
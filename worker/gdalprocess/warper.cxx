@@ -24,7 +24,9 @@ reduction of overheads in grpc (de-)serialisation and network traffic.
 
 #include <iostream>
 
-auto coordTransformCache = new CoordinateTransformCache();
+// The cache is per thread as cached transformers are updated in place.
+// This allows concurrent warps in the embedded mode.
+thread_local CoordinateTransformCache coordTransformCache;
 
 struct GenImgProjTransformInfo {
 
@@ -78,7 +80,7 @@ int roundCoord(double coord, int maxExtent) {
 	return c;
 }
 
-int warp_operation_fast(const char *srcFilePath, char *srcProjRef, double *srcGeot, const char **geoLocOpts, const char *dstProjRef, double *dstGeot, int dstXImageSize, int dstYImageSize, int band, int srsCf, void **dstBuf, int *dstBufSize, int *dstBbox, double *noData, GDALDataType *dType, size_t *bytesRead)
+int warp_operation_fast(const char *srcFilePath, char *srcProjRef, double *srcGeot, const char **geoLocOpts, const char *dstProjRef, double *dstGeot, int dstXImageSize, int dstYImageSize, int band, int srsCf, void **dstBuf, int *dstBufSize, int *dstBbox, double *noData, GDALDataType *dType, size_t *bytesRead, const int *cancelled)
 {
 	*bytesRead = 0;
 
@@ -128,7 +130,7 @@ int warp_operation_fast(const char *srcFilePath, char *srcProjRef, double *srcGe
 	bool hasCoordCache = false;
 	if(!hasGeoLoc) {
 		TransformKey key = std::make_pair(srcProjRef, dstProjRef);
-		hTransformArg = coordTransformCache->get(key);
+		hTransformArg = coordTransformCache.get(key);
 		if(hTransformArg == nullptr) {
 			hTransformArg = GDALCreateGenImgProjTransformer3(srcProjRef, srcGeot, dstProjRef, dstGeot);
 			if(!hTransformArg) {
@@ -137,7 +139,7 @@ int warp_operation_fast(const char *srcFilePath, char *srcProjRef, double *srcGe
 			}
 			GenImgProjTransformInfo *psInfo = (GenImgProjTransformInfo *)hTransformArg;
 			if(psInfo->pReprojectArg != nullptr) {
-				coordTransformCache->put(key, hTransformArg);
+				coordTransformCache.put(key, hTransformArg);
 				hasCoordCache = true;
 			}
 		} else {
@@ -254,7 +256,8 @@ int warp_operation_fast(const char *srcFilePath, char *srcProjRef, double *srcGe
 	const GDALDataType srcDataType = *dType;
         const int srcDataSize = GDALGetDataTypeSizeBytes(*dType);
 
-	const int supportedDataType = *dType == GDT_Byte || *dType == GDT_Int16 || *dType == GDT_UInt16 || *dType == GDT_Float32;
+	const int supportedDataType = *dType == GDT_Byte || *dType == GDT_Int16 || *dType == GDT_UInt16 || *dType == GDT_Float32 ||
+		*dType == GDT_Int32 || *dType == GDT_UInt32 || *dType == GDT_Float64;
 	if(!supportedDataType) {
 		*dType = GDT_Float32;
 	}
@@ -288,7 +291,13 @@ int warp_operation_fast(const char *srcFilePath, char *srcProjRef, double *srcGe
 
 	auto blockPixelMap = std::map<size_t, std::pair<std::vector<size_t>, std::vector<size_t> > >();
 
+	int isCancelled = 0;
 	for(int iDstY = 0; iDstY < dstYSize; iDstY++) {
+		if(__atomic_load_n(cancelled, __ATOMIC_RELAXED)) {
+			isCancelled = 1;
+			break;
+		}
+
                 memcpy(dx, dx + dstXSize, dstXSize * sizeof(double));
                 const double dfY = iDstY + 0.5 + dstYOff;
                 for(int iDstX = 0; iDstX < dstXSize; iDstX++) {
@@ -334,6 +343,11 @@ int warp_operation_fast(const char *srcFilePath, char *srcProjRef, double *srcGe
 	size_t nBlocksRead = 0;
 
 	for(const auto& it : blockPixelMap) {
+		if(isCancelled || __atomic_load_n(cancelled, __ATOMIC_RELAXED)) {
+			isCancelled = 1;
+			break;
+		}
+
 		const int nPixels = it.second.first.size();
 		if(nPixels == 0) {
 			continue;
@@ -389,5 +403,12 @@ int warp_operation_fast(const char *srcFilePath, char *srcProjRef, double *srcGe
 	}
 
 	GDALClose(hSrcDS);
+
+	if(isCancelled) {
+		free(pDstBuf);
+		*dstBuf = nullptr;
+		*dstBufSize = 0;
+		return WARP_CANCELLED;
+	}
 	return 0;
 }
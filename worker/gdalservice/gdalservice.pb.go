@@ -17,6 +17,7 @@ It has these top-level messages:
 	WorkerInfo
 	WorkerMetrics
 	Result
+	RasterChunk
 */
 package gdalservice
 
@@ -41,6 +42,33 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// Priority classes of the tasks queued on a worker. Interactive tasks
+// such as WMS tiles are favoured over bulk WCS and WPS tasks, which are
+// in turn favoured over background tasks.
+type Priority int32
+
+const (
+	Priority_INTERACTIVE Priority = 0
+	Priority_BULK        Priority = 1
+	Priority_BACKGROUND  Priority = 2
+)
+
+var Priority_name = map[int32]string{
+	0: "INTERACTIVE",
+	1: "BULK",
+	2: "BACKGROUND",
+}
+var Priority_value = map[string]int32{
+	"INTERACTIVE": 0,
+	"BULK":        1,
+	"BACKGROUND":  2,
+}
+
+func (x Priority) String() string {
+	return proto.EnumName(Priority_name, int32(x))
+}
+func (Priority) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
 type GeoRPCGranule struct {
 	Operation        string    `protobuf:"bytes,1,opt,name=operation" json:"operation,omitempty"`
 	Path             string    `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
@@ -60,6 +88,9 @@ type GeoRPCGranule struct {
 	SRSCf            int32     `protobuf:"varint,16,opt,name=sRSCf" json:"sRSCf,omitempty"`
 	PixelCount       int32     `protobuf:"varint,17,opt,name=pixelCount" json:"pixelCount,omitempty"`
 	VRT              string    `protobuf:"bytes,18,opt,name=vRT" json:"vRT,omitempty"`
+	TaskId           int64     `protobuf:"varint,19,opt,name=taskId" json:"taskId,omitempty"`
+	Priority         Priority  `protobuf:"varint,20,opt,name=priority,enum=gdalservice.Priority" json:"priority,omitempty"`
+	RasterType       string    `protobuf:"bytes,21,opt,name=rasterType" json:"rasterType,omitempty"`
 }
 
 func (m *GeoRPCGranule) Reset()                    { *m = GeoRPCGranule{} }
@@ -193,6 +224,27 @@ func (m *GeoRPCGranule) GetVRT() string {
 	return ""
 }
 
+func (m *GeoRPCGranule) GetTaskId() int64 {
+	if m != nil {
+		return m.TaskId
+	}
+	return 0
+}
+
+func (m *GeoRPCGranule) GetPriority() Priority {
+	if m != nil {
+		return m.Priority
+	}
+	return Priority_INTERACTIVE
+}
+
+func (m *GeoRPCGranule) GetRasterType() string {
+	if m != nil {
+		return m.RasterType
+	}
+	return ""
+}
+
 type Raster struct {
 	Data       []byte  `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
 	NoData     float64 `protobuf:"fixed64,2,opt,name=noData" json:"noData,omitempty"`
@@ -426,7 +478,10 @@ func (m *GeoFile) GetDataSets() []*GeoMetaData {
 }
 
 type WorkerInfo struct {
-	PoolSize int32 `protobuf:"varint,1,opt,name=poolSize" json:"poolSize,omitempty"`
+	PoolSize     int32   `protobuf:"varint,1,opt,name=poolSize" json:"poolSize,omitempty"`
+	ActiveTasks  int32   `protobuf:"varint,2,opt,name=activeTasks" json:"activeTasks,omitempty"`
+	QueueLength  int32   `protobuf:"varint,3,opt,name=queueLength" json:"queueLength,omitempty"`
+	QueueLengths []int32 `protobuf:"varint,4,rep,packed,name=queueLengths" json:"queueLengths,omitempty"`
 }
 
 func (m *WorkerInfo) Reset()                    { *m = WorkerInfo{} }
@@ -441,6 +496,27 @@ func (m *WorkerInfo) GetPoolSize() int32 {
 	return 0
 }
 
+func (m *WorkerInfo) GetActiveTasks() int32 {
+	if m != nil {
+		return m.ActiveTasks
+	}
+	return 0
+}
+
+func (m *WorkerInfo) GetQueueLength() int32 {
+	if m != nil {
+		return m.QueueLength
+	}
+	return 0
+}
+
+func (m *WorkerInfo) GetQueueLengths() []int32 {
+	if m != nil {
+		return m.QueueLengths
+	}
+	return nil
+}
+
 type WorkerMetrics struct {
 	BytesRead int64 `protobuf:"varint,1,opt,name=bytesRead" json:"bytesRead,omitempty"`
 	UserTime  int64 `protobuf:"varint,2,opt,name=userTime" json:"userTime,omitempty"`
@@ -537,6 +613,41 @@ func (m *Result) GetMetrics() *WorkerMetrics {
 	return nil
 }
 
+// RasterChunk carries a Result in pieces. The first chunk has the
+// Result with its raster data removed along with the total size of the
+// raster data. The raster data follows in the data of the chunks.
+type RasterChunk struct {
+	Header    *Result `protobuf:"bytes,1,opt,name=header" json:"header,omitempty"`
+	Data      []byte  `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	TotalSize int64   `protobuf:"varint,3,opt,name=totalSize" json:"totalSize,omitempty"`
+}
+
+func (m *RasterChunk) Reset()                    { *m = RasterChunk{} }
+func (m *RasterChunk) String() string            { return proto.CompactTextString(m) }
+func (*RasterChunk) ProtoMessage()               {}
+func (*RasterChunk) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *RasterChunk) GetHeader() *Result {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
+func (m *RasterChunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *RasterChunk) GetTotalSize() int64 {
+	if m != nil {
+		return m.TotalSize
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*GeoRPCGranule)(nil), "gdalservice.GeoRPCGranule")
 	proto.RegisterType((*Raster)(nil), "gdalservice.Raster")
@@ -547,6 +658,8 @@ func init() {
 	proto.RegisterType((*WorkerInfo)(nil), "gdalservice.WorkerInfo")
 	proto.RegisterType((*WorkerMetrics)(nil), "gdalservice.WorkerMetrics")
 	proto.RegisterType((*Result)(nil), "gdalservice.Result")
+	proto.RegisterType((*RasterChunk)(nil), "gdalservice.RasterChunk")
+	proto.RegisterEnum("gdalservice.Priority", Priority_name, Priority_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -561,6 +674,7 @@ const _ = grpc.SupportPackageIsVersion4
 
 type GDALClient interface {
 	Process(ctx context.Context, in *GeoRPCGranule, opts ...grpc.CallOption) (*Result, error)
+	ProcessStream(ctx context.Context, in *GeoRPCGranule, opts ...grpc.CallOption) (GDAL_ProcessStreamClient, error)
 }
 
 type gDALClient struct {
@@ -580,10 +694,43 @@ func (c *gDALClient) Process(ctx context.Context, in *GeoRPCGranule, opts ...grp
 	return out, nil
 }
 
+func (c *gDALClient) ProcessStream(ctx context.Context, in *GeoRPCGranule, opts ...grpc.CallOption) (GDAL_ProcessStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_GDAL_serviceDesc.Streams[0], c.cc, "/gdalservice.GDAL/ProcessStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &gDALProcessStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type GDAL_ProcessStreamClient interface {
+	Recv() (*RasterChunk, error)
+	grpc.ClientStream
+}
+
+type gDALProcessStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *gDALProcessStreamClient) Recv() (*RasterChunk, error) {
+	m := new(RasterChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for GDAL service
 
 type GDALServer interface {
 	Process(context.Context, *GeoRPCGranule) (*Result, error)
+	ProcessStream(*GeoRPCGranule, GDAL_ProcessStreamServer) error
 }
 
 func RegisterGDALServer(s *grpc.Server, srv GDALServer) {
@@ -608,6 +755,27 @@ func _GDAL_Process_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GDAL_ProcessStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GeoRPCGranule)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GDALServer).ProcessStream(m, &gDALProcessStreamServer{stream})
+}
+
+type GDAL_ProcessStreamServer interface {
+	Send(*RasterChunk) error
+	grpc.ServerStream
+}
+
+type gDALProcessStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *gDALProcessStreamServer) Send(m *RasterChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _GDAL_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "gdalservice.GDAL",
 	HandlerType: (*GDALServer)(nil),
@@ -617,68 +785,86 @@ var _GDAL_serviceDesc = grpc.ServiceDesc{
 			Handler:    _GDAL_Process_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ProcessStream",
+			Handler:       _GDAL_ProcessStream_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "gdalservice.proto",
 }
 
 func init() { proto.RegisterFile("gdalservice.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 881 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x55, 0x5f, 0x6f, 0xe3, 0x44,
-	0x10, 0x57, 0xea, 0xfc, 0xdd, 0xb4, 0xd0, 0x5b, 0x0e, 0x58, 0x45, 0x08, 0x2c, 0x3f, 0x59, 0x20,
-	0xe5, 0xa4, 0x5e, 0x05, 0xe8, 0xde, 0xa0, 0x15, 0x11, 0xa2, 0xc7, 0x9d, 0xd6, 0x41, 0xf7, 0xec,
-	0xd8, 0x93, 0x64, 0xc1, 0xf1, 0x5a, 0xbb, 0x9b, 0xb4, 0xe1, 0x03, 0xf1, 0x05, 0xf8, 0x70, 0xbc,
-	0xa2, 0x99, 0xb5, 0x63, 0xb7, 0xbd, 0x37, 0xff, 0x7e, 0x33, 0xb3, 0x3b, 0xf3, 0x9b, 0x99, 0x35,
-	0x7b, 0xb1, 0xc9, 0xd3, 0xc2, 0x82, 0x39, 0xa8, 0x0c, 0xe6, 0x95, 0xd1, 0x4e, 0xf3, 0x69, 0x87,
-	0x9a, 0x7d, 0xb3, 0xd1, 0x7a, 0x53, 0xc0, 0x2b, 0x32, 0xad, 0xf6, 0xeb, 0x57, 0x4e, 0xed, 0xc0,
-	0xba, 0x74, 0x57, 0x79, 0xef, 0xe8, 0xbf, 0x80, 0x5d, 0x2c, 0x40, 0xcb, 0xf7, 0x37, 0x0b, 0x93,
-	0x96, 0xfb, 0x02, 0xf8, 0x57, 0x6c, 0xa2, 0x2b, 0x30, 0xa9, 0x53, 0xba, 0x14, 0xbd, 0xb0, 0x17,
-	0x4f, 0x64, 0x4b, 0x70, 0xce, 0xfa, 0x55, 0xea, 0xb6, 0xe2, 0x8c, 0x0c, 0xf4, 0xcd, 0x67, 0x6c,
-	0xbc, 0x01, 0xbd, 0x03, 0x67, 0x8e, 0x22, 0x20, 0xfe, 0x84, 0xf9, 0x4b, 0x36, 0x58, 0xa5, 0x65,
-	0x6e, 0x45, 0x3f, 0x0c, 0xe2, 0x81, 0xf4, 0x80, 0x7f, 0xc1, 0x86, 0x5b, 0x50, 0x9b, 0xad, 0x13,
-	0x83, 0xb0, 0x17, 0x9f, 0xc9, 0x1a, 0xa1, 0xf7, 0xbd, 0xca, 0xdd, 0x56, 0x0c, 0x89, 0xf6, 0x00,
-	0xbd, 0xad, 0xc9, 0x12, 0x99, 0x88, 0x11, 0x9d, 0x5e, 0x23, 0x2e, 0xd8, 0xc8, 0x9a, 0x6c, 0x01,
-	0xda, 0x89, 0x71, 0x18, 0xc4, 0x3d, 0xd9, 0x40, 0x8c, 0xc8, 0xad, 0xc3, 0x88, 0x89, 0x8f, 0xf0,
-	0x08, 0x23, 0x72, 0xeb, 0x28, 0x82, 0xf9, 0x88, 0x1a, 0xf2, 0x90, 0x4d, 0x31, 0xb5, 0xc4, 0x19,
-	0x95, 0x83, 0x15, 0xd3, 0xb0, 0x17, 0x0f, 0x64, 0x97, 0xe2, 0x5f, 0x33, 0xb6, 0x01, 0x7d, 0xa7,
-	0xb3, 0x77, 0x95, 0xb3, 0xe2, 0x3c, 0x0c, 0xe2, 0x89, 0xec, 0x30, 0xfc, 0x5b, 0x76, 0x99, 0x1b,
-	0x55, 0x14, 0xb7, 0x90, 0xa9, 0x02, 0x6e, 0xf4, 0xbe, 0x74, 0xe2, 0x82, 0x8e, 0x79, 0xc6, 0xa3,
-	0xc6, 0x59, 0xa1, 0xaa, 0x3f, 0xaa, 0x0a, 0x8c, 0xf8, 0x84, 0x6a, 0x6d, 0x89, 0xc6, 0x7a, 0xa7,
-	0xef, 0xc1, 0x88, 0x4f, 0x5b, 0x2b, 0x11, 0xa8, 0x91, 0x95, 0xc9, 0xcd, 0x5a, 0x5c, 0xd2, 0xe1,
-	0x1e, 0x60, 0x76, 0x95, 0x7a, 0x80, 0xc2, 0xdf, 0xfb, 0x82, 0x4c, 0x1d, 0x86, 0x5f, 0xb2, 0xe0,
-	0x20, 0x97, 0x82, 0x93, 0x1c, 0xf8, 0x19, 0x6d, 0xd9, 0x50, 0xa6, 0xd6, 0x81, 0xc1, 0x9e, 0xe6,
-	0xa9, 0x4b, 0xa9, 0xd9, 0xe7, 0x92, 0xbe, 0x51, 0xc1, 0x52, 0xdf, 0x22, 0x8b, 0x9d, 0xee, 0xc9,
-	0x1a, 0xe1, 0x3d, 0x86, 0xa2, 0x96, 0xc7, 0x0a, 0xea, 0x6e, 0x77, 0x18, 0x3c, 0x6b, 0xb5, 0xd2,
-	0x0f, 0x75, 0xbb, 0xe9, 0x3b, 0xfa, 0x91, 0xb1, 0xa5, 0xda, 0x41, 0x02, 0x46, 0x81, 0xc5, 0xfc,
-	0x0f, 0x69, 0xb1, 0x07, 0xba, 0xae, 0x27, 0x3d, 0x40, 0x36, 0xa3, 0xd4, 0xcf, 0x7c, 0x55, 0x04,
-	0xa2, 0xef, 0xd9, 0xf8, 0xdd, 0x01, 0x47, 0x19, 0xee, 0xd1, 0xe3, 0x21, 0x51, 0x7f, 0xfb, 0xb8,
-	0x81, 0xf4, 0x00, 0xd9, 0x23, 0xb1, 0x75, 0x1c, 0x81, 0xe8, 0x9f, 0x80, 0x4d, 0x17, 0xa0, 0xdf,
-	0x82, 0x4b, 0x29, 0xeb, 0x90, 0x4d, 0xb1, 0x2a, 0x0b, 0xee, 0xf7, 0x74, 0x07, 0xf5, 0x54, 0x77,
-	0x29, 0xd4, 0xbc, 0x4c, 0x77, 0x90, 0x54, 0x69, 0x06, 0xf5, 0x70, 0xb7, 0x04, 0x56, 0xe5, 0xda,
-	0x7a, 0xe9, 0x1b, 0xcf, 0xf4, 0x75, 0x7b, 0xc9, 0xfb, 0x7e, 0x62, 0x3a, 0x14, 0x7f, 0xc3, 0x18,
-	0xae, 0x5b, 0x82, 0xeb, 0x66, 0xc5, 0x20, 0x0c, 0xe2, 0xe9, 0xd5, 0x6c, 0xee, 0x37, 0x72, 0xde,
-	0x6c, 0xe4, 0x7c, 0xd9, 0x6c, 0xa4, 0xec, 0x78, 0x77, 0x36, 0x64, 0x48, 0x83, 0xda, 0x6c, 0xc8,
-	0x6b, 0x36, 0xd1, 0xb5, 0x22, 0x56, 0x8c, 0xe8, 0xc8, 0xcf, 0xe7, 0xdd, 0x47, 0xa0, 0xd1, 0x4b,
-	0xb6, 0x7e, 0xad, 0x74, 0xe3, 0x8f, 0x4a, 0x37, 0xe9, 0x48, 0xc7, 0x23, 0x76, 0xbe, 0x01, 0xbd,
-	0x34, 0x69, 0x69, 0xd7, 0xda, 0xec, 0xea, 0x3d, 0x79, 0xc4, 0xe1, 0x1a, 0x55, 0xba, 0x38, 0x6e,
-	0x74, 0x49, 0x8b, 0x32, 0x91, 0x0d, 0x24, 0x8b, 0xd1, 0x7f, 0x7e, 0xf8, 0x6d, 0x29, 0xce, 0x6b,
-	0x8b, 0x87, 0x78, 0x1b, 0x7e, 0x5e, 0xd3, 0x4e, 0x4c, 0xa4, 0x07, 0x91, 0x65, 0xa3, 0x05, 0xe8,
-	0x5f, 0x54, 0x01, 0xf8, 0x8a, 0xac, 0x55, 0x01, 0x9d, 0x06, 0x9d, 0x30, 0xed, 0xb3, 0x51, 0x07,
-	0x30, 0x75, 0x6b, 0x6a, 0xc4, 0xaf, 0xd9, 0x18, 0x9b, 0x98, 0x80, 0xb3, 0x22, 0x20, 0x31, 0xc4,
-	0x23, 0x31, 0x3a, 0x33, 0x20, 0x4f, 0x9e, 0x51, 0xcc, 0xd8, 0x07, 0x6d, 0xfe, 0x02, 0xf3, 0x6b,
-	0xb9, 0xd6, 0x78, 0x6f, 0xa5, 0x75, 0xd1, 0x19, 0xad, 0x13, 0x8e, 0x32, 0x76, 0xe1, 0x3d, 0xdf,
-	0x82, 0x33, 0x2a, 0xb3, 0x38, 0x26, 0xab, 0xa3, 0x03, 0x2b, 0x21, 0xcd, 0xc9, 0x3b, 0x90, 0x2d,
-	0x81, 0x47, 0xed, 0x2d, 0x18, 0xec, 0x28, 0x25, 0x1a, 0xc8, 0x13, 0xa6, 0xc7, 0xea, 0x68, 0xc9,
-	0x14, 0x90, 0xa9, 0x81, 0xd1, 0xbf, 0x67, 0x6c, 0x28, 0xc1, 0xee, 0x0b, 0xc7, 0x7f, 0xa8, 0x27,
-	0x86, 0x36, 0x45, 0xf4, 0xa8, 0xa2, 0x2f, 0x1f, 0x55, 0xd4, 0x2e, 0x92, 0xec, 0xb8, 0xf2, 0xef,
-	0xd8, 0xd0, 0x4f, 0x1e, 0xdd, 0x3b, 0xbd, 0xfa, 0xec, 0x51, 0x90, 0xdf, 0x73, 0x59, 0xbb, 0xf0,
-	0x98, 0xf5, 0x55, 0xb9, 0xd6, 0x94, 0xc7, 0xf4, 0xea, 0xe5, 0x53, 0xc5, 0xb0, 0x1b, 0x92, 0x3c,
-	0xb0, 0x69, 0x60, 0x8c, 0x36, 0x34, 0xdd, 0x13, 0xe9, 0x01, 0xbd, 0x40, 0xdb, 0xb4, 0x02, 0x1a,
-	0x69, 0x7c, 0x81, 0x10, 0x60, 0xee, 0xf7, 0x27, 0x55, 0xe9, 0x01, 0x7f, 0x9a, 0x7b, 0x2b, 0xba,
-	0xec, 0xb8, 0xf2, 0x6b, 0x36, 0xda, 0x79, 0x79, 0xe9, 0x7d, 0xa7, 0x1d, 0x79, 0x16, 0x55, 0x37,
-	0x40, 0x36, 0xae, 0x57, 0x3f, 0xb3, 0xfe, 0xe2, 0xf6, 0xa7, 0x3b, 0xfe, 0x86, 0x8d, 0xde, 0x1b,
-	0x9d, 0x81, 0xb5, 0x7c, 0xf6, 0xb4, 0x92, 0xf6, 0xaf, 0x36, 0x7b, 0x22, 0x08, 0xc9, 0xbd, 0x1a,
-	0xd2, 0x12, 0xbe, 0xfe, 0x3f, 0x00, 0x00, 0xff, 0xff, 0x6e, 0x2a, 0x3e, 0x18, 0x46, 0x07, 0x00,
+	// 1073 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x56, 0xdb, 0x6e, 0x1b, 0x37,
+	0x10, 0xed, 0x6a, 0x75, 0xa5, 0xec, 0x44, 0x61, 0x92, 0x96, 0x30, 0x82, 0x56, 0xd0, 0x93, 0x90,
+	0x02, 0x4e, 0xeb, 0xa4, 0x17, 0xe4, 0x2d, 0x91, 0x53, 0xc1, 0x88, 0x13, 0x1b, 0x94, 0xdc, 0x3c,
+	0x53, 0xab, 0x91, 0xb4, 0xf5, 0x6a, 0xb9, 0x25, 0x29, 0xd9, 0xea, 0x2f, 0xf4, 0xa5, 0x5f, 0xd1,
+	0x1f, 0xe8, 0x1f, 0xf5, 0x4b, 0x8a, 0x19, 0xae, 0xb4, 0x2b, 0xc7, 0xe8, 0xdb, 0x9e, 0x33, 0x33,
+	0xe4, 0xf0, 0xcc, 0x45, 0x62, 0x8f, 0xe6, 0x53, 0x95, 0x58, 0x30, 0xeb, 0x38, 0x82, 0xe3, 0xcc,
+	0x68, 0xa7, 0x79, 0xbb, 0x44, 0x1d, 0x7d, 0x33, 0xd7, 0x7a, 0x9e, 0xc0, 0x0b, 0x32, 0x4d, 0x56,
+	0xb3, 0x17, 0x2e, 0x5e, 0x82, 0x75, 0x6a, 0x99, 0x79, 0xef, 0xde, 0xbf, 0x55, 0x76, 0x38, 0x04,
+	0x2d, 0x2f, 0x07, 0x43, 0xa3, 0xd2, 0x55, 0x02, 0xfc, 0x19, 0x6b, 0xe9, 0x0c, 0x8c, 0x72, 0xb1,
+	0x4e, 0x45, 0xd0, 0x0d, 0xfa, 0x2d, 0x59, 0x10, 0x9c, 0xb3, 0x6a, 0xa6, 0xdc, 0x42, 0x54, 0xc8,
+	0x40, 0xdf, 0xfc, 0x88, 0x35, 0xe7, 0xa0, 0x97, 0xe0, 0xcc, 0x46, 0x84, 0xc4, 0xef, 0x30, 0x7f,
+	0xc2, 0x6a, 0x13, 0x95, 0x4e, 0xad, 0xa8, 0x76, 0xc3, 0x7e, 0x4d, 0x7a, 0xc0, 0xbf, 0x64, 0xf5,
+	0x05, 0xc4, 0xf3, 0x85, 0x13, 0xb5, 0x6e, 0xd0, 0xaf, 0xc8, 0x1c, 0xa1, 0xf7, 0x4d, 0x3c, 0x75,
+	0x0b, 0x51, 0x27, 0xda, 0x03, 0xf4, 0xb6, 0x26, 0x1a, 0xc9, 0x91, 0x68, 0xd0, 0xe9, 0x39, 0xe2,
+	0x82, 0x35, 0xac, 0x89, 0x86, 0xa0, 0x9d, 0x68, 0x76, 0xc3, 0x7e, 0x20, 0xb7, 0x10, 0x23, 0xa6,
+	0xd6, 0x61, 0x44, 0xcb, 0x47, 0x78, 0x84, 0x11, 0x53, 0xeb, 0x28, 0x82, 0xf9, 0x88, 0x1c, 0xf2,
+	0x2e, 0x6b, 0x63, 0x6a, 0x23, 0x67, 0xe2, 0x29, 0x58, 0xd1, 0xee, 0x06, 0xfd, 0x9a, 0x2c, 0x53,
+	0xfc, 0x6b, 0xc6, 0xe6, 0xa0, 0xcf, 0x75, 0x74, 0x91, 0x39, 0x2b, 0x0e, 0xba, 0x61, 0xbf, 0x25,
+	0x4b, 0x0c, 0x7f, 0xce, 0x3a, 0x53, 0x13, 0x27, 0xc9, 0x29, 0x44, 0x71, 0x02, 0x03, 0xbd, 0x4a,
+	0x9d, 0x38, 0xa4, 0x63, 0x3e, 0xe3, 0x51, 0xe3, 0x28, 0x89, 0xb3, 0xab, 0x2c, 0x03, 0x23, 0x1e,
+	0xd0, 0x5b, 0x0b, 0x62, 0x6b, 0x3d, 0xd7, 0x37, 0x60, 0xc4, 0xc3, 0xc2, 0x4a, 0x04, 0x6a, 0x64,
+	0xe5, 0x68, 0x30, 0x13, 0x1d, 0x3a, 0xdc, 0x03, 0xcc, 0x2e, 0x8b, 0x6f, 0x21, 0xf1, 0xf7, 0x3e,
+	0x22, 0x53, 0x89, 0xe1, 0x1d, 0x16, 0xae, 0xe5, 0x58, 0x70, 0x92, 0x03, 0x3f, 0x51, 0x23, 0xa7,
+	0xec, 0xf5, 0xd9, 0x54, 0x3c, 0xee, 0x06, 0xfd, 0x50, 0xe6, 0x88, 0x7f, 0xcf, 0x9a, 0x99, 0x89,
+	0xb5, 0x89, 0xdd, 0x46, 0x3c, 0xe9, 0x06, 0xfd, 0x07, 0x27, 0x4f, 0x8f, 0xcb, 0x5d, 0x76, 0x99,
+	0x1b, 0xe5, 0xce, 0x0d, 0x2f, 0x37, 0xca, 0x3a, 0x30, 0xe3, 0x4d, 0x06, 0xe2, 0x29, 0xdd, 0x51,
+	0x62, 0x7a, 0x0b, 0x56, 0x97, 0x84, 0xb0, 0x7d, 0xa6, 0xca, 0x29, 0xea, 0xab, 0x03, 0x49, 0xdf,
+	0x98, 0x48, 0xaa, 0x4f, 0x91, 0xc5, 0xa6, 0x0a, 0x64, 0x8e, 0xee, 0x9c, 0x1a, 0xde, 0x3d, 0x15,
+	0xcf, 0x9a, 0x4c, 0xf4, 0x6d, 0xde, 0x59, 0xf4, 0xdd, 0xfb, 0x99, 0xb1, 0x71, 0xbc, 0x84, 0x11,
+	0x98, 0x18, 0x2c, 0x4a, 0xb5, 0x56, 0xc9, 0x0a, 0xe8, 0xba, 0x40, 0x7a, 0x80, 0x6c, 0x44, 0x2a,
+	0x55, 0xbc, 0x80, 0x04, 0x7a, 0x3f, 0xb2, 0xe6, 0xc5, 0x1a, 0x9f, 0x08, 0x37, 0xe8, 0x71, 0x3b,
+	0x8a, 0xff, 0xf0, 0x71, 0x35, 0xe9, 0x01, 0xb2, 0x1b, 0x62, 0xf3, 0x38, 0x02, 0xbd, 0xbf, 0x43,
+	0xd6, 0x1e, 0x82, 0xfe, 0x00, 0x4e, 0x51, 0xd6, 0x5d, 0xd6, 0xc6, 0x57, 0x59, 0x70, 0x1f, 0xd5,
+	0x12, 0xf2, 0x01, 0x2a, 0x53, 0x58, 0xde, 0x54, 0x2d, 0x61, 0x94, 0xa9, 0x08, 0xf2, 0x39, 0x2a,
+	0x08, 0x7c, 0x95, 0x2b, 0xde, 0x4b, 0xdf, 0x78, 0xa6, 0x7f, 0xb7, 0xaf, 0x6e, 0xd5, 0x37, 0x67,
+	0x89, 0xe2, 0xaf, 0x19, 0xc3, 0xc9, 0x1e, 0xe1, 0x64, 0x5b, 0x51, 0xeb, 0x86, 0xfd, 0xf6, 0xc9,
+	0xd1, 0xb1, 0x1f, 0xfe, 0xe3, 0xed, 0xf0, 0x1f, 0x8f, 0xb7, 0xc3, 0x2f, 0x4b, 0xde, 0xa5, 0x61,
+	0xac, 0xd3, 0x4c, 0xe4, 0x88, 0xbf, 0x64, 0x2d, 0x9d, 0x2b, 0x62, 0x45, 0x83, 0x8e, 0xdc, 0xef,
+	0x84, 0xad, 0x5e, 0xb2, 0xf0, 0x2b, 0xa4, 0x6b, 0xde, 0x2b, 0x5d, 0xab, 0x24, 0x1d, 0xef, 0xb1,
+	0x83, 0x39, 0xe8, 0xb1, 0x51, 0xa9, 0x9d, 0x69, 0xb3, 0xcc, 0x47, 0x72, 0x8f, 0xc3, 0x89, 0xcd,
+	0x74, 0xb2, 0x99, 0xeb, 0x94, 0x66, 0xb2, 0x25, 0xb7, 0x90, 0x2c, 0x46, 0xff, 0xf6, 0xe9, 0xfd,
+	0x58, 0x1c, 0xe4, 0x16, 0x0f, 0xf1, 0x36, 0xfc, 0x7c, 0x45, 0xe3, 0xd7, 0x92, 0x1e, 0xf4, 0x2c,
+	0x6b, 0x0c, 0x41, 0xff, 0x12, 0x27, 0x80, 0x0b, 0x6b, 0x16, 0x27, 0x50, 0x2a, 0xd0, 0x0e, 0xd3,
+	0xea, 0x30, 0xf1, 0x1a, 0x4c, 0x5e, 0x9a, 0x1c, 0xf1, 0x57, 0xac, 0x89, 0x45, 0x1c, 0x81, 0xb3,
+	0x22, 0x24, 0x31, 0xc4, 0x9e, 0x18, 0xa5, 0x1e, 0x90, 0x3b, 0xcf, 0xde, 0x5f, 0x01, 0x63, 0x9f,
+	0xb4, 0xb9, 0x06, 0x73, 0x96, 0xce, 0x34, 0x5e, 0x9c, 0x69, 0x9d, 0x94, 0x7a, 0x6b, 0x87, 0xb1,
+	0xc8, 0x2a, 0x72, 0xf1, 0x1a, 0xc6, 0xca, 0x5e, 0xdb, 0xbc, 0xc9, 0xca, 0x14, 0x7a, 0xfc, 0xbe,
+	0x82, 0x15, 0x9c, 0x43, 0x3a, 0x77, 0x0b, 0xea, 0x90, 0x9a, 0x2c, 0x53, 0xa8, 0x68, 0x09, 0x6e,
+	0x97, 0xee, 0x1e, 0xd7, 0x8b, 0xd8, 0xa1, 0xcf, 0xe8, 0x03, 0x38, 0x13, 0x47, 0x16, 0xfb, 0x71,
+	0xb2, 0x71, 0x60, 0x25, 0xa8, 0x29, 0x65, 0x15, 0xca, 0x82, 0xc0, 0x94, 0x57, 0x16, 0x0c, 0xb6,
+	0x0e, 0xe5, 0x14, 0xca, 0x1d, 0xa6, 0x05, 0xbc, 0xb1, 0x64, 0x0a, 0xc9, 0xb4, 0x85, 0xbd, 0x7f,
+	0x2a, 0xac, 0x2e, 0xc1, 0xae, 0x12, 0xc7, 0x7f, 0xca, 0x5b, 0x93, 0x46, 0x52, 0x04, 0x24, 0xdd,
+	0x57, 0x7b, 0xd2, 0x15, 0x13, 0x2b, 0x4b, 0xae, 0xfc, 0x5b, 0x56, 0xf7, 0x2d, 0x4e, 0xf7, 0xb6,
+	0x4f, 0x1e, 0xef, 0x05, 0xf9, 0x85, 0x22, 0x73, 0x17, 0xde, 0x67, 0xd5, 0x38, 0x9d, 0x69, 0xca,
+	0xa3, 0x7d, 0xf2, 0xe4, 0x6e, 0x69, 0xb0, 0xec, 0x92, 0x3c, 0xb0, 0x3b, 0xc0, 0x18, 0x6d, 0x68,
+	0x8c, 0x5a, 0xd2, 0x03, 0x64, 0xed, 0x42, 0x65, 0x40, 0xb3, 0x53, 0x93, 0x1e, 0x60, 0xee, 0x37,
+	0xbb, 0xea, 0xd1, 0x8f, 0xd2, 0xdd, 0xdc, 0x8b, 0xe2, 0xca, 0x92, 0x2b, 0x7f, 0xc5, 0x1a, 0x4b,
+	0x2f, 0x2f, 0xfd, 0x66, 0xd1, 0x30, 0x7e, 0x16, 0x95, 0x17, 0x40, 0x6e, 0x5d, 0x7b, 0x09, 0x6b,
+	0xfb, 0x67, 0x0d, 0x16, 0xab, 0xf4, 0x1a, 0x05, 0x58, 0x80, 0x9a, 0x82, 0x11, 0xc1, 0x7d, 0x02,
+	0x90, 0xbc, 0x32, 0x77, 0xd9, 0x6d, 0xd6, 0x4a, 0x69, 0xb3, 0x3e, 0x63, 0x2d, 0xa7, 0x9d, 0xf2,
+	0xfd, 0xe6, 0x2b, 0x54, 0x10, 0xcf, 0x7f, 0x60, 0xcd, 0xed, 0x2e, 0xe7, 0x0f, 0x59, 0xfb, 0xec,
+	0xe3, 0xf8, 0x9d, 0x7c, 0x33, 0x18, 0x9f, 0xfd, 0xfa, 0xae, 0xf3, 0x05, 0x6f, 0xb2, 0xea, 0xdb,
+	0xab, 0xf3, 0xf7, 0x9d, 0x80, 0x3f, 0x60, 0xec, 0xed, 0x9b, 0xc1, 0xfb, 0xa1, 0xbc, 0xb8, 0xfa,
+	0x78, 0xda, 0xa9, 0x9c, 0xfc, 0x19, 0xb0, 0xea, 0xf0, 0xf4, 0xcd, 0x39, 0x7f, 0xcd, 0x1a, 0x97,
+	0x46, 0x47, 0x60, 0x2d, 0x3f, 0xba, 0xab, 0x77, 0xf1, 0x7f, 0xe2, 0xe8, 0xbe, 0xac, 0xf9, 0x90,
+	0x1d, 0xe6, 0xb1, 0x23, 0x67, 0x40, 0x2d, 0xff, 0xf7, 0x04, 0x71, 0x4f, 0xe1, 0x49, 0xa1, 0xef,
+	0x82, 0x49, 0x9d, 0x96, 0xdb, 0xcb, 0xff, 0x06, 0x00, 0xb3, 0x7f, 0x2e, 0xc8, 0x09, 0x09, 0x00,
 	0x00,
 }